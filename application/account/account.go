@@ -0,0 +1,483 @@
+// Package account contains handlers that let a buyer or seller change their address, download the data stored
+// about them, and delete their account. Deleted accounts stay recoverable for a grace period before they are purged.
+package account
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"projectGoLive/application/apiclient"
+	"projectGoLive/application/buyer"
+	"projectGoLive/application/config"
+	"projectGoLive/application/coupon"
+	"projectGoLive/application/email"
+	"projectGoLive/application/location"
+	"projectGoLive/application/notify"
+	"projectGoLive/application/orders"
+	"projectGoLive/application/photo"
+	"projectGoLive/application/savedsearch"
+	"projectGoLive/application/server"
+	"projectGoLive/application/user_db"
+	"projectGoLive/application/webhook"
+
+	bcrypt "golang.org/x/crypto/bcrypt"
+)
+
+type accountStruct struct {
+	Username    string
+	IsBuyer     bool
+	IsSeller    bool
+	Operation   string
+	Mainmessage []string
+	GraceDays   int
+	Address     string
+}
+
+// Longest address accepted when a user changes their address
+const maxAddressLength = 256
+
+// This struct stores the profile details included in the data export, password is never exported
+type profileExport struct {
+	Username      string
+	Fullname      string
+	Roles         []string
+	Phone         string
+	Address       string
+	Email         string
+	GSTRegistered bool
+	GSTNumber     string
+	// Location worked out from the postal code of the address, when it is known
+	Location *location.Point
+}
+
+//---------------------------------------------------------------------------
+// Functions to view account options
+//---------------------------------------------------------------------------
+// This method is used to display the account page with the download and delete options
+func AccountHandler(w http.ResponseWriter, req *http.Request) {
+	user, ok := accountUser(w, req)
+	if !ok {
+		return
+	}
+
+	accountToTemplate := accountStruct{
+		Username:  user.Username,
+		IsBuyer:   user.IsBuyer(),
+		IsSeller:  user.IsSeller(),
+		Operation: "view",
+		GraceDays: config.AccountDeletionGraceDays,
+	}
+	if details, ok := user_db.GetARecord(config.DB, user.Username); ok {
+		accountToTemplate.Address = details.Address
+	}
+	accountToTemplate.Mainmessage = append(accountToTemplate.Mainmessage, "Manage your account")
+	config.TPL.ExecuteTemplate(w, "account.gohtml", accountToTemplate)
+}
+
+//---------------------------------------------------------------------------
+// Functions to change the address of an account
+//---------------------------------------------------------------------------
+// This method is used to change the address of a user
+// The location of the user is worked out again from the new address and stored, and for sellers
+// the profile used by search is saved again, so that distances and pickup areas follow the new address.
+func AddressHandler(w http.ResponseWriter, req *http.Request) {
+	user, ok := accountUser(w, req)
+	if !ok {
+		return
+	}
+
+	address := strings.TrimSpace(req.FormValue("address"))
+	if address == "" || len(address) > maxAddressLength {
+		accountToTemplate := accountStruct{
+			Username:  user.Username,
+			IsBuyer:   user.IsBuyer(),
+			IsSeller:  user.IsSeller(),
+			Operation: "view",
+			GraceDays: config.AccountDeletionGraceDays,
+			Address:   address,
+		}
+		accountToTemplate.Mainmessage = append(accountToTemplate.Mainmessage, "Manage your account",
+			"Please enter an address of up to "+strconv.Itoa(maxAddressLength)+" characters")
+		config.TPL.ExecuteTemplate(w, "account.gohtml", accountToTemplate)
+		return
+	}
+
+	if !user_db.UpdateAddress(config.DB, user.Username, address) {
+		config.Error.Printf("Unable to change the address of user %s\n", user.Username)
+		http.Error(w, "Unable to reach database, try again!", http.StatusInternalServerError)
+		return
+	}
+	config.Info.Printf("Address changed for user %s\n", user.Username)
+
+	if _, ok := location.SaveUser(config.DB, user.Username, address); !ok {
+		config.Warning.Printf("Unable to save the location of user %s\n", user.Username)
+	}
+	if user.IsSeller() {
+		details, ok := user_db.GetARecord(config.DB, user.Username)
+		if !ok || !apiclient.SaveSellerProfile(details.Username, details.Fullname, address) {
+			config.Warning.Printf("Unable to save the profile of seller %s\n", user.Username)
+		}
+	}
+	http.Redirect(w, req, "/account", http.StatusSeeOther)
+}
+
+//---------------------------------------------------------------------------
+// Functions to add a role to an account
+//---------------------------------------------------------------------------
+// This method is used to let a buyer start selling, or a seller start buying, with the same login
+func AddRoleHandler(w http.ResponseWriter, req *http.Request) {
+	user, ok := accountUser(w, req)
+	if !ok {
+		return
+	}
+
+	role := req.FormValue("role")
+	if role != user_db.RoleBuyer && role != user_db.RoleSeller {
+		http.Redirect(w, req, "/account", http.StatusSeeOther)
+		return
+	}
+
+	if !user.HasRole(role) {
+		roles := append(append([]string{}, user.Roles...), role)
+		if !user_db.UpdateRoles(config.DB, user.Username, roles) {
+			config.Error.Printf("Unable to add role %s for user %s\n", role, user.Username)
+			http.Redirect(w, req, "/account", http.StatusSeeOther)
+			return
+		}
+		server.SetUserRoles(user.Username, roles)
+		config.Info.Printf("Role %s added for user %s\n", role, user.Username)
+
+		// New sellers can be found by search from their name and area
+		if role == user_db.RoleSeller {
+			details, ok := user_db.GetARecord(config.DB, user.Username)
+			if !ok || !apiclient.SaveSellerProfile(details.Username, details.Fullname, details.Address) {
+				config.Warning.Printf("Unable to save the profile of seller %s\n", user.Username)
+			}
+		}
+	}
+	http.Redirect(w, req, "/"+role, http.StatusSeeOther)
+}
+
+//---------------------------------------------------------------------------
+// Functions to download personal data
+//---------------------------------------------------------------------------
+// This method is used to send a ZIP archive containing all the data stored about the user
+// The archive contains the profile, the listings of a seller and the shopping cart of a buyer, each as a JSON file,
+// along with the photos uploaded for the listings
+func ExportHandler(w http.ResponseWriter, req *http.Request) {
+	user, ok := accountUser(w, req)
+	if !ok {
+		return
+	}
+
+	details, ok := user_db.GetARecord(config.DB, user.Username)
+	if !ok {
+		config.Error.Printf("Unable to read user details for export of user %s\n", user.Username)
+		http.Error(w, "Unable to reach database, try again!", http.StatusInternalServerError)
+		return
+	}
+
+	profile := profileExport{
+		Username:      details.Username,
+		Fullname:      details.Fullname,
+		Roles:         details.Roles,
+		Phone:         details.Phone,
+		Address:       details.Address,
+		Email:         details.Email,
+		GSTRegistered: details.GSTRegistered,
+		GSTNumber:     details.GSTNumber,
+	}
+	if p, ok := location.OfUser(user.Username); ok {
+		profile.Location = &p
+	}
+
+	notifications, ok := notify.GetNotifications(config.DB, user.Username, 0)
+	if !ok {
+		config.Error.Printf("Unable to get notifications for export of user %s\n", user.Username)
+		http.Error(w, "Unable to reach database, try again!", http.StatusInternalServerError)
+		return
+	}
+
+	var photos []string
+	files := map[string]interface{}{
+		"profile.json":       profile,
+		"notifications.json": notifications,
+	}
+	if user.IsBuyer() {
+		files["cart.json"] = buyer.CartItems(user.Username)
+		purchases, ok := orders.GetOrders(config.DB, user.Username, false)
+		if !ok {
+			config.Error.Printf("Unable to get orders for export of user %s\n", user.Username)
+			http.Error(w, "Unable to reach database, try again!", http.StatusInternalServerError)
+			return
+		}
+		files["orders.json"] = purchases
+		disputes, ok := orders.GetDisputes(config.DB, user.Username, false)
+		if !ok {
+			config.Error.Printf("Unable to get disputes for export of user %s\n", user.Username)
+			http.Error(w, "Unable to reach database, try again!", http.StatusInternalServerError)
+			return
+		}
+		files["disputes.json"] = disputes
+		searches, ok := savedsearch.GetSearches(config.DB, user.Username)
+		if !ok {
+			config.Error.Printf("Unable to get saved searches for export of user %s\n", user.Username)
+			http.Error(w, "Unable to reach database, try again!", http.StatusInternalServerError)
+			return
+		}
+		files["searches.json"] = searches
+	}
+	if user.IsSeller() {
+		listings, ok := apiclient.GetItem("", user.Username, false)
+		if !ok {
+			config.Error.Printf("Unable to get listings for export of user %s\n", user.Username)
+			http.Error(w, "Unable to reach seller API, try again!", http.StatusInternalServerError)
+			return
+		}
+		files["listings.json"] = listings
+		sales, ok := orders.GetOrders(config.DB, user.Username, true)
+		if !ok {
+			config.Error.Printf("Unable to get sales for export of user %s\n", user.Username)
+			http.Error(w, "Unable to reach database, try again!", http.StatusInternalServerError)
+			return
+		}
+		files["sales.json"] = sales
+		webhooks, ok := webhook.GetEndpoints(config.DB, user.Username)
+		if !ok {
+			config.Error.Printf("Unable to get webhooks for export of user %s\n", user.Username)
+			http.Error(w, "Unable to reach database, try again!", http.StatusInternalServerError)
+			return
+		}
+		files["webhooks.json"] = webhooks
+		for _, item := range listings {
+			if item.Photo != "" {
+				photos = append(photos, item.Photo)
+			}
+		}
+	}
+
+	// The archive is built before anything is sent, so a failure gives an error instead of a broken download
+	archive, err := buildArchive(files, photos)
+	if err != nil {
+		config.Error.Printf("Unable to build data export of user %s: %v\n", user.Username, err)
+		http.Error(w, "Unable to export your data, try again!", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", "attachment; filename=\"peelrescue-"+user.Username+".zip\"")
+	w.Header().Set("Content-Length", strconv.Itoa(archive.Len()))
+	if _, err := archive.WriteTo(w); err != nil {
+		config.Error.Println(err)
+		return
+	}
+	config.Info.Printf("Data export downloaded by user %s\n", user.Username)
+}
+
+//---------------------------------------------------------------------------
+// Functions to delete an account
+//---------------------------------------------------------------------------
+// This method is used to delete the account of the user
+// The user has to confirm with their password. The seller's listings are hidden from buyers,
+// the cart is emptied and all sessions are ended straight away.
+// The user record and listings are only purged after the grace period, logging in before that cancels the deletion
+// and shows the listings again.
+func DeleteAccountHandler(w http.ResponseWriter, req *http.Request) {
+	user, ok := accountUser(w, req)
+	if !ok {
+		return
+	}
+
+	accountToTemplate := accountStruct{
+		Username:  user.Username,
+		IsBuyer:   user.IsBuyer(),
+		IsSeller:  user.IsSeller(),
+		Operation: "delete",
+		GraceDays: config.AccountDeletionGraceDays,
+	}
+
+	if req.Method == http.MethodPost {
+		password := req.FormValue("password")
+
+		details, ok := user_db.GetARecord(config.DB, user.Username)
+		if !ok {
+			accountToTemplate.Mainmessage = append(accountToTemplate.Mainmessage, "Unable to reach database, try again!")
+		} else if err := bcrypt.CompareHashAndPassword([]byte(details.Password), []byte(password)); err != nil {
+			config.Warning.Printf("Password does not match when deleting account of user %s\n", user.Username)
+			accountToTemplate.Mainmessage = append(accountToTemplate.Mainmessage, "Password does not match")
+		} else if !user_db.InsertDeletionRequest(config.DB, user.Username, time.Now()) {
+			accountToTemplate.Mainmessage = append(accountToTemplate.Mainmessage, "Unable to reach database, try again!")
+		} else {
+			removeUserData(user)
+			server.EndUserSessions(w, user.Username)
+			config.Info.Printf("Account deletion requested by user %s\n", user.Username)
+
+			logoutmessage := "Your account has been scheduled for deletion.\nLog in again within " +
+				strconv.Itoa(config.AccountDeletionGraceDays) + " days if you change your mind."
+			config.TPL.ExecuteTemplate(w, "logout.gohtml", logoutmessage)
+			return
+		}
+	}
+	config.TPL.ExecuteTemplate(w, "account.gohtml", accountToTemplate)
+}
+
+// This method purges the accounts whose grace period is over
+// It runs forever, checking for accounts to purge every PurgeAccountsTime seconds
+func PurgeDeletedAccounts() {
+	for {
+		purgeAccounts(time.Now())
+		time.Sleep(time.Second * time.Duration(config.PurgeAccountsTime))
+	}
+}
+
+//---------------------------------------------------------------------------
+// Helper functions
+//---------------------------------------------------------------------------
+// This method checks that the session is active and belongs to a buyer or seller
+// Admin has no account data, and is redirected to the admin page
+func accountUser(w http.ResponseWriter, req *http.Request) (server.UserInfo, bool) {
+	if !server.ActiveSession(w, req) {
+		http.Redirect(w, req, "/login", http.StatusSeeOther)
+		return server.UserInfo{}, false
+	}
+	user := server.GetUser(w, req)
+	if user.Username == "" {
+		http.Redirect(w, req, "/admin", http.StatusSeeOther)
+		return user, false
+	}
+	return user, true
+}
+
+// This method builds the ZIP archive of a data export in memory
+// Each file is written as indented JSON, followed by the listing photos.
+func buildArchive(files map[string]interface{}, photos []string) (*bytes.Buffer, error) {
+	archive := new(bytes.Buffer)
+	zw := zip.NewWriter(archive)
+	for name, content := range files {
+		f, err := zw.Create(name)
+		if err != nil {
+			return nil, err
+		}
+		enc := json.NewEncoder(f)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(content); err != nil {
+			return nil, err
+		}
+	}
+	for _, name := range photos {
+		if err := addPhoto(zw, name); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return archive, nil
+}
+
+// This method copies a listing photo into the data export archive
+func addPhoto(zw *zip.Writer, name string) error {
+	f, err := photo.Storage.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	zf, err := zw.Create("photos/" + name)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(zf, f)
+	return err
+}
+
+// This method removes the data that must not stay visible once a user asks for deletion
+// Listings are only hidden, so they can be shown again if the user logs in before the account is purged.
+func removeUserData(user server.UserInfo) {
+	if user.IsBuyer() {
+		buyer.DeleteCart(user.Username)
+	}
+	if !user.IsSeller() {
+		return
+	}
+
+	if !apiclient.DeleteSellerProfile(user.Username) {
+		config.Error.Printf("Unable to delete the profile of user %s\n", user.Username)
+	}
+	if !apiclient.HideLots(user.Username, true) {
+		config.Error.Printf("Unable to hide the listings of user %s\n", user.Username)
+	}
+}
+
+// This method deletes the listings and promo codes of a seller whose account is purged
+// It returns false if any listing could not be deleted, so the purge is tried again later.
+func deleteSellerData(username string) bool {
+	listings, ok := apiclient.GetItem("", username, false)
+	if !ok {
+		config.Error.Printf("Unable to get listings of user %s for deletion\n", username)
+		return false
+	}
+	for _, item := range listings {
+		if !apiclient.DeleteLot(item.ID, item.Username, false) {
+			config.Error.Printf("Unable to delete listing %s of user %s\n", item.Item, username)
+			return false
+		}
+		photo.Delete(item.Photo)
+	}
+	coupons, _ := coupon.GetCoupons(config.DB, username, false)
+	for _, c := range coupons {
+		coupon.DeleteCoupon(config.DB, c.Code, username, false)
+	}
+	if !apiclient.HideLots(username, false) {
+		config.Error.Printf("Unable to clear the hidden listings of user %s\n", username)
+	}
+	return true
+}
+
+// This method deletes the user record of every account whose grace period ended before now
+// The username is kept so it cannot be registered again by someone else.
+func purgeAccounts(now time.Time) {
+	requests, ok := user_db.GetDeletionRequests(config.DB)
+	if !ok {
+		config.Error.Println("Unable to read account deletion requests")
+		return
+	}
+
+	gracePeriod := time.Hour * 24 * time.Duration(config.AccountDeletionGraceDays)
+	for _, request := range requests {
+		if now.Sub(request.RequestedAt) < gracePeriod {
+			continue
+		}
+		if !deleteSellerData(request.Username) {
+			continue
+		}
+		// The address is read before the account is deleted, to delete the emails to it
+		details, _ := user_db.GetARecord(config.DB, request.Username)
+		if !user_db.PurgeRecord(config.DB, request.Username, now) {
+			config.Error.Printf("Unable to purge account of user %s\n", request.Username)
+			continue
+		}
+		if !notify.DeleteNotifications(config.DB, request.Username) {
+			config.Error.Printf("Unable to delete notifications of user %s\n", request.Username)
+		}
+		if !webhook.DeleteEndpoints(config.DB, request.Username) {
+			config.Error.Printf("Unable to delete webhooks of user %s\n", request.Username)
+		}
+		if !savedsearch.DeleteSearches(config.DB, request.Username) {
+			config.Error.Printf("Unable to delete saved searches of user %s\n", request.Username)
+		}
+		if !location.DeleteUser(config.DB, request.Username) {
+			config.Error.Printf("Unable to delete the location of user %s\n", request.Username)
+		}
+		if details.Email != "" && !email.DeleteOutbox(config.DB, details.Email) {
+			config.Error.Printf("Unable to delete the emails to user %s\n", request.Username)
+		}
+		server.RemoveUser(request.Username)
+		config.Info.Printf("Account of user %s has been purged\n", request.Username)
+	}
+}
@@ -1,21 +1,329 @@
 //Admin functions
+// The handlers are only reached by the admin, their routes are wrapped with server.RequireAdmin.
 package admin
 
 import (
 	"net/http"
+	"projectGoLive/application/apiclient"
 	"projectGoLive/application/config"
-	"projectGoLive/application/server"
+	"projectGoLive/application/coupon"
+	"projectGoLive/application/email"
+	"projectGoLive/application/ledger"
+	"projectGoLive/application/money"
+	"projectGoLive/application/notify"
+	"projectGoLive/application/orders"
+	"projectGoLive/application/payment"
+	"strconv"
+	"strings"
+	"time"
 )
 
+// Layout of the dates sent by the date inputs of the payout report
+const dateFormat = "2006-01-02"
+
+type adminStruct struct {
+	Operation   string
+	Mainmessage []string
+	Catalogue   []apiclient.PeelType
+	Coupons     []coupon.Coupon
+	Payouts     []ledger.Payout
+	From        string
+	To          string
+	// Disputes raised by buyers and their orders by order ID
+	Disputes []orders.Dispute
+	Orders   map[int64]orders.Order
+	// Emails of the outbox with Status, and the email opened by the admin
+	Outbox []email.OutboxMessage
+	Status string
+	Email  email.OutboxMessage
+	// Names of the email templates, and the one previewed
+	Templates []string
+	Template  string
+}
+
+// Number of emails listed on the outbox page
+const outboxPageSize = 50
+
 //---------------------------------------------------------------------------
 // Functions for admin
 //---------------------------------------------------------------------------
 // This method is used to perform all admin functions
 func AdminHandler(w http.ResponseWriter, req *http.Request) {
-	if !server.ActiveSession(w, req) {
-		http.Redirect(w, req, "/login", http.StatusSeeOther)
+	config.TPL.ExecuteTemplate(w, "admin.gohtml", adminStruct{Operation: "view"})
+}
+
+//---------------------------------------------------------------------------
+// Functions to manage the catalogue of peel types
+//---------------------------------------------------------------------------
+// This method is used to list, add, update and delete peel types in the catalogue
+// The peel types are stored by the seller API, and used for validating listings and rendering selects and images
+func CatalogueHandler(w http.ResponseWriter, req *http.Request) {
+	adminToTemplate := adminStruct{Operation: "catalogue"}
+
+	if req.Method == http.MethodPost {
+		action := req.FormValue("action")
+		p := apiclient.PeelType{
+			Name:        strings.ToLower(strings.TrimSpace(req.FormValue("name"))),
+			DisplayName: strings.TrimSpace(req.FormValue("displayname")),
+			Image:       strings.TrimSpace(req.FormValue("image")),
+			Unit:        strings.TrimSpace(req.FormValue("unit")),
+			Description: strings.TrimSpace(req.FormValue("description")),
+			Nutrients:   strings.TrimSpace(req.FormValue("nutrients")),
+		}
+
+		if p.Name == "" {
+			adminToTemplate.Mainmessage = append(adminToTemplate.Mainmessage, "Please enter the name of the peel type")
+		} else if action == "add" {
+			if !apiclient.AddPeelType(p) {
+				adminToTemplate.Mainmessage = append(adminToTemplate.Mainmessage, "Unable to add peel type, it may already exist!")
+			} else {
+				http.Redirect(w, req, "/admin/catalogue", http.StatusSeeOther)
+				return
+			}
+		} else if action == "update" {
+			if !apiclient.UpdatePeelType(p) {
+				adminToTemplate.Mainmessage = append(adminToTemplate.Mainmessage, "Unable to update peel type, it does not exist!")
+			} else {
+				http.Redirect(w, req, "/admin/catalogue", http.StatusSeeOther)
+				return
+			}
+		} else if action == "delete" {
+			if !apiclient.DeletePeelType(p.Name) {
+				adminToTemplate.Mainmessage = append(adminToTemplate.Mainmessage, "Unable to delete peel type, it may still have listings!")
+			} else {
+				http.Redirect(w, req, "/admin/catalogue", http.StatusSeeOther)
+				return
+			}
+		}
+	}
+
+	catalogue, ok := apiclient.GetCatalogue()
+	if !ok {
+		adminToTemplate.Mainmessage = append(adminToTemplate.Mainmessage, "Unable to get catalogue from seller API")
+	}
+	adminToTemplate.Catalogue = catalogue
+	config.TPL.ExecuteTemplate(w, "admin.gohtml", adminToTemplate)
+}
+
+//---------------------------------------------------------------------------
+// Functions to manage platform-wide promo codes
+//---------------------------------------------------------------------------
+// This method is used to list, add and delete promo codes
+// Codes added by an admin apply to all listings, the codes made by sellers are listed too and can be deleted
+func CouponsHandler(w http.ResponseWriter, req *http.Request) {
+	adminToTemplate := adminStruct{Operation: "coupons"}
+
+	if req.Method == http.MethodPost {
+		action := req.FormValue("action")
+		if action == "add" {
+			c, msg := coupon.ReadForm(req, "")
+			if msg != "" {
+				adminToTemplate.Mainmessage = append(adminToTemplate.Mainmessage, msg)
+			} else if !coupon.InsertCoupon(config.DB, c) {
+				adminToTemplate.Mainmessage = append(adminToTemplate.Mainmessage, "Unable to add promo code, it may already exist!")
+			} else {
+				http.Redirect(w, req, "/admin/coupons", http.StatusSeeOther)
+				return
+			}
+		} else if action == "delete" {
+			if !coupon.DeleteCoupon(config.DB, coupon.Normalize(req.FormValue("code")), "", true) {
+				adminToTemplate.Mainmessage = append(adminToTemplate.Mainmessage, "Unable to delete promo code, it does not exist!")
+			} else {
+				http.Redirect(w, req, "/admin/coupons", http.StatusSeeOther)
+				return
+			}
+		}
+	}
+
+	coupons, ok := coupon.GetCoupons(config.DB, "", true)
+	if !ok {
+		adminToTemplate.Mainmessage = append(adminToTemplate.Mainmessage, "Unable to get promo codes")
+	}
+	adminToTemplate.Coupons = coupons
+	config.TPL.ExecuteTemplate(w, "admin.gohtml", adminToTemplate)
+}
+
+//---------------------------------------------------------------------------
+// Functions to report payouts to sellers
+//---------------------------------------------------------------------------
+// This method is used to show what the platform owes each seller, or each seller owes the platform, for a period
+// The period runs from the start of the from date to the end of the to date, the current month by default
+// With format=csv the report is downloaded as a CSV file
+func PayoutsHandler(w http.ResponseWriter, req *http.Request) {
+	now := time.Now()
+	adminToTemplate := adminStruct{
+		Operation: "payouts",
+		From:      req.FormValue("from"),
+		To:        req.FormValue("to"),
+	}
+	if adminToTemplate.From == "" {
+		adminToTemplate.From = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.Local).Format(dateFormat)
+	}
+	if adminToTemplate.To == "" {
+		adminToTemplate.To = now.Format(dateFormat)
+	}
+
+	from, err := time.ParseInLocation(dateFormat, adminToTemplate.From, time.Local)
+	to, err2 := time.ParseInLocation(dateFormat, adminToTemplate.To, time.Local)
+	if err != nil || err2 != nil || to.Before(from) {
+		adminToTemplate.Mainmessage = append(adminToTemplate.Mainmessage, "Please enter a period with the end date after the start date")
+		config.TPL.ExecuteTemplate(w, "admin.gohtml", adminToTemplate)
 		return
 	}
 
-	config.TPL.ExecuteTemplate(w, "admin.gohtml", nil)
+	payouts, ok := ledger.Payouts(config.DB, from, to.AddDate(0, 0, 1))
+	if !ok {
+		adminToTemplate.Mainmessage = append(adminToTemplate.Mainmessage, "Unable to get payouts")
+	}
+
+	if req.FormValue("format") == "csv" && ok {
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", "attachment; filename=\"payouts-"+adminToTemplate.From+"-"+adminToTemplate.To+".csv\"")
+		if err := ledger.WritePayoutsCSV(w, payouts); err != nil {
+			config.Error.Printf("Unable to write payout report : %v\n", err)
+		}
+		return
+	}
+
+	adminToTemplate.Payouts = payouts
+	config.TPL.ExecuteTemplate(w, "admin.gohtml", adminToTemplate)
+}
+
+//---------------------------------------------------------------------------
+// Functions to resolve disputes
+//---------------------------------------------------------------------------
+// This method is used to review the disputes raised by buyers and resolve them with a full, partial or no refund
+// The refund is taken from the seller's balance, and given back through the payment provider when the order was paid online.
+// A refund the payment provider could not make stays pending, and can be tried again.
+func DisputesHandler(w http.ResponseWriter, req *http.Request) {
+	adminToTemplate := adminStruct{Operation: "disputes"}
+
+	if req.Method == http.MethodPost {
+		// The order is always the one of the dispute, so a refund is only ever paid for it
+		id := convertToID(req.FormValue("dispute"))
+		d, ok := orders.GetDispute(config.DB, id)
+		var o orders.Order
+		if ok {
+			o, ok = orders.GetOrder(config.DB, d.OrderID)
+		}
+		action := req.FormValue("action")
+		refund := money.Zero(o.Gross.Currency)
+		var err error
+		switch action {
+		case "full":
+			refund = o.Due()
+		case "partial":
+			refund, err = money.Parse(req.FormValue("refund"), o.Gross.Currency)
+		}
+		if !ok {
+			adminToTemplate.Mainmessage = append(adminToTemplate.Mainmessage, "Unable to find the order of the dispute")
+		} else if action == "retry" {
+			if !d.RefundPending() {
+				adminToTemplate.Mainmessage = append(adminToTemplate.Mainmessage, "This dispute has no refund waiting to be made")
+			} else if !refundDispute(d, o) {
+				adminToTemplate.Mainmessage = append(adminToTemplate.Mainmessage, "Unable to make the refund, please try again later")
+			} else {
+				http.Redirect(w, req, "/admin/disputes", http.StatusSeeOther)
+				return
+			}
+		} else if action != "full" && action != "partial" && action != "reject" {
+			adminToTemplate.Mainmessage = append(adminToTemplate.Mainmessage, "Please choose a full, partial or no refund")
+		} else if err != nil || (action == "partial" && refund.Amount <= 0) {
+			adminToTemplate.Mainmessage = append(adminToTemplate.Mainmessage, "Please enter the amount to refund")
+		} else if d, err := orders.ResolveDispute(config.DB, d.ID, refund, strings.TrimSpace(req.FormValue("note")), time.Now()); err != nil {
+			adminToTemplate.Mainmessage = append(adminToTemplate.Mainmessage, "Unable to resolve dispute, "+err.Error())
+		} else {
+			// The refund stays pending if it cannot be made now, the admin can try it again from the disputes page
+			if d.RefundPending() && refundDispute(d, o) {
+				d.Status = orders.DisputeRefunded
+			}
+			email.SendDisputeNotice(d)
+			notify.DisputeChanged(d)
+			http.Redirect(w, req, "/admin/disputes", http.StatusSeeOther)
+			return
+		}
+	}
+
+	disputes, ok := orders.GetDisputes(config.DB, "", true)
+	if !ok {
+		adminToTemplate.Mainmessage = append(adminToTemplate.Mainmessage, "Unable to get disputes")
+	}
+	adminToTemplate.Disputes = disputes
+	adminToTemplate.Orders = make(map[int64]orders.Order)
+	for _, d := range disputes {
+		if o, ok := orders.GetOrder(config.DB, d.OrderID); ok {
+			adminToTemplate.Orders[d.OrderID] = o
+		}
+	}
+	config.TPL.ExecuteTemplate(w, "admin.gohtml", adminToTemplate)
+}
+
+// This function gives back the pending refund of a dispute through the payment provider the order was paid with
+// It returns false if the refund is not made, the dispute then stays pending
+func refundDispute(d orders.Dispute, o orders.Order) bool {
+	if err := payment.RefundPayment(o.PaymentID, d.Refund); err != nil {
+		config.Error.Printf("Unable to refund dispute %d, the buyer is owed %s : %v\n", d.ID, d.Refund, err)
+		return false
+	}
+	if !orders.MarkRefunded(config.DB, d.ID) {
+		config.Error.Printf("Refund of dispute %d has been made but the dispute is still marked pending\n", d.ID)
+	}
+	return true
+}
+
+//---------------------------------------------------------------------------
+// Functions for the email outbox
+//---------------------------------------------------------------------------
+// This method is used to inspect the emails of the outbox and send again the ones given up
+// The emails given up are listed by default, with status=pending or status=sent listing the others.
+func OutboxHandler(w http.ResponseWriter, req *http.Request) {
+	adminToTemplate := adminStruct{Operation: "outbox", Status: email.OutboxDead}
+	switch req.FormValue("status") {
+	case email.OutboxPending, email.OutboxSent:
+		adminToTemplate.Status = req.FormValue("status")
+	}
+
+	if req.Method == http.MethodPost && req.FormValue("action") == "resend" {
+		if email.Resend(config.DB, convertToID(req.FormValue("id")), time.Now()) {
+			http.Redirect(w, req, "/admin/outbox?status="+adminToTemplate.Status, http.StatusSeeOther)
+			return
+		}
+		adminToTemplate.Mainmessage = append(adminToTemplate.Mainmessage, "Unable to send the email again")
+	}
+
+	if req.FormValue("action") == "view" {
+		if o, ok := email.GetOutboxMessage(config.DB, convertToID(req.FormValue("id"))); ok {
+			adminToTemplate.Email = o
+		} else {
+			adminToTemplate.Mainmessage = append(adminToTemplate.Mainmessage, "Unable to find the email")
+		}
+	}
+
+	outbox, ok := email.GetOutbox(config.DB, adminToTemplate.Status, outboxPageSize)
+	if !ok {
+		adminToTemplate.Mainmessage = append(adminToTemplate.Mainmessage, "Unable to get emails")
+	}
+	adminToTemplate.Outbox = outbox
+	config.TPL.ExecuteTemplate(w, "admin.gohtml", adminToTemplate)
+}
+
+// This method is used to preview the email templates with made up data, as html and as plain text
+func EmailTemplatesHandler(w http.ResponseWriter, req *http.Request) {
+	adminToTemplate := adminStruct{Operation: "emailtemplates", Templates: email.TemplateNames}
+	if name := req.FormValue("template"); name != "" {
+		m, err := email.Preview(name)
+		if err != nil {
+			adminToTemplate.Mainmessage = append(adminToTemplate.Mainmessage, "Unable to preview the email, "+err.Error())
+		} else {
+			adminToTemplate.Template = name
+			adminToTemplate.Email.Message = m
+		}
+	}
+	config.TPL.ExecuteTemplate(w, "admin.gohtml", adminToTemplate)
+}
+
+// This function converts the ID sent by a form to a number, 0 if it is not a number
+func convertToID(id string) int64 {
+	n, _ := strconv.ParseInt(id, 10, 64)
+	return n
 }
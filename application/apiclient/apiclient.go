@@ -18,23 +18,93 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"time"
 
 	"github.com/joho/godotenv"
 
 	config "projectGoLive/application/config"
+	"projectGoLive/application/location"
+	"projectGoLive/application/money"
 )
 
 //Data structure for each item
 type ItemsDetails struct {
-	Item     string  `json:"Item"`
-	Quantity int     `json:"Quantity"`
-	Cost     float64 `json:"Cost"`
-	Username string  `json:"Username"`
+	ID       int64       `json:"ID"`
+	Item     string      `json:"Item"`
+	Quantity float64     `json:"Quantity"`
+	Cost     money.Money `json:"Cost"`
+	Username string      `json:"Username"`
+	Photo    string      `json:"Photo"`
+
+	// Unit the quantity is measured in, and the smallest order and order step in that unit
+	Unit     string  `json:"Unit"`
+	MinOrder float64 `json:"MinOrder"`
+	Step     float64 `json:"Step"`
+
+	// Quantity price breaks, lowest quantity first
+	Tiers []PriceTier `json:"Tiers"`
+
+	// Date the peel was collected, and time after which it is no longer for sale
+	CollectedOn time.Time `json:"CollectedOn"`
+	ExpiresAt   time.Time `json:"ExpiresAt"`
+
+	// Quantity at or below which the seller is told the lot is running low, 0 for no warning
+	LowStock float64 `json:"LowStock"`
+
+	// Area the peel is picked up from, and its distance in km from the buyer, nil when not known
+	Pickup   string   `json:"Pickup,omitempty"`
+	Distance *float64 `json:"Distance,omitempty"`
+}
+
+// This method returns the number of whole days since the peel was collected
+func (i ItemsDetails) DaysSinceCollected() int {
+	return int(time.Since(i.CollectedOn).Hours() / 24)
+}
+
+// This method returns a label describing how fresh the peel is, shown to buyers and sellers
+func (i ItemsDetails) Freshness() string {
+	if i.CollectedOn.IsZero() {
+		return ""
+	}
+	label := ""
+	switch days := i.DaysSinceCollected(); days {
+	case 0:
+		label = "Collected today"
+	case 1:
+		label = "Collected 1 day ago"
+	default:
+		label = fmt.Sprintf("Collected %d days ago", days)
+	}
+
+	switch daysleft := int(time.Until(i.ExpiresAt).Hours() / 24); {
+	case time.Now().After(i.ExpiresAt):
+		label = label + ", expired"
+	case daysleft == 0:
+		label = label + ", expires today"
+	case daysleft == 1:
+		label = label + ", 1 day left"
+	default:
+		label = label + fmt.Sprintf(", %d days left", daysleft)
+	}
+	return label
+}
+
+// This method returns a label describing where the peel is picked up and how far away it is, shown to buyers
+func (i ItemsDetails) PickupLabel() string {
+	label := i.Pickup
+	if label == "" {
+		label = "Area not known"
+	}
+	if i.Distance != nil {
+		label = label + ", " + location.Approximate(*i.Distance)
+	}
+	return label
 }
 
 // Variable used only within this package
 var buyerapikey string
 var sellerapikey string
+var adminapikey string
 
 var apikey string
 
@@ -52,6 +122,7 @@ func init() {
 	// Get the api keys for seller and buyer stored as environment variables
 	sellerapikey, _ = os.LookupEnv("SELLER_API_KEY")
 	buyerapikey, _ = os.LookupEnv("BUYER_API_KEY")
+	adminapikey, _ = os.LookupEnv("ADMIN_API_KEY")
 }
 
 // This function sends a request to the REST API to get one or all Items, and then displays the response.
@@ -169,6 +240,7 @@ func AddItem(itemName, sellerName string, isBuyer bool, si ItemsDetails) bool {
 // It takes in the name of the Item to update of type string.
 // It also takes in the json data to be sent containing details of the Item to update.
 // Upon receiving the response from REST API, it displays the status of the request and if Item has been updated successfully.
+// Only sellers can update an Item, the REST API refuses updates sent with the buyer key.
 //func updateItem(code string, jsonData map[string]string) {
 func UpdateItem(itemName, sellerName string, isBuyer bool, si ItemsDetails) bool {
 	url := ""
@@ -0,0 +1,124 @@
+package apiclient
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+
+	config "projectGoLive/application/config"
+)
+
+//Data structure for each type of fruit peel in the catalogue
+type PeelType struct {
+	Name        string `json:"Name"`
+	DisplayName string `json:"DisplayName"`
+	Image       string `json:"Image"`
+	Unit        string `json:"Unit"`
+	Description string `json:"Description"`
+	Nutrients   string `json:"Nutrients"`
+}
+
+// This function returns the image of every peel type in the catalogue, key being the name of the peel type
+// It is used by templates to show the image of each listed item
+func CatalogueImages(catalogue []PeelType) map[string]string {
+	images := make(map[string]string)
+	for _, p := range catalogue {
+		images[p.Name] = p.Image
+	}
+	return images
+}
+
+// This function sends a request to the REST API to get all peel types in the catalogue.
+// It ignores TLS security as REST API server uses self generated certicates
+// It returns the catalogue, and false if the request is not successful.
+func GetCatalogue() ([]PeelType, bool) {
+	var catalogue []PeelType
+
+	// Skipping TLS verification as self generated certificate is used
+	tr := &http.Transport{
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	}
+	client := &http.Client{Transport: tr}
+
+	response, err := client.Get(baseURL + "catalogue?key=" + sellerapikey)
+	if err != nil {
+		config.Error.Printf("The HTTP request failed with error %s\n", err)
+		return catalogue, false
+	}
+	defer response.Body.Close()
+	data, _ := ioutil.ReadAll(response.Body)
+	if response.StatusCode != 200 {
+		config.Error.Println(response.StatusCode)
+		config.Error.Println(string(data))
+		return catalogue, false
+	}
+	if err := json.Unmarshal(data, &catalogue); err != nil {
+		config.Error.Println(err)
+		return catalogue, false
+	}
+	return catalogue, true
+}
+
+// This function sends a request to the REST API to add a peel type to the catalogue, using the admin api key.
+// It returns true if the peel type has been added successfully.
+func AddPeelType(p PeelType) bool {
+	return sendPeelType(http.MethodPost, p, http.StatusCreated)
+}
+
+// This function sends a request to the REST API to update a peel type in the catalogue, using the admin api key.
+// It returns true if the peel type has been updated successfully.
+func UpdatePeelType(p PeelType) bool {
+	return sendPeelType(http.MethodPut, p, http.StatusAccepted)
+}
+
+// This function sends a request to the REST API to delete a peel type from the catalogue, using the admin api key.
+// Peel types that still have listings are not deleted.
+// It returns true if the peel type has been deleted successfully.
+func DeletePeelType(name string) bool {
+	return sendPeelType(http.MethodDelete, PeelType{Name: name}, http.StatusAccepted)
+}
+
+// This function sends the peel type with the given method to /api/v1/catalogue/{peelname}
+// It returns true if the REST API responds with the expected status code
+func sendPeelType(method string, p PeelType, expected int) bool {
+	var body *bytes.Buffer
+	if method != http.MethodDelete {
+		jsonValue, _ := json.Marshal(p)
+		body = bytes.NewBuffer(jsonValue)
+	} else {
+		body = bytes.NewBuffer(nil)
+	}
+
+	request, err := http.NewRequest(method, baseURL+"catalogue/"+p.Name+"?key="+adminapikey, body)
+	if err != nil {
+		config.Error.Println(err)
+		return false
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	// Skipping TLS verification as self generated certificate is used
+	client := &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{
+				InsecureSkipVerify: true,
+			},
+		},
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		config.Error.Printf("The HTTP request failed with error %s\n", err)
+		return false
+	}
+	defer response.Body.Close()
+	data, _ := ioutil.ReadAll(response.Body)
+	config.Trace.Println(response.StatusCode)
+	config.Trace.Println(string(data))
+	if response.StatusCode != expected {
+		config.Error.Println(response.StatusCode)
+		config.Error.Println(string(data))
+		return false
+	}
+	return true
+}
@@ -0,0 +1,68 @@
+package apiclient
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	config "projectGoLive/application/config"
+)
+
+// Kinds of listing events sent by the REST API
+const (
+	// Listing removed from sale because it passed its expiry time
+	EventExpired = "expired"
+	// Quantity of a listing fell to its low stock warning or below
+	EventLowStock = "lowstock"
+	// Quantity of a listing fell to zero, the listing is kept for the seller to restock
+	EventSoldOut = "soldout"
+	// Price of a listing was lowered by its seller
+	EventPriceDrop = "pricedrop"
+	// New listing put on sale by its seller
+	EventListed = "listed"
+	// Listing that had sold out was given more quantity by its seller
+	EventRestocked = "restocked"
+)
+
+// Data structure for each event about a listing, the item is a snapshot of the listing when the event happened
+type ListingEvent struct {
+	ID        int64        `json:"ID"`
+	Kind      string       `json:"Kind"`
+	Item      ItemsDetails `json:"Item"`
+	CreatedAt time.Time    `json:"CreatedAt"`
+}
+
+// This function sends a request to the REST API to get the events about listings recorded after the event with ID after.
+// It ignores TLS security as REST API server uses self generated certicates
+// Events are returned oldest first, the REST API returns a limited number of events per request.
+// It returns false if the request is not successful.
+func GetEvents(after int64) ([]ListingEvent, bool) {
+	var events []ListingEvent
+
+	// Skipping TLS verification as self generated certificate is used
+	tr := &http.Transport{
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	}
+	client := &http.Client{Transport: tr}
+
+	response, err := client.Get(baseURL + "events?after=" + strconv.FormatInt(after, 10) + "&key=" + sellerapikey)
+	if err != nil {
+		config.Error.Printf("The HTTP request failed with error %s\n", err)
+		return events, false
+	}
+	defer response.Body.Close()
+	data, _ := ioutil.ReadAll(response.Body)
+	if response.StatusCode != 200 {
+		config.Error.Println(response.StatusCode)
+		config.Error.Println(string(data))
+		return events, false
+	}
+	if err := json.Unmarshal(data, &events); err != nil {
+		config.Error.Println(err)
+		return events, false
+	}
+	return events, true
+}
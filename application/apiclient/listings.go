@@ -0,0 +1,116 @@
+package apiclient
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	config "projectGoLive/application/config"
+	location "projectGoLive/application/location"
+	money "projectGoLive/application/money"
+)
+
+// Sort orders of listings for buyers, the empty sort lists them in the order they were listed
+const (
+	SortNewest    = "newest"
+	SortPrice     = "price"
+	SortPriceDesc = "price_desc"
+	SortFreshest  = "freshest"
+	SortExpiring  = "expiring"
+	SortQuantity  = "quantity"
+	SortDistance  = "distance"
+)
+
+// Data structure for the filters, sort order and page of listings asked for by a buyer
+// Each filter is not applied when it is zero or empty. MaxAge is in days since the peel was collected.
+// Limit is the number of listings in a page, 0 for all listings, and Cursor the page to start from, empty for the first.
+// Near is the location of the buyer, needed to sort by distance or keep listings within MaxDistance km.
+type ListingQuery struct {
+	Item        string
+	Seller      string
+	MinPrice    money.Money
+	MaxPrice    money.Money
+	MinQuantity float64
+	MaxAge      int
+	Sort        string
+	Limit       int
+	Cursor      string
+	Near        *location.Point
+	MaxDistance float64
+}
+
+// This method returns the query parameters understood by /api/v1/buyer
+func (q ListingQuery) values() url.Values {
+	v := url.Values{}
+	set := func(name, value string) {
+		if value != "" {
+			v.Set(name, value)
+		}
+	}
+	set("item", q.Item)
+	set("seller", q.Seller)
+	if q.MinPrice.Amount > 0 {
+		set("minprice", q.MinPrice.Decimal())
+		set("currency", q.MinPrice.Currency)
+	}
+	if q.MaxPrice.Amount > 0 {
+		set("maxprice", q.MaxPrice.Decimal())
+		set("currency", q.MaxPrice.Currency)
+	}
+	if q.MinQuantity > 0 {
+		set("minquantity", strconv.FormatFloat(q.MinQuantity, 'f', -1, 64))
+	}
+	if q.MaxAge > 0 {
+		set("maxage", strconv.Itoa(q.MaxAge))
+	}
+	if q.Near != nil {
+		set("lat", strconv.FormatFloat(q.Near.Lat, 'f', -1, 64))
+		set("lng", strconv.FormatFloat(q.Near.Lng, 'f', -1, 64))
+	}
+	if q.MaxDistance > 0 {
+		set("maxdistance", strconv.FormatFloat(q.MaxDistance, 'f', -1, 64))
+	}
+	set("sort", q.Sort)
+	if q.Limit > 0 {
+		set("limit", strconv.Itoa(q.Limit))
+	}
+	set("cursor", q.Cursor)
+	return v
+}
+
+// This function sends a request to the REST API to get the listings of all sellers matching the filters of a buyer.
+// It ignores TLS security as REST API server uses self generated certicates
+// It returns one page of listings and the cursor of the next page, empty on the last page.
+// It returns false if the request is not successful.
+func GetListings(q ListingQuery) ([]ItemsDetails, string, bool) {
+	var items []ItemsDetails
+
+	// Skipping TLS verification as self generated certificate is used
+	tr := &http.Transport{
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	}
+	client := &http.Client{Transport: tr}
+
+	v := q.values()
+	v.Set("key", buyerapikey)
+	response, err := client.Get(baseURL + "buyer?" + v.Encode())
+	if err != nil {
+		config.Error.Printf("The HTTP request failed with error %s\n", err)
+		return items, "", false
+	}
+	defer response.Body.Close()
+	data, _ := ioutil.ReadAll(response.Body)
+	if response.StatusCode != http.StatusOK {
+		config.Error.Println(response.StatusCode)
+		config.Error.Println(string(data))
+		return items, "", false
+	}
+	if err := json.Unmarshal(data, &items); err != nil {
+		config.Error.Println(err)
+		return items, "", false
+	}
+	return items, response.Header.Get("X-Next-Cursor"), true
+}
@@ -0,0 +1,176 @@
+package apiclient
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	config "projectGoLive/application/config"
+	"projectGoLive/application/money"
+)
+
+// This function returns the url of the lots of a seller, and the api key to use for buyer or seller
+func lotsURL(sellerName string, isBuyer bool) (string, string) {
+	if isBuyer {
+		return baseURL + "buyer/" + sellerName + "/items", buyerapikey
+	}
+	return baseURL + "seller/" + sellerName + "/items", sellerapikey
+}
+
+// This function sends a request to the REST API to get one lot of a seller by its ID.
+// It ignores TLS security as REST API server uses self generated certicates
+// It returns the lot, and false if the lot does not exist or the request is not successful.
+func GetLot(id int64, sellerName string, isBuyer bool) (ItemsDetails, bool) {
+	var lot ItemsDetails
+	url, key := lotsURL(sellerName, isBuyer)
+	data, ok := sendLot(http.MethodGet, url+"/"+strconv.FormatInt(id, 10)+"?key="+key, nil, http.StatusOK)
+	if !ok {
+		return lot, false
+	}
+	if err := json.Unmarshal(data, &lot); err != nil {
+		config.Error.Println(err)
+		return lot, false
+	}
+	return lot, true
+}
+
+// This function sends a request to the REST API to add a new lot for a seller.
+// A seller can have several lots of the same item, each lot gets its own ID.
+// It returns the lot with its ID, and false if the lot has not been added.
+func AddLot(sellerName string, si ItemsDetails) (ItemsDetails, bool) {
+	var lot ItemsDetails
+	url, key := lotsURL(sellerName, false)
+	jsonValue, _ := json.Marshal(si)
+	data, ok := sendLot(http.MethodPost, url+"?key="+key, jsonValue, http.StatusCreated)
+	if !ok {
+		return lot, false
+	}
+	if err := json.Unmarshal(data, &lot); err != nil {
+		config.Error.Println(err)
+		return lot, false
+	}
+	return lot, true
+}
+
+// This function sends a request to the REST API to update one lot of a seller by its ID.
+// Only sellers can update a lot, buyers take the quantity they bought with TakeLot.
+// It returns true if the lot has been updated successfully.
+func UpdateLot(id int64, sellerName string, isBuyer bool, si ItemsDetails) bool {
+	url, key := lotsURL(sellerName, isBuyer)
+	jsonValue, _ := json.Marshal(si)
+	_, ok := sendLot(http.MethodPut, url+"/"+strconv.FormatInt(id, 10)+"?key="+key, jsonValue, http.StatusAccepted)
+	return ok
+}
+
+// This function sends a request to the REST API to take the quantity of a cart item from its lot.
+// The REST API only takes the quantity if enough is left, so the lot cannot be oversold by buyers checking out together,
+// and only at the price per unit of the cart item, so the buyer is never charged a price they were not shown.
+// It returns true as first value if the quantity has been taken. When the lot has another price now, nothing is taken
+// and the lot is returned with its new price, with the quantity of the cart item and true as second value.
+func TakeLot(item ItemsDetails) (ItemsDetails, bool, bool) {
+	url, key := lotsURL(item.Username, true)
+	jsonValue, _ := json.Marshal(struct {
+		Quantity float64
+		UnitCost money.Money
+	}{item.Quantity, item.AppliedCost()})
+	data, status := sendLotStatus(http.MethodPost, url+"/"+strconv.FormatInt(item.ID, 10)+"/take?key="+key, jsonValue)
+	switch status {
+	case http.StatusAccepted:
+		return item, true, false
+	case http.StatusPreconditionFailed:
+		var lot ItemsDetails
+		if err := json.Unmarshal(data, &lot); err != nil {
+			config.Error.Println(err)
+			return item, false, false
+		}
+		lot.Quantity = item.Quantity
+		return lot, false, true
+	case 0:
+		return item, false, false
+	}
+	config.Error.Println(status)
+	config.Error.Println(string(data))
+	return item, false, false
+}
+
+// This function sends a request to the REST API to put a quantity back into one lot of a seller, e.g. from a cancelled order.
+// The REST API adds the quantity to what is left, so sales made at the same time are not lost.
+// It returns true if the quantity has been put back, and false if the lot does not exist or the request is not successful.
+func RestockLot(id int64, sellerName string, quantity float64) bool {
+	url, key := lotsURL(sellerName, false)
+	jsonValue, _ := json.Marshal(map[string]float64{"Quantity": quantity})
+	_, ok := sendLot(http.MethodPost, url+"/"+strconv.FormatInt(id, 10)+"/restock?key="+key, jsonValue, http.StatusAccepted)
+	return ok
+}
+
+// This function sends a request to the REST API to delete one lot of a seller by its ID.
+// It returns true if the lot has been deleted successfully.
+func DeleteLot(id int64, sellerName string, isBuyer bool) bool {
+	url, key := lotsURL(sellerName, isBuyer)
+	_, ok := sendLot(http.MethodDelete, url+"/"+strconv.FormatInt(id, 10)+"?key="+key, nil, http.StatusAccepted)
+	return ok
+}
+
+// This function sends a request to the REST API to hide all lots of a seller from buyers, or show them again.
+// Lots are hidden while the account of the seller waits to be deleted, and shown again if the deletion is cancelled.
+// It returns true if the request is successful.
+func HideLots(sellerName string, hidden bool) bool {
+	method := http.MethodPut
+	if !hidden {
+		method = http.MethodDelete
+	}
+	_, ok := sendLot(method, baseURL+"seller/"+sellerName+"/hidden?key="+sellerapikey, nil, http.StatusAccepted)
+	return ok
+}
+
+// Time after which a request sent with sendLot is given up, so a REST API that does not answer never holds up a page
+const sendTimeout = 10 * time.Second
+
+// This function sends a request with the given method and json body to a url of the REST API, such as the url of a lot
+// It returns the body of the response, and true if the REST API responds with the expected status code
+func sendLot(method, url string, jsonValue []byte, expected int) ([]byte, bool) {
+	data, status := sendLotStatus(method, url, jsonValue)
+	if status != expected {
+		if status != 0 {
+			config.Error.Println(status)
+			config.Error.Println(string(data))
+		}
+		return data, false
+	}
+	return data, true
+}
+
+// This function sends a request with the given method and json body to a url of the REST API
+// It returns the body and status code of the response, the status code is 0 when the request is not successful.
+func sendLotStatus(method, url string, jsonValue []byte) ([]byte, int) {
+	request, err := http.NewRequest(method, url, bytes.NewBuffer(jsonValue))
+	if err != nil {
+		config.Error.Println(err)
+		return nil, 0
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	// Skipping TLS verification as self generated certificate is used
+	client := &http.Client{
+		Timeout: sendTimeout,
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{
+				InsecureSkipVerify: true,
+			},
+		},
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		config.Error.Printf("The HTTP request failed with error %s\n", err)
+		return nil, 0
+	}
+	defer response.Body.Close()
+	data, _ := ioutil.ReadAll(response.Body)
+	config.Trace.Println(response.StatusCode)
+	config.Trace.Println(string(data))
+	return data, response.StatusCode
+}
@@ -0,0 +1,103 @@
+package apiclient
+
+import (
+	"math"
+	"strconv"
+
+	"projectGoLive/application/money"
+)
+
+// Units of measure a listing can be sold in, the first one is the default
+var Units = []string{"kg", "g", "bag", "crate"}
+
+// Quantities are kept to this many decimal places, so adding and removing amounts does not drift
+const quantityPlaces = 3
+
+// This function rounds a quantity to the precision quantities are stored with
+func RoundQuantity(q float64) float64 {
+	scale := math.Pow(10, quantityPlaces)
+	return math.Round(q*scale) / scale
+}
+
+// This function formats a quantity without trailing zeros, e.g. 2.5 or 3
+func FormatQuantity(q float64) string {
+	return strconv.FormatFloat(RoundQuantity(q), 'f', -1, 64)
+}
+
+// This method returns the quantity of the listing with its unit, e.g. "2.5 kg"
+func (i ItemsDetails) QuantityLabel() string {
+	return FormatQuantity(i.Quantity) + " " + i.UnitLabel()
+}
+
+// This method returns the unit of the listing, listings added before units were introduced are in kg
+func (i ItemsDetails) UnitLabel() string {
+	if i.Unit == "" {
+		return Units[0]
+	}
+	return i.Unit
+}
+
+// This method returns true if the listing has sold out, it is kept for the seller to restock but not shown to buyers
+func (i ItemsDetails) SoldOut() bool {
+	return i.Quantity <= 0
+}
+
+// This method returns true if the listing has not sold out but is at or below its low stock warning
+func (i ItemsDetails) LowOnStock() bool {
+	return !i.SoldOut() && i.LowStock > 0 && i.Quantity <= i.LowStock
+}
+
+// This method checks that a buyer can order quantity q of the listing
+// q must be at least the minimum order, and the minimum order plus a whole number of order steps,
+// e.g. 1.5, 2, 2.5 kg for a minimum order of 1.5 kg in steps of 0.5 kg
+func (i ItemsDetails) ValidOrder(q float64) bool {
+	step := i.Step
+	if step <= 0 {
+		step = 1
+	}
+	minOrder := math.Max(i.MinOrder, 0)
+	if q <= 0 || RoundQuantity(q) < RoundQuantity(minOrder) {
+		return false
+	}
+	steps := (q - minOrder) / step
+	return math.Abs(steps-math.Round(steps)) < 1e-6
+}
+
+// Data structure for a quantity price break of a listing
+// Orders of at least MinQuantity are charged Cost per unit, below the first break the listing's own cost applies
+type PriceTier struct {
+	MinQuantity float64     `json:"MinQuantity"`
+	Cost        money.Money `json:"Cost"`
+}
+
+// This method returns the price per unit for an order of quantity q, using the highest price break q reaches
+func (i ItemsDetails) UnitCost(q float64) money.Money {
+	cost := i.Cost
+	for _, tier := range i.Tiers {
+		if RoundQuantity(q) >= RoundQuantity(tier.MinQuantity) {
+			cost = tier.Cost
+		}
+	}
+	return cost
+}
+
+// This method returns the price per unit charged for the quantity of the listing, e.g. a cart line
+func (i ItemsDetails) AppliedCost() money.Money {
+	return i.UnitCost(i.Quantity)
+}
+
+// This method returns the cost of the quantity of the listing, e.g. the cost of 2.5 kg at the price per kg
+// The price break reached by the quantity is applied to the whole quantity
+// It returns money.ErrOverflow if the cost is too large to be stored
+func (i ItemsDetails) LineTotal() (money.Money, error) {
+	return i.AppliedCost().Mul(i.Quantity)
+}
+
+// This method describes the price breaks of the listing for templates, e.g. "10 kg or more: SGD 1.00"
+func (i ItemsDetails) TierLabels() []string {
+	var labels []string
+	for _, tier := range i.Tiers {
+		labels = append(labels, FormatQuantity(tier.MinQuantity)+" "+i.UnitLabel()+" or more: "+tier.Cost.String())
+	}
+	return labels
+}
@@ -0,0 +1,52 @@
+package apiclient
+
+import "testing"
+
+func TestValidOrder(t *testing.T) {
+	tests := []struct {
+		name     string
+		minOrder float64
+		step     float64
+		q        float64
+		want     bool
+	}{
+		{"minimum order", 1.5, 0.5, 1.5, true},
+		{"one step above minimum", 1.5, 0.5, 2, true},
+		{"several steps above minimum", 1.5, 0.5, 3.5, true},
+		{"below minimum", 1.5, 0.5, 1, false},
+		{"between steps", 1.5, 0.5, 1.75, false},
+		{"steps counted from minimum", 1, 2, 3, true},
+		{"multiple of step but not from minimum", 1, 2, 4, false},
+		{"decimal steps", 0.2, 0.1, 0.7, true},
+		{"zero", 1, 1, 0, false},
+		{"negative", 1, 1, -1, false},
+		{"no step defaults to 1", 1, 0, 3, true},
+		{"no step defaults to 1, between steps", 1, 0, 2.5, false},
+		{"no minimum order", 0, 0.5, 0.5, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			item := ItemsDetails{MinOrder: tt.minOrder, Step: tt.step}
+			if got := item.ValidOrder(tt.q); got != tt.want {
+				t.Errorf("ValidOrder(%v) with minimum %v and step %v = %v, want %v", tt.q, tt.minOrder, tt.step, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRoundQuantity(t *testing.T) {
+	tests := []struct {
+		q    float64
+		want float64
+	}{
+		{2.5, 2.5},
+		{0.1 + 0.2, 0.3},
+		{1.23456, 1.235},
+		{3 - 2.9, 0.1},
+	}
+	for _, tt := range tests {
+		if got := RoundQuantity(tt.q); got != tt.want {
+			t.Errorf("RoundQuantity(%v) = %v, want %v", tt.q, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package apiclient
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	config "projectGoLive/application/config"
+	"projectGoLive/application/location"
+)
+
+// Kinds of results found by search
+const (
+	KindListing = "listing"
+	KindSeller  = "seller"
+)
+
+// Data structure for the public profile of a seller, searched along with their listings
+// Location is the area the seller is in, never their full address, and Latitude and Longitude its centre,
+// nil when not known.
+type SellerProfile struct {
+	Username  string   `json:"Username"`
+	Name      string   `json:"Name"`
+	Location  string   `json:"Location"`
+	Latitude  *float64 `json:"Latitude,omitempty"`
+	Longitude *float64 `json:"Longitude,omitempty"`
+}
+
+// Data structure for one result of a search, a listing for sale or a seller
+// Listing results also have the profile of their seller, when the seller has one.
+type SearchHit struct {
+	Kind    string         `json:"Kind"`
+	Score   float64        `json:"Score"`
+	Listing *ItemsDetails  `json:"Listing,omitempty"`
+	Seller  *SellerProfile `json:"Seller,omitempty"`
+}
+
+// This function sends a request to the REST API to search listings and sellers by words, which may be misspelt.
+// Kind is KindListing or KindSeller to find only one kind, or empty for both, and limit is the most results wanted.
+// It returns the results best match first, and false if the request is not successful.
+func Search(query, kind string, limit int) ([]SearchHit, bool) {
+	var hits []SearchHit
+	v := url.Values{}
+	v.Set("q", query)
+	if kind != "" {
+		v.Set("kind", kind)
+	}
+	v.Set("limit", strconv.Itoa(limit))
+	v.Set("key", buyerapikey)
+	data, ok := sendLot(http.MethodGet, baseURL+"search?"+v.Encode(), nil, http.StatusOK)
+	if !ok {
+		return hits, false
+	}
+	if err := json.Unmarshal(data, &hits); err != nil {
+		config.Error.Println(err)
+		return hits, false
+	}
+	return hits, true
+}
+
+// This function sends a request to the REST API to save the public profile of a seller, so they can be found by search.
+// The profile has the full name of the seller and the area of their address, and the stored location of the seller
+// so buyers can tell how far away the seller is, stored again first if the postal code of their address has changed.
+// It is saved each time the seller logs in, so the profile follows changes to their address.
+// It returns true if the profile has been saved successfully.
+func SaveSellerProfile(username, fullname, address string) bool {
+	profile := SellerProfile{Username: username, Name: fullname}
+	profile.Location, _ = location.Area(address)
+	if p, ok := location.OfUser(username); ok {
+		profile.Latitude, profile.Longitude = &p.Lat, &p.Lng
+	}
+	jsonValue, _ := json.Marshal(profile)
+	_, ok := sendLot(http.MethodPut, baseURL+"seller/"+username+"/profile?key="+sellerapikey, jsonValue, http.StatusAccepted)
+	return ok
+}
+
+// This function sends a request to the REST API to delete the public profile of a seller.
+// It returns true if the profile has been deleted successfully.
+func DeleteSellerProfile(username string) bool {
+	_, ok := sendLot(http.MethodDelete, baseURL+"seller/"+username+"/profile?key="+sellerapikey, nil, http.StatusAccepted)
+	return ok
+}
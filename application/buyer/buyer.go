@@ -3,52 +3,202 @@
 package buyer
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
 	"net/http"
 	"projectGoLive/application/apiclient"
 	"projectGoLive/application/config"
+	"projectGoLive/application/coupon"
 	"projectGoLive/application/email"
+	"projectGoLive/application/invoice"
+	"projectGoLive/application/listingevents"
+	"projectGoLive/application/location"
+	"projectGoLive/application/money"
+	"projectGoLive/application/notify"
+	"projectGoLive/application/orders"
+	"projectGoLive/application/payment"
+	"projectGoLive/application/savedsearch"
 	"projectGoLive/application/server"
+	"projectGoLive/application/tax"
 	"projectGoLive/application/user_db"
+	"projectGoLive/application/webhook"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
+// Number of listings in each page of the list of all items
+const listingsPageSize = 20
+
+// Number of listings and sellers shown for a search from the menu
+const searchResultsSize = 30
+
 type buyerStruct struct {
 	Buyername   string
+	IsSeller    bool
 	Operation   string
 	Mainmessage []string
 	Items       []apiclient.ItemsDetails
-	CostPerItem []string
-	Totalcost   string
+	CostPerItem []money.Money
+	Totalcost   money.Money
+	Coupon      coupon.Applied
+	Tax         tax.Order
+	Inclusive   bool
+	Payable     money.Money
+	Catalogue   []apiclient.PeelType
+	Images      map[string]string
+	Sortby      string
+	Maxage      string
+	// Orders of the buyer and their disputes by order ID, shown on the orders page
+	Orders   []orders.Order
+	Disputes map[int64]orders.Dispute
+	// Search entered on the search page, and the searches saved by the buyer
+	Search   savedsearch.SavedSearch
+	Searches []savedsearch.SavedSearch
+	// Filters of the list of all items, and the urls of its next and first pages, empty when there is none
+	Filter    apiclient.ListingQuery
+	NextPage  string
+	FirstPage string
+	// Words searched for from the menu, the sellers found and the profiles of the sellers of the listings found
+	Query        string
+	FoundSellers []apiclient.SellerProfile
+	Profiles     map[string]*apiclient.SellerProfile
 }
 
-var buyerCartll CartLinkedList
+// This method adds the catalogue of peel types to the data sent to template, used for selects and images
+func (b *buyerStruct) addCatalogue() {
+	catalogue, ok := apiclient.GetCatalogue()
+	if !ok {
+		config.Error.Println("Unable to get catalogue from seller API")
+	}
+	b.Catalogue = catalogue
+	b.Images = apiclient.CatalogueImages(catalogue)
+}
+
+// Create a map to store the shopping cart of each buyer, key being the buyer's username
+var buyerCarts map[string]*CartLinkedList
+
+// Create a map to store the promo code applied to the cart of each buyer, key being the buyer's username
+var buyerCoupons map[string]string
+
+// Mutex for buyerCarts and buyerCoupons, as handlers for different buyers run concurrently
+var cartsMu sync.Mutex
 
 func init() {
-	buyerCartll = CartLinkedList{Head: nil, Size: 0}
+	buyerCarts = make(map[string]*CartLinkedList)
+	buyerCoupons = make(map[string]string)
+	listingevents.Subscribe(apiclient.EventPriceDrop, notifyPriceDrop)
 }
 
-func BuyerHandler(w http.ResponseWriter, req *http.Request) {
-	if !server.ActiveSession(w, req) {
-		http.Redirect(w, req, "/login", http.StatusSeeOther)
+// This method tells the buyers with a listing in their cart that its price dropped below the price they saw
+func notifyPriceDrop(event apiclient.ListingEvent) bool {
+	cartsMu.Lock()
+	was := make(map[string]money.Money)
+	for buyername, cart := range buyerCarts {
+		if cartItem, _, err := cart.SearchID(event.Item.ID); err == nil && cartItem.Cost.Currency == event.Item.Cost.Currency &&
+			event.Item.Cost.Amount < cartItem.Cost.Amount {
+			was[buyername] = cartItem.Cost
+		}
+	}
+	cartsMu.Unlock()
+
+	for buyername, cost := range was {
+		notify.PriceDropped(buyername, event.Item, cost.String())
+	}
+	// Carts are only kept in memory, a buyer that could not be told is not told again
+	return true
+}
+
+// This method returns the shopping cart of a buyer, creating an empty one if the buyer has none
+func getCart(buyername string) *CartLinkedList {
+	cartsMu.Lock()
+	defer cartsMu.Unlock()
+	cart, ok := buyerCarts[buyername]
+	if !ok {
+		cart = &CartLinkedList{Head: nil, Size: 0}
+		buyerCarts[buyername] = cart
+	}
+	return cart
+}
+
+// This method empties the shopping cart of a buyer
+// It is also used outside of buyer package when the account of a buyer is deleted
+func DeleteCart(buyername string) {
+	cartsMu.Lock()
+	delete(buyerCarts, buyername)
+	delete(buyerCoupons, buyername)
+	cartsMu.Unlock()
+}
+
+// This method returns the promo code applied to the cart of a buyer, empty if there is none
+func cartCoupon(buyername string) string {
+	cartsMu.Lock()
+	defer cartsMu.Unlock()
+	return buyerCoupons[buyername]
+}
+
+// This method applies a promo code to the cart of a buyer, an empty code removes it
+// Only one code can be applied to a cart, a new code replaces the previous one
+func setCartCoupon(buyername, code string) {
+	cartsMu.Lock()
+	defer cartsMu.Unlock()
+	if code == "" {
+		delete(buyerCoupons, buyername)
 		return
 	}
-	user := server.GetUser(w, req)
+	buyerCoupons[buyername] = code
+}
+
+// This method returns all items in the shopping cart of a buyer
+// It is used outside of buyer package, e.g. when a buyer downloads their data
+func CartItems(buyername string) []apiclient.ItemsDetails {
+	_, allitems := getCart(buyername).GetAllItems()
+	return allitems
+}
 
+func BuyerHandler(w http.ResponseWriter, req *http.Request, user server.UserInfo) {
 	buyerToTemplate := buyerStruct{}
 	buyerToTemplate.Buyername = user.Username
+	buyerToTemplate.IsSeller = user.IsSeller()
 	buyerToTemplate.Operation = "view"
 
-	allSellerItems, ok := apiclient.GetItem("", "", user.IsBuyer)
+	buyerToTemplate.Mainmessage = append(buyerToTemplate.Mainmessage, "List of all items: ")
+
+	// The seller API filters, sorts and pages the listings, the page shown is kept in the url
+	filter, msg := readListingFilter(req, user.Username)
+	if msg != "" {
+		buyerToTemplate.Mainmessage = append(buyerToTemplate.Mainmessage, msg)
+	}
+	allSellerItems, next, ok := apiclient.GetListings(filter)
+	if !ok && filter.Cursor != "" {
+		// The page may no longer be valid, e.g. a cursor kept from before the sort order changed, so the first page is shown
+		filter.Cursor = ""
+		allSellerItems, next, ok = apiclient.GetListings(filter)
+		if ok {
+			buyerToTemplate.Mainmessage = append(buyerToTemplate.Mainmessage, "That page of listings is no longer available, showing the first page.")
+		}
+	}
 	if !ok {
 		config.Error.Println("Unable to connect to Database!")
-		return
+		buyerToTemplate.Mainmessage = append(buyerToTemplate.Mainmessage, "Unable to get the listings, please try again!")
 	}
 
-	allSellerItems = removeCartItems(allSellerItems)
+	buyerCartll := getCart(user.Username)
+	allSellerItems = removeCartItems(buyerCartll, allSellerItems)
 
+	buyerToTemplate.Filter = filter
+	buyerToTemplate.Sortby = req.FormValue("sortby")
+	buyerToTemplate.Maxage = req.FormValue("maxage")
 	buyerToTemplate.Items = allSellerItems
-	buyerToTemplate.Mainmessage = append(buyerToTemplate.Mainmessage, "List of all items: ")
+	if next != "" {
+		buyerToTemplate.NextPage = pageURL(req, next)
+	}
+	if filter.Cursor != "" {
+		buyerToTemplate.FirstPage = pageURL(req, "")
+	}
 
 	if req.Method == http.MethodPost {
 		addthisitem := req.FormValue("product_id")
@@ -56,50 +206,27 @@ func BuyerHandler(w http.ResponseWriter, req *http.Request) {
 
 		if addthisitem != "" {
 			// Add this item to linked list
-			item := convStringtoSlice(addthisitem)
-			item.Quantity = config.ConvertToInt(newquantity)
-
-			// check if item exists in linked list
-			iteminll, index, err := buyerCartll.SearchItemandSellerName(item.Item, item.Username)
-			if err != nil {
-				// item does not exist in linked list, can add as a new node
-				err := buyerCartll.AddNode(item)
-				if err != nil {
-					buyerToTemplate.Mainmessage = append(buyerToTemplate.Mainmessage, "Server Error. Try again!")
-				} else {
-					// redirect to main index
-					http.Redirect(w, req, "/buyer/buyercart", http.StatusSeeOther)
-					return
-				}
+			if msg := addToCart(buyerCartll, addthisitem, newquantity); msg != "" {
+				buyerToTemplate.Mainmessage = append(buyerToTemplate.Mainmessage, msg)
 			} else {
-				// item exists, need to update the item
-				newitem := item
-				newitem.Quantity = item.Quantity + iteminll.Quantity
-				err := buyerCartll.WriteAtIndex(index, newitem)
-				if err != nil {
-					buyerToTemplate.Mainmessage = append(buyerToTemplate.Mainmessage, "Server Error. Try again!")
-				} else {
-					// redirect to main index
-					http.Redirect(w, req, "/buyer/buyercart", http.StatusSeeOther)
-					return
-				}
+				// redirect to main index
+				http.Redirect(w, req, "/buyer/buyercart", http.StatusSeeOther)
+				return
 			}
 		}
 	}
 	//display available items on browser
+	buyerToTemplate.addCatalogue()
 	config.TPL.ExecuteTemplate(w, "buyertemplate.gohtml", buyerToTemplate)
 }
 
-func LookForItemHandler(w http.ResponseWriter, req *http.Request) {
-	if !server.ActiveSession(w, req) {
-		http.Redirect(w, req, "/login", http.StatusSeeOther)
-		return
-	}
-	user := server.GetUser(w, req)
+func LookForItemHandler(w http.ResponseWriter, req *http.Request, user server.UserInfo) {
+	buyerCartll := getCart(user.Username)
 	var oneItemAllSellers []apiclient.ItemsDetails
 
 	buyerToTemplate := buyerStruct{}
 	buyerToTemplate.Buyername = user.Username
+	buyerToTemplate.IsSeller = user.IsSeller()
 	buyerToTemplate.Operation = "finditem"
 
 	buyerToTemplate.Mainmessage = append(buyerToTemplate.Mainmessage, "Please choose an item to search: ")
@@ -110,106 +237,240 @@ func LookForItemHandler(w http.ResponseWriter, req *http.Request) {
 		addthisitem := req.FormValue("product_id")
 
 		if chosenitem != "" {
-			allSellerItems, ok := apiclient.GetItem("", "", user.IsBuyer)
-			if !ok {
-				buyerToTemplate.Mainmessage = append(buyerToTemplate.Mainmessage, "Server Error. Try again!")
-			}
-			allSellerItems = removeCartItems(allSellerItems)
+			search, msg := savedsearch.ReadForm(req, user.Username)
+			buyerToTemplate.Search = search
+			buyerToTemplate.Sortby = req.FormValue("sortby")
+			buyerToTemplate.Maxage = req.FormValue("maxage")
+			if msg != "" {
+				buyerToTemplate.Mainmessage = append(buyerToTemplate.Mainmessage, msg)
+			} else if req.FormValue("action") == "save" {
+				// The buyer is emailed when a listing matching the search is put on sale or its price drops
+				if !savedsearch.InsertSearch(config.DB, search) {
+					buyerToTemplate.Mainmessage = append(buyerToTemplate.Mainmessage, fmt.Sprintf("Unable to save search, you can save at most %d!", savedsearch.MaxSearches))
+				} else {
+					http.Redirect(w, req, "/buyer/searches", http.StatusSeeOther)
+					return
+				}
+			} else {
+				allSellerItems, ok := apiclient.GetItem("", "", true)
+				if !ok {
+					buyerToTemplate.Mainmessage = append(buyerToTemplate.Mainmessage, "Server Error. Try again!")
+				}
+				allSellerItems = removeCartItems(buyerCartll, allSellerItems)
 
-			for _, item := range allSellerItems {
-				if item.Item == chosenitem {
-					oneItemAllSellers = append(oneItemAllSellers, item)
+				var known bool
+				oneItemAllSellers, known = matchSearch(allSellerItems, search)
+				if !known {
+					buyerToTemplate.Mainmessage = append(buyerToTemplate.Mainmessage, "Add a postal code to your address to search by distance")
 				}
+				buyerToTemplate.Items = sortByFreshness(oneItemAllSellers, buyerToTemplate.Sortby, buyerToTemplate.Maxage)
 			}
-			buyerToTemplate.Items = oneItemAllSellers
 		} else if addthisitem != "" {
 			// Add this item to linked list
-			item := convStringtoSlice(addthisitem)
 			newquantity := req.FormValue("newquantity")
-			item.Quantity = config.ConvertToInt(newquantity)
-
-			// check if item exists in linked list
-			iteminll, index, err := buyerCartll.SearchItemandSellerName(item.Item, item.Username)
-			if err != nil {
-				// item does not exist in linked list, can add as a new node
-				err := buyerCartll.AddNode(item)
-				if err != nil {
-					buyerToTemplate.Mainmessage = append(buyerToTemplate.Mainmessage, "Server Error. Try again!")
-				} else {
-					// redirect to main index
-					http.Redirect(w, req, "/buyer/buyercart", http.StatusSeeOther)
-					return
-				}
+			if msg := addToCart(buyerCartll, addthisitem, newquantity); msg != "" {
+				buyerToTemplate.Mainmessage = append(buyerToTemplate.Mainmessage, msg)
 			} else {
-				// item exists, need to update the item
-				newitem := item
-				newitem.Quantity = item.Quantity + iteminll.Quantity
-				err := buyerCartll.WriteAtIndex(index, newitem)
-				if err != nil {
-					buyerToTemplate.Mainmessage = append(buyerToTemplate.Mainmessage, "Server Error. Try again!")
-				} else {
-					// redirect to main index
-					http.Redirect(w, req, "/buyer/buyercart", http.StatusSeeOther)
-					return
-				}
+				// redirect to main index
+				http.Redirect(w, req, "/buyer/buyercart", http.StatusSeeOther)
+				return
 			}
-
 		}
 	}
+	buyerToTemplate.addCatalogue()
 	config.TPL.ExecuteTemplate(w, "buyertemplate.gohtml", buyerToTemplate)
 }
 
-func CartHandler(w http.ResponseWriter, req *http.Request) {
-	if !server.ActiveSession(w, req) {
-		http.Redirect(w, req, "/login", http.StatusSeeOther)
+//---------------------------------------------------------------------------
+// Functions to search listings and sellers
+//---------------------------------------------------------------------------
+// This method is used to search listings and sellers by words entered in the menu, such as "orange tampines"
+// The words are looked up in the names and descriptions of the peel, and the names and areas of the sellers,
+// and may be misspelt. Listings are added to the cart from the page of all items.
+func SearchHandler(w http.ResponseWriter, req *http.Request, user server.UserInfo) {
+	buyerToTemplate := buyerStruct{}
+	buyerToTemplate.Buyername = user.Username
+	buyerToTemplate.IsSeller = user.IsSeller()
+	buyerToTemplate.Operation = "search"
+	buyerToTemplate.Query = strings.TrimSpace(req.FormValue("q"))
+	if buyerToTemplate.Query == "" {
+		http.Redirect(w, req, "/buyer", http.StatusSeeOther)
+		return
+	}
+
+	hits, ok := apiclient.Search(buyerToTemplate.Query, "", searchResultsSize)
+	if !ok {
+		config.Error.Printf("Unable to search for %q\n", buyerToTemplate.Query)
+		buyerToTemplate.Mainmessage = append(buyerToTemplate.Mainmessage, "Unable to search, try again!")
+		config.TPL.ExecuteTemplate(w, "buyertemplate.gohtml", buyerToTemplate)
 		return
 	}
-	user := server.GetUser(w, req)
 
+	var listings []apiclient.ItemsDetails
+	buyerToTemplate.Profiles = make(map[string]*apiclient.SellerProfile)
+	for _, hit := range hits {
+		switch {
+		case hit.Kind == apiclient.KindListing && hit.Listing != nil:
+			listings = append(listings, *hit.Listing)
+			if hit.Seller != nil {
+				buyerToTemplate.Profiles[hit.Seller.Username] = hit.Seller
+			}
+		case hit.Kind == apiclient.KindSeller && hit.Seller != nil:
+			buyerToTemplate.FoundSellers = append(buyerToTemplate.FoundSellers, *hit.Seller)
+		}
+	}
+	buyerToTemplate.Items = removeCartItems(getCart(user.Username), listings)
+
+	if len(buyerToTemplate.Items) == 0 && len(buyerToTemplate.FoundSellers) == 0 {
+		buyerToTemplate.Mainmessage = append(buyerToTemplate.Mainmessage, "Nothing found for "+buyerToTemplate.Query)
+	} else {
+		buyerToTemplate.Mainmessage = append(buyerToTemplate.Mainmessage, "Results for "+buyerToTemplate.Query)
+	}
+	buyerToTemplate.addCatalogue()
+	config.TPL.ExecuteTemplate(w, "buyertemplate.gohtml", buyerToTemplate)
+}
+
+//---------------------------------------------------------------------------
+// Functions to manage saved searches of buyer
+//---------------------------------------------------------------------------
+// This method is used to view and delete the searches saved by the buyer
+// Searches are saved from the search page, and the buyer is emailed about new listings matching them.
+func SearchesHandler(w http.ResponseWriter, req *http.Request, user server.UserInfo) {
+	buyerToTemplate := buyerStruct{}
+	buyerToTemplate.Buyername = user.Username
+	buyerToTemplate.IsSeller = user.IsSeller()
+	buyerToTemplate.Operation = "searches"
+
+	if req.Method == http.MethodPost && req.FormValue("action") == "delete" {
+		id, _ := strconv.ParseInt(req.FormValue("search"), 10, 64)
+		if !savedsearch.DeleteSearch(config.DB, id, user.Username) {
+			buyerToTemplate.Mainmessage = append(buyerToTemplate.Mainmessage, "Unable to delete saved search, it does not exist!")
+		} else {
+			http.Redirect(w, req, "/buyer/searches", http.StatusSeeOther)
+			return
+		}
+	}
+
+	searches, ok := savedsearch.GetSearches(config.DB, user.Username)
+	if !ok {
+		config.Error.Printf("Unable to get saved searches for buyer %s \n", user.Username)
+		buyerToTemplate.Mainmessage = append(buyerToTemplate.Mainmessage, "Unable to get saved searches")
+	}
+	buyerToTemplate.Searches = searches
+	config.TPL.ExecuteTemplate(w, "buyertemplate.gohtml", buyerToTemplate)
+}
+
+func CartHandler(w http.ResponseWriter, req *http.Request, user server.UserInfo) {
 	buyerToTemplate := buyerStruct{}
 	buyerToTemplate.Buyername = user.Username
+	buyerToTemplate.IsSeller = user.IsSeller()
 	buyerToTemplate.Operation = "viewcart"
 
 	buyerToTemplate.Mainmessage = append(buyerToTemplate.Mainmessage, "Shopping Cart: ")
 
+	buyerCartll := getCart(user.Username)
 	_, allitems := buyerCartll.GetAllItems()
-	buyerToTemplate.Items = allitems
-
-	buyerToTemplate.Totalcost, buyerToTemplate.CostPerItem = computeTotalCost(allitems)
+	couponRemoved := buyerToTemplate.showCart(allitems)
 
 	// process form submission , when buyer clicks submit
 	if req.Method == http.MethodPost {
 		addmore := req.FormValue("add_more")
 		reset := req.FormValue("reset")
 		checkout := req.FormValue("checkout")
+		applycoupon := req.FormValue("applycoupon")
+		removecoupon := req.FormValue("removecoupon")
 
 		if addmore != "" {
 			http.Redirect(w, req, "/buyer", http.StatusSeeOther)
 			return
 		} else if reset != "" {
 			// reset shopping cart linked list
-			buyerCartll = CartLinkedList{Head: nil, Size: 0}
+			DeleteCart(user.Username)
 			http.Redirect(w, req, "/buyer", http.StatusSeeOther)
 			return
-		} else if checkout != "" {
-			// perform checkout
-			allSellerItems, ok := apiclient.GetItem("", "", user.IsBuyer)
-			if !ok {
-				config.Error.Println("Unable to connect to Database!")
+		} else if applycoupon != "" {
+			code := coupon.Normalize(req.FormValue("coupon"))
+			if _, err := checkCoupon(code, user.Username, allitems); err != nil {
+				buyerToTemplate.Mainmessage = append(buyerToTemplate.Mainmessage, "Unable to use promo code "+code+", "+err.Error())
+			} else {
+				setCartCoupon(user.Username, code)
+				http.Redirect(w, req, "/buyer/buyercart", http.StatusSeeOther)
+				return
+			}
+		} else if removecoupon != "" {
+			setCartCoupon(user.Username, "")
+			http.Redirect(w, req, "/buyer/buyercart", http.StatusSeeOther)
+			return
+		} else if checkout != "" && !couponRemoved {
+			// The cart is priced again from the listings, and a buyer shown a new price checks out again to accept it
+			if changes := repriceCart(buyerCartll); len(changes) > 0 {
+				_, allitems = buyerCartll.GetAllItems()
+				buyerToTemplate.showCart(allitems)
+				buyerToTemplate.Mainmessage = append(buyerToTemplate.Mainmessage, changes...)
+				buyerToTemplate.Mainmessage = append(buyerToTemplate.Mainmessage, "Please check the new total and check out again")
+				buyerToTemplate.addCatalogue()
+				config.TPL.ExecuteTemplate(w, "buyercart.gohtml", buyerToTemplate)
 				return
 			}
+
+			// perform checkout, unless the promo code has just been removed so the buyer sees the new total first
 			_, allitems := buyerCartll.GetAllItems()
 			allok := true
 
+			// The promo code is checked again and its use recorded before the stock is taken
+			applied := buyerToTemplate.Coupon
+			var redemptionID int64
+			if applied.Code != "" {
+				id, err := coupon.Redeem(config.DB, applied, user.Username, time.Now())
+				if err != nil {
+					setCartCoupon(user.Username, "")
+					buyerToTemplate.Coupon = coupon.Applied{}
+					buyerToTemplate.computeTax(allitems)
+					if coupon.IsReason(err) {
+						buyerToTemplate.Mainmessage = append(buyerToTemplate.Mainmessage, "Unable to use promo code "+applied.Code+", "+err.Error())
+					} else {
+						buyerToTemplate.Mainmessage = append(buyerToTemplate.Mainmessage, "Error while performing check out!")
+					}
+					buyerToTemplate.addCatalogue()
+					config.TPL.ExecuteTemplate(w, "buyercart.gohtml", buyerToTemplate)
+					return
+				}
+				redemptionID = id
+			}
+
+			// When paying online, the amount payable is reserved before the stock is taken
+			var paid payment.Record
+			if payment.Current != nil && buyerToTemplate.Payable.Amount > 0 {
+				record, err := payment.Authorise(user.Username, buyerToTemplate.Payable)
+				if err != nil {
+					if redemptionID != 0 {
+						coupon.Release(config.DB, redemptionID)
+					}
+					buyerToTemplate.Mainmessage = append(buyerToTemplate.Mainmessage, "Unable to take payment, "+err.Error())
+					buyerToTemplate.addCatalogue()
+					config.TPL.ExecuteTemplate(w, "buyercart.gohtml", buyerToTemplate)
+					return
+				}
+				paid = record
+			}
+
+			var taken []apiclient.ItemsDetails
 			for _, item := range allitems {
-				ok := updateDB(user.IsBuyer, allSellerItems, item)
+				lot, ok, repriced := updateDB(item)
 				allok = allok && ok
-				if !ok {
+				if repriced {
+					// The price changed since the cart was priced, the cart line is given the new price to check out again
+					buyerToTemplate.Mainmessage = append(buyerToTemplate.Mainmessage, priceChange(item, lot))
+					if _, index, err := buyerCartll.SearchID(item.ID); err == nil {
+						buyerCartll.WriteAtIndex(index, lot)
+					}
+				} else if !ok {
 					buyerToTemplate.Mainmessage = append(buyerToTemplate.Mainmessage, "Error while performing check out!")
 					buyerToTemplate.Mainmessage = append(buyerToTemplate.Mainmessage, "Try again")
 				} else {
+					taken = append(taken, item)
 					// If ok, remove that item from the linked list
-					_, index, err := buyerCartll.SearchItemandSellerName(item.Item, item.Username)
+					_, index, err := buyerCartll.SearchID(item.ID)
 					if err != nil {
 						config.Error.Println("Not able to find item in linked list")
 						config.Error.Println(err)
@@ -222,31 +483,92 @@ func CartHandler(w http.ResponseWriter, req *http.Request) {
 					}
 				}
 			}
+			if !allok {
+				// The items that were taken are put back on sale and in the cart, so the buyer can check out again
+				undoCheckout(buyerCartll, taken)
+			}
+			if !allok && redemptionID != 0 {
+				// The order was not completed, so the promo code can be used again
+				coupon.Release(config.DB, redemptionID)
+			}
+			if !allok && paid.ID != 0 {
+				// The order was not completed, so the payment is released
+				payment.Refund(paid)
+			}
 			if allok {
-				// Send invoice email to buyer and sellers
-				email.Sendemail(user.Username, allitems)
+				// If the payment cannot be taken, the authorisation is released and the checkout is undone
+				if paid.ID != 0 && payment.Capture(paid) != nil {
+					if payment.Refund(paid) != nil {
+						config.Error.Printf("Unable to release payment %d of buyer %s after it could not be captured\n", paid.ID, user.Username)
+					}
+					undoCheckout(buyerCartll, taken)
+					if redemptionID != 0 {
+						coupon.Release(config.DB, redemptionID)
+					}
+					buyerToTemplate.Mainmessage = append(buyerToTemplate.Mainmessage, "Unable to take payment, you have not been charged. Please try again!")
+					buyerToTemplate.addCatalogue()
+					config.TPL.ExecuteTemplate(w, "buyercart.gohtml", buyerToTemplate)
+					return
+				}
 
+				// Record an order for each seller, and the money owed for them in the ledger
+				placed := orders.Build(user.Username, allitems, applied, buyerToTemplate.Tax, time.Now())
+				for n := range placed {
+					placed[n].PaymentID = paid.ID
+				}
+				// The invoice emails to the buyer and sellers are rendered first, then queued with the PDF invoice
+				// of each order in the same transaction as the orders, so they are sent exactly when the orders are recorded.
+				// So are the webhooks of the sellers registered for new orders. An email or webhook that cannot be
+				// rendered or queued is logged, a notification never stops the orders from being recorded.
+				checkoutEmails, emailsOK := email.PrepareCheckout(user.Username, allitems, applied, buyerToTemplate.Tax)
+				placed, ok := orders.Place(config.DB, placed, func(tx *sql.Tx, placed []orders.Order) error {
+					invoices := invoice.IssueAll(tx, placed, time.Now())
+					if !emailsOK || !checkoutEmails.Queue(tx, invoices) {
+						config.Error.Printf("Unable to queue the checkout emails of buyer %s\n", user.Username)
+					}
+					if !webhook.OrdersPlaced(tx, placed) {
+						config.Error.Printf("Unable to queue the webhooks of the orders of buyer %s\n", user.Username)
+					}
+					return nil
+				})
+				if !ok {
+					// Without an order the checkout is undone, so the buyer is not charged for items they cannot collect
+					config.Error.Printf("Unable to record the orders of buyer %s\n", user.Username)
+					undoCheckout(buyerCartll, taken)
+					if redemptionID != 0 {
+						coupon.Release(config.DB, redemptionID)
+					}
+					if paid.ID != 0 && payment.Refund(paid) != nil {
+						config.Error.Printf("Unable to refund payment %d of buyer %s after the orders were not recorded\n", paid.ID, user.Username)
+					}
+					buyerToTemplate.Mainmessage = append(buyerToTemplate.Mainmessage, "Unable to record your order, you have not been charged. Please try again!")
+					buyerToTemplate.addCatalogue()
+					config.TPL.ExecuteTemplate(w, "buyercart.gohtml", buyerToTemplate)
+					return
+				}
+				for _, o := range placed {
+					notify.OrderPlaced(o)
+				}
+
+				if paid.ID != 0 {
+					http.Redirect(w, req, "/buyer/checkoutsuccess?paid=1", http.StatusSeeOther)
+					return
+				}
 				http.Redirect(w, req, "/buyer/checkoutsuccess", http.StatusSeeOther)
 				return
 			}
 		}
 	}
 
+	buyerToTemplate.addCatalogue()
 	config.TPL.ExecuteTemplate(w, "buyercart.gohtml", buyerToTemplate)
 }
 
 //---------------------------------------------------------------------------
 // Functions to display profile of buyer
 //---------------------------------------------------------------------------
-func ShowProfile(w http.ResponseWriter, req *http.Request) {
+func ShowProfile(w http.ResponseWriter, req *http.Request, user server.UserInfo) {
 	fmt.Println(req.URL.Path)
-	if !server.ActiveSession(w, req) {
-		http.Redirect(w, req, "/login", http.StatusSeeOther)
-		return
-	}
-
-	user := server.GetUser(w, req)
-
 	// Buyer details :
 	buyerdetails, _ := user_db.GetARecord(config.DB, user.Username)
 	buyerfullname := buyerdetails.Fullname
@@ -256,6 +578,7 @@ func ShowProfile(w http.ResponseWriter, req *http.Request) {
 
 	buyerToTemplate := buyerStruct{
 		Buyername:   user.Username,
+		IsSeller:    user.IsSeller(),
 		Operation:   "profile",
 		Mainmessage: nil,
 		Items:       nil,
@@ -270,87 +593,412 @@ func ShowProfile(w http.ResponseWriter, req *http.Request) {
 	config.TPL.ExecuteTemplate(w, "buyertemplate.gohtml", buyerToTemplate)
 }
 
-func CheckoutSuccessHandler(w http.ResponseWriter, req *http.Request) {
-	if !server.ActiveSession(w, req) {
-		http.Redirect(w, req, "/login", http.StatusSeeOther)
-		return
-	}
-	user := server.GetUser(w, req)
+func CheckoutSuccessHandler(w http.ResponseWriter, req *http.Request, user server.UserInfo) {
 	buyerToTemplate := buyerStruct{}
 	buyerToTemplate.Buyername = user.Username
+	buyerToTemplate.IsSeller = user.IsSeller()
 	buyerToTemplate.Operation = "checkoutsuccess"
 
 	buyerToTemplate.Mainmessage = append(buyerToTemplate.Mainmessage, "Checkout successful!")
 	buyerToTemplate.Mainmessage = append(buyerToTemplate.Mainmessage, "Invoice has been emailed to your registered email address.")
-	buyerToTemplate.Mainmessage = append(buyerToTemplate.Mainmessage, "Please make payment during collection!")
-	buyerCartll = CartLinkedList{Head: nil, Size: 0}
+	if req.FormValue("paid") != "" {
+		buyerToTemplate.Mainmessage = append(buyerToTemplate.Mainmessage, "Payment has been received, please show your invoice during collection!")
+	} else {
+		buyerToTemplate.Mainmessage = append(buyerToTemplate.Mainmessage, "Please make payment during collection!")
+	}
+	DeleteCart(user.Username)
 
 	config.TPL.ExecuteTemplate(w, "buyercart.gohtml", buyerToTemplate)
 }
 
-func computeTotalCost(allitems []apiclient.ItemsDetails) (totalcost string, costperitem []string) {
-	total := 0.0
-	temptotal := 0.0
+//---------------------------------------------------------------------------
+// Functions to display orders of buyer
+//---------------------------------------------------------------------------
+// This method is used to view the orders of the buyer, cancel an order before collecting it, or dispute a collected order
+func OrdersHandler(w http.ResponseWriter, req *http.Request, user server.UserInfo) {
+	buyerToTemplate := buyerStruct{}
+	buyerToTemplate.Buyername = user.Username
+	buyerToTemplate.IsSeller = user.IsSeller()
+	buyerToTemplate.Operation = "orders"
+	buyerToTemplate.Mainmessage = append(buyerToTemplate.Mainmessage, "My Orders: ")
+
+	if req.Method == http.MethodPost {
+		id, _ := strconv.ParseInt(req.FormValue("order"), 10, 64)
+		switch req.FormValue("action") {
+		case "cancel":
+			o, ok := orders.Cancel(config.DB, id, user.Username, false, "", time.Now())
+			if !ok {
+				buyerToTemplate.Mainmessage = append(buyerToTemplate.Mainmessage, "Unable to cancel order, it may already be collected!")
+				break
+			}
+			missing := orders.Restock(o)
+			refunded := o.PaymentID != 0 && payment.RefundPayment(o.PaymentID, o.Due()) == nil
+			email.SendCancellation(o, missing, refunded)
+			notify.OrderCancelled(o)
+			webhook.OrderCancelled(config.DB, o)
+			http.Redirect(w, req, "/buyer/orders", http.StatusSeeOther)
+			return
+		case "dispute":
+			reason := strings.TrimSpace(req.FormValue("reason"))
+			if reason == "" {
+				buyerToTemplate.Mainmessage = append(buyerToTemplate.Mainmessage, "Please tell us what went wrong with the order")
+				break
+			}
+			d, err := orders.OpenDispute(config.DB, id, user.Username, reason, time.Now())
+			if err != nil {
+				buyerToTemplate.Mainmessage = append(buyerToTemplate.Mainmessage, "Unable to dispute order, "+err.Error())
+				break
+			}
+			email.SendDisputeNotice(d)
+			notify.DisputeChanged(d)
+			http.Redirect(w, req, "/buyer/orders", http.StatusSeeOther)
+			return
+		}
+	}
+
+	buyerorders, ok := orders.GetOrders(config.DB, user.Username, false)
+	if !ok {
+		config.Error.Printf("Unable to get orders for buyer %s \n", user.Username)
+		buyerToTemplate.Mainmessage = append(buyerToTemplate.Mainmessage, "Unable to get orders")
+	}
+	disputes, ok := orders.GetDisputes(config.DB, user.Username, false)
+	if !ok {
+		config.Error.Printf("Unable to get disputes for buyer %s \n", user.Username)
+	}
+	buyerToTemplate.Orders = buyerorders
+	buyerToTemplate.Disputes = make(map[int64]orders.Dispute)
+	for _, d := range disputes {
+		buyerToTemplate.Disputes[d.OrderID] = d
+	}
+	config.TPL.ExecuteTemplate(w, "buyertemplate.gohtml", buyerToTemplate)
+}
+
+// This method computes the cost of each item in the cart and the total cost of the cart
+// Costs are added exactly in minor units, items in a different currency are logged and left out of the total
+func computeTotalCost(allitems []apiclient.ItemsDetails) (totalcost money.Money, costperitem []money.Money) {
+	totalcost = money.Zero(money.DefaultCurrency)
 	for _, item := range allitems {
-		temptotal = item.Cost * float64(item.Quantity)
-		costperitem = append(costperitem, fmt.Sprintf("%.2f", temptotal))
-		total = total + temptotal
+		itemcost, err := item.LineTotal()
+		if err != nil {
+			config.Error.Printf("Unable to work out cost of %s in cart : %v\n", item.Item, err)
+		}
+		costperitem = append(costperitem, itemcost)
+		if err != nil {
+			continue
+		}
+		total, err := totalcost.Add(itemcost)
+		if err != nil {
+			config.Error.Printf("Unable to add %s to cart total %s : %v\n", itemcost, totalcost, err)
+			continue
+		}
+		totalcost = total
 	}
-	return fmt.Sprintf("%.2f", total), costperitem
+	return totalcost, costperitem
 }
 
-func updateDB(isBuyer bool, allSellerItems []apiclient.ItemsDetails, oneCartItem apiclient.ItemsDetails) bool {
-	tempItem := apiclient.ItemsDetails{}
-	for _, item := range allSellerItems {
-		if item.Item == oneCartItem.Item && item.Username == oneCartItem.Username {
-			if item.Quantity > oneCartItem.Quantity {
-				tempItem.Quantity = item.Quantity - oneCartItem.Quantity
-				tempItem.Cost = item.Cost
-				tempItem.Item = item.Item
-				tempItem.Username = item.Username
-				ok := apiclient.UpdateItem(item.Item, item.Username, isBuyer, tempItem)
-				return ok
-			} else {
-				ok := apiclient.DeleteItem(item.Item, item.Username, isBuyer)
-				return ok
+// This method works out the discount of the promo code applied to the cart, and the amount payable
+// A code that can no longer be used, e.g. it has expired, is removed from the cart and the reason is shown
+// It returns true if the code has been removed
+func (b *buyerStruct) applyCoupon(allitems []apiclient.ItemsDetails) bool {
+	b.Payable = b.Totalcost
+	code := cartCoupon(b.Buyername)
+	if code == "" {
+		return false
+	}
+	applied, err := checkCoupon(code, b.Buyername, allitems)
+	if err == nil {
+		b.Payable, err = b.Totalcost.Sub(applied.Discount)
+	}
+	if err != nil {
+		setCartCoupon(b.Buyername, "")
+		b.Payable = b.Totalcost
+		b.Mainmessage = append(b.Mainmessage, "Promo code "+code+" has been removed, "+err.Error())
+		return true
+	}
+	b.Coupon = applied
+	return false
+}
 
-			}
+// This method works out the GST of the cart, per line and per seller, and the amount payable with GST
+func (b *buyerStruct) computeTax(allitems []apiclient.ItemsDetails) {
+	b.Inclusive = tax.Current.Inclusive
+	order, err := tax.Current.Compute(allitems, tax.RegisteredSellers(config.DB, allitems), b.Coupon)
+	if err != nil {
+		config.Error.Printf("Unable to work out GST of cart of %s : %v\n", b.Buyername, err)
+		return
+	}
+	b.Tax = order
+	b.Payable = order.Payable
+}
+
+// This method reads a promo code and checks that the buyer can use it on the items in the cart
+// It returns the discount, or an error with the reason the code cannot be used
+func checkCoupon(code, buyername string, allitems []apiclient.ItemsDetails) (coupon.Applied, error) {
+	c, ok := coupon.GetCoupon(config.DB, code)
+	if !ok {
+		return coupon.Applied{}, coupon.ErrNotFound
+	}
+	used, ok := coupon.CountRedemptions(config.DB, code, buyername)
+	if !ok {
+		return coupon.Applied{}, errors.New("please try again")
+	}
+	return c.Apply(allitems, used, time.Now())
+}
+
+// This method takes the quantity of a cart item from its lot in the seller API, at the price of the cart item
+// A listing that sells out is kept with no quantity, so the seller is told and can restock it
+// It returns false if not enough is left, e.g. another buyer checked out the same lot first.
+// When the price of the lot has changed, nothing is taken and the cart item is returned with the new price,
+// with true as third value.
+func updateDB(oneCartItem apiclient.ItemsDetails) (apiclient.ItemsDetails, bool, bool) {
+	return apiclient.TakeLot(oneCartItem)
+}
+
+// This method shows the items of the cart with their total cost, promo code and tax
+// It returns true if the promo code of the cart has been removed as it can no longer be used.
+func (b *buyerStruct) showCart(allitems []apiclient.ItemsDetails) bool {
+	b.Items = allitems
+	b.Totalcost, b.CostPerItem = computeTotalCost(allitems)
+	couponRemoved := b.applyCoupon(allitems)
+	b.computeTax(allitems)
+	return couponRemoved
+}
+
+// This function reads each lot in the cart again from the seller API, and gives the cart items the price of their lot
+// now, e.g. after the seller lowered or raised it. Items whose lot cannot be read are left as they are,
+// they are checked again when their quantity is taken.
+// It returns a message for the buyer for each item whose price per unit has changed.
+func repriceCart(buyerCartll *CartLinkedList) []string {
+	var changes []string
+	_, allitems := buyerCartll.GetAllItems()
+	for _, item := range allitems {
+		lot, ok := apiclient.GetLot(item.ID, item.Username, true)
+		if !ok {
+			continue
+		}
+		lot.Quantity = item.Quantity
+		if lot.AppliedCost() == item.AppliedCost() {
+			continue
+		}
+		_, index, err := buyerCartll.SearchID(item.ID)
+		if err == nil {
+			err = buyerCartll.WriteAtIndex(index, lot)
+		}
+		if err != nil {
+			config.Error.Println("Not able to update item in linked list")
+			config.Error.Println(err)
+			continue
+		}
+		changes = append(changes, priceChange(item, lot))
+	}
+	return changes
+}
+
+// This function returns a message telling the buyer the price per unit of a cart item has changed
+func priceChange(was, now apiclient.ItemsDetails) string {
+	return fmt.Sprintf("The price of %s from %s is now %s per %s, it was %s", now.Item, now.Username, now.AppliedCost(), now.UnitLabel(), was.AppliedCost())
+}
+
+// This function puts the quantities taken for a checkout that could not be completed back on sale,
+// and the items back in the cart of the buyer
+func undoCheckout(buyerCartll *CartLinkedList, taken []apiclient.ItemsDetails) {
+	for _, item := range taken {
+		line := orders.Line{LotID: item.ID, Item: item.Item, Quantity: item.Quantity, Unit: item.UnitLabel()}
+		if missing := orders.Restock(orders.Order{Seller: item.Username, Lines: []orders.Line{line}}); len(missing) != 0 {
+			config.Error.Printf("Unable to put %s of lot %d of seller %s back on sale\n", item.QuantityLabel(), item.ID, item.Username)
+		}
+		if err := buyerCartll.AddNode(item); err != nil {
+			config.Error.Println("Not able to add item back to linked list")
+			config.Error.Println(err)
 		}
 	}
-	return false
 }
 
-func removeCartItems(allSellerItems []apiclient.ItemsDetails) []apiclient.ItemsDetails {
-	tempItem := apiclient.ItemsDetails{}
+func removeCartItems(buyerCartll *CartLinkedList, allSellerItems []apiclient.ItemsDetails) []apiclient.ItemsDetails {
 	_, allcartitems := buyerCartll.GetAllItems()
 
+	incart := make(map[int64]float64)
 	for _, cartitem := range allcartitems {
-		for index, item := range allSellerItems {
-			if item.Item == cartitem.Item && item.Username == cartitem.Username {
-				if item.Quantity > cartitem.Quantity {
-					tempItem.Quantity = item.Quantity - cartitem.Quantity
-					tempItem.Cost = item.Cost
-					tempItem.Item = item.Item
-					tempItem.Username = item.Username
-					allSellerItems[index] = tempItem
-				} else {
-					allSellerItems = append(allSellerItems[0:index], allSellerItems[index+1:]...)
-				}
+		incart[cartitem.ID] = cartitem.Quantity
+	}
+
+	var available []apiclient.ItemsDetails
+	for _, item := range allSellerItems {
+		if item.Quantity > incart[item.ID] {
+			tempItem := item
+			tempItem.Quantity = apiclient.RoundQuantity(item.Quantity - incart[item.ID])
+			available = append(available, tempItem)
+		}
+	}
+	return available
+}
+
+// This method adds a listing to the shopping cart, or adds to its quantity if it is already in the cart
+// The product id sent by the template is "sellername/lotid", the listing is read from the seller API
+// so the cost and freshness cannot be changed by the browser.
+// It returns a message for the buyer if the item cannot be added, or an empty string if it has been added.
+func addToCart(buyerCartll *CartLinkedList, productid, newquantity string) string {
+	item, ok := lookupListing(productid)
+	if !ok || item.SoldOut() {
+		return "Item is no longer available!"
+	}
+	available := item.Quantity
+	item.Quantity = apiclient.RoundQuantity(config.ConvertToFloat(newquantity))
+	if !item.ValidOrder(item.Quantity) {
+		return fmt.Sprintf("Please order at least %s %s, in steps of %s %s", apiclient.FormatQuantity(item.MinOrder), item.UnitLabel(), apiclient.FormatQuantity(item.Step), item.UnitLabel())
+	}
+
+	// check if item exists in linked list
+	iteminll, index, err := buyerCartll.SearchID(item.ID)
+	if err != nil {
+		// item does not exist in linked list, can add as a new node
+		if item.Quantity > available {
+			return "Only " + apiclient.FormatQuantity(available) + " " + item.UnitLabel() + " available"
+		}
+		err = buyerCartll.AddNode(item)
+	} else {
+		// item exists, need to update the item
+		newitem := item
+		newitem.Quantity = apiclient.RoundQuantity(item.Quantity + iteminll.Quantity)
+		if newitem.Quantity > available {
+			return "Only " + apiclient.FormatQuantity(available) + " " + item.UnitLabel() + " available"
+		}
+		err = buyerCartll.WriteAtIndex(index, newitem)
+	}
+	if err != nil {
+		return "Server Error. Try again!"
+	}
+	return ""
+}
+
+// This method filters and orders the listings shown to the buyer by how fresh the peel is
+// sortby is "freshest" for most recently collected first, or "expiring" for the earliest best before date first
+// maxage keeps only listings collected within that many days, it is ignored when empty
+func sortByFreshness(items []apiclient.ItemsDetails, sortby, maxage string) []apiclient.ItemsDetails {
+	var filtered []apiclient.ItemsDetails
+	days := config.ConvertToInt(maxage)
+	for _, item := range items {
+		if maxage != "" && item.DaysSinceCollected() > days {
+			continue
+		}
+		filtered = append(filtered, item)
+	}
+
+	switch sortby {
+	case "freshest":
+		sort.SliceStable(filtered, func(i, j int) bool {
+			return filtered[i].CollectedOn.After(filtered[j].CollectedOn)
+		})
+	case "expiring":
+		sort.SliceStable(filtered, func(i, j int) bool {
+			return filtered[i].ExpiresAt.Before(filtered[j].ExpiresAt)
+		})
+	}
+	return filtered
+}
+
+// This method reads the filters and sort order of the list of all items from the url
+// Listings are near the location of the buyer when it is known, so each one shows how far away it is.
+// Filters that are not valid are left out. It returns a message for the buyer if any is not valid, or an empty string.
+func readListingFilter(req *http.Request, buyername string) (apiclient.ListingQuery, string) {
+	filter := apiclient.ListingQuery{
+		Item:   req.FormValue("item"),
+		Seller: strings.TrimSpace(req.FormValue("seller")),
+		Sort:   req.FormValue("sortby"),
+		MaxAge: config.ConvertToInt(req.FormValue("maxage")),
+		Limit:  listingsPageSize,
+		Cursor: req.FormValue("cursor"),
+	}
+	if filter.Sort == "" {
+		filter.Sort = apiclient.SortNewest
+	}
+
+	msg := ""
+	for name, price := range map[string]*money.Money{"minprice": &filter.MinPrice, "maxprice": &filter.MaxPrice} {
+		if s := strings.TrimSpace(req.FormValue(name)); s != "" {
+			amount, err := money.Parse(s, money.DefaultCurrency)
+			if err != nil || amount.IsNegative() {
+				msg = "Please enter prices such as 1.50"
 				continue
 			}
+			*price = amount
+		}
+	}
+	if s := strings.TrimSpace(req.FormValue("minquantity")); s != "" {
+		q, err := strconv.ParseFloat(s, 64)
+		if err != nil || q < 0 {
+			msg = "Please enter a lowest quantity such as 5"
+		} else {
+			filter.MinQuantity = q
+		}
+	}
+	if s := strings.TrimSpace(req.FormValue("maxdistance")); s != "" {
+		d, err := strconv.ParseFloat(s, 64)
+		if err != nil || d < 0 {
+			msg = "Please enter a distance such as 5"
+		} else {
+			filter.MaxDistance = d
+		}
+	}
+
+	if p, ok := location.OfUser(buyername); ok {
+		filter.Near = &p
+	} else if filter.MaxDistance > 0 || filter.Sort == apiclient.SortDistance {
+		msg = "Add a postal code to your address to search by distance"
+		filter.MaxDistance = 0
+		filter.Sort = apiclient.SortNewest
+		filter.Cursor = ""
+	}
+	return filter, msg
+}
+
+// This method returns the url of the list of all items with the same filters, starting from a page
+func pageURL(req *http.Request, cursor string) string {
+	v := req.URL.Query()
+	v.Del("cursor")
+	if cursor != "" {
+		v.Set("cursor", cursor)
+	}
+	return "/buyer?" + v.Encode()
+}
+
+// This method returns the listings matching a search of the buyer, with their distance when it is known
+// The distance to each seller is worked out from the postal codes of their addresses. It returns false if the search
+// has a distance but the location of the buyer is not known, in which case no listing matches.
+func matchSearch(items []apiclient.ItemsDetails, search savedsearch.SavedSearch) ([]apiclient.ItemsDetails, bool) {
+	var matched []apiclient.ItemsDetails
+	buyer, buyerKnown := location.OfUser(search.Buyer)
+	sellers := make(map[string]location.Point)
+	for _, item := range items {
+		distance, known := 0.0, false
+		if buyerKnown {
+			seller, ok := sellers[item.Username]
+			if !ok {
+				if seller, ok = location.OfUser(item.Username); ok {
+					sellers[item.Username] = seller
+				}
+			}
+			if ok {
+				distance, known = location.DistanceKm(buyer, seller), true
+				item.Distance = &distance
+			}
+		}
+		if search.Matches(item, distance, known) {
+			matched = append(matched, item)
 		}
 	}
-	return allSellerItems
+	return matched, buyerKnown || search.MaxDistance == 0
 }
 
-func convStringtoSlice(iteminput string) apiclient.ItemsDetails {
-	iteminput = strings.Replace(iteminput, "{", "", -1)
-	iteminput = strings.Replace(iteminput, "}", "", -1)
-	itemslice := strings.Split(iteminput, " ")
-	item := apiclient.ItemsDetails{}
-	item.Item = itemslice[0]
-	item.Quantity = config.ConvertToInt(itemslice[1])
-	item.Cost = config.ConvertToFloat(itemslice[2])
-	item.Username = itemslice[3]
-	return item
+// This method reads the listing for a product id of the form "sellername/lotid" from the seller API
+func lookupListing(productid string) (apiclient.ItemsDetails, bool) {
+	parts := strings.SplitN(productid, "/", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return apiclient.ItemsDetails{}, false
+	}
+	id, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return apiclient.ItemsDetails{}, false
+	}
+	return apiclient.GetLot(id, parts[0], true)
 }
@@ -235,6 +235,24 @@ func (c *CartLinkedList) SearchItemandSellerName(itemname, sellername string) (a
 	return item, -1, errors.New("Item not found in list")
 }
 
+// This is a method for linked list struct
+// It is used to search for one lot in the linked list, a seller can have several lots of the same item
+// It returns the item, the index at which the item is found, and any errors if present
+func (c *CartLinkedList) SearchID(id int64) (apiclient.ItemsDetails, int, error) {
+	item := apiclient.ItemsDetails{}
+	if c.Head == nil {
+		return item, -1, errors.New("Empty Linked list!")
+	}
+	currentNode := c.Head
+	for index := 1; index <= c.Size; index++ {
+		if currentNode.Item.ID == id {
+			return currentNode.Item, index, nil
+		}
+		currentNode = currentNode.Next
+	}
+	return item, -1, errors.New("Item not found in list")
+}
+
 // This is a method for linked list struct
 // It is used to write Item data at a specified index in the linked list
 // It takes in an index of type int, and thisItem of type ItemDetails
@@ -12,10 +12,53 @@ const (
 	// Time to clean MapSessions 120 seconds = 2 minutes
 	CleanSessionTime int = 120
 
+	// Number of days an account stays recoverable after the user asks to delete it
+	AccountDeletionGraceDays int = 7
+
+	// Time to check for accounts to purge 3600 seconds = 1 hour
+	PurgeAccountsTime int = 3600
+
+	// Time to check for new events about listings from Seller API 60 seconds = 1 minute
+	EventPollTime int = 60
+
+	// Time to check the email outbox for emails to send 30 seconds
+	OutboxPollTime int = 30
+
+	// Wait before sending a failed email again 60 seconds = 1 minute, doubled after each failure up to OutboxMaxRetryTime
+	OutboxRetryTime int = 60
+
+	// Longest wait between attempts to send an email 21600 seconds = 6 hours
+	OutboxMaxRetryTime int = 21600
+
+	// Number of failed attempts after which an email is given up, until an admin sends it again
+	OutboxMaxAttempts int = 10
+
+	// Number of days sent emails are kept in the outbox, for admins to send them again
+	OutboxKeepDays int = 30
+
+	// Time to check for webhook deliveries to post to sellers' systems 15 seconds
+	WebhookPollTime int = 15
+
+	// Wait before posting a failed webhook delivery again 30 seconds, doubled after each failure up to WebhookMaxRetryTime
+	WebhookRetryTime int = 30
+
+	// Longest wait between attempts to post a webhook delivery 3600 seconds = 1 hour
+	WebhookMaxRetryTime int = 3600
+
+	// Number of failed attempts after which a webhook delivery is given up, until the seller sends it again
+	WebhookMaxAttempts int = 8
+
 	// Directory that stores self generated cerificate.
 	CertPath = "./cert/"
 
 	// Other directory Paths
-	FilePath = "./"
-	LogPath  = "./log/"
+	FilePath  = "./"
+	LogPath   = "./log/"
+	PhotoPath = "./photos/"
+
+	// Largest listing photo a seller can upload 5 MB
+	MaxPhotoSize int64 = 5 << 20
+
+	// Width and height in pixels that listing photo thumbnails fit in
+	ThumbnailSize int = 200
 )
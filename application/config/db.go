@@ -15,15 +15,19 @@ var (
 func init() {
 	//dataSourceName := fmt.Sprintf("%s:%s@tcp(%s)/%s",
 	//	"user", "password", "127.0.0.1:3306", "coolname_db")
-	DB, err = sql.Open("mysql", "root:password@tcp(localhost:33062)/recycle_db")
+	DB, err = sql.Open("mysql", "root:password@tcp(localhost:33062)/recycle_db?parseTime=true")
 
 	if err != nil {
 		panic(err.Error())
 	}
+}
+
+// This function checks that the database can be reached, it panics if it cannot
+// The connection is only made when the application starts, so packages can be tested without a database
+func connectDB() {
 	if err = DB.Ping(); err != nil {
 		panic(err)
 	}
 
 	log.Println(" Connected to Database ")
-
 }
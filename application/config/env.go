@@ -0,0 +1,29 @@
+package config
+
+import (
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// This function reads a rate given in percent, e.g. "9" or "8.5", from the environment in basis points (1/100 of a percent)
+// rate is returned if the variable is not set, or is not a percentage from 0 to 100
+func RateFromEnv(name string, rate int64) int64 {
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return rate
+	}
+	percent, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil || percent < 0 || percent > 100 {
+		log.Printf("%s %q is not a percentage, using %d basis points\n", name, value, rate)
+		return rate
+	}
+	return int64(math.Round(percent * 100))
+}
+
+// This function returns a rate in basis points as text, e.g. "8.5%"
+func RateLabel(rate int64) string {
+	return strconv.FormatFloat(float64(rate)/100, 'f', -1, 64) + "%"
+}
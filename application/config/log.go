@@ -16,12 +16,6 @@ var (
 )
 
 func init() {
-	// Create log file for writing Errors
-	sfile, err := os.OpenFile(LogPath+"srvlog", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
-	if err != nil {
-		log.Fatalln("Failed to open server error log file:", err)
-	}
-
 	// Create loggers for logging Trace, Info, Warning and Error messages
 	Trace = log.New(ioutil.Discard,
 		"TRACE: ",
@@ -35,7 +29,18 @@ func init() {
 		"WARNING: ",
 		log.Ldate|log.Ltime|log.Lshortfile)
 
-	Error = log.New(io.MultiWriter(sfile, os.Stderr),
+	Error = log.New(os.Stderr,
 		"ERROR: ",
 		log.Ldate|log.Ltime|log.Lshortfile)
 }
+
+// This function opens the server error log file, errors are written to it as well as to standard error from then on
+// The file is only opened when the application starts, so packages can be tested without the log directory
+func openLogFile() {
+	// Create log file for writing Errors
+	sfile, err := os.OpenFile(LogPath+"srvlog", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+	if err != nil {
+		log.Fatalln("Failed to open server error log file:", err)
+	}
+	Error.SetOutput(io.MultiWriter(sfile, os.Stderr))
+}
@@ -0,0 +1,10 @@
+package config
+
+// This function connects to the database, opens the error log file and parses the templates of the pages
+// It is called when the application starts, instead of when the package is loaded, so the packages using config
+// can be tested without a database or the files of the application.
+func Setup() {
+	openLogFile()
+	connectDB()
+	parseTemplates()
+}
@@ -6,6 +6,7 @@ var (
 	TPL *template.Template
 )
 
-func init() {
+// This function parses the templates of the pages, it panics if any template is not valid
+func parseTemplates() {
 	TPL = template.Must(template.ParseGlob("templates/*"))
 }
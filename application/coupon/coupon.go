@@ -0,0 +1,232 @@
+// Package coupon handles promo codes given at checkout.
+// Admins create platform-wide codes for all listings, sellers create codes for their own listings only.
+// A code takes a percentage or a fixed amount off the cart, and can be limited by dates, uses and a minimum order.
+package coupon
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"projectGoLive/application/apiclient"
+	"projectGoLive/application/config"
+	"projectGoLive/application/money"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Kinds of discount
+const (
+	KindPercent = "percent"
+	KindFixed   = "fixed"
+)
+
+// Layout of the dates sent by the date inputs of the promo code form
+const dateFormat = "2006-01-02"
+
+// Codes are stored in upper case, made of letters, digits and dashes
+var codeMatch = regexp.MustCompile(`^[A-Z0-9-]{3,20}$`)
+
+// Reasons a promo code cannot be used, the messages are shown to the buyer
+var (
+	ErrNotFound   = errors.New("this promo code does not exist")
+	ErrNotStarted = errors.New("this promo code cannot be used yet")
+	ErrExpired    = errors.New("this promo code has expired")
+	ErrUsedUp     = errors.New("this promo code has been fully used")
+	ErrUsedByYou  = errors.New("you have already used this promo code")
+	ErrNoItems    = errors.New("this promo code does not apply to any item in your cart")
+	ErrMinOrder   = errors.New("your cart is below the minimum order for this promo code")
+)
+
+// Data structure for each promo code
+// Seller is empty for platform-wide codes, otherwise the code only applies to the listings of that seller.
+// Percent is used for KindPercent and Amount for KindFixed, MaxUses and PerBuyer are 0 when there is no limit.
+type Coupon struct {
+	Code       string
+	Seller     string
+	Kind       string
+	Percent    int64
+	Amount     money.Money
+	MinOrder   money.Money
+	ValidFrom  time.Time
+	ValidUntil time.Time
+	MaxUses    int
+	PerBuyer   int
+	Uses       int
+}
+
+// Data structure for a promo code applied to a cart, the zero value means no code is applied
+type Applied struct {
+	Code     string
+	Seller   string
+	Label    string
+	Discount money.Money
+}
+
+// This method returns a short description of the discount, e.g. "10% off items from seller1"
+func (c Coupon) Label() string {
+	label := c.Amount.String() + " off"
+	if c.Kind == KindPercent {
+		label = fmt.Sprintf("%d%% off", c.Percent)
+	}
+	if c.Seller != "" {
+		label = label + " items from " + c.Seller
+	}
+	if c.MinOrder.Amount > 0 {
+		label = label + ", minimum order " + c.MinOrder.String()
+	}
+	return label
+}
+
+// This method returns true if the code applies to a listing
+func (c Coupon) Eligible(item apiclient.ItemsDetails) bool {
+	return c.Seller == "" || c.Seller == item.Username
+}
+
+// This method returns the text shown for the number of uses, e.g. "3 of 100"
+func (c Coupon) UsesLabel() string {
+	if c.MaxUses == 0 {
+		return fmt.Sprintf("%d of unlimited", c.Uses)
+	}
+	return fmt.Sprintf("%d of %d", c.Uses, c.MaxUses)
+}
+
+// This method checks that the code can be used by a buyer who has already used it usedByBuyer times,
+// and works out the discount on the items in the cart.
+// The discount is taken off the items the code applies to, and is never more than their cost.
+func (c Coupon) Apply(items []apiclient.ItemsDetails, usedByBuyer int, now time.Time) (Applied, error) {
+	if now.Before(c.ValidFrom) {
+		return Applied{}, ErrNotStarted
+	}
+	if !c.ValidUntil.IsZero() && now.After(c.ValidUntil) {
+		return Applied{}, ErrExpired
+	}
+	if err := c.checkUses(usedByBuyer); err != nil {
+		return Applied{}, err
+	}
+
+	subtotal := money.Money{}
+	eligible := false
+	for _, item := range items {
+		if !c.Eligible(item) {
+			continue
+		}
+		total, err := item.LineTotal()
+		if err != nil {
+			return Applied{}, err
+		}
+		sum, err := subtotal.Add(total)
+		if err != nil {
+			return Applied{}, err
+		}
+		subtotal = sum
+		eligible = true
+	}
+	if !eligible {
+		return Applied{}, ErrNoItems
+	}
+	if c.MinOrder.Amount > 0 {
+		if c.MinOrder.Currency != subtotal.Currency {
+			return Applied{}, money.ErrCurrencyMismatch
+		}
+		if subtotal.Amount < c.MinOrder.Amount {
+			return Applied{}, ErrMinOrder
+		}
+	}
+
+	discount := subtotal.Percent(c.Percent)
+	if c.Kind == KindFixed {
+		if c.Amount.Currency != subtotal.Currency {
+			return Applied{}, money.ErrCurrencyMismatch
+		}
+		discount = c.Amount
+		if discount.Amount > subtotal.Amount {
+			discount = subtotal
+		}
+	}
+	return Applied{Code: c.Code, Seller: c.Seller, Label: c.Label(), Discount: discount}, nil
+}
+
+// This method checks that the code has uses left for a buyer who has already used it usedByBuyer times
+// It is checked when the code is applied to the cart, and again when the use is recorded at checkout
+func (c Coupon) checkUses(usedByBuyer int) error {
+	if c.MaxUses > 0 && c.Uses >= c.MaxUses {
+		return ErrUsedUp
+	}
+	if c.PerBuyer > 0 && usedByBuyer >= c.PerBuyer {
+		return ErrUsedByYou
+	}
+	return nil
+}
+
+// This function cleans up a code typed by a user, codes are not case sensitive
+func Normalize(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// This function reads a new promo code from the form shared by the admin and seller pages
+// seller is empty for a platform-wide code made by an admin.
+// It returns a message for the user if the form is not valid, or an empty string.
+func ReadForm(req *http.Request, seller string) (Coupon, string) {
+	c := Coupon{
+		Code:     Normalize(req.FormValue("code")),
+		Seller:   seller,
+		Kind:     req.FormValue("kind"),
+		MaxUses:  config.ConvertToInt(req.FormValue("maxuses")),
+		PerBuyer: config.ConvertToInt(req.FormValue("perbuyer")),
+	}
+	if !codeMatch.MatchString(c.Code) {
+		return c, "Please enter a code of 3 to 20 letters, digits or dashes"
+	}
+
+	value := strings.TrimSpace(req.FormValue("value"))
+	switch c.Kind {
+	case KindPercent:
+		c.Percent = int64(config.ConvertToInt(value))
+		if c.Percent < 1 || c.Percent > 100 {
+			return c, "Please enter a percentage from 1 to 100"
+		}
+	case KindFixed:
+		amount, err := money.Parse(value, money.DefaultCurrency)
+		if err != nil || amount.Amount <= 0 {
+			return c, "Please enter an amount off such as 2.50"
+		}
+		c.Amount = amount
+	default:
+		return c, "Please choose a percentage or a fixed amount off"
+	}
+
+	c.MinOrder = money.Zero(money.DefaultCurrency)
+	if minorder := strings.TrimSpace(req.FormValue("minorder")); minorder != "" {
+		amount, err := money.Parse(minorder, money.DefaultCurrency)
+		if err != nil || amount.IsNegative() {
+			return c, "Please enter a minimum order such as 10.00"
+		}
+		c.MinOrder = amount
+	}
+
+	if c.MaxUses < 0 || c.PerBuyer < 0 {
+		return c, "Usage limits cannot be negative, leave them at 0 for no limit"
+	}
+
+	// The code can be used from the start of its first day until the end of its last day
+	c.ValidFrom = time.Now()
+	if validfrom := req.FormValue("validfrom"); validfrom != "" {
+		date, err := time.ParseInLocation(dateFormat, validfrom, time.Local)
+		if err != nil {
+			return c, "Please enter a valid start date"
+		}
+		c.ValidFrom = date
+	}
+	if validuntil := req.FormValue("validuntil"); validuntil != "" {
+		date, err := time.ParseInLocation(dateFormat, validuntil, time.Local)
+		if err != nil {
+			return c, "Please enter a valid end date"
+		}
+		c.ValidUntil = date.AddDate(0, 0, 1).Add(-time.Second)
+		if !c.ValidUntil.After(c.ValidFrom) {
+			return c, "The end date must be after the start date"
+		}
+	}
+	return c, ""
+}
@@ -0,0 +1,148 @@
+package coupon
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"projectGoLive/application/apiclient"
+	"projectGoLive/application/money"
+	"strings"
+	"testing"
+	"time"
+)
+
+func sgd(amount int64) money.Money {
+	return money.Money{Amount: amount, Currency: "SGD"}
+}
+
+func TestApply(t *testing.T) {
+	now := time.Date(2021, 6, 15, 12, 0, 0, 0, time.UTC)
+	cart := []apiclient.ItemsDetails{
+		{Item: "Orange Peel", Quantity: 2, Cost: sgd(500), Username: "seller1"},
+		{Item: "Lemon Peel", Quantity: 1.5, Cost: sgd(200), Username: "seller2"},
+	}
+	tests := []struct {
+		name        string
+		coupon      Coupon
+		items       []apiclient.ItemsDetails
+		usedByBuyer int
+		want        money.Money
+		wantErr     error
+	}{
+		{"percent off whole cart", Coupon{Code: "TEN", Kind: KindPercent, Percent: 10}, cart, 0, sgd(130), nil},
+		{"percent off one seller", Coupon{Code: "S1", Seller: "seller1", Kind: KindPercent, Percent: 15}, cart, 0, sgd(150), nil},
+		{"fixed amount", Coupon{Code: "TWO", Kind: KindFixed, Amount: sgd(200)}, cart, 0, sgd(200), nil},
+		{"fixed amount capped at eligible cost", Coupon{Code: "BIG", Seller: "seller2", Kind: KindFixed, Amount: sgd(1000)}, cart, 0, sgd(300), nil},
+		{"minimum order reached", Coupon{Code: "MIN", Kind: KindPercent, Percent: 10, MinOrder: sgd(1300)}, cart, 0, sgd(130), nil},
+		{"below minimum order", Coupon{Code: "MIN", Kind: KindPercent, Percent: 10, MinOrder: sgd(1301)}, cart, 0, money.Money{}, ErrMinOrder},
+		{"not started", Coupon{Code: "SOON", Kind: KindPercent, Percent: 10, ValidFrom: now.Add(time.Hour)}, cart, 0, money.Money{}, ErrNotStarted},
+		{"expired", Coupon{Code: "OLD", Kind: KindPercent, Percent: 10, ValidUntil: now.Add(-time.Second)}, cart, 0, money.Money{}, ErrExpired},
+		{"no end date", Coupon{Code: "OPEN", Kind: KindPercent, Percent: 10, ValidFrom: now.AddDate(-1, 0, 0)}, cart, 0, sgd(130), nil},
+		{"used up", Coupon{Code: "LAST", Kind: KindPercent, Percent: 10, MaxUses: 5, Uses: 5}, cart, 0, money.Money{}, ErrUsedUp},
+		{"uses left", Coupon{Code: "LAST", Kind: KindPercent, Percent: 10, MaxUses: 5, Uses: 4}, cart, 0, sgd(130), nil},
+		{"used by buyer", Coupon{Code: "ONCE", Kind: KindPercent, Percent: 10, PerBuyer: 1}, cart, 1, money.Money{}, ErrUsedByYou},
+		{"no limit per buyer", Coupon{Code: "MANY", Kind: KindPercent, Percent: 10}, cart, 3, sgd(130), nil},
+		{"no eligible items", Coupon{Code: "S3", Seller: "seller3", Kind: KindPercent, Percent: 10}, cart, 0, money.Money{}, ErrNoItems},
+		{"empty cart", Coupon{Code: "TEN", Kind: KindPercent, Percent: 10}, nil, 0, money.Money{}, ErrNoItems},
+		{"fixed amount in other currency", Coupon{Code: "MYR", Kind: KindFixed, Amount: money.Money{Amount: 200, Currency: "MYR"}}, cart, 0, money.Money{}, money.ErrCurrencyMismatch},
+		{"cart in mixed currencies", Coupon{Code: "TEN", Kind: KindPercent, Percent: 10}, append([]apiclient.ItemsDetails{{Quantity: 1, Cost: money.Money{Amount: 100, Currency: "MYR"}}}, cart...), 0, money.Money{}, money.ErrCurrencyMismatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.coupon.Apply(tt.items, tt.usedByBuyer, now)
+			if err != tt.wantErr {
+				t.Fatalf("Apply() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got.Discount != tt.want {
+				t.Errorf("Apply() discount = %v, want %v", got.Discount, tt.want)
+			}
+			if got.Code != tt.coupon.Code || got.Seller != tt.coupon.Seller {
+				t.Errorf("Apply() = %+v, want code %q of seller %q", got, tt.coupon.Code, tt.coupon.Seller)
+			}
+		})
+	}
+}
+
+func TestCheckUses(t *testing.T) {
+	tests := []struct {
+		name        string
+		coupon      Coupon
+		usedByBuyer int
+		want        error
+	}{
+		{"no limits", Coupon{Uses: 1000}, 50, nil},
+		{"last use left", Coupon{MaxUses: 3, Uses: 2}, 0, nil},
+		{"all uses taken", Coupon{MaxUses: 3, Uses: 3}, 0, ErrUsedUp},
+		{"more uses than limit", Coupon{MaxUses: 3, Uses: 4}, 0, ErrUsedUp},
+		{"buyer has a use left", Coupon{PerBuyer: 2}, 1, nil},
+		{"buyer used all their uses", Coupon{PerBuyer: 2}, 2, ErrUsedByYou},
+		{"used up before used by buyer", Coupon{MaxUses: 1, Uses: 1, PerBuyer: 1}, 1, ErrUsedUp},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.coupon.checkUses(tt.usedByBuyer); got != tt.want {
+				t.Errorf("checkUses(%d) = %v, want %v", tt.usedByBuyer, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadForm(t *testing.T) {
+	tests := []struct {
+		name    string
+		form    url.Values
+		wantMsg string
+		check   func(c Coupon) bool
+	}{
+		{"percent code", url.Values{"code": {" spring-10 "}, "kind": {"percent"}, "value": {"10"}},
+			"", func(c Coupon) bool { return c.Code == "SPRING-10" && c.Percent == 10 && c.MinOrder == sgd(0) }},
+		{"fixed code with minimum order", url.Values{"code": {"TWO"}, "kind": {"fixed"}, "value": {"2.50"}, "minorder": {"10"}},
+			"", func(c Coupon) bool { return c.Amount == sgd(250) && c.MinOrder == sgd(1000) }},
+		{"last day included", url.Values{"code": {"DAY"}, "kind": {"percent"}, "value": {"5"}, "validfrom": {"2021-06-01"}, "validuntil": {"2021-06-01"}},
+			"", func(c Coupon) bool { return c.ValidUntil.Sub(c.ValidFrom) == 24*time.Hour-time.Second }},
+		{"code too short", url.Values{"code": {"AB"}, "kind": {"percent"}, "value": {"10"}}, "code", nil},
+		{"percentage over 100", url.Values{"code": {"ALL"}, "kind": {"percent"}, "value": {"101"}}, "percentage", nil},
+		{"amount with too many places", url.Values{"code": {"TWO"}, "kind": {"fixed"}, "value": {"2.505"}}, "amount", nil},
+		{"no kind", url.Values{"code": {"TWO"}, "value": {"2"}}, "percentage or a fixed amount", nil},
+		{"negative minimum order", url.Values{"code": {"TWO"}, "kind": {"fixed"}, "value": {"2"}, "minorder": {"-1"}}, "minimum order", nil},
+		{"negative limit", url.Values{"code": {"TWO"}, "kind": {"fixed"}, "value": {"2"}, "maxuses": {"-1"}}, "negative", nil},
+		{"end before start", url.Values{"code": {"DAY"}, "kind": {"percent"}, "value": {"5"}, "validfrom": {"2021-06-02"}, "validuntil": {"2021-06-01"}}, "end date", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/coupons", strings.NewReader(tt.form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			c, msg := ReadForm(req, "")
+			if tt.wantMsg == "" {
+				if msg != "" {
+					t.Fatalf("ReadForm() message = %q, want none", msg)
+				}
+				if !tt.check(c) {
+					t.Errorf("ReadForm() = %+v", c)
+				}
+			} else if !strings.Contains(msg, tt.wantMsg) {
+				t.Errorf("ReadForm() message = %q, want it to mention %q", msg, tt.wantMsg)
+			}
+		})
+	}
+}
+
+func TestIsReason(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{ErrNotFound, true},
+		{ErrUsedUp, true},
+		{ErrUsedByYou, true},
+		{ErrExpired, false},
+		{money.ErrCurrencyMismatch, false},
+	}
+	for _, tt := range tests {
+		if got := IsReason(tt.err); got != tt.want {
+			t.Errorf("IsReason(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
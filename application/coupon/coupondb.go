@@ -0,0 +1,215 @@
+package coupon
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+)
+
+// Columns read for each promo code, in the order used by scanCoupon
+const couponColumns = "Code, Seller, Kind, Percent, Amount, MinOrder, Currency, ValidFrom, ValidUntil, MaxUses, PerBuyer, Uses"
+
+// Interface satisfied by *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// Function to read one promo code from a row selected with couponColumns.
+func scanCoupon(row scanner) (Coupon, error) {
+	var c Coupon
+	var currency string
+	var validUntil sql.NullTime
+	err := row.Scan(&c.Code, &c.Seller, &c.Kind, &c.Percent, &c.Amount.Amount, &c.MinOrder.Amount, &currency,
+		&c.ValidFrom, &validUntil, &c.MaxUses, &c.PerBuyer, &c.Uses)
+	c.Amount.Currency = currency
+	c.MinOrder.Currency = currency
+	if validUntil.Valid {
+		c.ValidUntil = validUntil.Time
+	}
+	return c, err
+}
+
+//-----------------------------------------------------------------------
+// Functions for promo codes
+//-----------------------------------------------------------------------
+// Function to add a promo code in the MYSQL database.
+// It returns false when there is any error encountered, e.g. the code already exists.
+func InsertCoupon(db *sql.DB, c Coupon) bool {
+	currency := c.MinOrder.Currency
+	if c.Kind == KindFixed {
+		currency = c.Amount.Currency
+	}
+	var validUntil sql.NullTime
+	if !c.ValidUntil.IsZero() {
+		validUntil = sql.NullTime{Time: c.ValidUntil, Valid: true}
+	}
+	_, err := db.Exec("INSERT INTO `coupons` ("+couponColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, 0)",
+		c.Code, c.Seller, c.Kind, c.Percent, c.Amount.Amount, c.MinOrder.Amount, currency,
+		c.ValidFrom, validUntil, c.MaxUses, c.PerBuyer)
+	if err != nil {
+		log.Println("Unable to insert the promo code")
+		log.Println(err)
+		return false
+	}
+	return true
+}
+
+// Function to get a promo code from the MYSQL database.
+// It returns false when the code does not exist or there is any error encountered.
+func GetCoupon(db *sql.DB, code string) (Coupon, bool) {
+	c, err := scanCoupon(db.QueryRow("SELECT "+couponColumns+" FROM `coupons` WHERE Code=?", code))
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Println("Unable to get the promo code")
+			log.Println(err)
+		}
+		return c, false
+	}
+	return c, true
+}
+
+// Function to get the promo codes of a seller from the MYSQL database, newest first.
+// all returns the codes of every seller and the platform-wide codes, it is used by admins.
+// It returns false when there is any error encountered and retrieval of codes is not successful.
+func GetCoupons(db *sql.DB, seller string, all bool) ([]Coupon, bool) {
+	var coupons []Coupon
+	var results *sql.Rows
+	var err error
+	if all {
+		results, err = db.Query("SELECT " + couponColumns + " FROM `coupons` ORDER BY ValidFrom DESC")
+	} else {
+		results, err = db.Query("SELECT "+couponColumns+" FROM `coupons` WHERE Seller=? ORDER BY ValidFrom DESC", seller)
+	}
+	if err != nil {
+		log.Println("Not able to get promo codes")
+		log.Println(err)
+		return coupons, false
+	}
+	defer results.Close()
+
+	for results.Next() {
+		c, err := scanCoupon(results)
+		if err != nil {
+			log.Println("Unable to get promo codes")
+			log.Println(err)
+			return coupons, false
+		}
+		coupons = append(coupons, c)
+	}
+	return coupons, true
+}
+
+// Function to delete a promo code from the MYSQL database, along with the record of its uses.
+// seller must be the owner of the code, or all must be true for admins.
+// It returns true only when the code existed and has been deleted.
+func DeleteCoupon(db *sql.DB, code, seller string, all bool) bool {
+	var result sql.Result
+	var err error
+	if all {
+		result, err = db.Exec("DELETE FROM `coupons` WHERE Code=?", code)
+	} else {
+		result, err = db.Exec("DELETE FROM `coupons` WHERE Code=? AND Seller=?", code, seller)
+	}
+	if err != nil {
+		log.Println("Unable to delete the promo code")
+		log.Println(err)
+		return false
+	}
+	n, _ := result.RowsAffected()
+	return n == 1
+}
+
+// Function to count how many times a buyer has used a promo code.
+// It returns false when there is any error encountered.
+func CountRedemptions(db *sql.DB, code, buyer string) (int, bool) {
+	var n int
+	err := db.QueryRow("SELECT COUNT(*) FROM `couponredemptions` WHERE Code=? AND Buyer=?", code, buyer).Scan(&n)
+	if err != nil {
+		log.Println("Unable to count uses of the promo code")
+		log.Println(err)
+		return 0, false
+	}
+	return n, true
+}
+
+// Function to record that a buyer used a promo code at checkout.
+// The code is locked while its limits are checked again, so two buyers cannot take its last use.
+// It returns the ID of the record, used by Release, or an error if the use is not recorded.
+// The error is one from this package when the code can no longer be used, see IsReason.
+func Redeem(db *sql.DB, a Applied, buyer string, now time.Time) (int64, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		log.Println(err)
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	c, err := scanCoupon(tx.QueryRow("SELECT "+couponColumns+" FROM `coupons` WHERE Code=? FOR UPDATE", a.Code))
+	if err == sql.ErrNoRows {
+		return 0, ErrNotFound
+	}
+	if err != nil {
+		log.Println("Unable to get the promo code")
+		log.Println(err)
+		return 0, err
+	}
+	var used int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM `couponredemptions` WHERE Code=? AND Buyer=?", a.Code, buyer).Scan(&used); err != nil {
+		log.Println(err)
+		return 0, err
+	}
+	if err := c.checkUses(used); err != nil {
+		return 0, err
+	}
+
+	result, err := tx.Exec("INSERT INTO `couponredemptions` (Code, Buyer, Discount, Currency, RedeemedAt) VALUES (?, ?, ?, ?, ?)",
+		a.Code, buyer, a.Discount.Amount, a.Discount.Currency, now)
+	if err != nil {
+		log.Println("Unable to record use of the promo code")
+		log.Println(err)
+		return 0, err
+	}
+	if _, err := tx.Exec("UPDATE `coupons` SET Uses=Uses+1 WHERE Code=?", a.Code); err != nil {
+		log.Println(err)
+		return 0, err
+	}
+	id, _ := result.LastInsertId()
+	if err := tx.Commit(); err != nil {
+		log.Println(err)
+		return 0, err
+	}
+	return id, nil
+}
+
+// Function to undo a use of a promo code recorded by Redeem, when the checkout could not be completed.
+// It returns false when there is any error encountered.
+func Release(db *sql.DB, redemptionID int64) bool {
+	tx, err := db.Begin()
+	if err != nil {
+		log.Println(err)
+		return false
+	}
+	defer tx.Rollback()
+
+	var code string
+	if err := tx.QueryRow("SELECT Code FROM `couponredemptions` WHERE ID=? FOR UPDATE", redemptionID).Scan(&code); err != nil {
+		log.Println("Unable to find use of the promo code")
+		log.Println(err)
+		return false
+	}
+	if _, err := tx.Exec("DELETE FROM `couponredemptions` WHERE ID=?", redemptionID); err != nil {
+		log.Println(err)
+		return false
+	}
+	if _, err := tx.Exec("UPDATE `coupons` SET Uses=Uses-1 WHERE Code=? AND Uses>0", code); err != nil {
+		log.Println(err)
+		return false
+	}
+	return tx.Commit() == nil
+}
+
+// This function returns true if an error from Redeem is a reason to show the buyer, not a database error
+func IsReason(err error) bool {
+	return errors.Is(err, ErrNotFound) || errors.Is(err, ErrUsedUp) || errors.Is(err, ErrUsedByYou)
+}
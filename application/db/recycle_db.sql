@@ -0,0 +1,278 @@
+-- Schema for recycle_db, the database storing user information for the main application.
+-- Statements are listed in the order the tables were introduced, run them on a fresh database
+-- or run only the newer statements on an existing database.
+
+CREATE DATABASE IF NOT EXISTS recycle_db;
+USE recycle_db;
+
+-- All registered buyers and sellers
+CREATE TABLE IF NOT EXISTS userdetails (
+    Username VARCHAR(30) NOT NULL PRIMARY KEY,
+    Password VARCHAR(256) NOT NULL,
+    Fullname VARCHAR(100) NOT NULL,
+    Isbuyer BOOLEAN NOT NULL,
+    Phone VARCHAR(8) NOT NULL,
+    Address VARCHAR(256) NOT NULL,
+    Email VARCHAR(100) NOT NULL
+);
+
+-- Accounts waiting to be deleted once the grace period is over
+CREATE TABLE IF NOT EXISTS accountdeletions (
+    Username VARCHAR(30) NOT NULL PRIMARY KEY,
+    RequestedAt DATETIME NOT NULL
+);
+
+-- A user can be a buyer, a seller or both, replacing the single Isbuyer flag
+ALTER TABLE userdetails ADD COLUMN Roles VARCHAR(30) NOT NULL DEFAULT '' AFTER Isbuyer;
+UPDATE userdetails SET Roles = IF(Isbuyer, 'buyer', 'seller');
+ALTER TABLE userdetails DROP COLUMN Isbuyer;
+
+-- ID of the last event read from the seller API by each reader
+CREATE TABLE IF NOT EXISTS eventcursors (
+    Name VARCHAR(30) NOT NULL PRIMARY KEY,
+    LastID BIGINT NOT NULL
+);
+
+-- Promo codes, Seller is empty for platform-wide codes made by an admin
+-- Amount and MinOrder are in minor units of Currency, ValidUntil is NULL for codes that do not expire
+CREATE TABLE IF NOT EXISTS coupons (
+    Code VARCHAR(20) NOT NULL PRIMARY KEY,
+    Seller VARCHAR(30) NOT NULL DEFAULT '',
+    Kind VARCHAR(10) NOT NULL,
+    Percent INT NOT NULL DEFAULT 0,
+    Amount BIGINT NOT NULL DEFAULT 0,
+    MinOrder BIGINT NOT NULL DEFAULT 0,
+    Currency CHAR(3) NOT NULL DEFAULT 'SGD',
+    ValidFrom DATETIME NOT NULL,
+    ValidUntil DATETIME NULL,
+    MaxUses INT NOT NULL DEFAULT 0,
+    PerBuyer INT NOT NULL DEFAULT 0,
+    Uses INT NOT NULL DEFAULT 0,
+    INDEX (Seller)
+);
+
+-- Each use of a promo code at checkout
+CREATE TABLE IF NOT EXISTS couponredemptions (
+    ID BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    Code VARCHAR(20) NOT NULL,
+    Buyer VARCHAR(30) NOT NULL,
+    Discount BIGINT NOT NULL,
+    Currency CHAR(3) NOT NULL,
+    RedeemedAt DATETIME NOT NULL,
+    INDEX (Code, Buyer),
+    FOREIGN KEY (Code) REFERENCES coupons(Code) ON DELETE CASCADE
+);
+
+-- Sellers registered for GST charge it on their listings, see the GST_* settings of the application
+ALTER TABLE userdetails ADD COLUMN GSTRegistered BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Orders placed at checkout, one for each seller in the cart, amounts are in minor units of Currency
+CREATE TABLE IF NOT EXISTS orders (
+    ID BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    Buyer VARCHAR(30) NOT NULL,
+    Seller VARCHAR(30) NOT NULL,
+    Status VARCHAR(20) NOT NULL,
+    PlacedAt DATETIME NOT NULL,
+    CollectedAt DATETIME NULL,
+    Coupon VARCHAR(20) NOT NULL DEFAULT '',
+    Currency CHAR(3) NOT NULL,
+    Net BIGINT NOT NULL,
+    Tax BIGINT NOT NULL,
+    Gross BIGINT NOT NULL,
+    Discount BIGINT NOT NULL DEFAULT 0,
+    PlatformDiscount BIGINT NOT NULL DEFAULT 0,
+    Fee BIGINT NOT NULL DEFAULT 0,
+    INDEX (Buyer),
+    INDEX (Seller)
+);
+
+-- Items of each order, copied from the listing when it was bought
+CREATE TABLE IF NOT EXISTS orderlines (
+    ID BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    OrderID BIGINT NOT NULL,
+    LotID BIGINT NOT NULL,
+    Item VARCHAR(30) NOT NULL,
+    Quantity DECIMAL(10,3) NOT NULL,
+    Unit VARCHAR(10) NOT NULL,
+    UnitCost BIGINT NOT NULL,
+    Total BIGINT NOT NULL,
+    Tax BIGINT NOT NULL,
+    FOREIGN KEY (OrderID) REFERENCES orders(ID) ON DELETE CASCADE
+);
+
+-- Double-entry ledger of money owed between buyers, sellers and the platform
+-- The debits and credits of the entries posted together are equal
+CREATE TABLE IF NOT EXISTS ledgerentries (
+    ID BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    OrderID BIGINT NOT NULL,
+    Account VARCHAR(50) NOT NULL,
+    Kind VARCHAR(20) NOT NULL,
+    Debit BIGINT NOT NULL DEFAULT 0,
+    Credit BIGINT NOT NULL DEFAULT 0,
+    Currency CHAR(3) NOT NULL,
+    Memo VARCHAR(100) NOT NULL DEFAULT '',
+    PostedAt DATETIME NOT NULL,
+    INDEX (Account, PostedAt)
+);
+
+-- Payments taken online through a payment provider, Reference is the provider's ID of the payment
+CREATE TABLE IF NOT EXISTS payments (
+    ID BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    Provider VARCHAR(20) NOT NULL,
+    Reference VARCHAR(100) NOT NULL,
+    Buyer VARCHAR(30) NOT NULL,
+    Amount BIGINT NOT NULL,
+    Currency CHAR(3) NOT NULL,
+    Status VARCHAR(20) NOT NULL,
+    CreatedAt DATETIME NOT NULL,
+    UpdatedAt DATETIME NOT NULL,
+    UNIQUE (Provider, Reference)
+);
+
+-- Payment taken online for each order, 0 when the buyer pays during collection
+ALTER TABLE orders ADD COLUMN PaymentID BIGINT NOT NULL DEFAULT 0;
+
+-- Refunds given back from payments taken online
+ALTER TABLE payments ADD COLUMN Refunded BIGINT NOT NULL DEFAULT 0;
+
+-- Cancellation of orders before they are collected, CancelledBy is buyer or seller
+ALTER TABLE orders ADD COLUMN CancelledAt DATETIME NULL;
+ALTER TABLE orders ADD COLUMN CancelledBy VARCHAR(10) NOT NULL DEFAULT '';
+ALTER TABLE orders ADD COLUMN CancelReason VARCHAR(255) NOT NULL DEFAULT '';
+
+-- Disputes raised by buyers about collected orders, resolved by admins with a refund paid by the seller
+CREATE TABLE IF NOT EXISTS disputes (
+    ID BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    OrderID BIGINT NOT NULL,
+    Buyer VARCHAR(30) NOT NULL,
+    Seller VARCHAR(30) NOT NULL,
+    Reason VARCHAR(255) NOT NULL,
+    Status VARCHAR(10) NOT NULL,
+    Refund BIGINT NOT NULL DEFAULT 0,
+    Currency CHAR(3) NOT NULL,
+    Note VARCHAR(255) NOT NULL DEFAULT '',
+    OpenedAt DATETIME NOT NULL,
+    ResolvedAt DATETIME NULL,
+    UNIQUE (OrderID),
+    FOREIGN KEY (OrderID) REFERENCES orders(ID)
+);
+
+-- Invoices issued for orders, the ID is the sequence number shown in the invoice number
+CREATE TABLE IF NOT EXISTS invoices (
+    ID BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    OrderID BIGINT NOT NULL,
+    IssuedAt DATETIME NOT NULL,
+    UNIQUE (OrderID),
+    FOREIGN KEY (OrderID) REFERENCES orders(ID)
+);
+
+-- Outbox of emails, written with the order they are about, and sent in the background with retries
+-- Attachments are stored as JSON, Status is pending, sent or dead when given up
+CREATE TABLE IF NOT EXISTS emailoutbox (
+    ID BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    Recipients TEXT NOT NULL,
+    Subject VARCHAR(255) NOT NULL,
+    Body MEDIUMTEXT NOT NULL,
+    Attachments LONGBLOB NOT NULL,
+    Status VARCHAR(10) NOT NULL,
+    Attempts INT NOT NULL DEFAULT 0,
+    NextAttemptAt DATETIME NOT NULL,
+    LastError TEXT NOT NULL,
+    CreatedAt DATETIME NOT NULL,
+    SentAt DATETIME NULL,
+    INDEX (Status, NextAttemptAt)
+);
+
+-- Plain text alternative of the emails in the outbox
+ALTER TABLE emailoutbox ADD COLUMN TextBody MEDIUMTEXT NOT NULL AFTER Body;
+
+-- Notifications shown to each user in the app, ReadAt is NULL until the user opens the notification centre
+CREATE TABLE IF NOT EXISTS notifications (
+    ID BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    Username VARCHAR(30) NOT NULL,
+    Kind VARCHAR(20) NOT NULL,
+    Message VARCHAR(255) NOT NULL,
+    Link VARCHAR(100) NOT NULL,
+    CreatedAt DATETIME NOT NULL,
+    ReadAt DATETIME NULL,
+    INDEX (Username, ReadAt)
+);
+
+-- Webhooks registered by sellers, Events is a comma separated list of the events posted to the URL
+CREATE TABLE IF NOT EXISTS webhookendpoints (
+    ID BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    Seller VARCHAR(30) NOT NULL,
+    URL VARCHAR(255) NOT NULL,
+    Secret VARCHAR(64) NOT NULL,
+    Events VARCHAR(255) NOT NULL,
+    CreatedAt DATETIME NOT NULL,
+    INDEX (Seller)
+);
+
+-- Events queued for the webhooks of sellers, posted in the background with retries
+-- Status is pending, delivered or failed when given up, deliveries are deleted with their webhook
+CREATE TABLE IF NOT EXISTS webhookdeliveries (
+    ID BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    EndpointID BIGINT NOT NULL,
+    EventID VARCHAR(40) NOT NULL,
+    Event VARCHAR(30) NOT NULL,
+    Body MEDIUMTEXT NOT NULL,
+    Status VARCHAR(10) NOT NULL,
+    Attempts INT NOT NULL DEFAULT 0,
+    NextAttemptAt DATETIME NOT NULL,
+    ResponseCode INT NOT NULL DEFAULT 0,
+    LastError TEXT NOT NULL,
+    CreatedAt DATETIME NOT NULL,
+    DeliveredAt DATETIME NULL,
+    INDEX (Status, NextAttemptAt),
+    FOREIGN KEY (EndpointID) REFERENCES webhookendpoints(ID) ON DELETE CASCADE
+);
+
+-- Searches saved by buyers, MaxPrice is in minor units of Currency, each limit is not checked when it is 0
+CREATE TABLE IF NOT EXISTS savedsearches (
+    ID BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    Buyer VARCHAR(30) NOT NULL,
+    Item VARCHAR(30) NOT NULL,
+    MaxPrice BIGINT NOT NULL DEFAULT 0,
+    Currency CHAR(3) NOT NULL,
+    MinQuantity DOUBLE NOT NULL DEFAULT 0,
+    MaxDistance DOUBLE NOT NULL DEFAULT 0,
+    CreatedAt DATETIME NOT NULL,
+    INDEX (Buyer),
+    INDEX (Item)
+);
+
+-- Listings each saved search has emailed its buyer about, and the price at the time, so a buyer is only emailed again when it drops
+CREATE TABLE IF NOT EXISTS savedsearchalerts (
+    SearchID BIGINT NOT NULL,
+    LotID BIGINT NOT NULL,
+    Cost BIGINT NOT NULL,
+    Currency CHAR(3) NOT NULL,
+    AlertedAt DATETIME NOT NULL,
+    PRIMARY KEY (SearchID, LotID),
+    FOREIGN KEY (SearchID) REFERENCES savedsearches(ID) ON DELETE CASCADE
+);
+
+-- Location of each user, the centre of the postal district of the postal code in their address, used to work out distances
+CREATE TABLE IF NOT EXISTS userlocations (
+    Username VARCHAR(30) NOT NULL PRIMARY KEY,
+    PostalCode CHAR(6) NOT NULL,
+    Latitude DOUBLE NOT NULL,
+    Longitude DOUBLE NOT NULL,
+    UpdatedAt DATETIME NOT NULL
+);
+
+-- GST registration number of sellers registered for GST, printed on their tax invoices
+ALTER TABLE userdetails ADD COLUMN GSTNumber VARCHAR(10) NOT NULL DEFAULT '' AFTER GSTRegistered;
+
+-- GST registration number of the seller when the order was placed
+ALTER TABLE orders ADD COLUMN GSTNumber VARCHAR(10) NOT NULL DEFAULT '' AFTER Gross;
+
+-- Details of the buyer and the seller printed on each invoice, as JSON, stored when the invoice is issued
+ALTER TABLE invoices ADD COLUMN Buyer VARCHAR(2000) NOT NULL DEFAULT '' AFTER IssuedAt, ADD COLUMN Seller VARCHAR(2000) NOT NULL DEFAULT '' AFTER Buyer;
+
+-- Usernames of purged accounts, which cannot be registered again as orders, invoices and payments are still kept under them
+CREATE TABLE IF NOT EXISTS purgedusernames (
+    Username VARCHAR(30) NOT NULL PRIMARY KEY,
+    PurgedAt DATETIME NOT NULL
+);
@@ -2,11 +2,15 @@ package email
 
 import (
 	"fmt"
-	"net/smtp"
 	apiclient "projectGoLive/application/apiclient"
 	config "projectGoLive/application/config"
+	coupon "projectGoLive/application/coupon"
+	invoice "projectGoLive/application/invoice"
+	money "projectGoLive/application/money"
+	orders "projectGoLive/application/orders"
+	tax "projectGoLive/application/tax"
 	user_db "projectGoLive/application/user_db"
-	"strconv"
+	"sort"
 	"time"
 )
 
@@ -18,16 +22,22 @@ type SellerInfo struct {
 	CartItems []apiclient.ItemsDetails
 }
 
-func Sendemail(buyername string, cartItems []apiclient.ItemsDetails) bool {
+// Data structure for the checkout emails to the buyer and to each seller, rendered before the orders are placed
+// The invoices are attached when the orders are placed, see Queue.
+type Checkout struct {
+	buyer   Message
+	sellers map[string]Message
+}
+
+// This function renders the emails of a checkout to the buyer and to each seller
+// It is called before the orders are placed, so an email that cannot be written never stops an order from being recorded.
+// discount is the promo code used for the order, its zero value when no code was used
+// order is the GST worked out at checkout for the cart items, so the emails match what the buyer was charged
+// even if a seller's GST registration changes afterwards.
+// It returns false when the emails cannot be rendered.
+func PrepareCheckout(buyername string, cartItems []apiclient.ItemsDetails, discount coupon.Applied, order tax.Order) (Checkout, bool) {
+	checkout := Checkout{sellers: map[string]Message{}}
 
-	// Email configuration
-	from := "peelrescue@gmail.com"
-	password := "blueappleredorange"
-	// smtp server configuration.
-	smtpHost := "smtp.gmail.com"
-	smtpPort := "587"
-	// Authentication.
-	auth := smtp.PlainAuth("", from, password, smtpHost)
 	timeNow := time.Now()
 	tNow := timeNow.Format("2006-01-02 15:04:05")
 
@@ -37,15 +47,19 @@ func Sendemail(buyername string, cartItems []apiclient.ItemsDetails) bool {
 
 	// Buyer details :
 	buyerdetails, _ := user_db.GetARecord(config.DB, buyername)
-	buyerfullname := buyerdetails.Fullname
-	buyeraddress := buyerdetails.Address
-	buyeremail := buyerdetails.Email
-	buyerphone := buyerdetails.Phone
 
 	// Get all user records :
 	userdetails, _ := user_db.GetRecords(config.DB)
 
-	for _, cartitem := range cartItems {
+	// GST of each cart item, by its position in the cart
+	if len(order.Lines) != len(cartItems) {
+		config.Error.Printf("GST of order of %s has not been worked out for all %d items\n", buyername, len(cartItems))
+		return checkout, false
+	}
+	sellerTaxes := make(map[string][]money.Money)
+
+	for i, cartitem := range cartItems {
+		sellerTaxes[cartitem.Username] = append(sellerTaxes[cartitem.Username], order.Lines[i].Tax)
 		for _, user := range userdetails {
 
 			if user.Username == cartitem.Username {
@@ -61,112 +75,207 @@ func Sendemail(buyername string, cartItems []apiclient.ItemsDetails) bool {
 		}
 	}
 
-	//------------------------
-	// Sending email to buyer.
-
-	to := []string{
-		buyeremail,
-	}
-	subject := fmt.Sprintf("Subject: Invoice from Peel Rescue! Your order at %s\n", tNow)
-	mime := "MIME-version: 1.0;\nContent-Type: text/html; charset=\"UTF-8\";\n\n"
-	body := fmt.Sprintf("<html><style>table {width:800px; border: 8px solid black;} th, td {border: 2px solid rgb(242, 248, 163);width:50px;height:40px;text-align: center;}</style><body>")
-	body = body + fmt.Sprintf("<h1>Hello Buyer : %s </h1> ", buyerfullname)
-
-	alltotalcost := 0.0
-	// Send email invoice to buyer
-	for _, value := range sellerMap {
-		body = body + fmt.Sprintf("<br>")
-		body = body + fmt.Sprintf("<h2>Items purchased from seller : %s </h2> ", value.Fullname)
-		body = body + fmt.Sprintf("<p>Pick up address : %s </p> ", value.Address)
-		body = body + fmt.Sprintf("<p>Phone : %s </p> ", value.Phone)
-		body = body + fmt.Sprintf("<p>Email : %s </p> ", value.Email)
-
-		body = body + fmt.Sprintf("<table>")
-		body = body + fmt.Sprintf("<tr><th>Item name</th><th>Quantity (In kgs)</th><th>Cost (In SGD)</th></tr>")
-
-		totalcost := 0.0
-		for _, cartitem := range value.CartItems {
-			body = body + fmt.Sprintf("<tr>")
-			body = body + fmt.Sprintf("<td>%s</td>", cartitem.Item)
-			body = body + fmt.Sprintf("<td>%s</td>", strconv.Itoa(cartitem.Quantity))
-			body = body + fmt.Sprintf("<td>%s</td>", floattostr(cartitem.Cost))
-			body = body + fmt.Sprintf("</tr>")
-			totalcost = totalcost + (cartitem.Cost * float64(cartitem.Quantity))
-		}
-		alltotalcost = alltotalcost + totalcost
-		body = body + fmt.Sprintf("</table>")
-		body = body + fmt.Sprintf("<br>")
-		body = body + fmt.Sprintf("<h2>Total Cost : $%.2f</h2>", totalcost)
-		body = body + fmt.Sprintf("<br>")
-	}
-	body = body + fmt.Sprintf("<h2>Total Amount Spent for this order : $%.2f</h2>", alltotalcost)
-	body = body + fmt.Sprintf("<br>")
-	body = body + fmt.Sprintf("<h3>Thank you for using Peel Rescue. </h3>")
-	body = body + fmt.Sprintf("<h4>Saving Earth one Peel at a time!</h4>")
-	body = body + "</body></html>"
-
-	// Sending email to buyer
-	bytemessage := []byte(subject + mime + body)
-
-	err := smtp.SendMail(smtpHost+":"+smtpPort, auth, from, to, bytemessage)
-	if err != nil {
-		config.Error.Println(err)
-		return false
+	// The sellers are shown in the order of their usernames
+	var sellernames []string
+	for sellername := range sellerMap {
+		sellernames = append(sellernames, sellername)
 	}
-	config.Info.Println("Email Sent Successfully to !", buyeremail)
-
-	//-----------------------------------
-	// Send email invoice to each seller
+	sort.Strings(sellernames)
 
-	for _, value := range sellerMap {
-		// Each sellers email address.
-		to := []string{
-			value.Email,
+	data := checkoutData{Name: buyerdetails.Fullname, Buyer: buyerdetails, PlacedAt: timeNow, Total: order.Total, Payable: order.Payable}
+	if discount.Code != "" && discount.Seller == "" {
+		data.Discount = discount
+	}
+	for _, sellername := range sellernames {
+		value := sellerMap[sellername]
+		section := sellerOrder{
+			Seller:    value,
+			Amounts:   order.Sellers[sellername],
+			RateLabel: tax.Current.SellerRateLabel(order, sellername),
+		}
+		for n, cartitem := range value.CartItems {
+			total, err := cartitem.LineTotal()
+			if err != nil {
+				config.Error.Printf("Unable to work out cost of %s for checkout email : %v\n", cartitem.Item, err)
+				return checkout, false
+			}
+			section.Lines = append(section.Lines, itemLine{cartitem.Item, cartitem.QuantityLabel(), cartitem.AppliedCost(), total, sellerTaxes[sellername][n]})
 		}
-		subject := fmt.Sprintf("Subject: Invoice from Peel Rescue! For Buyer: %s at %s\n", buyerfullname, tNow)
-		mime := "MIME-version: 1.0;\nContent-Type: text/html; charset=\"UTF-8\";\n\n"
-
-		body := fmt.Sprintf("<html><style>table {width:800px; border: 8px solid black;} th, td {border: 2px solid rgb(242, 248, 163);width:50px;height:40px;text-align: center;}</style><body>")
-		body = body + fmt.Sprintf("<h1>Hello Seller : %s </h1> ", value.Fullname)
-		body = body + fmt.Sprintf("<div>Items purchased by buyer : %s </div> ", buyerfullname)
-		body = body + fmt.Sprintf("<p>Address for delivery : %s </p> ", buyeraddress)
-		body = body + fmt.Sprintf("<p>Buyer Phone : %s </p> ", buyerphone)
-		body = body + fmt.Sprintf("<p>Buyer Email : %s </p> ", buyeremail)
-
-		body = body + fmt.Sprintf("<table>")
-		body = body + fmt.Sprintf("<tr><th>Item name</th><th>Quantity (In kgs)</th><th>Cost (In SGD)</th></tr>")
-		totalcost := 0.0
-		for _, cartitem := range value.CartItems {
-			body = body + fmt.Sprintf("<tr>")
-			body = body + fmt.Sprintf("<td>%s</td>", cartitem.Item)
-			body = body + fmt.Sprintf("<td>%s</td>", strconv.Itoa(cartitem.Quantity))
-			body = body + fmt.Sprintf("<td>%s</td>", floattostr(cartitem.Cost))
-			body = body + fmt.Sprintf("</tr>")
-			totalcost = totalcost + (cartitem.Cost * float64(cartitem.Quantity))
+		if discount.Code != "" && discount.Seller == sellername {
+			section.Discount = discount
 		}
-		body = body + fmt.Sprintf("</table>")
-		body = body + fmt.Sprintf("<h2>Total Cost : $%.2f</h2>", totalcost)
+		data.Sellers = append(data.Sellers, section)
+	}
 
-		// Message.
-		body = body + fmt.Sprintf("<h3>Thank you for using Peel Rescue. </h3>")
-		body = body + fmt.Sprintf("<h4>Saving Earth one Peel at a time!</h4>")
-		body = body + "</body></html>"
+	// Email to the buyer
+	var ok bool
+	subject := fmt.Sprintf("Invoice from Peel Rescue! Your order at %s", tNow)
+	if checkout.buyer, ok = renderTo(buyerdetails.Email, subject, "checkoutbuyer", data); !ok {
+		return checkout, false
+	}
 
-		// Sending email.
-		bytemessage := []byte(subject + mime + body)
+	// Email invoice to each seller, with only the items bought from them
+	for n, sellername := range sellernames {
+		value := sellerMap[sellername]
+		sellerData := data
+		sellerData.Name = value.Fullname
+		sellerData.Sellers = data.Sellers[n : n+1]
 
-		err := smtp.SendMail(smtpHost+":"+smtpPort, auth, from, to, bytemessage)
-		if err != nil {
-			config.Error.Println(err)
-			return false
+		subject := fmt.Sprintf("Invoice from Peel Rescue! For Buyer: %s at %s", buyerdetails.Fullname, tNow)
+		if checkout.sellers[sellername], ok = renderTo(value.Email, subject, "checkoutseller", sellerData); !ok {
+			return checkout, false
 		}
-		config.Info.Println("Email Sent Successfully to !", value.Email)
+	}
+	return checkout, true
+}
+
+// This method queues the checkout emails in the outbox through db, the transaction storing the orders
+// invoices are the PDF invoices of the orders placed, the buyer gets all of them and each seller gets their own.
+// It returns false when an email cannot be queued, the others are still queued.
+func (c Checkout) Queue(db execer, invoices []invoice.Invoice) bool {
+	m := c.buyer
+	m.Attachments = invoiceAttachments(invoices, "")
+	queued := queueMessage(db, m)
+
+	var sellernames []string
+	for sellername := range c.sellers {
+		sellernames = append(sellernames, sellername)
+	}
+	sort.Strings(sellernames)
+	for _, sellername := range sellernames {
+		m := c.sellers[sellername]
+		m.Attachments = invoiceAttachments(invoices, sellername)
+		queued = queueMessage(db, m) && queued
+	}
+	return queued
+}
+
+// This function emails a seller that their listing has expired and has been removed from sale
+// It returns false if the seller cannot be found or the email cannot be sent
+func SendExpiryNotice(sellername string, item apiclient.ItemsDetails) bool {
+	sellerdetails, ok := user_db.GetARecord(config.DB, sellername)
+	if !ok {
+		config.Error.Printf("Unable to find seller %s for expiry notice\n", sellername)
+		return false
+	}
+
+	subject := fmt.Sprintf("Peel Rescue! Your %s listing has expired", item.Item)
+	return queue(config.DB, sellerdetails.Email, subject, "expiry", expiryData{sellerdetails.Fullname, item}, nil)
+}
+
+// This function emails a seller that a listing is running low or has sold out, the listing is kept for them to restock
+// It returns false if the seller cannot be found or the email cannot be sent
+func SendStockNotice(sellername string, item apiclient.ItemsDetails) bool {
+	sellerdetails, ok := user_db.GetARecord(config.DB, sellername)
+	if !ok {
+		config.Error.Printf("Unable to find seller %s for stock notice\n", sellername)
+		return false
+	}
+
+	subject := fmt.Sprintf("Peel Rescue! Your %s listing is running low", item.Item)
+	if item.SoldOut() {
+		subject = fmt.Sprintf("Peel Rescue! Your %s listing has sold out", item.Item)
+	}
+	return queue(config.DB, sellerdetails.Email, subject, "stock", expiryData{sellerdetails.Fullname, item}, nil)
+}
 
+// This function emails a buyer about a listing matching one of their saved searches, described by search
+// distance is how far the seller is, empty when it is not known, and was is the price the buyer was last told about,
+// its zero value when the listing is new to them.
+// It returns false if the buyer cannot be found or the email cannot be sent
+func SendSearchAlert(buyername, search string, item apiclient.ItemsDetails, distance string, was money.Money) bool {
+	buyerdetails, ok := user_db.GetARecord(config.DB, buyername)
+	if !ok {
+		config.Error.Printf("Unable to find buyer %s for saved search alert\n", buyername)
+		return false
+	}
+
+	subject := fmt.Sprintf("Peel Rescue! %s peel matching your saved search is on sale", item.Item)
+	if was.Currency != "" {
+		subject = fmt.Sprintf("Peel Rescue! %s peel matching your saved search is now %s", item.Item, item.Cost)
+	}
+	return queue(config.DB, buyerdetails.Email, subject, "searchalert", searchAlertData{buyerdetails.Fullname, search, item, distance, was}, nil)
+}
+
+// This function emails the buyer and the seller of an order that it has been cancelled
+// missing are the lines that could not be put back on sale, the seller is asked to list them again.
+// refunded is true when a payment taken online has been given back to the buyer.
+func SendCancellation(o orders.Order, missing []orders.Line, refunded bool) bool {
+	buyerdetails, ok := user_db.GetARecord(config.DB, o.Buyer)
+	sellerdetails, ok2 := user_db.GetARecord(config.DB, o.Seller)
+	if !ok || !ok2 {
+		config.Error.Printf("Unable to find buyer %s or seller %s of cancelled order %d\n", o.Buyer, o.Seller, o.ID)
+		return false
+	}
+
+	subject := fmt.Sprintf("Peel Rescue! Order %d has been cancelled", o.ID)
+	data := cancellationData{Name: buyerdetails.Fullname, Order: o, By: "you", Missing: missing, Refunded: refunded}
+	if o.CancelledBy == orders.CancelledBySeller {
+		data.By = "the seller " + sellerdetails.Fullname
+	}
+	sent := queue(config.DB, buyerdetails.Email, subject, "cancelbuyer", data, nil)
+
+	data.Name, data.By = sellerdetails.Fullname, "the buyer "+buyerdetails.Fullname
+	if o.CancelledBy == orders.CancelledBySeller {
+		data.By = "you"
+	}
+	return queue(config.DB, sellerdetails.Email, subject, "cancelseller", data, nil) && sent
+}
+
+// This function emails the buyer and the seller of an order about a dispute, when it is raised and when it is resolved
+func SendDisputeNotice(d orders.Dispute) bool {
+	buyerdetails, ok := user_db.GetARecord(config.DB, d.Buyer)
+	sellerdetails, ok2 := user_db.GetARecord(config.DB, d.Seller)
+	if !ok || !ok2 {
+		config.Error.Printf("Unable to find buyer %s or seller %s of dispute %d\n", d.Buyer, d.Seller, d.ID)
+		return false
+	}
+
+	subject := fmt.Sprintf("Peel Rescue! Dispute about order %d", d.OrderID)
+	data := disputeData{Name: buyerdetails.Fullname, Role: "Buyer", BuyerName: buyerdetails.Fullname, Dispute: d, Refunded: d.HasRefund()}
+	sent := queue(config.DB, buyerdetails.Email, subject, "dispute", data, nil)
+
+	data.Name, data.Role = sellerdetails.Fullname, "Seller"
+	return queue(config.DB, sellerdetails.Email, subject, "dispute", data, nil) && sent
+}
+
+// This function renders an email with its templates and queues it in the outbox, it is sent in the background by DeliverOutbox
+func queue(db execer, to, subject, name string, data interface{}, attachments []Attachment) bool {
+	m, ok := renderTo(to, subject, name, data)
+	if !ok {
+		return false
+	}
+	m.Attachments = attachments
+	return queueMessage(db, m)
+}
+
+// This function renders an email to one address with its templates
+func renderTo(to, subject, name string, data interface{}) (Message, bool) {
+	m, err := render(name, data)
+	if err != nil {
+		config.Error.Printf("Unable to render email %s to %s : %v\n", name, to, err)
+		return m, false
+	}
+	m.To, m.Subject = []string{to}, subject
+	return m, true
+}
+
+// This function queues a rendered email in the outbox
+func queueMessage(db execer, m Message) bool {
+	if err := Queue(db, m); err != nil {
+		config.Error.Printf("Unable to queue email to %v : %v\n", m.To, err)
+		return false
 	}
 	return true
 }
 
-func floattostr(input float64) string {
-	// to convert a float number to a string
-	return strconv.FormatFloat(input, 'f', -1, 64)
+// This function returns the PDF invoices of the orders of a seller as attachments, or of all sellers when sellername is empty
+func invoiceAttachments(invoices []invoice.Invoice, sellername string) []Attachment {
+	var attachments []Attachment
+	for _, inv := range invoices {
+		if sellername == "" || inv.Order.Seller == sellername {
+			attachments = append(attachments, Attachment{Name: inv.FileName(), ContentType: "application/pdf", Data: inv.PDF()})
+		}
+	}
+	return attachments
 }
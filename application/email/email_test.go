@@ -0,0 +1,93 @@
+package email
+
+import (
+	"database/sql"
+	"errors"
+	"projectGoLive/application/invoice"
+	"projectGoLive/application/orders"
+	"strings"
+	"testing"
+)
+
+// fakeOutbox records the emails queued through it, failing for the recipients in fail
+type fakeOutbox struct {
+	fail   map[string]bool
+	queued map[string]string
+}
+
+// This method records the recipients and the attachments of an email inserted in the outbox
+func (f *fakeOutbox) Exec(query string, args ...interface{}) (sql.Result, error) {
+	to := args[0].(string)
+	if f.fail[to] {
+		return nil, errors.New("outbox is not available")
+	}
+	f.queued[to] = string(args[4].([]byte))
+	return nil, nil
+}
+
+func TestCheckoutQueue(t *testing.T) {
+	checkout := Checkout{
+		buyer: Message{To: []string{"buyer@example.com"}, Subject: "Your order"},
+		sellers: map[string]Message{
+			"seller1": {To: []string{"seller1@example.com"}, Subject: "New order"},
+			"seller2": {To: []string{"seller2@example.com"}, Subject: "New order"},
+		},
+	}
+	invoices := []invoice.Invoice{
+		{Number: "PR-000001", Order: orders.Order{ID: 1, Seller: "seller1"}},
+		{Number: "PR-000002", Order: orders.Order{ID: 2, Seller: "seller2"}},
+	}
+
+	tests := []struct {
+		name       string
+		fail       map[string]bool
+		want       bool
+		wantQueued map[string][]string
+	}{
+		{
+			name: "all queued",
+			want: true,
+			wantQueued: map[string][]string{
+				"buyer@example.com":   {"invoice-PR-000001.pdf", "invoice-PR-000002.pdf"},
+				"seller1@example.com": {"invoice-PR-000001.pdf"},
+				"seller2@example.com": {"invoice-PR-000002.pdf"},
+			},
+		},
+		{
+			name: "one fails",
+			fail: map[string]bool{"buyer@example.com": true},
+			want: false,
+			wantQueued: map[string][]string{
+				"seller1@example.com": {"invoice-PR-000001.pdf"},
+				"seller2@example.com": {"invoice-PR-000002.pdf"},
+			},
+		},
+	}
+	for _, test := range tests {
+		outbox := &fakeOutbox{fail: test.fail, queued: map[string]string{}}
+		if got := checkout.Queue(outbox, invoices); got != test.want {
+			t.Errorf("%s: Queue = %v, want %v", test.name, got, test.want)
+		}
+		if len(outbox.queued) != len(test.wantQueued) {
+			t.Errorf("%s: %d emails queued, want %d", test.name, len(outbox.queued), len(test.wantQueued))
+		}
+		for to, names := range test.wantQueued {
+			attachments, ok := outbox.queued[to]
+			if !ok {
+				t.Errorf("%s: no email queued to %s", test.name, to)
+				continue
+			}
+			if got := strings.Count(attachments, `"Name"`); got != len(names) {
+				t.Errorf("%s: email to %s has %d attachments, want %d", test.name, to, got, len(names))
+			}
+			for _, name := range names {
+				if !strings.Contains(attachments, `"Name":"`+name+`"`) {
+					t.Errorf("%s: email to %s does not have %s attached", test.name, to, name)
+				}
+			}
+		}
+	}
+	if len(checkout.buyer.Attachments) != 0 {
+		t.Errorf("Queue changed the attachments of the prepared email")
+	}
+}
@@ -0,0 +1,206 @@
+package email
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"os"
+	"strings"
+	"time"
+)
+
+// Address and display name the emails are sent from, used when MAIL_FROM and MAIL_FROM_NAME are not set
+const (
+	defaultFrom     = "peelrescue@gmail.com"
+	defaultFromName = "Peel Rescue"
+)
+
+// Data structure for an email, with the same content as html and as plain text
+// MessageID is generated when the email is written if it is empty.
+type Message struct {
+	From        string
+	FromName    string
+	MessageID   string
+	To          []string
+	Subject     string
+	HTML        string
+	Text        string
+	Attachments []Attachment
+}
+
+// Data structure for a file attached to an email
+type Attachment struct {
+	Name        string
+	ContentType string
+	Data        []byte
+}
+
+// Interface for sending emails, through an SMTP server or to a local sink
+// Every email of the application is queued in the outbox and sent through the mailer given to DeliverOutbox.
+type Mailer interface {
+	Send(m Message) error
+}
+
+// Address and display name the emails are sent from, read from MAIL_FROM and MAIL_FROM_NAME by OpenMailer
+var (
+	From     = defaultFrom
+	FromName = defaultFromName
+)
+
+// This function returns the mailer chosen with MAIL_TRANSPORT in the env file, it is called when the application starts
+// MAIL_TRANSPORT is smtp when it is not set. The application does not start without SMTP_HOST or with a transport
+// it does not know, so emails are never left on the disk of the server when they should be sent.
+func OpenMailer() Mailer {
+	From = envOr(os.LookupEnv, "MAIL_FROM", defaultFrom)
+	FromName = envOr(os.LookupEnv, "MAIL_FROM_NAME", defaultFromName)
+	mailer, err := newMailer(os.LookupEnv)
+	if err != nil {
+		panic(err)
+	}
+	return mailer
+}
+
+// This function returns the mailer chosen with MAIL_TRANSPORT, reading the settings with lookup
+func newMailer(lookup func(string) (string, bool)) (Mailer, error) {
+	transport := envOr(lookup, "MAIL_TRANSPORT", "smtp")
+	switch transport {
+	case "smtp":
+		s := &SMTPMailer{
+			Host:     envOr(lookup, "SMTP_HOST", ""),
+			Port:     envOr(lookup, "SMTP_PORT", "587"),
+			Username: envOr(lookup, "SMTP_USERNAME", ""),
+			Password: envOr(lookup, "SMTP_PASSWORD", ""),
+			Security: envOr(lookup, "SMTP_SECURITY", SecurityStartTLS),
+		}
+		if s.Host == "" {
+			return nil, errors.New("SMTP_HOST must be set to send emails, or MAIL_TRANSPORT set to dir to write them to MAIL_DIR")
+		}
+		switch s.Security {
+		case SecurityStartTLS, SecurityTLS, SecurityNone:
+		default:
+			return nil, fmt.Errorf("unknown SMTP_SECURITY %q, it must be starttls, tls or none", s.Security)
+		}
+		return s, nil
+	case "dir":
+		return &DirMailer{Dir: envOr(lookup, "MAIL_DIR", "mail")}, nil
+	case "memory":
+		return &MemoryMailer{}, nil
+	}
+	return nil, fmt.Errorf("unknown MAIL_TRANSPORT %q, it must be smtp, dir or memory", transport)
+}
+
+// This function returns the value of an environment variable read with lookup, or value if it is not set
+func envOr(lookup func(string) (string, bool), name, value string) string {
+	if v, ok := lookup(name); ok {
+		return strings.TrimSpace(v)
+	}
+	return value
+}
+
+// This method returns the email as a MIME message
+// The text and html bodies are sent as a multipart/alternative message, wrapped in a multipart/mixed message when files are attached.
+func (m Message) Bytes() []byte {
+	var message bytes.Buffer
+	from := (&mail.Address{Name: m.FromName, Address: m.From}).String()
+	messageID := m.MessageID
+	if messageID == "" {
+		messageID = newMessageID(m.From)
+	}
+	fmt.Fprintf(&message, "From: %s\r\n", from)
+	fmt.Fprintf(&message, "To: %s\r\n", strings.Join(m.To, ", "))
+	fmt.Fprintf(&message, "Subject: %s\r\n", mime.QEncoding.Encode("UTF-8", m.Subject))
+	fmt.Fprintf(&message, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&message, "Message-ID: %s\r\n", messageID)
+	message.WriteString("MIME-Version: 1.0\r\n")
+
+	header, body := m.alternative()
+	if len(m.Attachments) == 0 {
+		for _, name := range []string{"Content-Type", "Content-Transfer-Encoding"} {
+			if value := header.Get(name); value != "" {
+				fmt.Fprintf(&message, "%s: %s\r\n", name, value)
+			}
+		}
+		message.WriteString("\r\n")
+		message.Write(body)
+		return message.Bytes()
+	}
+
+	parts := multipart.NewWriter(&message)
+	fmt.Fprintf(&message, "Content-Type: multipart/mixed; boundary=%q\r\n\r\n", parts.Boundary())
+	part, _ := parts.CreatePart(header)
+	part.Write(body)
+	for _, a := range m.Attachments {
+		part, _ := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {fmt.Sprintf("%s; name=%q", a.ContentType, a.Name)},
+			"Content-Disposition":       {fmt.Sprintf("attachment; filename=%q", a.Name)},
+			"Content-Transfer-Encoding": {"base64"},
+		})
+		// Base64 lines are kept to 76 characters, as required for email
+		encoded := base64.StdEncoding.EncodeToString(a.Data)
+		for len(encoded) > 76 {
+			part.Write([]byte(encoded[:76] + "\r\n"))
+			encoded = encoded[76:]
+		}
+		part.Write([]byte(encoded + "\r\n"))
+	}
+	parts.Close()
+	return message.Bytes()
+}
+
+// This method returns the headers and the body of the content of the email, with the text and html as alternatives
+// Emails with no text are written as html only.
+func (m Message) alternative() (textproto.MIMEHeader, []byte) {
+	var body bytes.Buffer
+	if m.Text == "" {
+		writeQuotedPrintable(&body, m.HTML)
+		return textproto.MIMEHeader{
+			"Content-Type":              {"text/html; charset=\"UTF-8\""},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		}, body.Bytes()
+	}
+
+	parts := multipart.NewWriter(&body)
+	// The last part is the one preferred by email clients, so html comes after text
+	for _, content := range []struct{ contentType, text string }{{"text/plain", m.Text}, {"text/html", m.HTML}} {
+		part, _ := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {content.contentType + "; charset=\"UTF-8\""},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		writeQuotedPrintable(part, content.text)
+	}
+	parts.Close()
+	return textproto.MIMEHeader{
+		"Content-Type": {fmt.Sprintf("multipart/alternative; boundary=%q", parts.Boundary())},
+	}, body.Bytes()
+}
+
+// This function writes a body as quoted-printable, which keeps its lines short and any character safe for email
+func writeQuotedPrintable(w io.Writer, body string) {
+	qp := quotedprintable.NewWriter(w)
+	qp.Write([]byte(body))
+	qp.Close()
+}
+
+// This function returns a new unique Message-ID in the domain of the address the email is sent from
+func newMessageID(from string) string {
+	random := make([]byte, 8)
+	rand.Read(random)
+	return fmt.Sprintf("<%d.%s@%s>", time.Now().UnixNano(), hex.EncodeToString(random), domainOf(from))
+}
+
+// This function returns the domain of an email address, localhost when it has none
+func domainOf(address string) string {
+	if at := strings.LastIndex(address, "@"); at >= 0 && at < len(address)-1 {
+		return address[at+1:]
+	}
+	return "localhost"
+}
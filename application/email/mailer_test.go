@@ -0,0 +1,195 @@
+package email
+
+import (
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// This function returns a lookup reading the settings from env, like os.LookupEnv
+func lookupIn(env map[string]string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	}
+}
+
+func TestNewMailer(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		want    string
+		wantErr bool
+	}{
+		{"smtp by default", map[string]string{"SMTP_HOST": "smtp.example.com"}, "*email.SMTPMailer", false},
+		{"smtp without host", map[string]string{}, "", true},
+		{"smtp with blank host", map[string]string{"MAIL_TRANSPORT": "smtp", "SMTP_HOST": "  "}, "", true},
+		{"smtp with unknown security", map[string]string{"SMTP_HOST": "smtp.example.com", "SMTP_SECURITY": "ssl"}, "", true},
+		{"dir", map[string]string{"MAIL_TRANSPORT": "dir"}, "*email.DirMailer", false},
+		{"memory", map[string]string{"MAIL_TRANSPORT": " memory "}, "*email.MemoryMailer", false},
+		{"unknown", map[string]string{"MAIL_TRANSPORT": "pigeon", "SMTP_HOST": "smtp.example.com"}, "", true},
+	}
+	for _, test := range tests {
+		mailer, err := newMailer(lookupIn(test.env))
+		if (err != nil) != test.wantErr {
+			t.Errorf("%s: error = %v, want error %v", test.name, err, test.wantErr)
+			continue
+		}
+		if got := fmt.Sprintf("%T", mailer); !test.wantErr && got != test.want {
+			t.Errorf("%s: mailer = %s, want %s", test.name, got, test.want)
+		}
+	}
+
+	mailer, _ := newMailer(lookupIn(map[string]string{"SMTP_HOST": "smtp.example.com"}))
+	if s := mailer.(*SMTPMailer); s.Port != "587" || s.Security != SecurityStartTLS {
+		t.Errorf("smtp defaults = port %s and security %s, want 587 and starttls", s.Port, s.Security)
+	}
+	mailer, _ = newMailer(lookupIn(map[string]string{"MAIL_TRANSPORT": "dir"}))
+	if d := mailer.(*DirMailer); d.Dir != "mail" {
+		t.Errorf("dir default = %q, want mail", d.Dir)
+	}
+}
+
+func TestDirMailer(t *testing.T) {
+	tests := []struct {
+		name        string
+		message     Message
+		wantParts   []string
+		wantSubject string
+	}{
+		{
+			name:        "text and html",
+			message:     Message{From: "peelrescue@example.com", To: []string{"zoe@example.com"}, Subject: "Your order", Text: "Thank you", HTML: "<p>Thank you</p>"},
+			wantParts:   []string{"text/plain", "text/html"},
+			wantSubject: "Your order",
+		},
+		{
+			name:        "html only",
+			message:     Message{From: "peelrescue@example.com", To: []string{"zoe@example.com"}, Subject: "Prix réduit", HTML: "<p>Café</p>"},
+			wantSubject: "Prix réduit",
+		},
+		{
+			name: "attachment",
+			message: Message{From: "peelrescue@example.com", To: []string{"zoe@example.com", "jose@example.com"}, Subject: "Invoice", Text: "Attached", HTML: "<p>Attached</p>",
+				Attachments: []Attachment{{Name: "invoice-PR-000001.pdf", ContentType: "application/pdf", Data: []byte(strings.Repeat("%PDF-1.4 ", 40))}}},
+			wantParts:   []string{"multipart/alternative", "application/pdf"},
+			wantSubject: "Invoice",
+		},
+	}
+	for _, test := range tests {
+		dir := filepath.Join(t.TempDir(), "mail")
+		d := &DirMailer{Dir: dir}
+		if err := d.Send(test.message); err != nil {
+			t.Fatalf("%s: Send returned %v", test.name, err)
+		}
+		if err := d.Send(test.message); err != nil {
+			t.Fatalf("%s: second Send returned %v", test.name, err)
+		}
+		files, _ := filepath.Glob(filepath.Join(dir, "*.eml"))
+		if len(files) != 2 {
+			t.Fatalf("%s: %d files written, want 2", test.name, len(files))
+		}
+
+		data, _ := ioutil.ReadFile(files[0])
+		m, err := mail.ReadMessage(strings.NewReader(string(data)))
+		if err != nil {
+			t.Fatalf("%s: the file is not an email: %v", test.name, err)
+		}
+		subject, _ := new(mime.WordDecoder).DecodeHeader(m.Header.Get("Subject"))
+		if subject != test.wantSubject {
+			t.Errorf("%s: subject = %q, want %q", test.name, subject, test.wantSubject)
+		}
+		if to := m.Header.Get("To"); to != strings.Join(test.message.To, ", ") {
+			t.Errorf("%s: To = %q", test.name, to)
+		}
+		if _, err := time.Parse(time.RFC1123Z, m.Header.Get("Date")); err != nil {
+			t.Errorf("%s: Date = %q: %v", test.name, m.Header.Get("Date"), err)
+		}
+		if !strings.HasSuffix(m.Header.Get("Message-ID"), "@example.com>") {
+			t.Errorf("%s: Message-ID = %q, want one in the domain of the sender", test.name, m.Header.Get("Message-ID"))
+		}
+
+		mediaType, params, _ := mime.ParseMediaType(m.Header.Get("Content-Type"))
+		if len(test.wantParts) == 0 {
+			if mediaType != "text/html" {
+				t.Errorf("%s: Content-Type = %s, want text/html", test.name, mediaType)
+			}
+			continue
+		}
+		var parts []string
+		reader := multipart.NewReader(m.Body, params["boundary"])
+		for {
+			part, err := reader.NextPart()
+			if err != nil {
+				break
+			}
+			partType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+			parts = append(parts, partType)
+		}
+		if strings.Join(parts, ",") != strings.Join(test.wantParts, ",") {
+			t.Errorf("%s: parts = %v, want %v", test.name, parts, test.wantParts)
+		}
+	}
+}
+
+func TestSMTPTimeout(t *testing.T) {
+	// A server that accepts the connection but never sends its greeting
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("unable to listen: %v", err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+
+	tests := []struct {
+		name     string
+		security string
+	}{
+		{"starttls", SecurityStartTLS},
+		{"tls", SecurityTLS},
+	}
+	for _, test := range tests {
+		s := &SMTPMailer{Host: host, Port: port, Security: test.security, Timeout: 200 * time.Millisecond}
+		start := time.Now()
+		err := s.Send(Message{From: "peelrescue@example.com", To: []string{"zoe@example.com"}, Text: "Hello"})
+		if err == nil {
+			t.Errorf("%s: Send to a server that does not answer returned no error", test.name)
+		}
+		if took := time.Since(start); took > 5*time.Second {
+			t.Errorf("%s: Send took %v, want it to give up after the timeout", test.name, took)
+		}
+	}
+}
+
+func TestDomainOf(t *testing.T) {
+	tests := []struct {
+		address string
+		want    string
+	}{
+		{"peelrescue@gmail.com", "gmail.com"},
+		{"a@b@example.com", "example.com"},
+		{"nobody", "localhost"},
+		{"trailing@", "localhost"},
+	}
+	for _, test := range tests {
+		if got := domainOf(test.address); got != test.want {
+			t.Errorf("domainOf(%q) = %q, want %q", test.address, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package email
+
+import (
+	"database/sql"
+	"fmt"
+	"projectGoLive/application/config"
+	"projectGoLive/application/retry"
+	"time"
+)
+
+// Status of an email in the outbox
+const (
+	// Waiting to be sent, or to be tried again after failing
+	OutboxPending = "pending"
+	// Sent through the mailer
+	OutboxSent = "sent"
+	// Given up after OutboxMaxAttempts failures, until an admin sends it again
+	OutboxDead = "dead"
+)
+
+// Number of emails read from the outbox at a time
+const outboxBatch = 20
+
+// Data structure for an email stored in the outbox
+type OutboxMessage struct {
+	ID            int64
+	Message       Message
+	Status        string
+	Attempts      int
+	NextAttemptAt time.Time
+	LastError     string
+	CreatedAt     time.Time
+	SentAt        time.Time
+}
+
+// Interface satisfied by both *sql.DB and *sql.Tx, so emails can be queued inside the transaction that stores an order
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// This method sends the emails waiting in the outbox through mailer
+// It runs forever, checking for emails to send every OutboxPollTime seconds.
+// Emails sent more than OutboxKeepDays ago are deleted, with their attachments.
+func DeliverOutbox(mailer Mailer) {
+	for {
+		deliverDue(mailer, time.Now())
+		PruneOutbox(config.DB, time.Now().AddDate(0, 0, -config.OutboxKeepDays))
+		time.Sleep(time.Second * time.Duration(config.OutboxPollTime))
+	}
+}
+
+// This function sends the emails of the outbox due by now, and schedules the failed ones to be tried again
+func deliverDue(mailer Mailer, now time.Time) {
+	var due []OutboxMessage
+	retry.RunDue(outboxBatch, func(limit int) (int, bool) {
+		var ok bool
+		due, ok = dueOutbox(config.DB, now, limit)
+		return len(due), ok
+	}, func(i int) bool {
+		return claimOutbox(config.DB, due[i].ID, now, now.Add(retryAfter(due[i].Attempts+1)))
+	}, func(i int) {
+		send(mailer, due[i], now)
+	})
+}
+
+// This function sends one email of the outbox, and marks it as sent or schedules it to be tried again
+func send(mailer Mailer, o OutboxMessage, now time.Time) {
+	o.Message.From = From
+	o.Message.FromName = FromName
+	// The Message-ID stays the same when the email is tried again, so clients do not show it twice
+	o.Message.MessageID = fmt.Sprintf("<outbox.%d.%d@%s>", o.ID, o.CreatedAt.Unix(), domainOf(From))
+	if err := mailer.Send(o.Message); err != nil {
+		o.Attempts++
+		status := OutboxPending
+		if o.Attempts >= config.OutboxMaxAttempts {
+			status = OutboxDead
+			config.Error.Printf("Email %d to %v given up after %d attempts : %v\n", o.ID, o.Message.To, o.Attempts, err)
+		} else {
+			config.Warning.Printf("Email %d to %v failed, attempt %d : %v\n", o.ID, o.Message.To, o.Attempts, err)
+		}
+		failOutbox(config.DB, o.ID, status, o.Attempts, now.Add(retryAfter(o.Attempts)), err.Error())
+		return
+	}
+	markSent(config.DB, o.ID, time.Now())
+	config.Info.Println("Email Sent Successfully to !", o.Message.To)
+}
+
+// This function returns how long to wait before the next attempt after a number of failed attempts
+// The wait doubles after each failure, from OutboxRetryTime up to OutboxMaxRetryTime.
+func retryAfter(attempts int) time.Duration {
+	return retry.Backoff(attempts, time.Duration(config.OutboxRetryTime)*time.Second, time.Duration(config.OutboxMaxRetryTime)*time.Second)
+}
@@ -0,0 +1,175 @@
+package email
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"strings"
+	"time"
+)
+
+// Columns read for each email of the outbox, in the order used by scanOutbox
+const outboxColumns = "ID, Recipients, Subject, Body, TextBody, Attachments, Status, Attempts, NextAttemptAt, LastError, CreatedAt, SentAt"
+
+// Interface satisfied by *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// Function to read one email of the outbox from a row selected with outboxColumns.
+func scanOutbox(row scanner) (OutboxMessage, error) {
+	var o OutboxMessage
+	var recipients string
+	var attachments []byte
+	var sentAt sql.NullTime
+	err := row.Scan(&o.ID, &recipients, &o.Message.Subject, &o.Message.HTML, &o.Message.Text, &attachments, &o.Status, &o.Attempts, &o.NextAttemptAt, &o.LastError, &o.CreatedAt, &sentAt)
+	if err != nil {
+		return o, err
+	}
+	o.Message.To = strings.Split(recipients, ",")
+	if sentAt.Valid {
+		o.SentAt = sentAt.Time
+	}
+	if len(attachments) > 0 {
+		err = json.Unmarshal(attachments, &o.Message.Attachments)
+	}
+	return o, err
+}
+
+//-----------------------------------------------------------------------
+// Functions for the email outbox
+//-----------------------------------------------------------------------
+// Function to store an email in the outbox of the MYSQL database, to be sent by DeliverOutbox.
+// The function takes in a handle to the database or a transaction, so an email is only queued if its order is stored.
+func Queue(db execer, m Message) error {
+	attachments, err := json.Marshal(m.Attachments)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	_, err = db.Exec("INSERT INTO `emailoutbox` (Recipients, Subject, Body, TextBody, Attachments, Status, Attempts, NextAttemptAt, LastError, CreatedAt) VALUES (?, ?, ?, ?, ?, ?, 0, ?, '', ?)",
+		strings.Join(m.To, ","), m.Subject, m.HTML, m.Text, attachments, OutboxPending, now, now)
+	if err != nil {
+		log.Println("Unable to insert the email in the outbox")
+		log.Println(err)
+	}
+	return err
+}
+
+// Function to get the emails of the outbox due to be sent by now from the MYSQL database, oldest first.
+// It returns false when there is any error encountered.
+func dueOutbox(db *sql.DB, now time.Time, limit int) ([]OutboxMessage, bool) {
+	return queryOutbox(db, "SELECT "+outboxColumns+" FROM `emailoutbox` WHERE Status=? AND NextAttemptAt<=? ORDER BY NextAttemptAt, ID LIMIT ?", OutboxPending, now, limit)
+}
+
+// Function to take an email of the outbox to send it, by moving its next attempt to until.
+// It returns false if the email is no longer due, e.g. another worker has taken it.
+func claimOutbox(db *sql.DB, id int64, now, until time.Time) bool {
+	result, err := db.Exec("UPDATE `emailoutbox` SET NextAttemptAt=? WHERE ID=? AND Status=? AND NextAttemptAt<=?", until, id, OutboxPending, now)
+	if err != nil {
+		log.Println("Unable to claim the email")
+		log.Println(err)
+		return false
+	}
+	n, _ := result.RowsAffected()
+	return n == 1
+}
+
+// Function to record in the MYSQL database that an email of the outbox has been sent.
+func markSent(db *sql.DB, id int64, now time.Time) {
+	_, err := db.Exec("UPDATE `emailoutbox` SET Status=?, SentAt=?, LastError='' WHERE ID=?", OutboxSent, now, id)
+	if err != nil {
+		log.Println("Unable to update the email")
+		log.Println(err)
+	}
+}
+
+// Function to record in the MYSQL database a failed attempt to send an email, and when to try again.
+func failOutbox(db *sql.DB, id int64, status string, attempts int, next time.Time, lastError string) {
+	_, err := db.Exec("UPDATE `emailoutbox` SET Status=?, Attempts=?, NextAttemptAt=?, LastError=? WHERE ID=?", status, attempts, next, lastError, id)
+	if err != nil {
+		log.Println("Unable to update the email")
+		log.Println(err)
+	}
+}
+
+// Function to get the emails of the outbox with a status from the MYSQL database, newest first.
+// It returns at most limit emails, and false when there is any error encountered.
+func GetOutbox(db *sql.DB, status string, limit int) ([]OutboxMessage, bool) {
+	return queryOutbox(db, "SELECT "+outboxColumns+" FROM `emailoutbox` WHERE Status=? ORDER BY ID DESC LIMIT ?", status, limit)
+}
+
+// Function to get one email of the outbox from the MYSQL database.
+// It returns false when the email does not exist or there is any error encountered.
+func GetOutboxMessage(db *sql.DB, id int64) (OutboxMessage, bool) {
+	o, err := scanOutbox(db.QueryRow("SELECT "+outboxColumns+" FROM `emailoutbox` WHERE ID=?", id))
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Println("Unable to get the email")
+			log.Println(err)
+		}
+		return o, false
+	}
+	return o, true
+}
+
+// Function to send an email of the outbox again, with its attempts counted from zero.
+// Only emails given up or already sent can be sent again.
+// It returns false when the email does not exist, is still pending, or there is any error encountered.
+func Resend(db *sql.DB, id int64, now time.Time) bool {
+	result, err := db.Exec("UPDATE `emailoutbox` SET Status=?, Attempts=0, NextAttemptAt=?, LastError='', SentAt=NULL WHERE ID=? AND Status<>?", OutboxPending, now, id, OutboxPending)
+	if err != nil {
+		log.Println("Unable to resend the email")
+		log.Println(err)
+		return false
+	}
+	n, _ := result.RowsAffected()
+	return n == 1
+}
+
+// Function to delete the emails of the outbox sent before a time from the MYSQL database.
+// It returns false when there is any error encountered.
+func PruneOutbox(db *sql.DB, before time.Time) bool {
+	_, err := db.Exec("DELETE FROM `emailoutbox` WHERE Status=? AND SentAt<?", OutboxSent, before)
+	if err != nil {
+		log.Println("Unable to prune the outbox")
+		log.Println(err)
+		return false
+	}
+	return true
+}
+
+// Function to delete every email of the outbox to an address from the MYSQL database, used when an account is purged.
+// It returns false when there is any error encountered.
+func DeleteOutbox(db *sql.DB, address string) bool {
+	_, err := db.Exec("DELETE FROM `emailoutbox` WHERE Recipients=?", address)
+	if err != nil {
+		log.Println("Unable to delete the emails of the outbox")
+		log.Println(err)
+		return false
+	}
+	return true
+}
+
+// Function to run a query selecting emails of the outbox with outboxColumns.
+func queryOutbox(db *sql.DB, query string, args ...interface{}) ([]OutboxMessage, bool) {
+	var messages []OutboxMessage
+	results, err := db.Query(query, args...)
+	if err != nil {
+		log.Println("Not able to get emails of the outbox")
+		log.Println(err)
+		return messages, false
+	}
+	defer results.Close()
+
+	for results.Next() {
+		o, err := scanOutbox(results)
+		if err != nil {
+			log.Println("Unable to get emails of the outbox")
+			log.Println(err)
+			return messages, false
+		}
+		messages = append(messages, o)
+	}
+	return messages, true
+}
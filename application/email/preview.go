@@ -0,0 +1,100 @@
+package email
+
+import (
+	"fmt"
+	"projectGoLive/application/apiclient"
+	"projectGoLive/application/coupon"
+	"projectGoLive/application/money"
+	"projectGoLive/application/orders"
+	"projectGoLive/application/tax"
+	"projectGoLive/application/user_db"
+	"time"
+)
+
+// Names of the emails of the application, previewed by admins
+var TemplateNames = []string{"checkoutbuyer", "checkoutseller", "expiry", "stock", "searchalert", "cancelbuyer", "cancelseller", "dispute"}
+
+// This function renders an email with made up data, so admins can check how its html and text look
+// The names include characters that must be escaped in html. It returns an error for an unknown email.
+func Preview(name string) (Message, error) {
+	data, ok := previewData(time.Now())[name]
+	if !ok {
+		return Message{}, fmt.Errorf("no email named %q", name)
+	}
+	m, err := render(name, data)
+	m.From, m.FromName, m.Subject = From, FromName, "Preview of "+name
+	return m, err
+}
+
+// This function returns the made up data of each email
+func previewData(now time.Time) map[string]interface{} {
+	sgd := func(cents int64) money.Money {
+		return money.Money{Amount: cents, Currency: money.DefaultCurrency}
+	}
+	buyer := user_db.UserDetails{Fullname: "Tan & Sons <Juices>", Address: "1 Orchard Road, #01-01 S238823", Phone: "91234567", Email: "buyer@example.com"}
+	seller := SellerInfo{Fullname: "O'Reilly's \"Fresh\" Fruits", Address: "2 Jurong West St 41 S649413", Phone: "98765432", Email: "seller@example.com"}
+	discount := coupon.Applied{Code: "PEEL10", Seller: "seller1", Label: "10% off items from seller1", Discount: sgd(90)}
+
+	checkout := checkoutData{
+		Name:     buyer.Fullname,
+		Buyer:    buyer,
+		PlacedAt: now,
+		Sellers: []sellerOrder{{
+			Seller: seller,
+			Lines: []itemLine{
+				{"Orange", "5 kg", sgd(120), sgd(600), sgd(50)},
+				{"Lemon", "3 kg", sgd(100), sgd(300), sgd(25)},
+			},
+			Discount:  discount,
+			Amounts:   tax.Amounts{Net: sgd(743), Tax: sgd(67), Gross: sgd(810)},
+			RateLabel: tax.Current.RateLabel(true),
+		}},
+		Total:   tax.Amounts{Net: sgd(743), Tax: sgd(67), Gross: sgd(810)},
+		Payable: sgd(810),
+	}
+	sellerCheckout := checkout
+	sellerCheckout.Name = seller.Fullname
+
+	order := orders.Order{
+		ID:           123,
+		PlacedAt:     now.Add(-time.Hour),
+		Gross:        sgd(810),
+		PaymentID:    1,
+		CancelledBy:  orders.CancelledBySeller,
+		CancelReason: "Peel <spoilt> in the rain & heat",
+		Lines: []orders.Line{
+			{Item: "Orange", Quantity: 5, Unit: "kg", UnitCost: sgd(120), Total: sgd(600)},
+			{Item: "Lemon", Quantity: 3, Unit: "kg", UnitCost: sgd(100), Total: sgd(300)},
+		},
+	}
+
+	return map[string]interface{}{
+		"checkoutbuyer":  checkout,
+		"checkoutseller": sellerCheckout,
+		"expiry": expiryData{
+			Name: seller.Fullname,
+			Item: apiclient.ItemsDetails{Item: "Orange", Quantity: 5, Unit: "kg", CollectedOn: now.AddDate(0, 0, -5), ExpiresAt: now},
+		},
+		"stock": expiryData{
+			Name: seller.Fullname,
+			Item: apiclient.ItemsDetails{ID: 45, Item: "Orange", Quantity: 2, Unit: "kg", LowStock: 5},
+		},
+		"searchalert": searchAlertData{
+			Name:     buyer.Fullname,
+			Search:   "Orange at most SGD 1.50 per unit within 5 km",
+			Item:     apiclient.ItemsDetails{ID: 46, Item: "Orange", Quantity: 8, Unit: "kg", Cost: sgd(110), Username: "O'Reilly & <Co>", CollectedOn: now.AddDate(0, 0, -1), ExpiresAt: now.AddDate(0, 0, 3)},
+			Distance: "about 3 km away",
+			Was:      sgd(150),
+		},
+		"cancelbuyer":  cancellationData{Name: buyer.Fullname, Order: order, By: "the seller " + seller.Fullname, Refunded: true},
+		"cancelseller": cancellationData{Name: seller.Fullname, Order: order, By: "you", Missing: order.Lines[1:]},
+		"dispute": disputeData{
+			Name:      buyer.Fullname,
+			Role:      "Buyer",
+			BuyerName: buyer.Fullname,
+			Dispute: orders.Dispute{OrderID: 123, Reason: "Peel was <mouldy> & wet", Status: orders.DisputeRefunded,
+				Refund: sgd(300), Note: "Refund of the lemons"},
+			Refunded: true,
+		},
+	}
+}
@@ -0,0 +1,113 @@
+package email
+
+import (
+	"bytes"
+	htmltemplate "html/template"
+	"path/filepath"
+	"projectGoLive/application/apiclient"
+	"projectGoLive/application/coupon"
+	"projectGoLive/application/money"
+	"projectGoLive/application/orders"
+	"projectGoLive/application/tax"
+	"projectGoLive/application/user_db"
+	texttemplate "text/template"
+	"time"
+)
+
+// Folder of the email templates, kept apart from the pages in templates/
+// Each email has an html template (name.gohtml) and a plain text template (name.txt), rendered with the same data.
+// Blocks shared by the emails are defined in layout.gohtml and layout.txt.
+const templateDir = "emails"
+
+// Templates of the emails, parsed when the application starts
+var (
+	htmlTemplates *htmltemplate.Template
+	textTemplates *texttemplate.Template
+)
+
+// This method parses the templates of the emails, it panics if any template is not valid
+func ParseTemplates() {
+	htmlTemplates = htmltemplate.Must(htmltemplate.ParseGlob(filepath.Join(templateDir, "*.gohtml")))
+	textTemplates = texttemplate.Must(texttemplate.ParseGlob(filepath.Join(templateDir, "*.txt")))
+}
+
+// Data of the checkout emails, checkoutbuyer and checkoutseller
+// The seller's email only has the items bought from them in Sellers.
+type checkoutData struct {
+	Name     string
+	Buyer    user_db.UserDetails
+	PlacedAt time.Time
+	Sellers  []sellerOrder
+	Total    tax.Amounts
+	// Promo code of the platform, taken off the amount payable, its zero value when none was used
+	Discount coupon.Applied
+	Payable  money.Money
+}
+
+// Items bought from one seller in the checkout emails, with their GST
+// The emails are rendered before the orders are placed, the invoice of each order is attached to them.
+type sellerOrder struct {
+	Seller SellerInfo
+	Lines  []itemLine
+	// Promo code of the seller, its zero value when none was used
+	Discount  coupon.Applied
+	Amounts   tax.Amounts
+	RateLabel string
+}
+
+// One item of the checkout emails
+type itemLine struct {
+	Item     string
+	Quantity string
+	UnitCost money.Money
+	Total    money.Money
+	Tax      money.Money
+}
+
+// Data of the expiry and stock emails
+type expiryData struct {
+	Name string
+	Item apiclient.ItemsDetails
+}
+
+// Data of the saved search alert email
+// Search describes the saved search, Distance is empty when it is not known, Was is the price the buyer was last
+// told about and its zero value for a listing new to them.
+type searchAlertData struct {
+	Name     string
+	Search   string
+	Item     apiclient.ItemsDetails
+	Distance string
+	Was      money.Money
+}
+
+// Data of the cancellation emails, cancelbuyer and cancelseller
+// By is who cancelled the order, "you" when it is the recipient.
+type cancellationData struct {
+	Name     string
+	Order    orders.Order
+	By       string
+	Missing  []orders.Line
+	Refunded bool
+}
+
+// Data of the dispute email, Role is Buyer or Seller
+type disputeData struct {
+	Name      string
+	Role      string
+	BuyerName string
+	Dispute   orders.Dispute
+	Refunded  bool
+}
+
+// This function renders an email with the html and text templates of the same name
+func render(name string, data interface{}) (Message, error) {
+	var html, text bytes.Buffer
+	if err := htmlTemplates.ExecuteTemplate(&html, name+".gohtml", data); err != nil {
+		return Message{}, err
+	}
+	if err := textTemplates.ExecuteTemplate(&text, name+".txt", data); err != nil {
+		return Message{}, err
+	}
+	return Message{HTML: html.String(), Text: text.String()}, nil
+}
@@ -0,0 +1,60 @@
+package email
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// DirMailer writes each email to a .eml file in a directory instead of sending it, for development
+// The files can be opened with any email client. The directory is read from MAIL_DIR, "mail" by default.
+type DirMailer struct {
+	Dir string
+
+	mu   sync.Mutex
+	next int
+}
+
+// This method writes an email to a new file named after the time it was sent
+func (d *DirMailer) Send(m Message) error {
+	d.mu.Lock()
+	d.next++
+	name := fmt.Sprintf("%s-%04d.eml", time.Now().Format("20060102-150405"), d.next)
+	d.mu.Unlock()
+
+	if err := os.MkdirAll(d.Dir, 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(d.Dir, name), m.Bytes(), 0644)
+}
+
+// MemoryMailer keeps the emails sent in memory, for tests
+type MemoryMailer struct {
+	mu       sync.Mutex
+	messages []Message
+}
+
+// This method keeps an email in memory
+func (mm *MemoryMailer) Send(m Message) error {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+	mm.messages = append(mm.messages, m)
+	return nil
+}
+
+// This method returns the emails sent so far, oldest first
+func (mm *MemoryMailer) Messages() []Message {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+	return append([]Message(nil), mm.messages...)
+}
+
+// This method forgets the emails sent so far
+func (mm *MemoryMailer) Reset() {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+	mm.messages = nil
+}
@@ -0,0 +1,97 @@
+package email
+
+import (
+	"crypto/tls"
+	"net"
+	"net/smtp"
+	"time"
+)
+
+// Security of the connection to the SMTP server
+const (
+	// Connect in plain text and upgrade with STARTTLS, usually on port 587
+	SecurityStartTLS = "starttls"
+	// Connect with TLS from the start, usually on port 465
+	SecurityTLS = "tls"
+	// No encryption, only for a local server
+	SecurityNone = "none"
+)
+
+// Longest time to connect to the SMTP server, and to send an email once connected, when Timeout is not set
+const (
+	smtpDialTimeout = 30 * time.Second
+	smtpSendTimeout = 2 * time.Minute
+)
+
+// SMTPMailer sends emails through an SMTP server, read from SMTP_HOST, SMTP_PORT and SMTP_SECURITY in the env file.
+// Username and Password, from SMTP_USERNAME and SMTP_PASSWORD, are only used when a username is set.
+// Timeout is the longest time a whole SMTP session can take, so a server that stops answering does not hold up the outbox.
+type SMTPMailer struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	Security string
+	Timeout  time.Duration
+}
+
+// This method sends an email to all its recipients in one SMTP session
+func (s *SMTPMailer) Send(m Message) error {
+	timeout := s.Timeout
+	if timeout == 0 {
+		timeout = smtpSendTimeout
+	}
+	dialer := net.Dialer{Timeout: smtpDialTimeout, KeepAlive: 30 * time.Second}
+	if timeout < smtpDialTimeout {
+		dialer.Timeout = timeout
+	}
+	conn, err := dialer.Dial("tcp", net.JoinHostPort(s.Host, s.Port))
+	if err != nil {
+		return err
+	}
+	// Every read and write of the session, including the TLS handshakes, fails after the deadline
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		conn.Close()
+		return err
+	}
+	if s.Security == SecurityTLS {
+		conn = tls.Client(conn, &tls.Config{ServerName: s.Host})
+	}
+	c, err := smtp.NewClient(conn, s.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if s.Security == SecurityStartTLS {
+		if err := c.StartTLS(&tls.Config{ServerName: s.Host}); err != nil {
+			return err
+		}
+	}
+	if s.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", s.Username, s.Password, s.Host)); err != nil {
+			return err
+		}
+	}
+
+	if err := c.Mail(m.From); err != nil {
+		return err
+	}
+	for _, to := range m.To {
+		if err := c.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(m.Bytes()); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
@@ -0,0 +1,217 @@
+// Package invoice renders the invoice of each order as a PDF, numbered in the order the invoices are issued.
+// The invoice is attached to the checkout emails and can be downloaded again from the order history.
+package invoice
+
+import (
+	"fmt"
+	"net/http"
+	"projectGoLive/application/config"
+	"projectGoLive/application/orders"
+	"projectGoLive/application/server"
+	"strconv"
+	"time"
+)
+
+// Layout of the page, in points from the bottom left corner
+const (
+	left   = 50.0
+	right  = pageWidth - 50
+	top    = pageHeight - 60
+	bottom = 80.0
+)
+
+// Data structure for the invoice of an order, with the details of its buyer and seller when it was issued
+type Invoice struct {
+	Number   string
+	IssuedAt time.Time
+	Order    orders.Order
+	Buyer    Party
+	Seller   Party
+}
+
+// Data structure for the details of the buyer or the seller printed on an invoice
+type Party struct {
+	Fullname string
+	Address  string
+	Phone    string
+	Email    string
+}
+
+// This method returns the name of the PDF file of the invoice
+func (inv Invoice) FileName() string {
+	return "invoice-" + inv.Number + ".pdf"
+}
+
+// This method returns the title of the invoice, a tax invoice when the seller charged GST
+func (inv Invoice) Title() string {
+	if inv.Order.Tax.Amount != 0 {
+		return "TAX INVOICE"
+	}
+	return "INVOICE"
+}
+
+// This method renders the invoice as a PDF file
+func (inv Invoice) PDF() []byte {
+	o := inv.Order
+	d := newDocument()
+	y := top
+
+	d.text(left, y, 20, true, "Peel Rescue")
+	d.textRight(right, y, 16, true, inv.Title())
+	y = y - 20
+	d.text(left, y, 10, false, "Saving Earth one Peel at a time!")
+	d.textRight(right, y, 10, false, "Invoice number : "+inv.Number)
+	y = y - 14
+	d.textRight(right, y, 10, false, "Date : "+inv.IssuedAt.Local().Format("2006-01-02"))
+	y = y - 14
+	d.textRight(right, y, 10, false, fmt.Sprintf("Order : %d placed on %s", o.ID, o.PlacedAt.Local().Format("2006-01-02 15:04")))
+	y = y - 30
+
+	// Seller on the left and buyer on the right, long details such as addresses take several lines
+	d.text(left, y, 11, true, "Seller")
+	d.text(pageWidth/2, y, 11, true, "Buyer")
+	y = y - 14
+	parties := [][2]string{
+		{inv.Seller.Fullname, inv.Buyer.Fullname},
+		{inv.Seller.Address, inv.Buyer.Address},
+		{inv.Seller.Phone, inv.Buyer.Phone},
+		{inv.Seller.Email, inv.Buyer.Email},
+	}
+	// A tax invoice shows the GST registration number the seller had when the order was placed
+	if o.GSTNumber != "" {
+		parties = append(parties, [2]string{"GST registration number : " + o.GSTNumber, ""})
+	}
+	for _, pair := range parties {
+		sellerLines := d.wrap(pair[0], pageWidth/2-left-10, 10, false)
+		buyerLines := d.wrap(pair[1], right-pageWidth/2, 10, false)
+		for n, line := range sellerLines {
+			d.text(left, y-14*float64(n), 10, false, line)
+		}
+		for n, line := range buyerLines {
+			d.text(pageWidth/2, y-14*float64(n), 10, false, line)
+		}
+		if len(buyerLines) > len(sellerLines) {
+			sellerLines = buyerLines
+		}
+		y = y - 14*float64(len(sellerLines))
+	}
+	y = y - 30
+
+	// Columns of the items table, the amounts are aligned on their right end
+	columns := []float64{left, 250, 350, 450, right}
+	header := func() {
+		d.text(columns[0], y, 10, true, "Item")
+		d.textRight(columns[1]+60, y, 10, true, "Quantity")
+		d.textRight(columns[2]+60, y, 10, true, "Unit price")
+		d.textRight(columns[3]+50, y, 10, true, "Amount")
+		d.textRight(columns[4], y, 10, true, "GST")
+		d.line(left, y-5, right, y-5)
+		y = y - 20
+	}
+	header()
+	for _, l := range o.Lines {
+		// Long item names take several lines, the first one has the amounts
+		names := d.wrap(l.Item, 200, 10, false)
+		if y-12*float64(len(names)-1) < bottom {
+			d.addPage()
+			y = top
+			header()
+		}
+		for n, name := range names {
+			d.text(columns[0], y-12*float64(n), 10, false, name)
+		}
+		d.textRight(columns[1]+60, y, 10, false, l.QuantityLabel())
+		d.textRight(columns[2]+60, y, 10, false, l.UnitCost.String())
+		d.textRight(columns[3]+50, y, 10, false, l.Total.String())
+		d.textRight(columns[4], y, 10, false, l.Tax.String())
+		y = y - 16 - 12*float64(len(names)-1)
+	}
+	d.line(left, y+8, right, y+8)
+	y = y - 10
+
+	// Totals, with the promo codes taken off
+	totals := [][2]string{}
+	if o.Discount.Amount != 0 {
+		totals = append(totals, [2]string{"Promo code " + o.Coupon, "-" + o.Discount.String()})
+	}
+	totals = append(totals,
+		[2]string{"Net", o.Net.String()},
+		[2]string{"GST", o.Tax.String()},
+		[2]string{"Gross", o.Gross.String()},
+	)
+	if o.PlatformDiscount.Amount != 0 {
+		totals = append(totals, [2]string{"Peel Rescue promo code " + o.Coupon, "-" + o.PlatformDiscount.String()})
+	}
+	if y < bottom+16*float64(len(totals)+3) {
+		d.addPage()
+		y = top
+	}
+	for _, total := range totals {
+		d.textRight(columns[3]+50, y, 10, false, total[0])
+		d.textRight(columns[4], y, 10, false, total[1])
+		y = y - 16
+	}
+	d.textRight(columns[3]+50, y, 12, true, "Amount due")
+	d.textRight(columns[4], y, 12, true, o.Due().String())
+	y = y - 30
+
+	d.text(left, y, 11, true, inv.status())
+	d.text(left, bottom-30, 9, false, "Thank you for using Peel Rescue.")
+	return d.bytes()
+}
+
+// This method returns how the order has been paid, or that it has been cancelled
+func (inv Invoice) status() string {
+	o := inv.Order
+	switch {
+	case o.Status == orders.StatusCancelled:
+		return "CANCELLED on " + o.CancelledAt.Local().Format("2006-01-02") + ", nothing is due"
+	case o.PaymentID != 0:
+		return "Paid online, please show this invoice during collection"
+	case o.Status == orders.StatusCollected:
+		return "Paid during collection on " + o.CollectedAt.Local().Format("2006-01-02")
+	}
+	return "Please pay " + o.Due().String() + " during collection"
+}
+
+// This function issues the invoices of the orders placed at checkout, for the emails sent to the buyer and sellers
+// db is the transaction storing the orders. An order whose invoice cannot be issued is left out,
+// its invoice can still be downloaded later.
+func IssueAll(db querier, placed []orders.Order, now time.Time) []Invoice {
+	var invoices []Invoice
+	for _, o := range placed {
+		if inv, ok := Issue(db, o, now); ok {
+			invoices = append(invoices, inv)
+		}
+	}
+	return invoices
+}
+
+//---------------------------------------------------------------------------
+// Functions to download invoices
+//---------------------------------------------------------------------------
+// This method is used to download the invoice of an order, by its buyer or its seller
+// The invoice is issued now if the order did not get one at checkout.
+func DownloadHandler(w http.ResponseWriter, req *http.Request) {
+	if !server.ActiveSession(w, req) {
+		http.Redirect(w, req, "/login", http.StatusSeeOther)
+		return
+	}
+	user := server.GetUser(w, req)
+
+	id, _ := strconv.ParseInt(req.FormValue("order"), 10, 64)
+	o, ok := orders.GetOrder(config.DB, id)
+	if !ok || (o.Buyer != user.Username && o.Seller != user.Username) {
+		http.NotFound(w, req)
+		return
+	}
+	inv, ok := issueAlone(config.DB, o, time.Now())
+	if !ok {
+		http.Error(w, "Unable to reach database, try again!", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", "attachment; filename=\""+inv.FileName()+"\"")
+	w.Write(inv.PDF())
+}
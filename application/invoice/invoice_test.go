@@ -0,0 +1,205 @@
+package invoice
+
+import (
+	"bytes"
+	"fmt"
+	"projectGoLive/application/money"
+	"projectGoLive/application/orders"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// This function returns the fonts installed with the DejaVu fonts package, the test is skipped without them
+func testFonts(t *testing.T) [2]*trueTypeFont {
+	var loaded [2]*trueTypeFont
+	for n, path := range []string{defaultFont, defaultBoldFont} {
+		f, err := loadFont(path)
+		if err != nil {
+			t.Skipf("the DejaVu fonts are not installed: %v", err)
+		}
+		loaded[n] = f
+	}
+	return loaded
+}
+
+// This function checks that each entry of the cross-reference table of a PDF file gives the offset of its object
+func checkXref(t *testing.T, pdf []byte) {
+	t.Helper()
+	match := regexp.MustCompile(`startxref\n(\d+)\n%%EOF\n$`).FindSubmatch(pdf)
+	if match == nil {
+		t.Fatalf("the PDF file does not end with startxref")
+	}
+	xref, _ := strconv.Atoi(string(match[1]))
+	if !bytes.HasPrefix(pdf[xref:], []byte("xref\n")) {
+		t.Fatalf("startxref %d does not point at the cross-reference table", xref)
+	}
+	lines := strings.Split(string(pdf[xref:]), "\n")
+	var count int
+	fmt.Sscanf(lines[1], "0 %d", &count)
+	for n := 1; n < count; n++ {
+		offset, _ := strconv.Atoi(lines[2+n][:10])
+		if want := fmt.Sprintf("%d 0 obj\n", n); !bytes.HasPrefix(pdf[offset:], []byte(want)) {
+			t.Errorf("object %d is not at offset %d", n, offset)
+		}
+	}
+}
+
+func TestNumber(t *testing.T) {
+	tests := []struct {
+		id   int64
+		want string
+	}{
+		{1, "PR-000001"},
+		{42, "PR-000042"},
+		{1234567, "PR-1234567"},
+	}
+	for _, test := range tests {
+		if got := number(test.id); got != test.want {
+			t.Errorf("number(%d) = %q, want %q", test.id, got, test.want)
+		}
+	}
+}
+
+func TestEscape(t *testing.T) {
+	tests := []struct {
+		s    string
+		want string
+	}{
+		{"Banana peels", "Banana peels"},
+		{`(1) \ 2`, `\(1\) \\ 2`},
+		{"Café", "Caf?"},
+		{"tab\there", "tab?here"},
+	}
+	for _, test := range tests {
+		if got := escape(test.s); got != test.want {
+			t.Errorf("escape(%q) = %q, want %q", test.s, got, test.want)
+		}
+	}
+}
+
+func TestWrap(t *testing.T) {
+	tests := []struct {
+		name  string
+		s     string
+		width float64
+		want  []string
+	}{
+		{"fits", "Banana peels", 200, []string{"Banana peels"}},
+		{"empty", "", 200, []string{""}},
+		{"between words", "Blk 123 Ang Mo Kio Avenue 6", 80, []string{"Blk 123 Ang Mo", "Kio Avenue 6"}},
+		{"extra spaces", "  Blk   123  ", 200, []string{"Blk 123"}},
+		{"long word", "Supercalifragilistic", 50, []string{"Supercalifr", "agilistic"}},
+	}
+	d := &document{}
+	for _, test := range tests {
+		got := d.wrap(test.s, test.width, 10, false)
+		if fmt.Sprint(got) != fmt.Sprint(test.want) {
+			t.Errorf("%s: wrap(%q, %v) = %q, want %q", test.name, test.s, test.width, got, test.want)
+		}
+		for _, line := range got {
+			if strings.Contains(line, " ") && d.width(line, 10, false) > test.width {
+				t.Errorf("%s: line %q is wider than %v", test.name, line, test.width)
+			}
+		}
+	}
+}
+
+func TestTrueTypeFont(t *testing.T) {
+	loaded := testFonts(t)
+	f := loaded[0]
+	tests := []struct {
+		r     rune
+		found bool
+	}{
+		{'A', true},
+		{'é', true},
+		{'Ж', true},
+		{'€', true},
+		{'\U0001F34C', false},
+	}
+	for _, test := range tests {
+		glyph := f.glyph(test.r)
+		if (glyph != 0) != test.found {
+			t.Errorf("glyph(%q) = %d, want found %v", test.r, glyph, test.found)
+		}
+		if test.found && f.width(glyph) <= 0 {
+			t.Errorf("width of %q = %d, want more than 0", test.r, f.width(glyph))
+		}
+	}
+	if f.name != "DejaVuSans" || loaded[1].name != "DejaVuSans-Bold" {
+		t.Errorf("names = %q and %q, want DejaVuSans and DejaVuSans-Bold", f.name, loaded[1].name)
+	}
+	if _, err := parseTrueType([]byte("not a font at all")); err == nil {
+		t.Errorf("parseTrueType of text did not return an error")
+	}
+}
+
+func TestSubset(t *testing.T) {
+	f := testFonts(t)[0]
+	used := map[uint16]rune{}
+	for _, r := range "Zoë Ωμέγα" {
+		used[f.glyph(r)] = r
+	}
+	sub, err := parseTrueType(f.subset(used))
+	if err != nil {
+		t.Fatalf("the subset cannot be read: %v", err)
+	}
+	if len(sub.offsets) != len(f.offsets) {
+		t.Fatalf("the subset has %d glyphs, want %d", len(sub.offsets)-1, len(f.offsets)-1)
+	}
+	// Used glyphs, and the glyphs composite glyphs such as ë are made of, keep their outlines
+	for glyph := range used {
+		if !bytes.Equal(sub.outline(glyph), f.outline(glyph)) {
+			t.Errorf("glyph %d of %q does not have its outline in the subset", glyph, used[glyph])
+		}
+		for _, component := range f.components(glyph) {
+			if !bytes.Equal(sub.outline(component), f.outline(component)) {
+				t.Errorf("component %d of %q does not have its outline in the subset", component, used[glyph])
+			}
+		}
+	}
+	if unused := f.glyph('X'); len(sub.outline(unused)) != 0 {
+		t.Errorf("the unused glyph of X has an outline in the subset")
+	}
+}
+
+func TestPDF(t *testing.T) {
+	placed := time.Date(2021, 6, 1, 10, 30, 0, 0, time.UTC)
+	line := orders.Line{Item: "Banana peels", Quantity: 2, Unit: "kg", UnitCost: money.Money{Amount: 150, Currency: "SGD"}, Total: money.Money{Amount: 300, Currency: "SGD"}}
+	long := line
+	long.Item = strings.Repeat("Organic banana peels from the hawker centre ", 4)
+	inv := Invoice{
+		Number:   "PR-000042",
+		IssuedAt: placed,
+		Order:    orders.Order{ID: 7, Buyer: "zoe", Seller: "jose", PlacedAt: placed, Lines: []orders.Line{line, long}},
+		Buyer:    Party{Fullname: "Zoë Tan", Address: "Blk 123 Ang Mo Kio Avenue 6, #12-345, Singapore 560123", Email: "zoe@example.com"},
+		Seller:   Party{Fullname: "José 陈", Address: "10 Eunos Road", Email: "jose@example.com"},
+	}
+	for n := 0; n < 60; n++ {
+		inv.Order.Lines = append(inv.Order.Lines, line)
+	}
+	installed := testFonts(t)
+
+	tests := []struct {
+		name  string
+		fonts [2]*trueTypeFont
+		want  []string
+	}{
+		{"Helvetica", [2]*trueTypeFont{}, []string{"/BaseFont /Helvetica ", "(Zo? Tan)", "/Count 2"}},
+		{"embedded", installed, []string{"/Subtype /Type0", "/FontFile2", "/ToUnicode", "/Count 2"}},
+	}
+	defer func(saved [2]*trueTypeFont) { fonts = saved }(fonts)
+	for _, test := range tests {
+		fonts = test.fonts
+		pdf := inv.PDF()
+		for _, want := range test.want {
+			if !bytes.Contains(pdf, []byte(want)) {
+				t.Errorf("%s: the PDF file does not have %q", test.name, want)
+			}
+		}
+		checkXref(t, pdf)
+	}
+}
@@ -0,0 +1,117 @@
+package invoice
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"projectGoLive/application/config"
+	"projectGoLive/application/orders"
+	"projectGoLive/application/user_db"
+	"time"
+)
+
+// Prefix of invoice numbers, followed by the sequence number of the invoice
+const numberPrefix = "PR-"
+
+// This function returns the invoice number for a sequence number, e.g. PR-000042
+func number(id int64) string {
+	return fmt.Sprintf("%s%06d", numberPrefix, id)
+}
+
+//-----------------------------------------------------------------------
+// Functions for invoices
+//-----------------------------------------------------------------------
+// Interface satisfied by both *sql.DB and *sql.Tx, so invoices can be issued inside the transaction that stores the orders
+type querier interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// Function to issue the invoice of an order in the MYSQL database, with the details its buyer and seller have now.
+// Each order has one invoice, issuing it again returns the same number, date and details, so the invoice does not
+// change when they update their profile or delete their account. The invoice is only inserted when the order does
+// not have one yet, so the invoice numbers have no gaps; db should be a transaction, in which the row is locked.
+// It returns false when there is any error encountered.
+func Issue(db querier, o orders.Order, now time.Time) (Invoice, bool) {
+	inv := Invoice{Order: o}
+	var id int64
+	var buyer, seller string
+	err := db.QueryRow("SELECT ID, IssuedAt, Buyer, Seller FROM `invoices` WHERE OrderID=? FOR UPDATE", o.ID).Scan(&id, &inv.IssuedAt, &buyer, &seller)
+	switch {
+	case err == sql.ErrNoRows:
+		inv.IssuedAt = now
+		inv.Buyer = currentParty(o.Buyer)
+		inv.Seller = currentParty(o.Seller)
+		buyer, seller = inv.Buyer.encode(), inv.Seller.encode()
+		result, err := db.Exec("INSERT INTO `invoices` (OrderID, IssuedAt, Buyer, Seller) VALUES (?, ?, ?, ?)", o.ID, now, buyer, seller)
+		if err == nil {
+			id, err = result.LastInsertId()
+		}
+		if err != nil {
+			log.Println("Unable to insert the invoice")
+			log.Println(err)
+			return inv, false
+		}
+	case err != nil:
+		log.Println("Unable to get the invoice")
+		log.Println(err)
+		return inv, false
+	case buyer == "" || seller == "":
+		// Invoices issued before the details were stored get the details the users have now, once
+		inv.Buyer = currentParty(o.Buyer)
+		inv.Seller = currentParty(o.Seller)
+		_, err = db.Exec("UPDATE `invoices` SET Buyer=?, Seller=? WHERE ID=?", inv.Buyer.encode(), inv.Seller.encode(), id)
+		if err != nil {
+			log.Println("Unable to store the details of the invoice")
+			log.Println(err)
+			return inv, false
+		}
+	default:
+		if json.Unmarshal([]byte(buyer), &inv.Buyer) != nil || json.Unmarshal([]byte(seller), &inv.Seller) != nil {
+			log.Println("Unable to read the details of invoice", id)
+			return inv, false
+		}
+	}
+	inv.Number = number(id)
+	return inv, true
+}
+
+// Function to issue the invoice of an order in its own transaction in the MYSQL database, see Issue
+// It returns false when there is any error encountered.
+func issueAlone(db *sql.DB, o orders.Order, now time.Time) (Invoice, bool) {
+	tx, err := db.Begin()
+	if err != nil {
+		log.Println("Unable to start the transaction of the invoice")
+		log.Println(err)
+		return Invoice{Order: o}, false
+	}
+	inv, ok := Issue(tx, o, now)
+	if !ok {
+		tx.Rollback()
+		return inv, false
+	}
+	if err := tx.Commit(); err != nil {
+		log.Println("Unable to commit the invoice")
+		log.Println(err)
+		return inv, false
+	}
+	return inv, true
+}
+
+// This function returns the details of a user as printed on an invoice
+// Users who deleted their account are shown by their username only.
+func currentParty(username string) Party {
+	details, _ := user_db.GetARecord(config.DB, username)
+	p := Party{Fullname: details.Fullname, Address: details.Address, Phone: details.Phone, Email: details.Email}
+	if p.Fullname == "" {
+		p.Fullname = username
+	}
+	return p
+}
+
+// This method returns the details of a user as stored with the invoice
+func (p Party) encode() string {
+	data, _ := json.Marshal(p)
+	return string(data)
+}
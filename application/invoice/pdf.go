@@ -0,0 +1,321 @@
+package invoice
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"projectGoLive/application/config"
+	"sort"
+	"strings"
+	"unicode/utf16"
+)
+
+// Size of an A4 page in points, 1/72 of an inch
+const (
+	pageWidth  = 595.0
+	pageHeight = 842.0
+)
+
+// Widths of the printable ASCII characters, from space to ~, in 1/1000 of the font size
+// Taken from the metrics of the standard Helvetica and Helvetica-Bold fonts, which every PDF reader has.
+var (
+	regularWidths = [95]int{
+		278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+		556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+		1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+		667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+		333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+		556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+	}
+	boldWidths = [95]int{
+		278, 333, 474, 556, 556, 889, 722, 238, 333, 333, 389, 584, 278, 333, 278, 278,
+		556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 333, 333, 584, 584, 584, 611,
+		975, 722, 722, 722, 722, 667, 611, 778, 722, 278, 556, 722, 611, 833, 722, 778,
+		667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 333, 278, 333, 584, 556,
+		333, 556, 611, 556, 611, 556, 333, 611, 611, 278, 278, 556, 278, 889, 611, 611,
+		611, 611, 389, 556, 333, 611, 556, 778, 556, 556, 500, 389, 280, 389, 584,
+	}
+)
+
+// Regular and bold fonts embedded in the invoices, see Setup
+// When they are not set the standard Helvetica fonts are used, which only have the printable ASCII characters.
+var fonts [2]*trueTypeFont
+
+// document is a PDF of one or more A4 pages with text and lines, in the embedded fonts or the standard Helvetica fonts
+// It only covers what an invoice needs, so no external tool or library is used to render it.
+type document struct {
+	pages []*bytes.Buffer
+	fonts [2]*trueTypeFont
+	// Glyphs drawn with each embedded font, and the character each one is for
+	used [2]map[uint16]rune
+}
+
+// This function returns a document with one empty page
+func newDocument() *document {
+	d := &document{fonts: fonts, used: [2]map[uint16]rune{{}, {}}}
+	d.addPage()
+	return d
+}
+
+// This function returns the number of a font, 0 for regular and 1 for bold
+func fontIndex(bold bool) int {
+	if bold {
+		return 1
+	}
+	return 0
+}
+
+// This method starts a new page, later text and lines are drawn on it
+func (d *document) addPage() {
+	d.pages = append(d.pages, &bytes.Buffer{})
+}
+
+// This method draws text with its left end at x, y is the baseline measured from the bottom of the page
+func (d *document) text(x, y, size float64, bold bool, s string) {
+	font := fontIndex(bold)
+	str := "(" + escape(s) + ")"
+	if f := d.fonts[font]; f != nil {
+		// Embedded fonts are encoded with 2 byte glyph numbers
+		var b strings.Builder
+		b.WriteString("<")
+		for _, r := range s {
+			glyph := f.glyph(r)
+			d.used[font][glyph] = r
+			fmt.Fprintf(&b, "%04X", glyph)
+		}
+		b.WriteString(">")
+		str = b.String()
+	}
+	fmt.Fprintf(d.pages[len(d.pages)-1], "BT /F%d %.1f Tf %.2f %.2f Td %s Tj ET\n", font+1, size, x, y, str)
+}
+
+// This method draws text with its right end at x, used for amounts
+func (d *document) textRight(x, y, size float64, bold bool, s string) {
+	d.text(x-d.width(s, size, bold), y, size, bold, s)
+}
+
+// This method returns the width of text in points
+func (d *document) width(s string, size float64, bold bool) float64 {
+	f := d.fonts[fontIndex(bold)]
+	if f == nil {
+		return textWidth(s, size, bold)
+	}
+	total := 0
+	for _, r := range s {
+		total = total + f.width(f.glyph(r))
+	}
+	return float64(total) * size / 1000
+}
+
+// This method splits text into lines no wider than width points, breaking between words when it can
+// A word wider than a line is broken between its characters.
+func (d *document) wrap(s string, width, size float64, bold bool) []string {
+	var lines []string
+	line := ""
+	for _, word := range strings.Fields(s) {
+		candidate := word
+		if line != "" {
+			candidate = line + " " + word
+		}
+		if d.width(candidate, size, bold) <= width {
+			line = candidate
+			continue
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+		line = ""
+		for _, r := range word {
+			if line != "" && d.width(line+string(r), size, bold) > width {
+				lines = append(lines, line)
+				line = ""
+			}
+			line = line + string(r)
+		}
+	}
+	if line != "" || len(lines) == 0 {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// This method draws a thin line from x1, y1 to x2, y2
+func (d *document) line(x1, y1, x2, y2 float64) {
+	fmt.Fprintf(d.pages[len(d.pages)-1], "0.5 w %.2f %.2f m %.2f %.2f l S\n", x1, y1, x2, y2)
+}
+
+// This method returns the document as a PDF file
+// Objects are numbered as: 1 catalog, 2 page tree, then the fonts, then a page and its content for each page.
+func (d *document) bytes() []byte {
+	objects := []string{"<< /Type /Catalog /Pages 2 0 R >>", ""}
+	add := func(object string) int {
+		objects = append(objects, object)
+		return len(objects)
+	}
+	var fontRefs [2]int
+	for n, name := range []string{"Helvetica", "Helvetica-Bold"} {
+		if d.fonts[n] == nil {
+			fontRefs[n] = add("<< /Type /Font /Subtype /Type1 /BaseFont /" + name + " /Encoding /WinAnsiEncoding >>")
+		} else {
+			fontRefs[n] = d.embed(d.fonts[n], d.used[n], add)
+		}
+	}
+
+	var kids []string
+	for n := range d.pages {
+		kids = append(kids, fmt.Sprintf("%d 0 R", len(objects)+1+2*n))
+	}
+	objects[1] = fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages))
+	for _, page := range d.pages {
+		add(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.0f %.0f] /Resources << /Font << /F1 %d 0 R /F2 %d 0 R >> >> /Contents %d 0 R >>",
+			pageWidth, pageHeight, fontRefs[0], fontRefs[1], len(objects)+2))
+		add(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", page.Len(), page.String()))
+	}
+
+	// The cross-reference table gives the byte offset of each object
+	var out bytes.Buffer
+	out.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for n, object := range objects {
+		offsets[n] = out.Len()
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", n+1, object)
+	}
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return out.Bytes()
+}
+
+// This method adds the objects of an embedded font to a PDF file, with the glyphs the document used, and returns the number of the font
+// The font is a Type0 font with glyph numbers as character codes, and a ToUnicode map so its text can be copied and searched.
+func (d *document) embed(f *trueTypeFont, used map[uint16]rune, add func(string) int) int {
+	name := subsetTag(used) + "+" + f.name
+
+	var file bytes.Buffer
+	raw := f.subset(used)
+	w := zlib.NewWriter(&file)
+	w.Write(raw)
+	w.Close()
+	fontFile := add(fmt.Sprintf("<< /Length %d /Length1 %d /Filter /FlateDecode >>\nstream\n%s\nendstream", file.Len(), len(raw), file.String()))
+	descriptor := add(fmt.Sprintf("<< /Type /FontDescriptor /FontName /%s /Flags 32 /FontBBox [%d %d %d %d] /ItalicAngle 0 /Ascent %d /Descent %d /CapHeight %d /StemV 80 /FontFile2 %d 0 R >>",
+		name, f.bbox[0], f.bbox[1], f.bbox[2], f.bbox[3], f.ascent, f.descent, f.capHeight, fontFile))
+
+	glyphs := make([]int, 0, len(used))
+	for glyph := range used {
+		glyphs = append(glyphs, int(glyph))
+	}
+	sort.Ints(glyphs)
+	var widths, unicode strings.Builder
+	for _, glyph := range glyphs {
+		fmt.Fprintf(&widths, "%d [%d] ", glyph, f.width(uint16(glyph)))
+		fmt.Fprintf(&unicode, "<%04X> <%s>\n", glyph, utf16Hex(used[uint16(glyph)]))
+	}
+	cidFont := add(fmt.Sprintf("<< /Type /Font /Subtype /CIDFontType2 /BaseFont /%s /CIDSystemInfo << /Registry (Adobe) /Ordering (Identity) /Supplement 0 >> /FontDescriptor %d 0 R /W [%s] /CIDToGIDMap /Identity >>",
+		name, descriptor, widths.String()))
+
+	// The map is written in ranges of at most 100 entries, as the CMap format asks
+	var cmap strings.Builder
+	cmap.WriteString("/CIDInit /ProcSet findresource begin\n12 dict begin\nbegincmap\n/CIDSystemInfo << /Registry (Adobe) /Ordering (UCS) /Supplement 0 >> def\n/CMapName /Adobe-Identity-UCS def\n/CMapType 2 def\n1 begincodespacerange\n<0000> <FFFF>\nendcodespacerange\n")
+	entries := strings.SplitAfter(unicode.String(), "\n")
+	entries = entries[:len(entries)-1]
+	for len(entries) > 0 {
+		chunk := entries
+		if len(chunk) > 100 {
+			chunk = chunk[:100]
+		}
+		fmt.Fprintf(&cmap, "%d beginbfchar\n%sendbfchar\n", len(chunk), strings.Join(chunk, ""))
+		entries = entries[len(chunk):]
+	}
+	cmap.WriteString("endcmap\nCMapName currentdict /CMap defineresource pop\nend\nend\n")
+	toUnicode := add(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", cmap.Len(), cmap.String()))
+
+	return add(fmt.Sprintf("<< /Type /Font /Subtype /Type0 /BaseFont /%s /Encoding /Identity-H /DescendantFonts [%d 0 R] /ToUnicode %d 0 R >>",
+		name, cidFont, toUnicode))
+}
+
+// This function returns a character in UTF-16 as hexadecimal, as a ToUnicode map has it
+func utf16Hex(r rune) string {
+	var b strings.Builder
+	for _, unit := range utf16.Encode([]rune{r}) {
+		fmt.Fprintf(&b, "%04X", unit)
+	}
+	return b.String()
+}
+
+// This function returns the width of text in points in the standard Helvetica fonts
+// Characters outside printable ASCII are drawn as ? by escape, so they are measured as one.
+func textWidth(s string, size float64, bold bool) float64 {
+	widths := &regularWidths
+	if bold {
+		widths = &boldWidths
+	}
+	total := 0
+	for _, r := range s {
+		if r < ' ' || r > '~' {
+			r = '?'
+		}
+		total = total + widths[r-' ']
+	}
+	return float64(total) * size / 1000
+}
+
+// This function returns text as the body of a PDF string, with the characters that end a string escaped
+func escape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteRune('\\')
+			b.WriteRune(r)
+		case r < ' ' || r > '~':
+			b.WriteRune('?')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+//---------------------------------------------------------------------------
+// Fonts of the invoices
+//---------------------------------------------------------------------------
+// Fonts used when INVOICE_FONT and INVOICE_FONT_BOLD are not set, as installed by the DejaVu fonts package
+const (
+	defaultFont     = "/usr/share/fonts/truetype/dejavu/DejaVuSans.ttf"
+	defaultBoldFont = "/usr/share/fonts/truetype/dejavu/DejaVuSans-Bold.ttf"
+)
+
+// This function reads the TrueType fonts of the invoices set in the env file, it is called when the application starts
+// The application does not start when a font that was set cannot be read. Without the default fonts the invoices
+// use the standard Helvetica fonts, which only have the printable ASCII characters.
+func Setup() {
+	for n, setting := range []struct{ name, fallback string }{{"INVOICE_FONT", defaultFont}, {"INVOICE_FONT_BOLD", defaultBoldFont}} {
+		path, set := os.LookupEnv(setting.name)
+		if !set {
+			path = setting.fallback
+		}
+		f, err := loadFont(path)
+		if err != nil && set {
+			panic(fmt.Errorf("%s: %v", setting.name, err))
+		}
+		if err != nil {
+			config.Warning.Printf("%s is not set and %s cannot be read, invoices only show ASCII characters: %v\n", setting.name, path, err)
+			continue
+		}
+		fonts[n] = f
+	}
+}
+
+// This function reads a TrueType font file
+func loadFont(path string) (*trueTypeFont, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseTrueType(data)
+}
@@ -0,0 +1,404 @@
+package invoice
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"sort"
+	"strings"
+	"unicode/utf16"
+)
+
+// trueTypeFont is a TrueType font read from a .ttf file, embedded in the invoices so any Unicode text can be drawn
+// Only the glyphs used in an invoice are embedded, see subset.
+type trueTypeFont struct {
+	name       string
+	tables     map[string][]byte
+	unitsPerEm int
+	bbox       [4]int
+	ascent     int
+	descent    int
+	capHeight  int
+	glyphs     map[rune]uint16
+	advances   []int
+	offsets    []int
+}
+
+// Tables of the font file kept in the subsets, the others are not needed to draw the glyphs or read them again
+var subsetTables = []string{"cmap", "cvt ", "fpgm", "glyf", "head", "hhea", "hmtx", "loca", "maxp", "prep"}
+
+// This function reads a TrueType font file, it returns an error when a table needed to draw text is missing or broken
+func parseTrueType(data []byte) (*trueTypeFont, error) {
+	if len(data) < 12 {
+		return nil, errors.New("not a TrueType font")
+	}
+	if version := binary.BigEndian.Uint32(data); version != 0x00010000 && version != 0x74727565 {
+		return nil, errors.New("not a TrueType font, only fonts with TrueType outlines can be embedded")
+	}
+	f := &trueTypeFont{tables: map[string][]byte{}, glyphs: map[rune]uint16{}}
+	count := int(binary.BigEndian.Uint16(data[4:]))
+	for n := 0; n < count; n++ {
+		entry := 12 + 16*n
+		if entry+16 > len(data) {
+			return nil, errors.New("the table directory is truncated")
+		}
+		offset := int(binary.BigEndian.Uint32(data[entry+8:]))
+		length := int(binary.BigEndian.Uint32(data[entry+12:]))
+		if offset < 0 || length < 0 || offset+length > len(data) {
+			return nil, errors.New("a table is outside the file")
+		}
+		f.tables[string(data[entry:entry+4])] = data[offset : offset+length]
+	}
+	for _, tag := range []string{"head", "hhea", "hmtx", "maxp", "loca", "glyf", "cmap"} {
+		if _, ok := f.tables[tag]; !ok {
+			return nil, fmt.Errorf("the %q table is missing", tag)
+		}
+	}
+
+	head := f.tables["head"]
+	hhea := f.tables["hhea"]
+	if len(head) < 54 || len(hhea) < 36 || len(f.tables["maxp"]) < 6 {
+		return nil, errors.New("the font header is truncated")
+	}
+	f.unitsPerEm = int(binary.BigEndian.Uint16(head[18:]))
+	if f.unitsPerEm == 0 {
+		return nil, errors.New("the font has no units per em")
+	}
+	for n := range f.bbox {
+		f.bbox[n] = f.scale(int(int16(binary.BigEndian.Uint16(head[36+2*n:]))))
+	}
+	f.ascent = f.scale(int(int16(binary.BigEndian.Uint16(hhea[4:]))))
+	f.descent = f.scale(int(int16(binary.BigEndian.Uint16(hhea[6:]))))
+	f.capHeight = f.ascent
+	if os2 := f.tables["OS/2"]; len(os2) >= 90 && binary.BigEndian.Uint16(os2) >= 2 {
+		f.capHeight = f.scale(int(int16(binary.BigEndian.Uint16(os2[88:]))))
+	}
+
+	// Advance widths, glyphs after the last metric have the width of the last one
+	numGlyphs := int(binary.BigEndian.Uint16(f.tables["maxp"][4:]))
+	numMetrics := int(binary.BigEndian.Uint16(hhea[34:]))
+	hmtx := f.tables["hmtx"]
+	if numMetrics == 0 || len(hmtx) < 4*numMetrics {
+		return nil, errors.New("the hmtx table is truncated")
+	}
+	f.advances = make([]int, numGlyphs)
+	for n := range f.advances {
+		metric := n
+		if metric >= numMetrics {
+			metric = numMetrics - 1
+		}
+		f.advances[n] = f.scale(int(binary.BigEndian.Uint16(hmtx[4*metric:])))
+	}
+
+	// Offsets of the glyphs in the glyf table, in short (halved) or long format
+	loca := f.tables["loca"]
+	f.offsets = make([]int, numGlyphs+1)
+	for n := range f.offsets {
+		if binary.BigEndian.Uint16(head[50:]) == 0 {
+			if len(loca) < 2*(n+1) {
+				return nil, errors.New("the loca table is truncated")
+			}
+			f.offsets[n] = 2 * int(binary.BigEndian.Uint16(loca[2*n:]))
+		} else {
+			if len(loca) < 4*(n+1) {
+				return nil, errors.New("the loca table is truncated")
+			}
+			f.offsets[n] = int(binary.BigEndian.Uint32(loca[4*n:]))
+		}
+		if f.offsets[n] > len(f.tables["glyf"]) || (n > 0 && f.offsets[n] < f.offsets[n-1]) {
+			return nil, errors.New("the loca table is broken")
+		}
+	}
+
+	if err := f.parseCmap(); err != nil {
+		return nil, err
+	}
+	f.name = f.postScriptName()
+	return f, nil
+}
+
+// This method converts a length in font units to 1/1000 of the font size, the unit of the PDF font metrics
+func (f *trueTypeFont) scale(units int) int {
+	return units * 1000 / f.unitsPerEm
+}
+
+// This method reads the glyph of each character from the Unicode cmap subtable, in format 12 (all of Unicode) or 4 (the BMP)
+func (f *trueTypeFont) parseCmap() error {
+	cmap := f.tables["cmap"]
+	if len(cmap) < 4 {
+		return errors.New("the cmap table is truncated")
+	}
+	best, bestFormat := -1, 0
+	for n := 0; n < int(binary.BigEndian.Uint16(cmap[2:])); n++ {
+		record := 4 + 8*n
+		if record+8 > len(cmap) {
+			return errors.New("the cmap table is truncated")
+		}
+		platform := binary.BigEndian.Uint16(cmap[record:])
+		encoding := binary.BigEndian.Uint16(cmap[record+2:])
+		offset := int(binary.BigEndian.Uint32(cmap[record+4:]))
+		if offset+2 > len(cmap) || !(platform == 0 || (platform == 3 && (encoding == 1 || encoding == 10))) {
+			continue
+		}
+		format := int(binary.BigEndian.Uint16(cmap[offset:]))
+		if (format == 12 && bestFormat != 12) || (format == 4 && bestFormat == 0) {
+			best, bestFormat = offset, format
+		}
+	}
+	if best < 0 {
+		return errors.New("the font has no Unicode cmap")
+	}
+
+	table := cmap[best:]
+	if bestFormat == 12 {
+		if len(table) < 16 {
+			return errors.New("the cmap table is truncated")
+		}
+		groups := int(binary.BigEndian.Uint32(table[12:]))
+		if len(table) < 16+12*groups {
+			return errors.New("the cmap table is truncated")
+		}
+		for n := 0; n < groups; n++ {
+			group := table[16+12*n:]
+			start := binary.BigEndian.Uint32(group)
+			end := binary.BigEndian.Uint32(group[4:])
+			glyph := binary.BigEndian.Uint32(group[8:])
+			for r := start; r <= end && r <= 0x10FFFF; r++ {
+				f.glyphs[rune(r)] = uint16(glyph + r - start)
+			}
+		}
+		return nil
+	}
+
+	if len(table) < 14 {
+		return errors.New("the cmap table is truncated")
+	}
+	segments := int(binary.BigEndian.Uint16(table[6:])) / 2
+	if len(table) < 16+8*segments {
+		return errors.New("the cmap table is truncated")
+	}
+	ends := table[14:]
+	starts := table[16+2*segments:]
+	deltas := table[16+4*segments:]
+	rangeOffsets := table[16+6*segments:]
+	for n := 0; n < segments; n++ {
+		start := int(binary.BigEndian.Uint16(starts[2*n:]))
+		end := int(binary.BigEndian.Uint16(ends[2*n:]))
+		delta := int(binary.BigEndian.Uint16(deltas[2*n:]))
+		rangeOffset := int(binary.BigEndian.Uint16(rangeOffsets[2*n:]))
+		for r := start; r <= end && r != 0xFFFF; r++ {
+			glyph := 0
+			if rangeOffset == 0 {
+				glyph = (r + delta) & 0xFFFF
+			} else {
+				// The offset is counted from the place it is stored in the table
+				index := 16 + 6*segments + 2*n + rangeOffset + 2*(r-start)
+				if index+2 > len(table) {
+					continue
+				}
+				if glyph = int(binary.BigEndian.Uint16(table[index:])); glyph != 0 {
+					glyph = (glyph + delta) & 0xFFFF
+				}
+			}
+			if glyph != 0 {
+				f.glyphs[rune(r)] = uint16(glyph)
+			}
+		}
+	}
+	return nil
+}
+
+// This method returns the PostScript name of the font from its name table, with the characters a PDF name cannot have left out
+func (f *trueTypeFont) postScriptName() string {
+	name := ""
+	table := f.tables["name"]
+	if len(table) >= 6 {
+		count := int(binary.BigEndian.Uint16(table[2:]))
+		storage := int(binary.BigEndian.Uint16(table[4:]))
+		for n := 0; n < count && 6+12*n+12 <= len(table) && name == ""; n++ {
+			record := table[6+12*n:]
+			platform := binary.BigEndian.Uint16(record)
+			length := int(binary.BigEndian.Uint16(record[8:]))
+			offset := storage + int(binary.BigEndian.Uint16(record[10:]))
+			if binary.BigEndian.Uint16(record[6:]) != 6 || offset+length > len(table) {
+				continue
+			}
+			raw := table[offset : offset+length]
+			if platform == 1 {
+				name = string(raw)
+			} else {
+				units := make([]uint16, len(raw)/2)
+				for i := range units {
+					units[i] = binary.BigEndian.Uint16(raw[2*i:])
+				}
+				name = string(utf16.Decode(units))
+			}
+		}
+	}
+	name = strings.Map(func(r rune) rune {
+		if r <= ' ' || r > '~' || strings.ContainsRune("()<>[]{}/%#", r) {
+			return -1
+		}
+		return r
+	}, name)
+	if name == "" {
+		name = "Embedded"
+	}
+	return name
+}
+
+// This method returns the glyph drawing a character, 0 (the missing glyph) when the font does not have it
+func (f *trueTypeFont) glyph(r rune) uint16 {
+	return f.glyphs[r]
+}
+
+// This method returns the advance width of a glyph in 1/1000 of the font size
+func (f *trueTypeFont) width(glyph uint16) int {
+	if int(glyph) >= len(f.advances) {
+		return 0
+	}
+	return f.advances[glyph]
+}
+
+// This method returns the outline of a glyph from the glyf table
+func (f *trueTypeFont) outline(glyph uint16) []byte {
+	if int(glyph)+1 >= len(f.offsets) {
+		return nil
+	}
+	return f.tables["glyf"][f.offsets[glyph]:f.offsets[glyph+1]]
+}
+
+// This method returns the glyphs a composite glyph is made of, a simple glyph has none
+func (f *trueTypeFont) components(glyph uint16) []uint16 {
+	outline := f.outline(glyph)
+	if len(outline) < 10 || int16(binary.BigEndian.Uint16(outline)) >= 0 {
+		return nil
+	}
+	var components []uint16
+	for pos := 10; pos+4 <= len(outline); {
+		flags := binary.BigEndian.Uint16(outline[pos:])
+		components = append(components, binary.BigEndian.Uint16(outline[pos+2:]))
+		pos = pos + 4
+		// Arguments are two words or two bytes, followed by an optional scale or 2 by 2 matrix
+		if flags&0x0001 != 0 {
+			pos = pos + 4
+		} else {
+			pos = pos + 2
+		}
+		switch {
+		case flags&0x0008 != 0:
+			pos = pos + 2
+		case flags&0x0040 != 0:
+			pos = pos + 4
+		case flags&0x0080 != 0:
+			pos = pos + 8
+		}
+		if flags&0x0020 == 0 {
+			break
+		}
+	}
+	return components
+}
+
+// This method returns a font file with the outlines of the given glyphs only, and of the glyphs they are made of
+// The other glyphs are left empty, so glyph numbers are unchanged and the text can use them as they are.
+func (f *trueTypeFont) subset(used map[uint16]rune) []byte {
+	keep := map[uint16]bool{}
+	pending := []uint16{0}
+	for glyph := range used {
+		pending = append(pending, glyph)
+	}
+	for len(pending) > 0 {
+		glyph := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if keep[glyph] {
+			continue
+		}
+		keep[glyph] = true
+		pending = append(pending, f.components(glyph)...)
+	}
+
+	// The outlines are kept 4 byte aligned, with long offsets in loca
+	var glyf bytes.Buffer
+	loca := make([]byte, 4*len(f.offsets))
+	for glyph := 0; glyph+1 < len(f.offsets); glyph++ {
+		binary.BigEndian.PutUint32(loca[4*glyph:], uint32(glyf.Len()))
+		if keep[uint16(glyph)] {
+			glyf.Write(f.outline(uint16(glyph)))
+			for glyf.Len()%4 != 0 {
+				glyf.WriteByte(0)
+			}
+		}
+	}
+	binary.BigEndian.PutUint32(loca[4*(len(f.offsets)-1):], uint32(glyf.Len()))
+	head := append([]byte(nil), f.tables["head"]...)
+	binary.BigEndian.PutUint32(head[8:], 0)
+	binary.BigEndian.PutUint16(head[50:], 1)
+
+	tables := map[string][]byte{"glyf": glyf.Bytes(), "loca": loca, "head": head}
+	var tags []string
+	for _, tag := range subsetTables {
+		if _, ok := tables[tag]; !ok {
+			table, ok := f.tables[tag]
+			if !ok {
+				continue
+			}
+			tables[tag] = table
+		}
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+
+	// Offset table, then the directory of the tables and the tables themselves
+	var out bytes.Buffer
+	entrySelector := 0
+	for 1<<(entrySelector+1) <= len(tags) {
+		entrySelector++
+	}
+	searchRange := 16 << entrySelector
+	binary.Write(&out, binary.BigEndian, []uint16{1, 0, uint16(len(tags)), uint16(searchRange), uint16(entrySelector), uint16(16*len(tags) - searchRange)})
+	offset := 12 + 16*len(tags)
+	for _, tag := range tags {
+		table := tables[tag]
+		out.WriteString(tag)
+		binary.Write(&out, binary.BigEndian, []uint32{checksum(table), uint32(offset), uint32(len(table))})
+		offset = offset + (len(table)+3)/4*4
+	}
+	for _, tag := range tags {
+		out.Write(tables[tag])
+		for out.Len()%4 != 0 {
+			out.WriteByte(0)
+		}
+	}
+	return out.Bytes()
+}
+
+// This function returns the checksum of a table of a font file, the sum of its 32 bit words
+func checksum(table []byte) uint32 {
+	var sum uint32
+	for n := 0; n < len(table); n = n + 4 {
+		var word [4]byte
+		copy(word[:], table[n:])
+		sum = sum + binary.BigEndian.Uint32(word[:])
+	}
+	return sum
+}
+
+// This function returns the tag of a font subset, six capital letters worked out from the glyphs it has
+// The tag goes before the name of the font, as PDF readers expect for subsets.
+func subsetTag(used map[uint16]rune) string {
+	glyphs := make([]int, 0, len(used))
+	for glyph := range used {
+		glyphs = append(glyphs, int(glyph))
+	}
+	sort.Ints(glyphs)
+	hash := crc32.ChecksumIEEE([]byte(fmt.Sprint(glyphs)))
+	tag := make([]byte, 6)
+	for n := range tag {
+		tag[n] = byte('A' + hash%26)
+		hash = hash / 26
+	}
+	return string(tag)
+}
@@ -0,0 +1,162 @@
+// Package ledger records the money owed between buyers, sellers and the platform as double-entry postings.
+// Every posting has entries whose debits and credits add up to the same amount, so the accounts always balance.
+//
+// Accounts used by the application:
+//   buyer:<name>         what a buyer owes, a debit balance
+//   seller:<name>        what the platform owes a seller, a credit balance, or a debit balance if the seller owes fees
+//   platform:commission  commission charged to sellers, a credit balance
+//   platform:promotions  platform-wide promo codes paid by the platform, a debit balance
+//   platform:payments    payments taken online from buyers and held by the platform, a debit balance
+package ledger
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"projectGoLive/application/money"
+	"strings"
+	"time"
+)
+
+// Accounts of the platform
+const (
+	Commission = "platform:commission"
+	Promotions = "platform:promotions"
+	Payments   = "platform:payments"
+)
+
+// Kinds of entries, used to break down the balance of a seller
+const (
+	KindSale       = "sale"
+	KindFee        = "fee"
+	KindCollection = "collection"
+	KindPayment    = "payment"
+	KindRefund     = "refund"
+)
+
+// Errors returned by this package
+var (
+	ErrUnbalanced = errors.New("debits and credits of the posting are not equal")
+)
+
+// Data structure for each entry of a posting, one of Debit and Credit is zero
+type Entry struct {
+	ID       int64
+	OrderID  int64
+	Account  string
+	Kind     string
+	Debit    money.Money
+	Credit   money.Money
+	Memo     string
+	PostedAt time.Time
+}
+
+// This function returns the account of a buyer
+func BuyerAccount(buyername string) string {
+	return "buyer:" + buyername
+}
+
+// This function returns the account of a seller
+func SellerAccount(sellername string) string {
+	return "seller:" + sellername
+}
+
+// This function returns the name of the seller of a seller account
+func SellerName(account string) string {
+	return strings.TrimPrefix(account, "seller:")
+}
+
+// This function returns an entry debiting an account
+func Debit(account, kind string, amount money.Money) Entry {
+	return Entry{Account: account, Kind: kind, Debit: amount, Credit: money.Zero(amount.Currency)}
+}
+
+// This function returns an entry crediting an account
+func Credit(account, kind string, amount money.Money) Entry {
+	return Entry{Account: account, Kind: kind, Debit: money.Zero(amount.Currency), Credit: amount}
+}
+
+// This function returns entries undoing the given entries, each debit becomes a credit of the same kind
+func Reverse(entries []Entry) []Entry {
+	reversed := make([]Entry, 0, len(entries))
+	for _, e := range entries {
+		e.Debit, e.Credit = e.Credit, e.Debit
+		reversed = append(reversed, e)
+	}
+	return reversed
+}
+
+// Interface satisfied by both *sql.DB and *sql.Tx, so postings can be made inside the transaction that changes an order
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+//-----------------------------------------------------------------------
+// Functions for ledger entries
+//-----------------------------------------------------------------------
+// Function to record a posting in the MYSQL database.
+// The function takes in a handle to the database or a transaction, the order the posting is about, a memo and its entries.
+// Entries with a zero amount are left out.
+// It returns ErrUnbalanced if the debits and credits are not equal or not in one currency, and nothing is recorded.
+func Post(db execer, orderID int64, memo string, now time.Time, entries []Entry) error {
+	var debits, credits int64
+	currency := ""
+	var posted []Entry
+	for _, e := range entries {
+		if e.Debit.Amount == 0 && e.Credit.Amount == 0 {
+			continue
+		}
+		for _, c := range []string{e.Debit.Currency, e.Credit.Currency} {
+			if currency == "" {
+				currency = c
+			} else if c != currency {
+				return ErrUnbalanced
+			}
+		}
+		debits = debits + e.Debit.Amount
+		credits = credits + e.Credit.Amount
+		posted = append(posted, e)
+	}
+	if debits != credits {
+		return ErrUnbalanced
+	}
+
+	for _, e := range posted {
+		_, err := db.Exec("INSERT INTO `ledgerentries` (OrderID, Account, Kind, Debit, Credit, Currency, Memo, PostedAt) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+			orderID, e.Account, e.Kind, e.Debit.Amount, e.Credit.Amount, currency, memo, now)
+		if err != nil {
+			log.Println("Unable to insert the ledger entry")
+			log.Println(err)
+			return err
+		}
+	}
+	return nil
+}
+
+// Function to get the entries of an account from the MYSQL database, oldest first.
+// It returns false when there is any error encountered and retrieval of entries is not successful.
+func GetEntries(db *sql.DB, account string) ([]Entry, bool) {
+	var entries []Entry
+	results, err := db.Query("SELECT ID, OrderID, Account, Kind, Debit, Credit, Currency, Memo, PostedAt FROM `ledgerentries` WHERE Account=? ORDER BY ID", account)
+	if err != nil {
+		log.Println("Not able to get ledger entries")
+		log.Println(err)
+		return entries, false
+	}
+	defer results.Close()
+
+	for results.Next() {
+		var e Entry
+		var currency string
+		err := results.Scan(&e.ID, &e.OrderID, &e.Account, &e.Kind, &e.Debit.Amount, &e.Credit.Amount, &currency, &e.Memo, &e.PostedAt)
+		if err != nil {
+			log.Println("Unable to get ledger entries")
+			log.Println(err)
+			return entries, false
+		}
+		e.Debit.Currency = currency
+		e.Credit.Currency = currency
+		entries = append(entries, e)
+	}
+	return entries, true
+}
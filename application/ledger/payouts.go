@@ -0,0 +1,113 @@
+package ledger
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"io"
+	"log"
+	"projectGoLive/application/money"
+	"sort"
+	"time"
+)
+
+// Summary of the entries of a seller account over a period
+// Sales are credited at checkout, less cancelled orders, Fees are the commission and Collected is paid by buyers to the seller in person.
+// Refunds are given back to buyers at the seller's expense after a dispute.
+// Balance is what the platform owes the seller for the period, it is negative when the seller owes the platform.
+type Payout struct {
+	Seller    string
+	Sales     money.Money
+	Fees      money.Money
+	Collected money.Money
+	Refunds   money.Money
+	Balance   money.Money
+}
+
+// Function to sum up the entries of every seller account posted from from until before to, from the MYSQL database.
+// Passing zero times for both covers all entries.
+// It returns the payouts in seller name order, and false when there is any error encountered.
+func Payouts(db *sql.DB, from, to time.Time) ([]Payout, bool) {
+	return sumPayouts(db, "LIKE", SellerAccount("%"), from, to)
+}
+
+// Function to sum up all the entries of one seller account from the MYSQL database.
+// It returns one payout for each currency the seller sold in, and false when there is any error encountered.
+func SellerPayouts(db *sql.DB, sellername string) ([]Payout, bool) {
+	return sumPayouts(db, "=", SellerAccount(sellername), time.Time{}, time.Time{})
+}
+
+// Function to sum up the entries of the seller accounts compared to account with op, = or LIKE, between from and to.
+func sumPayouts(db *sql.DB, op, account string, from, to time.Time) ([]Payout, bool) {
+	var payouts []Payout
+	query := "SELECT Account, Kind, Currency, SUM(Credit)-SUM(Debit) FROM `ledgerentries` WHERE Account " + op + " ?"
+	args := []interface{}{account}
+	if !from.IsZero() {
+		query = query + " AND PostedAt >= ?"
+		args = append(args, from)
+	}
+	if !to.IsZero() {
+		query = query + " AND PostedAt < ?"
+		args = append(args, to)
+	}
+	query = query + " GROUP BY Account, Kind, Currency"
+
+	results, err := db.Query(query, args...)
+	if err != nil {
+		log.Println("Not able to get payouts")
+		log.Println(err)
+		return payouts, false
+	}
+	defer results.Close()
+
+	// A seller selling in several currencies has a payout for each currency
+	bySeller := make(map[string]*Payout)
+	for results.Next() {
+		var account, kind, currency string
+		var amount int64
+		if err := results.Scan(&account, &kind, &currency, &amount); err != nil {
+			log.Println("Unable to get payouts")
+			log.Println(err)
+			return payouts, false
+		}
+		seller := SellerName(account)
+		p, ok := bySeller[seller+" "+currency]
+		if !ok {
+			p = &Payout{Seller: seller, Sales: money.Zero(currency), Fees: money.Zero(currency), Collected: money.Zero(currency), Refunds: money.Zero(currency), Balance: money.Zero(currency)}
+			bySeller[seller+" "+currency] = p
+		}
+		// Fees, collections and refunds are debits, shown as positive amounts
+		switch kind {
+		case KindSale:
+			p.Sales.Amount = p.Sales.Amount + amount
+		case KindFee:
+			p.Fees.Amount = p.Fees.Amount - amount
+		case KindCollection:
+			p.Collected.Amount = p.Collected.Amount - amount
+		case KindRefund:
+			p.Refunds.Amount = p.Refunds.Amount - amount
+		}
+		p.Balance.Amount = p.Balance.Amount + amount
+	}
+
+	for _, p := range bySeller {
+		payouts = append(payouts, *p)
+	}
+	sort.Slice(payouts, func(i, j int) bool {
+		if payouts[i].Seller != payouts[j].Seller {
+			return payouts[i].Seller < payouts[j].Seller
+		}
+		return payouts[i].Balance.Currency < payouts[j].Balance.Currency
+	})
+	return payouts, true
+}
+
+// This function writes a payout report as CSV, with a header row and amounts in major units
+func WritePayoutsCSV(w io.Writer, payouts []Payout) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"Seller", "Currency", "Sales", "Fees", "Collected", "Refunds", "Balance"})
+	for _, p := range payouts {
+		cw.Write([]string{p.Seller, p.Balance.Currency, p.Sales.Decimal(), p.Fees.Decimal(), p.Collected.Decimal(), p.Refunds.Decimal(), p.Balance.Decimal()})
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,35 @@
+package listingevents
+
+import (
+	"database/sql"
+	"log"
+)
+
+// Function to get the ID of the last event handled from the MYSQL database.
+// The function takes in the handle to the database and the name of the cursor.
+// It returns 0 if the cursor has never been stored, and false when there is any error encountered.
+func GetCursor(db *sql.DB, name string) (int64, bool) {
+	var lastID int64
+	err := db.QueryRow("SELECT LastID FROM `eventcursors` WHERE Name=?", name).Scan(&lastID)
+	if err == sql.ErrNoRows {
+		return 0, true
+	}
+	if err != nil {
+		log.Println("Unable to get the cursor")
+		log.Println(err)
+		return 0, false
+	}
+	return lastID, true
+}
+
+// Function to store the ID of the last event handled in the MYSQL database.
+// It returns false when there is any error encountered, and the cursor is not stored.
+func SetCursor(db *sql.DB, name string, lastID int64) bool {
+	_, err := db.Exec("INSERT INTO `eventcursors` (Name, LastID) VALUES (?, ?) ON DUPLICATE KEY UPDATE LastID=VALUES(LastID)", name, lastID)
+	if err != nil {
+		log.Println("Unable to store the cursor")
+		log.Println(err)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,116 @@
+// Package listingevents reads the events about listings recorded by the seller API, such as listings that expired,
+// and passes each event to the handlers subscribed to its kind.
+// The ID of the last event handled is stored in the user database, so no event is missed across restarts.
+package listingevents
+
+import (
+	"sync"
+	"time"
+
+	"projectGoLive/application/apiclient"
+	"projectGoLive/application/config"
+	"projectGoLive/application/email"
+	"projectGoLive/application/notify"
+	"projectGoLive/application/photo"
+)
+
+// Name of the cursor storing the ID of the last event handled
+const cursorName = "listingevents"
+
+// Handler is a function that acts on one event about a listing
+// It returns false if the event could not be handled, the event is then handled again at the next poll,
+// by every handler of its kind, so handlers must not act twice on an event they already handled.
+type Handler func(event apiclient.ListingEvent) bool
+
+// Handlers subscribed to each kind of event
+var (
+	handlers   = make(map[string][]Handler)
+	handlersMu sync.Mutex
+)
+
+func init() {
+	Subscribe(apiclient.EventExpired, notifyExpired)
+	Subscribe(apiclient.EventLowStock, notifyStock)
+	Subscribe(apiclient.EventSoldOut, notifyStock)
+}
+
+// This method subscribes a handler to a kind of event, handlers are called in the order they subscribe
+func Subscribe(kind string, h Handler) {
+	handlersMu.Lock()
+	handlers[kind] = append(handlers[kind], h)
+	handlersMu.Unlock()
+}
+
+// This method reads and handles new events about listings
+// It runs forever, checking for new events every EventPollTime seconds
+func WatchListingEvents() {
+	for {
+		pollEvents()
+		time.Sleep(time.Second * time.Duration(config.EventPollTime))
+	}
+}
+
+// This method handles all events recorded after the stored cursor, and moves the cursor past them
+// The cursor stops before the first event a handler fails on, so it is tried again at the next poll.
+func pollEvents() {
+	after, ok := GetCursor(config.DB, cursorName)
+	if !ok {
+		config.Error.Println("Unable to read listing events cursor")
+		return
+	}
+
+	for {
+		events, ok := apiclient.GetEvents(after)
+		if !ok || len(events) == 0 {
+			return
+		}
+		handled := true
+		for _, event := range events {
+			if !handle(event) {
+				config.Error.Printf("Unable to handle listing event %d, it is tried again later\n", event.ID)
+				handled = false
+				break
+			}
+			after = event.ID
+		}
+		if !SetCursor(config.DB, cursorName, after) {
+			config.Error.Println("Unable to store listing events cursor")
+			return
+		}
+		if !handled {
+			return
+		}
+	}
+}
+
+// This method passes an event to every handler subscribed to its kind
+// It returns false if any handler could not handle the event.
+func handle(event apiclient.ListingEvent) bool {
+	handlersMu.Lock()
+	hs := handlers[event.Kind]
+	handlersMu.Unlock()
+	ok := true
+	for _, h := range hs {
+		if !h(event) {
+			ok = false
+		}
+	}
+	return ok
+}
+
+// This method tells the seller of a listing that it expired, by email and in the app, and removes the photo of the listing
+// A listing that had sold out is removed without an email, there was nothing left for sale.
+func notifyExpired(event apiclient.ListingEvent) bool {
+	config.Info.Printf("Listing %s of seller %s expired\n", event.Item.Item, event.Item.Username)
+	photo.Delete(event.Item.Photo)
+	if event.Item.SoldOut() {
+		return true
+	}
+	return email.SendExpiryNotice(event.Item.Username, event.Item) && notify.ListingExpired(event.Item)
+}
+
+// This method tells the seller of a listing that it is running low or sold out, by email and in the app
+func notifyStock(event apiclient.ListingEvent) bool {
+	config.Info.Printf("Listing %d %s of seller %s is %s\n", event.Item.ID, event.Item.Item, event.Item.Username, event.Kind)
+	return email.SendStockNotice(event.Item.Username, event.Item) && notify.StockChanged(event.Item)
+}
@@ -0,0 +1,117 @@
+// Package location works out where users are from the postal code in their address, without calling any online service.
+// Singapore postal codes have six digits, the first two being the postal sector. Each sector belongs to one of the
+// 28 postal districts, and the location of a user is taken as the centre of their district, which is close enough
+// to tell buyers which sellers are near them. Distances are only approximate, they are shown rounded to the km and
+// users of the same district are shown as in the same district. The location of each user is stored when they sign up
+// and when they change their address.
+package location
+
+import (
+	"fmt"
+	"math"
+	"projectGoLive/application/config"
+	"projectGoLive/application/user_db"
+	"regexp"
+)
+
+// Radius of the earth in km, used to work out distances
+const earthRadius = 6371.0
+
+// Distance in km under which two users are in the same postal district, as the centres of districts are further apart
+const sameDistrictKm = 0.5
+
+// Postal codes are 6 digits, written alone or after an S as in S238823
+var postalMatch = regexp.MustCompile(`(?:^|\D)(\d{6})(?:\D|$)`)
+
+// Data structure for a place on the map, in degrees
+type Point struct {
+	Lat float64
+	Lng float64
+}
+
+// This function returns the last postal code written in an address
+// It returns false if the address has no postal code.
+func PostalCode(address string) (string, bool) {
+	matches := postalMatch.FindAllStringSubmatch(address, -1)
+	if len(matches) == 0 {
+		return "", false
+	}
+	return matches[len(matches)-1][1], true
+}
+
+// This function returns the centre of the postal district of a postal code
+// It returns false for a postal code in no known sector.
+func Lookup(postal string) (Point, bool) {
+	d, ok := lookupDistrict(postal)
+	return d.Centre, ok
+}
+
+// This function returns the postal district of a postal code
+// It returns false for a postal code in no known sector.
+func lookupDistrict(postal string) (district, bool) {
+	if len(postal) != 6 {
+		return district{}, false
+	}
+	number, ok := sectors[postal[:2]]
+	if !ok {
+		return district{}, false
+	}
+	return districts[number], true
+}
+
+// This function returns the location of an address from its postal code
+// It returns false if the address has no known postal code.
+func FromAddress(address string) (Point, bool) {
+	postal, ok := PostalCode(address)
+	if !ok {
+		return Point{}, false
+	}
+	return Lookup(postal)
+}
+
+// This function returns the names of the areas in the postal district of an address, such as "Tampines, Pasir Ris"
+// It is shown to other users instead of the address itself.
+// It returns false if the address has no known postal code.
+func Area(address string) (string, bool) {
+	postal, ok := PostalCode(address)
+	if !ok {
+		return "", false
+	}
+	d, ok := lookupDistrict(postal)
+	return d.Name, ok
+}
+
+// This function returns the stored location of a user
+// The location is stored when users sign up or change their address, it is only worked out from the address
+// of their account, and stored, for users who signed up before locations were stored.
+// It returns false if the user cannot be found or their address has no known postal code.
+func OfUser(username string) (Point, bool) {
+	p, found, ok := getUser(config.DB, username)
+	if found || !ok {
+		return p, found
+	}
+	details, ok := user_db.GetARecord(config.DB, username)
+	if !ok {
+		return p, false
+	}
+	return SaveUser(config.DB, username, details.Address)
+}
+
+// This function returns the distance between two points in km, as the crow flies
+func DistanceKm(a, b Point) float64 {
+	rad := math.Pi / 180
+	dLat := (b.Lat - a.Lat) * rad
+	dLng := (b.Lng - a.Lng) * rad
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(a.Lat*rad)*math.Cos(b.Lat*rad)*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * earthRadius * math.Asin(math.Sqrt(h))
+}
+
+// This function returns a label for the distance in km between two users, such as "about 3 km away"
+// Locations are only known to the centre of a postal district, so distances are rounded to the km,
+// and users of the same district are said to be in it rather than 0 km apart.
+func Approximate(km float64) string {
+	if km < sameDistrictKm {
+		return "in the same postal district"
+	}
+	return fmt.Sprintf("about %.0f km away", math.Round(km))
+}
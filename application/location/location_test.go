@@ -0,0 +1,105 @@
+package location
+
+import (
+	"math"
+	"testing"
+)
+
+func TestDistanceKm(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b Point
+		want float64
+	}{
+		{"same point", Point{1.3521, 103.8198}, Point{1.3521, 103.8198}, 0},
+		{"one degree of latitude", Point{0, 0}, Point{1, 0}, 111.195},
+		{"one degree of longitude at the equator", Point{0, 0}, Point{0, 1}, 111.195},
+		{"half way round", Point{0, 0}, Point{0, 180}, 20015.087},
+		{"Raffles Place to Tampines", districts[1].Centre, districts[18].Centre, 13.097},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DistanceKm(tt.a, tt.b); math.Abs(got-tt.want) > 0.001 {
+				t.Errorf("DistanceKm(%v, %v) = %.3f, want %.3f", tt.a, tt.b, got, tt.want)
+			}
+			if got, back := DistanceKm(tt.a, tt.b), DistanceKm(tt.b, tt.a); math.Abs(got-back) > 1e-9 {
+				t.Errorf("DistanceKm is %.6f one way and %.6f the other", got, back)
+			}
+		})
+	}
+}
+
+func TestApproximate(t *testing.T) {
+	tests := []struct {
+		km   float64
+		want string
+	}{
+		{0, "in the same postal district"},
+		{0.49, "in the same postal district"},
+		{0.5, "about 1 km away"},
+		{1.2, "about 1 km away"},
+		{2.5, "about 3 km away"},
+		{14.394, "about 14 km away"},
+	}
+	for _, tt := range tests {
+		if got := Approximate(tt.km); got != tt.want {
+			t.Errorf("Approximate(%v) = %q, want %q", tt.km, got, tt.want)
+		}
+	}
+}
+
+func TestPostalCode(t *testing.T) {
+	tests := []struct {
+		address string
+		want    string
+		wantOk  bool
+	}{
+		{"10 Tampines Central 1, Singapore 529536", "529536", true},
+		{"Blk 123 #04-56 S238823", "238823", true},
+		{"Unit 123456, Singapore 018956", "018956", true},
+		{"1234567 Long Number Road", "", false},
+		{"No postal code", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := PostalCode(tt.address)
+		if got != tt.want || ok != tt.wantOk {
+			t.Errorf("PostalCode(%q) = %q, %v, want %q, %v", tt.address, got, ok, tt.want, tt.wantOk)
+		}
+	}
+}
+
+func TestArea(t *testing.T) {
+	tests := []struct {
+		address string
+		want    string
+		wantOk  bool
+	}{
+		{"Singapore 529536", districts[18].Name, true},
+		{"Singapore 018956", districts[1].Name, true},
+		{"Singapore 999999", "", false},
+		{"No postal code", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := Area(tt.address)
+		if got != tt.want || ok != tt.wantOk {
+			t.Errorf("Area(%q) = %q, %v, want %q, %v", tt.address, got, ok, tt.want, tt.wantOk)
+		}
+	}
+}
+
+func TestSectors(t *testing.T) {
+	for sector, number := range sectors {
+		if _, ok := districts[number]; !ok {
+			t.Errorf("sector %s is in district %d, which is not known", sector, number)
+		}
+	}
+
+	// Users of different districts are never shown as in the same district
+	for i, a := range districts {
+		for j, b := range districts {
+			if i != j && DistanceKm(a.Centre, b.Centre) < sameDistrictKm {
+				t.Errorf("districts %d and %d are less than %v km apart", i, j, sameDistrictKm)
+			}
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package location
+
+import (
+	"database/sql"
+	"log"
+	"time"
+)
+
+//-----------------------------------------------------------------------
+// Functions for the locations of users
+//-----------------------------------------------------------------------
+// Function to work out the location of a user from their address and store it in the MYSQL database.
+// The stored location is removed when the address has no known postal code.
+// It returns the location, and false when it is not known or there is any error encountered.
+func SaveUser(db *sql.DB, username, address string) (Point, bool) {
+	postal, ok := PostalCode(address)
+	var p Point
+	if ok {
+		p, ok = Lookup(postal)
+	}
+	if !ok {
+		DeleteUser(db, username)
+		return p, false
+	}
+
+	_, err := db.Exec("INSERT INTO `userlocations` (Username, PostalCode, Latitude, Longitude, UpdatedAt) VALUES (?, ?, ?, ?, ?) ON DUPLICATE KEY UPDATE PostalCode=VALUES(PostalCode), Latitude=VALUES(Latitude), Longitude=VALUES(Longitude), UpdatedAt=VALUES(UpdatedAt)",
+		username, postal, p.Lat, p.Lng, time.Now())
+	if err != nil {
+		log.Println("Unable to save the user location")
+		log.Println(err)
+		return p, false
+	}
+	return p, true
+}
+
+// Function to get the stored location of a user from the MYSQL database.
+// It returns false as second value if no location is stored for the user, and false as third value
+// when there is any error encountered.
+func getUser(db *sql.DB, username string) (Point, bool, bool) {
+	var p Point
+	err := db.QueryRow("SELECT Latitude, Longitude FROM `userlocations` WHERE Username=?", username).Scan(&p.Lat, &p.Lng)
+	if err == sql.ErrNoRows {
+		return p, false, true
+	}
+	if err != nil {
+		log.Println("Unable to get the user location")
+		log.Println(err)
+		return p, false, false
+	}
+	return p, true, true
+}
+
+// Function to delete the stored location of a user from the MYSQL database, used when the account is purged.
+// It returns false when there is any error encountered.
+func DeleteUser(db *sql.DB, username string) bool {
+	_, err := db.Exec("DELETE FROM `userlocations` WHERE Username=?", username)
+	if err != nil {
+		log.Println("Unable to delete the user location")
+		log.Println(err)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,71 @@
+package location
+
+// Postal district of each postal sector, the first two digits of a postal code
+var sectors = map[string]int{
+	"01": 1, "02": 1, "03": 1, "04": 1, "05": 1, "06": 1,
+	"07": 2, "08": 2,
+	"14": 3, "15": 3, "16": 3,
+	"09": 4, "10": 4,
+	"11": 5, "12": 5, "13": 5,
+	"17": 6,
+	"18": 7, "19": 7,
+	"20": 8, "21": 8,
+	"22": 9, "23": 9,
+	"24": 10, "25": 10, "26": 10, "27": 10,
+	"28": 11, "29": 11, "30": 11,
+	"31": 12, "32": 12, "33": 12,
+	"34": 13, "35": 13, "36": 13, "37": 13,
+	"38": 14, "39": 14, "40": 14, "41": 14,
+	"42": 15, "43": 15, "44": 15, "45": 15,
+	"46": 16, "47": 16, "48": 16,
+	"49": 17, "50": 17, "81": 17,
+	"51": 18, "52": 18,
+	"53": 19, "54": 19, "55": 19, "82": 19,
+	"56": 20, "57": 20,
+	"58": 21, "59": 21,
+	"60": 22, "61": 22, "62": 22, "63": 22, "64": 22,
+	"65": 23, "66": 23, "67": 23, "68": 23,
+	"69": 24, "70": 24, "71": 24,
+	"72": 25, "73": 25,
+	"77": 26, "78": 26,
+	"75": 27, "76": 27,
+	"79": 28, "80": 28,
+}
+
+// Data structure for a postal district, with the names of the areas in it and its approximate centre
+type district struct {
+	Name   string
+	Centre Point
+}
+
+// Postal districts, by number
+var districts = map[int]district{
+	1:  {"Raffles Place, Marina", Point{1.2840, 103.8510}},
+	2:  {"Anson, Tanjong Pagar", Point{1.2760, 103.8450}},
+	3:  {"Queenstown, Tiong Bahru", Point{1.2900, 103.8100}},
+	4:  {"Telok Blangah, Harbourfront", Point{1.2700, 103.8200}},
+	5:  {"Pasir Panjang, Clementi", Point{1.3000, 103.7700}},
+	6:  {"High Street, Beach Road", Point{1.2930, 103.8500}},
+	7:  {"Middle Road, Golden Mile", Point{1.3000, 103.8600}},
+	8:  {"Little India", Point{1.3100, 103.8550}},
+	9:  {"Orchard, River Valley", Point{1.3040, 103.8320}},
+	10: {"Bukit Timah, Holland Road", Point{1.3180, 103.8050}},
+	11: {"Novena, Thomson", Point{1.3250, 103.8400}},
+	12: {"Balestier, Toa Payoh", Point{1.3300, 103.8550}},
+	13: {"Macpherson, Braddell", Point{1.3350, 103.8800}},
+	14: {"Geylang, Eunos", Point{1.3180, 103.8950}},
+	15: {"Katong, Joo Chiat", Point{1.3050, 103.9050}},
+	16: {"Bedok, Upper East Coast", Point{1.3250, 103.9300}},
+	17: {"Loyang, Changi", Point{1.3600, 103.9800}},
+	18: {"Tampines, Pasir Ris", Point{1.3550, 103.9450}},
+	19: {"Serangoon Garden, Hougang, Punggol", Point{1.3650, 103.8900}},
+	20: {"Bishan, Ang Mo Kio", Point{1.3600, 103.8450}},
+	21: {"Upper Bukit Timah, Clementi Park", Point{1.3400, 103.7750}},
+	22: {"Jurong", Point{1.3400, 103.7100}},
+	23: {"Bukit Panjang, Choa Chu Kang", Point{1.3750, 103.7600}},
+	24: {"Lim Chu Kang, Tengah", Point{1.4200, 103.7100}},
+	25: {"Kranji, Woodlands", Point{1.4350, 103.7650}},
+	26: {"Upper Thomson, Springleaf", Point{1.3950, 103.8250}},
+	27: {"Yishun, Sembawang", Point{1.4300, 103.8300}},
+	28: {"Seletar", Point{1.3950, 103.8700}},
+}
@@ -0,0 +1,228 @@
+// Package money stores amounts of money exactly, as a whole number of minor units (e.g. cents) and a currency code.
+// It is used for prices, totals and invoices so sums do not drift like float64 amounts.
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Currency used when none is given, listings made before currencies were stored are in this currency
+const DefaultCurrency = "SGD"
+
+// Number of decimal places of the minor unit of each currency, currencies not listed use 2
+var minorUnits = map[string]int{
+	"SGD": 2,
+	"MYR": 2,
+	"USD": 2,
+	"JPY": 0,
+}
+
+// Errors returned by this package
+var (
+	ErrCurrencyMismatch = errors.New("amounts are in different currencies")
+	ErrInvalidAmount    = errors.New("invalid amount")
+	ErrOverflow         = errors.New("amount is too large")
+)
+
+// Money is an amount in minor units of its currency, e.g. {120, "SGD"} is SGD 1.20
+type Money struct {
+	Amount   int64  `json:"Amount"`
+	Currency string `json:"Currency"`
+}
+
+// This function returns the number of decimal places used by a currency
+func places(currency string) int {
+	if p, ok := minorUnits[currency]; ok {
+		return p
+	}
+	return 2
+}
+
+// This function returns 10 to the power of n
+func pow10(n int) int64 {
+	p := int64(1)
+	for i := 0; i < n; i++ {
+		p = p * 10
+	}
+	return p
+}
+
+// This function returns a zero amount in a currency
+func Zero(currency string) Money {
+	return Money{Amount: 0, Currency: currency}
+}
+
+// This function converts an amount in major units stored as float64, e.g. 1.2 dollars, to Money
+// It is the compatibility path for prices stored before amounts were kept in minor units
+func FromFloat(f float64, currency string) Money {
+	if currency == "" {
+		currency = DefaultCurrency
+	}
+	return Money{Amount: int64(math.Round(f * float64(pow10(places(currency))))), Currency: currency}
+}
+
+// This function parses an amount typed in major units, e.g. "1.20" or "3", without going through float64
+// It returns ErrInvalidAmount if the text is not a number, or has more decimal places than the currency
+func Parse(s, currency string) (Money, error) {
+	if currency == "" {
+		currency = DefaultCurrency
+	}
+	s = strings.TrimSpace(s)
+	negative := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(s, "-")
+
+	whole, frac := s, ""
+	if i := strings.Index(s, "."); i >= 0 {
+		whole, frac = s[:i], s[i+1:]
+	}
+	p := places(currency)
+	if (whole == "" && frac == "") || len(frac) > p || strings.ContainsAny(whole+frac, "+-") {
+		return Money{}, ErrInvalidAmount
+	}
+	frac = frac + strings.Repeat("0", p-len(frac))
+	if whole == "" {
+		whole = "0"
+	}
+	amount, err := strconv.ParseInt(whole+frac, 10, 64)
+	if err != nil {
+		return Money{}, ErrInvalidAmount
+	}
+	if negative {
+		amount = -amount
+	}
+	return Money{Amount: amount, Currency: currency}, nil
+}
+
+// This method adds two amounts, they must be in the same currency
+// A zero amount without a currency can be added to any amount
+func (m Money) Add(other Money) (Money, error) {
+	if m.Currency == "" && m.Amount == 0 {
+		return other, nil
+	}
+	if other.Currency == "" && other.Amount == 0 {
+		return m, nil
+	}
+	if m.Currency != other.Currency {
+		return m, ErrCurrencyMismatch
+	}
+	return Money{Amount: m.Amount + other.Amount, Currency: m.Currency}, nil
+}
+
+// This method subtracts an amount, they must be in the same currency
+// A zero amount without a currency can be subtracted from any amount
+func (m Money) Sub(other Money) (Money, error) {
+	return m.Add(Money{Amount: -other.Amount, Currency: other.Currency})
+}
+
+// This method returns a whole percentage of the amount, e.g. 10 for a 10% discount
+// The result is rounded half away from zero to the minor unit
+func (m Money) Percent(percent int64) Money {
+	product := m.Amount * percent
+	amount := product / 100
+	if rem := product % 100; rem >= 50 {
+		amount++
+	} else if rem <= -50 {
+		amount--
+	}
+	return Money{Amount: amount, Currency: m.Currency}
+}
+
+// This method returns a rate of the amount given in basis points (1/100 of a percent), e.g. 950 for 9.5%
+// The result is rounded half away from zero to the minor unit
+func (m Money) BasisPoints(rate int64) Money {
+	product := m.Amount * rate
+	amount := product / 10000
+	if rem := product % 10000; rem >= 5000 {
+		amount++
+	} else if rem <= -5000 {
+		amount--
+	}
+	return Money{Amount: amount, Currency: m.Currency}
+}
+
+// This method returns the price of a quantity, e.g. the cost of 2.5 kg at the price per kg
+// Quantities are used to 3 decimal places, and the result is rounded half away from zero to the minor unit
+// It returns ErrOverflow if the quantity or the price is too large to be stored
+func (m Money) Mul(quantity float64) (Money, error) {
+	scaled := math.Round(quantity * 1000)
+	if math.IsNaN(scaled) || math.Abs(scaled) >= math.MaxInt64 {
+		return Money{}, ErrOverflow
+	}
+	thousandths := int64(scaled)
+	product := m.Amount * thousandths
+	if thousandths != 0 && (product/thousandths != m.Amount || (thousandths == -1 && m.Amount == math.MinInt64)) {
+		return Money{}, ErrOverflow
+	}
+	amount := product / 1000
+	if rem := product % 1000; rem >= 500 {
+		amount++
+	} else if rem <= -500 {
+		amount--
+	}
+	return Money{Amount: amount, Currency: m.Currency}, nil
+}
+
+// This method returns true if the amount is below zero
+func (m Money) IsNegative() bool {
+	return m.Amount < 0
+}
+
+// This method returns the amount without its sign, e.g. to show a balance owed
+func (m Money) Abs() Money {
+	if m.Amount < 0 {
+		return Money{Amount: -m.Amount, Currency: m.Currency}
+	}
+	return m
+}
+
+// This method returns the amount in major units without the currency, e.g. "1.20"
+// It is used for form values and wherever the currency is already shown
+func (m Money) Decimal() string {
+	p := places(m.Currency)
+	amount := m.Amount
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+	if p == 0 {
+		return sign + strconv.FormatInt(amount, 10)
+	}
+	scale := pow10(p)
+	return fmt.Sprintf("%s%d.%0*d", sign, amount/scale, p, amount%scale)
+}
+
+// This method returns the amount with its currency, e.g. "SGD 1.20"
+func (m Money) String() string {
+	currency := m.Currency
+	if currency == "" {
+		currency = DefaultCurrency
+	}
+	return currency + " " + m.Decimal()
+}
+
+// This method reads an amount from JSON
+// Besides {"Amount":120,"Currency":"SGD"} it accepts a plain number in major units, e.g. 1.2,
+// which is how prices were sent before amounts were kept in minor units
+func (m *Money) UnmarshalJSON(data []byte) error {
+	var f float64
+	if err := json.Unmarshal(data, &f); err == nil {
+		*m = FromFloat(f, DefaultCurrency)
+		return nil
+	}
+	type plain Money
+	var p plain
+	if err := json.Unmarshal(data, &p); err != nil {
+		return err
+	}
+	if p.Currency == "" {
+		p.Currency = DefaultCurrency
+	}
+	*m = Money(p)
+	return nil
+}
@@ -0,0 +1,169 @@
+package money
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		s        string
+		currency string
+		want     Money
+		wantErr  bool
+	}{
+		{"1.20", "SGD", Money{120, "SGD"}, false},
+		{"3", "SGD", Money{300, "SGD"}, false},
+		{".5", "SGD", Money{50, "SGD"}, false},
+		{"-2.05", "SGD", Money{-205, "SGD"}, false},
+		{" 7.1 ", "", Money{710, DefaultCurrency}, false},
+		{"500", "JPY", Money{500, "JPY"}, false},
+		{"1.5", "JPY", Money{}, true},
+		{"1.234", "SGD", Money{}, true},
+		{"abc", "SGD", Money{}, true},
+		{"", "SGD", Money{}, true},
+		{"--1", "SGD", Money{}, true},
+		{"1.+2", "SGD", Money{}, true},
+	}
+	for _, tt := range tests {
+		got, err := Parse(tt.s, tt.currency)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Parse(%q, %q) error = %v, wantErr %v", tt.s, tt.currency, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Errorf("Parse(%q, %q) = %v, want %v", tt.s, tt.currency, got, tt.want)
+		}
+	}
+}
+
+func TestAddSub(t *testing.T) {
+	tests := []struct {
+		name    string
+		a, b    Money
+		add     Money
+		sub     Money
+		wantErr bool
+	}{
+		{"same currency", Money{120, "SGD"}, Money{30, "SGD"}, Money{150, "SGD"}, Money{90, "SGD"}, false},
+		{"zero without currency", Money{}, Money{30, "SGD"}, Money{30, "SGD"}, Money{-30, "SGD"}, false},
+		{"different currencies", Money{120, "SGD"}, Money{30, "MYR"}, Money{}, Money{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			add, err := tt.a.Add(tt.b)
+			if (err == ErrCurrencyMismatch) != tt.wantErr {
+				t.Fatalf("Add error = %v, wantErr %v", err, tt.wantErr)
+			}
+			sub, err := tt.a.Sub(tt.b)
+			if (err == ErrCurrencyMismatch) != tt.wantErr {
+				t.Fatalf("Sub error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if add != tt.add {
+				t.Errorf("Add = %v, want %v", add, tt.add)
+			}
+			if sub != tt.sub {
+				t.Errorf("Sub = %v, want %v", sub, tt.sub)
+			}
+		})
+	}
+}
+
+func TestPercentAndBasisPoints(t *testing.T) {
+	tests := []struct {
+		name string
+		got  Money
+		want int64
+	}{
+		{"10% of 1.25", Money{125, "SGD"}.Percent(10), 13},
+		{"10% of 1.24", Money{124, "SGD"}.Percent(10), 12},
+		{"10% of -1.25", Money{-125, "SGD"}.Percent(10), -13},
+		{"9% of 1.00", Money{100, "SGD"}.BasisPoints(900), 9},
+		{"9.5% of 0.10", Money{10, "SGD"}.BasisPoints(950), 1},
+		{"9% of 0.05", Money{5, "SGD"}.BasisPoints(900), 0},
+	}
+	for _, tt := range tests {
+		if tt.got.Amount != tt.want {
+			t.Errorf("%s = %d, want %d", tt.name, tt.got.Amount, tt.want)
+		}
+	}
+}
+
+func TestMul(t *testing.T) {
+	tests := []struct {
+		name     string
+		m        Money
+		quantity float64
+		want     Money
+		wantErr  error
+	}{
+		{"whole quantity", Money{120, "SGD"}, 3, Money{360, "SGD"}, nil},
+		{"decimal quantity", Money{199, "SGD"}, 2.5, Money{498, "SGD"}, nil},
+		{"rounds half away from zero", Money{1, "SGD"}, 0.5, Money{1, "SGD"}, nil},
+		{"rounds down", Money{1, "SGD"}, 0.499, Money{0, "SGD"}, nil},
+		{"negative amount", Money{-1, "SGD"}, 0.5, Money{-1, "SGD"}, nil},
+		{"zero quantity", Money{math.MaxInt64, "SGD"}, 0, Money{0, "SGD"}, nil},
+		{"amount too large", Money{math.MaxInt64 / 10, "SGD"}, 2, Money{}, ErrOverflow},
+		{"quantity too large", Money{1, "SGD"}, 1e17, Money{}, ErrOverflow},
+		{"quantity not a number", Money{1, "SGD"}, math.NaN(), Money{}, ErrOverflow},
+		{"infinite quantity", Money{1, "SGD"}, math.Inf(1), Money{}, ErrOverflow},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.m.Mul(tt.quantity)
+			if err != tt.wantErr {
+				t.Fatalf("Mul(%v) error = %v, want %v", tt.quantity, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Mul(%v) = %v, want %v", tt.quantity, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestString(t *testing.T) {
+	tests := []struct {
+		m    Money
+		want string
+	}{
+		{Money{120, "SGD"}, "SGD 1.20"},
+		{Money{5, "SGD"}, "SGD 0.05"},
+		{Money{-205, "SGD"}, "SGD -2.05"},
+		{Money{500, "JPY"}, "JPY 500"},
+		{Money{100, ""}, "SGD 1.00"},
+	}
+	for _, tt := range tests {
+		if got := tt.m.String(); got != tt.want {
+			t.Errorf("%#v.String() = %q, want %q", tt.m, got, tt.want)
+		}
+	}
+}
+
+func TestUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		data    string
+		want    Money
+		wantErr bool
+	}{
+		{`{"Amount":120,"Currency":"SGD"}`, Money{120, "SGD"}, false},
+		{`{"Amount":500,"Currency":"JPY"}`, Money{500, "JPY"}, false},
+		{`{"Amount":120}`, Money{120, DefaultCurrency}, false},
+		{`1.2`, Money{120, DefaultCurrency}, false},
+		{`"1.20"`, Money{}, true},
+	}
+	for _, tt := range tests {
+		var got Money
+		err := json.Unmarshal([]byte(tt.data), &got)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Unmarshal(%s) error = %v, wantErr %v", tt.data, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Errorf("Unmarshal(%s) = %v, want %v", tt.data, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+// Package notify keeps the notifications shown to each user in the app, such as new orders and listings that expired.
+// Notifications are stored in the user database and pushed to the open pages of their user with Server-Sent Events,
+// so the unread badge in the menu changes without refreshing the page.
+package notify
+
+import (
+	"fmt"
+	"projectGoLive/application/apiclient"
+	"projectGoLive/application/config"
+	"projectGoLive/application/orders"
+	"time"
+)
+
+// Kinds of notifications
+const (
+	// An order was placed with the seller
+	KindNewOrder = "neworder"
+	// An order was collected, cancelled or disputed
+	KindOrderStatus = "orderstatus"
+	// A listing passed its best before time and was removed from sale
+	KindListingExpired = "expired"
+	// A listing is running low or sold out
+	KindStock = "stock"
+	// The price of a listing in the buyer's cart dropped
+	KindPriceDrop = "pricedrop"
+)
+
+// Data structure for a notification of a user, Link is the page it is about
+type Notification struct {
+	ID        int64
+	Username  string
+	Kind      string
+	Message   string
+	Link      string
+	CreatedAt time.Time
+	ReadAt    time.Time
+}
+
+// This method returns true if the user has not seen the notification yet
+func (n Notification) Unread() bool {
+	return n.ReadAt.IsZero()
+}
+
+// This function returns the IDs of the notifications the user has not seen yet
+func unreadIDs(notifications []Notification) []int64 {
+	var ids []int64
+	for _, n := range notifications {
+		if n.Unread() {
+			ids = append(ids, n.ID)
+		}
+	}
+	return ids
+}
+
+// This function stores a notification for a user and pushes it to the user's open pages
+// It returns false if the notification cannot be stored.
+func Send(username, kind, message, link string) bool {
+	n := Notification{Username: username, Kind: kind, Message: message, Link: link, CreatedAt: time.Now()}
+	id, ok := insertNotification(config.DB, n)
+	if !ok {
+		config.Error.Printf("Unable to store notification for %s : %s\n", username, message)
+		return false
+	}
+	n.ID = id
+	hub.publish(n)
+	return true
+}
+
+//---------------------------------------------------------------------------
+// Notifications about orders and listings
+//---------------------------------------------------------------------------
+// This function tells the seller of an order that it has been placed
+func OrderPlaced(o orders.Order) bool {
+	return Send(o.Seller, KindNewOrder, fmt.Sprintf("New order %d from %s, %s to be collected", o.ID, o.Buyer, o.Due()), "/seller/earnings")
+}
+
+// This function tells the buyer of an order that it has been collected
+func OrderCollected(o orders.Order) bool {
+	return Send(o.Buyer, KindOrderStatus, fmt.Sprintf("Order %d from %s has been collected", o.ID, o.Seller), "/buyer/orders")
+}
+
+// This function tells the other side of an order that it has been cancelled
+func OrderCancelled(o orders.Order) bool {
+	if o.CancelledBy == orders.CancelledBySeller {
+		return Send(o.Buyer, KindOrderStatus, fmt.Sprintf("Order %d has been cancelled by the seller %s", o.ID, o.Seller), "/buyer/orders")
+	}
+	return Send(o.Seller, KindOrderStatus, fmt.Sprintf("Order %d has been cancelled by the buyer %s", o.ID, o.Buyer), "/seller/earnings")
+}
+
+// This function tells the seller that a dispute has been raised, and both sides when it is resolved
+func DisputeChanged(d orders.Dispute) bool {
+	if d.IsOpen() {
+		return Send(d.Seller, KindOrderStatus, fmt.Sprintf("Order %d has been disputed by %s", d.OrderID, d.Buyer), "/seller/earnings")
+	}
+	message := fmt.Sprintf("The dispute about order %d has been resolved without a refund", d.OrderID)
+	if d.HasRefund() {
+		message = fmt.Sprintf("The dispute about order %d has been resolved with a refund of %s", d.OrderID, d.Refund)
+	}
+	sent := Send(d.Buyer, KindOrderStatus, message, "/buyer/orders")
+	return Send(d.Seller, KindOrderStatus, message, "/seller/earnings") && sent
+}
+
+// This function tells a seller that a listing expired and was removed from sale
+func ListingExpired(item apiclient.ItemsDetails) bool {
+	return Send(item.Username, KindListingExpired, fmt.Sprintf("Your %s listing, lot %d, expired and has been removed from sale", item.Item, item.ID), "/seller")
+}
+
+// This function tells a seller that a listing is running low or sold out
+func StockChanged(item apiclient.ItemsDetails) bool {
+	message := fmt.Sprintf("Your %s listing, lot %d, is running low, only %s left", item.Item, item.ID, item.QuantityLabel())
+	if item.SoldOut() {
+		message = fmt.Sprintf("Your %s listing, lot %d, has sold out", item.Item, item.ID)
+	}
+	return Send(item.Username, KindStock, message, "/seller")
+}
+
+// This function tells a buyer that the price of a listing in their cart dropped from was
+func PriceDropped(buyername string, item apiclient.ItemsDetails, was string) bool {
+	message := fmt.Sprintf("%s from %s in your cart is now %s per %s, was %s", item.Item, item.Username, item.Cost, item.UnitLabel(), was)
+	return Send(buyername, KindPriceDrop, message, "/buyer/buyercart")
+}
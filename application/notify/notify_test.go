@@ -0,0 +1,26 @@
+package notify
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestUnreadIDs(t *testing.T) {
+	read := time.Date(2021, 6, 1, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name          string
+		notifications []Notification
+		want          []int64
+	}{
+		{"none", nil, nil},
+		{"all unread", []Notification{{ID: 3}, {ID: 2}, {ID: 1}}, []int64{3, 2, 1}},
+		{"some read", []Notification{{ID: 9}, {ID: 8, ReadAt: read}, {ID: 7}}, []int64{9, 7}},
+		{"all read", []Notification{{ID: 5, ReadAt: read}}, nil},
+	}
+	for _, test := range tests {
+		if got := unreadIDs(test.notifications); fmt.Sprint(got) != fmt.Sprint(test.want) {
+			t.Errorf("%s: unreadIDs = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package notify
+
+import (
+	"database/sql"
+	"log"
+	"strings"
+	"time"
+)
+
+// Columns read for each notification, in the order used by scanNotification
+const notificationColumns = "ID, Username, Kind, Message, Link, CreatedAt, ReadAt"
+
+// Function to read one notification from a row selected with notificationColumns.
+func scanNotification(rows *sql.Rows) (Notification, error) {
+	var n Notification
+	var readAt sql.NullTime
+	err := rows.Scan(&n.ID, &n.Username, &n.Kind, &n.Message, &n.Link, &n.CreatedAt, &readAt)
+	if readAt.Valid {
+		n.ReadAt = readAt.Time
+	}
+	return n, err
+}
+
+//-----------------------------------------------------------------------
+// Functions for notifications
+//-----------------------------------------------------------------------
+// Function to store a notification in the MYSQL database.
+// It returns the ID of the notification, and false when there is any error encountered.
+func insertNotification(db *sql.DB, n Notification) (int64, bool) {
+	result, err := db.Exec("INSERT INTO `notifications` (Username, Kind, Message, Link, CreatedAt) VALUES (?, ?, ?, ?, ?)",
+		n.Username, n.Kind, n.Message, n.Link, n.CreatedAt)
+	if err != nil {
+		log.Println("Unable to insert the notification")
+		log.Println(err)
+		return 0, false
+	}
+	id, _ := result.LastInsertId()
+	return id, true
+}
+
+// Function to get the latest notifications of a user from the MYSQL database, newest first.
+// It returns at most limit notifications, all of them when limit is 0, and false when there is any error encountered.
+func GetNotifications(db *sql.DB, username string, limit int) ([]Notification, bool) {
+	var notifications []Notification
+	query := "SELECT " + notificationColumns + " FROM `notifications` WHERE Username=? ORDER BY ID DESC"
+	args := []interface{}{username}
+	if limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, limit)
+	}
+	results, err := db.Query(query, args...)
+	if err != nil {
+		log.Println("Not able to get notifications")
+		log.Println(err)
+		return notifications, false
+	}
+	defer results.Close()
+
+	for results.Next() {
+		n, err := scanNotification(results)
+		if err != nil {
+			log.Println("Unable to get notifications")
+			log.Println(err)
+			return notifications, false
+		}
+		notifications = append(notifications, n)
+	}
+	return notifications, true
+}
+
+// Function to count the notifications of a user not read yet in the MYSQL database.
+// It returns false when there is any error encountered.
+func CountUnread(db *sql.DB, username string) (int, bool) {
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM `notifications` WHERE Username=? AND ReadAt IS NULL", username).Scan(&count)
+	if err != nil {
+		log.Println("Unable to count notifications")
+		log.Println(err)
+		return 0, false
+	}
+	return count, true
+}
+
+// Function to mark notifications of a user as read in the MYSQL database, by their IDs.
+// Only the notifications the user has been shown are marked, so the ones added since stay unread.
+// It returns false when there is any error encountered.
+func MarkRead(db *sql.DB, username string, ids []int64, now time.Time) bool {
+	if len(ids) == 0 {
+		return true
+	}
+	args := []interface{}{now, username}
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(ids)), ",")
+	_, err := db.Exec("UPDATE `notifications` SET ReadAt=? WHERE Username=? AND ReadAt IS NULL AND ID IN ("+placeholders+")", args...)
+	if err != nil {
+		log.Println("Unable to mark notifications as read")
+		log.Println(err)
+		return false
+	}
+	return true
+}
+
+// Function to delete all notifications of a user from the MYSQL database, used when the account is purged.
+// It returns false when there is any error encountered.
+func DeleteNotifications(db *sql.DB, username string) bool {
+	_, err := db.Exec("DELETE FROM `notifications` WHERE Username=?", username)
+	if err != nil {
+		log.Println("Unable to delete notifications")
+		log.Println(err)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,139 @@
+package notify
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"projectGoLive/application/config"
+	"projectGoLive/application/server"
+	"sync"
+	"time"
+)
+
+// Number of notifications listed on the notifications page
+const pageSize = 50
+
+// Time between comments sent on an idle stream, so proxies do not close it
+const keepAliveTime = 30 * time.Second
+
+// Number of notifications held for a page that is slow to read them, later ones are dropped for that page
+const streamBuffer = 16
+
+// hubStreams keeps the open streams of each user, a user can have several pages open
+// The streams are held in memory, so notifications only reach pages served by the same instance of the application.
+type hubStreams struct {
+	mu      sync.Mutex
+	streams map[string]map[chan Notification]bool
+}
+
+var hub = &hubStreams{streams: make(map[string]map[chan Notification]bool)}
+
+// This method opens a stream for a user, the returned function closes it
+func (h *hubStreams) subscribe(username string) (chan Notification, func()) {
+	ch := make(chan Notification, streamBuffer)
+	h.mu.Lock()
+	if h.streams[username] == nil {
+		h.streams[username] = make(map[chan Notification]bool)
+	}
+	h.streams[username][ch] = true
+	h.mu.Unlock()
+
+	return ch, func() {
+		h.mu.Lock()
+		delete(h.streams[username], ch)
+		if len(h.streams[username]) == 0 {
+			delete(h.streams, username)
+		}
+		h.mu.Unlock()
+	}
+}
+
+// This method pushes a notification to every open stream of its user, without waiting for slow pages
+func (h *hubStreams) publish(n Notification) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.streams[n.Username] {
+		select {
+		case ch <- n:
+		default:
+		}
+	}
+}
+
+// Data structure for the notifications page
+type notificationsStruct struct {
+	Username      string
+	IsBuyer       bool
+	IsSeller      bool
+	Mainmessage   []string
+	Notifications []Notification
+}
+
+//---------------------------------------------------------------------------
+// Functions for the notification centre
+//---------------------------------------------------------------------------
+// This method is used to list the notifications of the user, they are marked as read once listed
+func NotificationsHandler(w http.ResponseWriter, req *http.Request) {
+	if !server.ActiveSession(w, req) {
+		http.Redirect(w, req, "/login", http.StatusSeeOther)
+		return
+	}
+	user := server.GetUser(w, req)
+
+	page := notificationsStruct{Username: user.Username, IsBuyer: user.IsBuyer(), IsSeller: user.IsSeller()}
+	notifications, ok := GetNotifications(config.DB, user.Username, pageSize)
+	if !ok {
+		page.Mainmessage = append(page.Mainmessage, "Unable to get notifications")
+	}
+	page.Notifications = notifications
+	MarkRead(config.DB, user.Username, unreadIDs(notifications), time.Now())
+	config.TPL.ExecuteTemplate(w, "notifications.gohtml", page)
+}
+
+// This method is used to stream the notifications of the user to the browser with Server-Sent Events
+// An "unread" event with the number of unread notifications is sent when the stream opens and after each
+// "notification" event. Visitors who are not logged in get 204 No Content, which tells the browser not to reconnect.
+func StreamHandler(w http.ResponseWriter, req *http.Request) {
+	if !server.ActiveSession(w, req) {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	user := server.GetUser(w, req)
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	ch, unsubscribe := hub.subscribe(user.Username)
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	sendUnread(w, user.Username)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(keepAliveTime)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case n := <-ch:
+			data, _ := json.Marshal(n)
+			fmt.Fprintf(w, "event: notification\ndata: %s\n\n", data)
+			sendUnread(w, user.Username)
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep alive\n\n")
+		case <-req.Context().Done():
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+// This function writes the number of unread notifications of a user to a stream
+func sendUnread(w http.ResponseWriter, username string) {
+	if unread, ok := CountUnread(config.DB, username); ok {
+		fmt.Fprintf(w, "event: unread\ndata: %d\n\n", unread)
+	}
+}
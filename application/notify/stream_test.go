@@ -0,0 +1,107 @@
+package notify
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHubPublish(t *testing.T) {
+	h := &hubStreams{streams: make(map[string]map[chan Notification]bool)}
+	first, closeFirst := h.subscribe("buyer1")
+	defer closeFirst()
+	second, closeSecond := h.subscribe("buyer1")
+	defer closeSecond()
+	other, closeOther := h.subscribe("seller1")
+	defer closeOther()
+
+	h.publish(Notification{ID: 1, Username: "buyer1"})
+	for i, ch := range []chan Notification{first, second} {
+		select {
+		case n := <-ch:
+			if n.ID != 1 {
+				t.Errorf("stream %d got notification %d, want 1", i, n.ID)
+			}
+		default:
+			t.Errorf("stream %d got no notification", i)
+		}
+	}
+	select {
+	case n := <-other:
+		t.Errorf("stream of another user got notification %d", n.ID)
+	default:
+	}
+}
+
+func TestHubSlowStream(t *testing.T) {
+	h := &hubStreams{streams: make(map[string]map[chan Notification]bool)}
+	slow, closeSlow := h.subscribe("buyer1")
+	defer closeSlow()
+
+	// Notifications past the buffer are dropped for a page that does not read them, without blocking publish
+	done := make(chan bool)
+	go func() {
+		for i := 1; i <= streamBuffer+5; i++ {
+			h.publish(Notification{ID: int64(i), Username: "buyer1"})
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("publish blocked on a slow stream")
+	}
+	if len(slow) != streamBuffer {
+		t.Errorf("slow stream holds %d notifications, want %d", len(slow), streamBuffer)
+	}
+	if n := <-slow; n.ID != 1 {
+		t.Errorf("slow stream first got notification %d, want 1", n.ID)
+	}
+}
+
+func TestHubUnsubscribe(t *testing.T) {
+	h := &hubStreams{streams: make(map[string]map[chan Notification]bool)}
+	first, closeFirst := h.subscribe("buyer1")
+	_, closeSecond := h.subscribe("buyer1")
+
+	closeFirst()
+	if got := len(h.streams["buyer1"]); got != 1 {
+		t.Errorf("buyer1 has %d streams after one is closed, want 1", got)
+	}
+	h.publish(Notification{ID: 1, Username: "buyer1"})
+	if len(first) != 0 {
+		t.Error("closed stream got a notification")
+	}
+
+	closeSecond()
+	if _, ok := h.streams["buyer1"]; ok {
+		t.Error("buyer1 is still in the hub after all streams are closed")
+	}
+	// Publishing to a user with no stream does nothing
+	h.publish(Notification{ID: 2, Username: "buyer1"})
+}
+
+func TestStreamHandlerVisitor(t *testing.T) {
+	tests := []struct {
+		name   string
+		cookie *http.Cookie
+	}{
+		{"no cookie", nil},
+		{"unknown session", &http.Cookie{Name: "PeelRescue", Value: "not-a-session"}},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/notifications/stream", nil)
+		if test.cookie != nil {
+			req.AddCookie(test.cookie)
+		}
+		w := httptest.NewRecorder()
+		StreamHandler(w, req)
+		if w.Code != http.StatusNoContent {
+			t.Errorf("%s: status %d, want %d", test.name, w.Code, http.StatusNoContent)
+		}
+		if ct := w.Header().Get("Content-Type"); ct == "text/event-stream" {
+			t.Errorf("%s: stream opened for a visitor", test.name)
+		}
+	}
+}
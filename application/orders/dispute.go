@@ -0,0 +1,87 @@
+package orders
+
+import (
+	"errors"
+	"projectGoLive/application/ledger"
+	"projectGoLive/application/money"
+	"time"
+)
+
+// Status of a dispute
+const (
+	// Raised by the buyer, waiting for an admin
+	DisputeOpen = "open"
+	// Resolved by an admin with a refund to the buyer, given back through the payment provider for an order paid online
+	DisputeRefunded = "refunded"
+	// Resolved by an admin with a refund of an order paid online, which the payment provider has not made yet
+	DisputePending = "pending"
+	// Resolved by an admin with a refund of an order paid during collection, owed to the buyer by the seller
+	DisputeOwed = "owed"
+	// Resolved by an admin without a refund
+	DisputeRejected = "rejected"
+)
+
+// Errors returned when a dispute cannot be raised or resolved
+var (
+	ErrNoOrder        = errors.New("this order does not exist")
+	ErrNotCollected   = errors.New("only collected orders can be disputed")
+	ErrAlreadyOpened  = errors.New("this order has already been disputed")
+	ErrNotOpen        = errors.New("this dispute has already been resolved")
+	ErrRefundTooLarge = errors.New("the refund is more than the buyer paid for the order")
+)
+
+// Data structure for a dispute raised by a buyer about a collected order
+// Refund is given back to the buyer at the seller's expense, zero when the dispute is rejected.
+type Dispute struct {
+	ID         int64
+	OrderID    int64
+	Buyer      string
+	Seller     string
+	Reason     string
+	Status     string
+	Refund     money.Money
+	Note       string
+	OpenedAt   time.Time
+	ResolvedAt time.Time
+}
+
+// This method returns true if the dispute is waiting for an admin
+func (d Dispute) IsOpen() bool {
+	return d.Status == DisputeOpen
+}
+
+// This method returns true if the dispute has been resolved with a refund, whether it has been given back yet or not
+func (d Dispute) HasRefund() bool {
+	return !d.IsOpen() && d.Refund.Amount > 0
+}
+
+// This method returns true if the refund of an order paid online has still to be made, it can be tried again
+func (d Dispute) RefundPending() bool {
+	return d.Status == DisputePending
+}
+
+// This method returns true if the refund of an order paid during collection is owed to the buyer by the seller
+func (d Dispute) Owed() bool {
+	return d.Status == DisputeOwed
+}
+
+// This function returns the status of a dispute resolved with a refund of the given order
+// The refund of an order paid online is pending until the payment provider makes it, see MarkRefunded,
+// the refund of an order paid during collection is owed by the seller.
+func resolvedStatus(o Order, refund money.Money) string {
+	switch {
+	case refund.Amount <= 0:
+		return DisputeRejected
+	case o.PaymentID != 0:
+		return DisputePending
+	}
+	return DisputeOwed
+}
+
+// This function returns the ledger entries for a refund after a dispute, the seller gives the amount back to the buyer
+func refundEntries(o Order, refund money.Money) []ledger.Entry {
+	return []ledger.Entry{
+		ledger.Debit(ledger.SellerAccount(o.Seller), ledger.KindRefund, refund),
+		ledger.Credit(ledger.BuyerAccount(o.Buyer), ledger.KindRefund, refund),
+	}
+}
@@ -0,0 +1,37 @@
+package orders
+
+import (
+	"projectGoLive/application/money"
+	"testing"
+)
+
+func TestResolvedStatus(t *testing.T) {
+	sgd := func(amount int64) money.Money {
+		return money.Money{Amount: amount, Currency: "SGD"}
+	}
+	tests := []struct {
+		name    string
+		order   Order
+		refund  money.Money
+		want    string
+		pending bool
+		owed    bool
+	}{
+		{"no refund", Order{PaymentID: 1}, sgd(0), DisputeRejected, false, false},
+		{"refund of order paid online", Order{PaymentID: 1}, sgd(500), DisputePending, true, false},
+		{"refund of order paid during collection", Order{}, sgd(500), DisputeOwed, false, true},
+		{"no refund of order paid during collection", Order{}, sgd(0), DisputeRejected, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := resolvedStatus(tt.order, tt.refund)
+			if got != tt.want {
+				t.Fatalf("resolvedStatus() = %q, want %q", got, tt.want)
+			}
+			d := Dispute{Status: got, Refund: tt.refund}
+			if d.RefundPending() != tt.pending || d.Owed() != tt.owed || d.HasRefund() != (tt.refund.Amount > 0) {
+				t.Errorf("dispute %+v: RefundPending %v, Owed %v, HasRefund %v", d, d.RefundPending(), d.Owed(), d.HasRefund())
+			}
+		})
+	}
+}
@@ -0,0 +1,170 @@
+package orders
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"projectGoLive/application/ledger"
+	"projectGoLive/application/money"
+	"time"
+)
+
+// Columns read for each dispute, in the order used by scanDispute
+const disputeColumns = "ID, OrderID, Buyer, Seller, Reason, Status, Refund, Currency, Note, OpenedAt, ResolvedAt"
+
+// Function to read one dispute from a row selected with disputeColumns.
+func scanDispute(row scanner) (Dispute, error) {
+	var d Dispute
+	var resolvedAt sql.NullTime
+	err := row.Scan(&d.ID, &d.OrderID, &d.Buyer, &d.Seller, &d.Reason, &d.Status, &d.Refund.Amount, &d.Refund.Currency, &d.Note, &d.OpenedAt, &resolvedAt)
+	if resolvedAt.Valid {
+		d.ResolvedAt = resolvedAt.Time
+	}
+	return d, err
+}
+
+//-----------------------------------------------------------------------
+// Functions for disputes
+//-----------------------------------------------------------------------
+// Function to store a dispute by a buyer about one of their collected orders in the MYSQL database.
+// Each order can only be disputed once.
+// It returns the dispute with its ID, and an error explaining why the dispute cannot be raised.
+func OpenDispute(db *sql.DB, orderID int64, buyername, reason string, now time.Time) (Dispute, error) {
+	d := Dispute{OrderID: orderID, Buyer: buyername, Reason: reason, Status: DisputeOpen, OpenedAt: now}
+	o, ok := GetOrder(db, orderID)
+	if !ok || o.Buyer != buyername {
+		return d, ErrNoOrder
+	}
+	if o.Status != StatusCollected {
+		return d, ErrNotCollected
+	}
+	d.Seller = o.Seller
+	d.Refund = money.Zero(o.Gross.Currency)
+
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM `disputes` WHERE OrderID=?", orderID).Scan(&count); err != nil {
+		log.Println("Unable to count disputes")
+		log.Println(err)
+		return d, err
+	}
+	if count > 0 {
+		return d, ErrAlreadyOpened
+	}
+
+	result, err := db.Exec("INSERT INTO `disputes` (OrderID, Buyer, Seller, Reason, Status, Refund, Currency, Note, OpenedAt) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		d.OrderID, d.Buyer, d.Seller, d.Reason, d.Status, d.Refund.Amount, d.Refund.Currency, d.Note, d.OpenedAt)
+	if err != nil {
+		// The unique key on OrderID stops a second dispute raised at the same time
+		log.Println("Unable to insert the dispute")
+		log.Println(err)
+		return d, ErrAlreadyOpened
+	}
+	d.ID, _ = result.LastInsertId()
+	return d, nil
+}
+
+// Function to resolve an open dispute in the MYSQL database, with a refund of part or all of what the buyer paid.
+// A zero refund rejects the dispute. The refund is posted to the ledger, from the seller to the buyer.
+// The refund of an order paid online is left pending, it is marked refunded with MarkRefunded once the payment
+// provider has made it.
+// It returns the resolved dispute, and an error when the dispute is not open or the refund is too large.
+func ResolveDispute(db *sql.DB, id int64, refund money.Money, note string, now time.Time) (Dispute, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		log.Println(err)
+		return Dispute{}, err
+	}
+	defer tx.Rollback()
+
+	d, err := scanDispute(tx.QueryRow("SELECT "+disputeColumns+" FROM `disputes` WHERE ID=? FOR UPDATE", id))
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Println("Unable to get the dispute")
+			log.Println(err)
+		}
+		return d, err
+	}
+	if !d.IsOpen() {
+		return d, ErrNotOpen
+	}
+	o, err := scanOrder(tx.QueryRow("SELECT "+orderColumns+" FROM `orders` WHERE ID=?", d.OrderID))
+	if err != nil {
+		log.Println("Unable to get the order")
+		log.Println(err)
+		return d, err
+	}
+	if refund.IsNegative() || refund.Currency != o.Gross.Currency || refund.Amount > o.Due().Amount {
+		return d, ErrRefundTooLarge
+	}
+
+	d.Status, d.Refund, d.Note, d.ResolvedAt = resolvedStatus(o, refund), refund, note, now
+	if _, err := tx.Exec("UPDATE `disputes` SET Status=?, Refund=?, Note=?, ResolvedAt=? WHERE ID=?", d.Status, d.Refund.Amount, d.Note, d.ResolvedAt, d.ID); err != nil {
+		log.Println("Unable to update the dispute")
+		log.Println(err)
+		return d, err
+	}
+	if err := ledger.Post(tx, o.ID, fmt.Sprintf("Dispute %d of order %d resolved", d.ID, o.ID), now, refundEntries(o, refund)); err != nil {
+		log.Println(err)
+		return d, err
+	}
+	return d, tx.Commit()
+}
+
+// Function to mark the pending refund of a dispute as made by the payment provider in the MYSQL database.
+// It returns false when the dispute has no pending refund or there is any error encountered.
+func MarkRefunded(db *sql.DB, id int64) bool {
+	result, err := db.Exec("UPDATE `disputes` SET Status=? WHERE ID=? AND Status=?", DisputeRefunded, id, DisputePending)
+	if err != nil {
+		log.Println("Unable to update the dispute")
+		log.Println(err)
+		return false
+	}
+	n, err := result.RowsAffected()
+	return err == nil && n == 1
+}
+
+// Function to get one dispute from the MYSQL database.
+// It returns false when the dispute does not exist or there is any error encountered.
+func GetDispute(db *sql.DB, id int64) (Dispute, bool) {
+	d, err := scanDispute(db.QueryRow("SELECT "+disputeColumns+" FROM `disputes` WHERE ID=?", id))
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Println("Unable to get the dispute")
+			log.Println(err)
+		}
+		return d, false
+	}
+	return d, true
+}
+
+// Function to get the disputes of a buyer, or of all buyers when all is true, from the MYSQL database.
+// Open disputes come first, then the newest.
+// It returns false when there is any error encountered.
+func GetDisputes(db *sql.DB, buyername string, all bool) ([]Dispute, bool) {
+	var disputes []Dispute
+	query := "SELECT " + disputeColumns + " FROM `disputes`"
+	var args []interface{}
+	if !all {
+		query = query + " WHERE Buyer=?"
+		args = append(args, buyername)
+	}
+	args = append(args, DisputeOpen)
+	results, err := db.Query(query+" ORDER BY Status=? DESC, ID DESC", args...)
+	if err != nil {
+		log.Println("Not able to get disputes")
+		log.Println(err)
+		return disputes, false
+	}
+	defer results.Close()
+
+	for results.Next() {
+		d, err := scanDispute(results)
+		if err != nil {
+			log.Println("Unable to get disputes")
+			log.Println(err)
+			return disputes, false
+		}
+		disputes = append(disputes, d)
+	}
+	return disputes, true
+}
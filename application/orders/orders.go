@@ -0,0 +1,217 @@
+// Package orders keeps the orders placed at checkout, one order for each seller in the cart.
+// Placing an order and collecting it post the money owed between the buyer, the seller and the platform to the ledger.
+// An order can be cancelled before it is collected, and a buyer can dispute a collected order for an admin to resolve.
+package orders
+
+import (
+	"projectGoLive/application/apiclient"
+	"projectGoLive/application/config"
+	"projectGoLive/application/coupon"
+	"projectGoLive/application/ledger"
+	"projectGoLive/application/money"
+	"projectGoLive/application/tax"
+	"time"
+)
+
+// Status of an order
+const (
+	// Placed at checkout, waiting for the buyer to collect and pay
+	StatusPlaced = "placed"
+	// Collected by the buyer and paid to the seller
+	StatusCollected = "collected"
+	// Cancelled by the buyer or the seller before it was collected
+	StatusCancelled = "cancelled"
+)
+
+// Who cancelled an order
+const (
+	CancelledByBuyer  = "buyer"
+	CancelledBySeller = "seller"
+)
+
+// Commission used when it is not set in the environment, in basis points (1/100 of a percent)
+const defaultCommissionRate = 500
+
+// Commission charged to sellers on the net amount of each order, in basis points, read from COMMISSION_RATE
+var CommissionRate int64
+
+func init() {
+	CommissionRate = config.RateFromEnv("COMMISSION_RATE", defaultCommissionRate)
+}
+
+// Data structure for each line of an order, a copy of the listing when it was bought
+type Line struct {
+	LotID    int64
+	Item     string
+	Quantity float64
+	Unit     string
+	UnitCost money.Money
+	Total    money.Money
+	Tax      money.Money
+}
+
+// Data structure for each order
+// Net, Tax and Gross are after the seller's own promo code, shown as Discount.
+// PlatformDiscount is this order's share of a platform-wide promo code, paid to the seller by the platform.
+// Fee is the commission the seller owes the platform.
+// GSTNumber is the seller's GST registration number when the order was placed, empty when they were not registered.
+// PaymentID is the payment taken online for the order, 0 when the buyer pays during collection.
+// CancelledBy is the buyer or the seller when the order has been cancelled, with the seller's reason.
+type Order struct {
+	ID               int64
+	Buyer            string
+	Seller           string
+	Status           string
+	PlacedAt         time.Time
+	CollectedAt      time.Time
+	Coupon           string
+	Net              money.Money
+	Tax              money.Money
+	Gross            money.Money
+	GSTNumber        string
+	Discount         money.Money
+	PlatformDiscount money.Money
+	Fee              money.Money
+	PaymentID        int64
+	CancelledAt      time.Time
+	CancelledBy      string
+	CancelReason     string
+	Lines            []Line
+}
+
+// This method returns the amount the buyer pays the seller when collecting the order
+func (o Order) Due() money.Money {
+	due, _ := o.Gross.Sub(o.PlatformDiscount)
+	return due
+}
+
+// This method returns true if the order can still be cancelled, it has not been collected
+func (o Order) Cancellable() bool {
+	return o.Status == StatusPlaced
+}
+
+// This method returns the quantity of a line with its unit, e.g. "2.5 kg"
+func (l Line) QuantityLabel() string {
+	return apiclient.FormatQuantity(l.Quantity) + " " + l.Unit
+}
+
+// This function returns the commission rate as text, e.g. "5%"
+func CommissionLabel() string {
+	return config.RateLabel(CommissionRate)
+}
+
+// This function splits a cart into one order for each seller, in the order the sellers first appear in the cart
+// taxes must be worked out by tax.Rules.Compute for the same items and promo code.
+// A platform-wide promo code is shared between the orders in proportion to their gross amounts.
+func Build(buyername string, items []apiclient.ItemsDetails, discount coupon.Applied, taxes tax.Order, now time.Time) []Order {
+	var orders []Order
+	index := make(map[string]int)
+	for i, item := range items {
+		n, ok := index[item.Username]
+		if !ok {
+			n = len(orders)
+			index[item.Username] = n
+			amounts := taxes.Sellers[item.Username]
+			currency := amounts.Gross.Currency
+			orders = append(orders, Order{
+				Buyer:            buyername,
+				Seller:           item.Username,
+				Status:           StatusPlaced,
+				PlacedAt:         now,
+				Net:              amounts.Net,
+				Tax:              amounts.Tax,
+				Gross:            amounts.Gross,
+				GSTNumber:        taxes.Registrations[item.Username].Number,
+				Discount:         money.Zero(currency),
+				PlatformDiscount: money.Zero(currency),
+				Fee:              amounts.Net.BasisPoints(CommissionRate),
+			})
+		}
+		line := Line{
+			LotID:    item.ID,
+			Item:     item.Item,
+			Quantity: item.Quantity,
+			Unit:     item.UnitLabel(),
+			UnitCost: item.AppliedCost(),
+		}
+		// The line totals have been checked when the GST of the cart was worked out
+		line.Total, _ = item.LineTotal()
+		if i < len(taxes.Lines) {
+			line.Tax = taxes.Lines[i].Tax
+		}
+		orders[n].Lines = append(orders[n].Lines, line)
+	}
+
+	if discount.Code == "" {
+		return orders
+	}
+	if discount.Seller != "" {
+		if n, ok := index[discount.Seller]; ok {
+			orders[n].Coupon = discount.Code
+			orders[n].Discount = discount.Discount
+		}
+		return orders
+	}
+
+	// The last order takes what is left after rounding, so the shares add up to the discount
+	var total int64
+	for _, o := range orders {
+		total = total + o.Gross.Amount
+	}
+	left := discount.Discount.Amount
+	for n := range orders {
+		orders[n].Coupon = discount.Code
+		share := left
+		if n < len(orders)-1 && total > 0 {
+			share = discount.Discount.Amount * orders[n].Gross.Amount / total
+		}
+		left = left - share
+		orders[n].PlatformDiscount = money.Money{Amount: share, Currency: discount.Discount.Currency}
+	}
+	return orders
+}
+
+// This function returns the ledger entries for placing an order
+// The buyer owes the seller the gross amount, less the platform's share of a promo code which the platform pays,
+// and the seller owes the platform its commission.
+func placedEntries(o Order) []ledger.Entry {
+	return []ledger.Entry{
+		ledger.Debit(ledger.BuyerAccount(o.Buyer), ledger.KindSale, o.Due()),
+		ledger.Debit(ledger.Promotions, ledger.KindSale, o.PlatformDiscount),
+		ledger.Credit(ledger.SellerAccount(o.Seller), ledger.KindSale, o.Gross),
+		ledger.Debit(ledger.SellerAccount(o.Seller), ledger.KindFee, o.Fee),
+		ledger.Credit(ledger.Commission, ledger.KindFee, o.Fee),
+	}
+}
+
+// This function returns the ledger entries for cancelling an order, undoing the entries of placing it
+// A payment taken online is given back separately, by refunding it with the payment provider.
+func cancelledEntries(o Order) []ledger.Entry {
+	return ledger.Reverse(placedEntries(o))
+}
+
+// This function puts the quantities of a cancelled order back on sale through the seller API
+// The quantities are added to what is left of each lot, and buyers waiting for a lot that had sold out are told it is back.
+// A lot that has been deleted cannot be restored, its lines are returned so the seller can list them again.
+func Restock(o Order) []Line {
+	var missing []Line
+	for _, l := range o.Lines {
+		if !apiclient.RestockLot(l.LotID, o.Seller, l.Quantity) {
+			config.Warning.Printf("Unable to restock lot %d of seller %s for order %d\n", l.LotID, o.Seller, o.ID)
+			missing = append(missing, l)
+		}
+	}
+	return missing
+}
+
+// This function returns the ledger entries for collecting an order, the buyer pays the seller in person
+// An order paid online has no entries, the payment was posted when it was captured
+func collectedEntries(o Order) []ledger.Entry {
+	if o.PaymentID != 0 {
+		return nil
+	}
+	return []ledger.Entry{
+		ledger.Debit(ledger.SellerAccount(o.Seller), ledger.KindCollection, o.Due()),
+		ledger.Credit(ledger.BuyerAccount(o.Buyer), ledger.KindCollection, o.Due()),
+	}
+}
@@ -0,0 +1,280 @@
+package orders
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"projectGoLive/application/ledger"
+	"time"
+)
+
+// Columns read for each order, in the order used by scanOrder
+const orderColumns = "ID, Buyer, Seller, Status, PlacedAt, CollectedAt, Coupon, Currency, Net, Tax, Gross, GSTNumber, Discount, PlatformDiscount, Fee, PaymentID, CancelledAt, CancelledBy, CancelReason"
+
+// Interface satisfied by *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// Function to read one order, without its lines, from a row selected with orderColumns.
+func scanOrder(row scanner) (Order, error) {
+	var o Order
+	var currency string
+	var collectedAt, cancelledAt sql.NullTime
+	err := row.Scan(&o.ID, &o.Buyer, &o.Seller, &o.Status, &o.PlacedAt, &collectedAt, &o.Coupon, &currency,
+		&o.Net.Amount, &o.Tax.Amount, &o.Gross.Amount, &o.GSTNumber, &o.Discount.Amount, &o.PlatformDiscount.Amount, &o.Fee.Amount, &o.PaymentID,
+		&cancelledAt, &o.CancelledBy, &o.CancelReason)
+	for _, m := range []*string{&o.Net.Currency, &o.Tax.Currency, &o.Gross.Currency, &o.Discount.Currency, &o.PlatformDiscount.Currency, &o.Fee.Currency} {
+		*m = currency
+	}
+	if collectedAt.Valid {
+		o.CollectedAt = collectedAt.Time
+	}
+	if cancelledAt.Valid {
+		o.CancelledAt = cancelledAt.Time
+	}
+	return o, err
+}
+
+//-----------------------------------------------------------------------
+// Functions for orders
+//-----------------------------------------------------------------------
+// Function to store the orders of a checkout in the MYSQL database, with their lines and ledger postings.
+// All orders are stored in one transaction, so either all of them or none are recorded.
+// then, if not nil, is called with the stored orders inside the same transaction, e.g. to queue their emails,
+// and the orders are not recorded if it returns an error.
+// It returns the orders with their IDs, and false when there is any error encountered.
+func Place(db *sql.DB, orders []Order, then func(tx *sql.Tx, placed []Order) error) ([]Order, bool) {
+	tx, err := db.Begin()
+	if err != nil {
+		log.Println(err)
+		return orders, false
+	}
+	defer tx.Rollback()
+
+	for n, o := range orders {
+		result, err := tx.Exec("INSERT INTO `orders` (Buyer, Seller, Status, PlacedAt, Coupon, Currency, Net, Tax, Gross, GSTNumber, Discount, PlatformDiscount, Fee, PaymentID) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+			o.Buyer, o.Seller, o.Status, o.PlacedAt, o.Coupon, o.Gross.Currency,
+			o.Net.Amount, o.Tax.Amount, o.Gross.Amount, o.GSTNumber, o.Discount.Amount, o.PlatformDiscount.Amount, o.Fee.Amount, o.PaymentID)
+		if err != nil {
+			log.Println("Unable to insert the order")
+			log.Println(err)
+			return orders, false
+		}
+		o.ID, _ = result.LastInsertId()
+
+		for _, l := range o.Lines {
+			_, err := tx.Exec("INSERT INTO `orderlines` (OrderID, LotID, Item, Quantity, Unit, UnitCost, Total, Tax) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+				o.ID, l.LotID, l.Item, l.Quantity, l.Unit, l.UnitCost.Amount, l.Total.Amount, l.Tax.Amount)
+			if err != nil {
+				log.Println("Unable to insert the order line")
+				log.Println(err)
+				return orders, false
+			}
+		}
+
+		if err := ledger.Post(tx, o.ID, fmt.Sprintf("Order %d placed", o.ID), o.PlacedAt, placedEntries(o)); err != nil {
+			log.Println(err)
+			return orders, false
+		}
+		orders[n] = o
+	}
+
+	if then != nil {
+		if err := then(tx, orders); err != nil {
+			log.Println(err)
+			return orders, false
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		log.Println(err)
+		return orders, false
+	}
+	return orders, true
+}
+
+// Function to mark an order of a seller as collected in the MYSQL database, and post the payment by the buyer.
+// It returns true only when the order was waiting to be collected and has been updated.
+func MarkCollected(db *sql.DB, id int64, seller string, now time.Time) bool {
+	tx, err := db.Begin()
+	if err != nil {
+		log.Println(err)
+		return false
+	}
+	defer tx.Rollback()
+
+	o, err := scanOrder(tx.QueryRow("SELECT "+orderColumns+" FROM `orders` WHERE ID=? AND Seller=? AND Status=? FOR UPDATE", id, seller, StatusPlaced))
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Println("Unable to get the order")
+			log.Println(err)
+		}
+		return false
+	}
+	if _, err := tx.Exec("UPDATE `orders` SET Status=?, CollectedAt=? WHERE ID=?", StatusCollected, now, id); err != nil {
+		log.Println("Unable to update the order")
+		log.Println(err)
+		return false
+	}
+	if err := ledger.Post(tx, o.ID, fmt.Sprintf("Order %d collected", o.ID), now, collectedEntries(o)); err != nil {
+		log.Println(err)
+		return false
+	}
+	return tx.Commit() == nil
+}
+
+// Function to cancel an order of a buyer, or of a seller when asSeller is true, in the MYSQL database, and undo its ledger postings.
+// The seller gives a reason, which is sent to the buyer.
+// It returns the cancelled order with its lines, and false unless the order was waiting to be collected and has been cancelled.
+func Cancel(db *sql.DB, id int64, username string, asSeller bool, reason string, now time.Time) (Order, bool) {
+	tx, err := db.Begin()
+	if err != nil {
+		log.Println(err)
+		return Order{}, false
+	}
+	defer tx.Rollback()
+
+	column, by := "Buyer", CancelledByBuyer
+	if asSeller {
+		column, by = "Seller", CancelledBySeller
+	}
+	o, err := scanOrder(tx.QueryRow("SELECT "+orderColumns+" FROM `orders` WHERE ID=? AND "+column+"=? AND Status=? FOR UPDATE", id, username, StatusPlaced))
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Println("Unable to get the order")
+			log.Println(err)
+		}
+		return o, false
+	}
+	if _, err := tx.Exec("UPDATE `orders` SET Status=?, CancelledAt=?, CancelledBy=?, CancelReason=? WHERE ID=?", StatusCancelled, now, by, reason, id); err != nil {
+		log.Println("Unable to update the order")
+		log.Println(err)
+		return o, false
+	}
+	if err := ledger.Post(tx, o.ID, fmt.Sprintf("Order %d cancelled by %s", o.ID, by), now, cancelledEntries(o)); err != nil {
+		log.Println(err)
+		return o, false
+	}
+	if err := tx.Commit(); err != nil {
+		log.Println(err)
+		return o, false
+	}
+
+	o.Status, o.CancelledAt, o.CancelledBy, o.CancelReason = StatusCancelled, now, by, reason
+	// The order is cancelled even if its lines cannot be read, getLines logs the error
+	o.Lines, _ = getLines(db, o.ID, o.Gross.Currency)
+	return o, true
+}
+
+// Function to get an order with its lines from the MYSQL database.
+// It returns false when the order does not exist or there is any error encountered.
+func GetOrder(db *sql.DB, id int64) (Order, bool) {
+	o, err := scanOrder(db.QueryRow("SELECT "+orderColumns+" FROM `orders` WHERE ID=?", id))
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Println("Unable to get the order")
+			log.Println(err)
+		}
+		return o, false
+	}
+	lines, ok := getLines(db, o.ID, o.Gross.Currency)
+	o.Lines = lines
+	return o, ok
+}
+
+// Function to get the orders of a buyer, or of a seller when asSeller is true, from the MYSQL database, newest first.
+// It returns the orders with their lines, and false when there is any error encountered.
+func GetOrders(db *sql.DB, username string, asSeller bool) ([]Order, bool) {
+	var orders []Order
+	column := "Buyer"
+	if asSeller {
+		column = "Seller"
+	}
+	results, err := db.Query("SELECT "+orderColumns+" FROM `orders` WHERE "+column+"=? ORDER BY ID DESC", username)
+	if err != nil {
+		log.Println("Not able to get orders")
+		log.Println(err)
+		return orders, false
+	}
+	for results.Next() {
+		o, err := scanOrder(results)
+		if err != nil {
+			log.Println("Unable to get orders")
+			log.Println(err)
+			results.Close()
+			return orders, false
+		}
+		orders = append(orders, o)
+	}
+	results.Close()
+
+	// The lines of all the orders are read at once
+	results, err = db.Query("SELECT l.OrderID, "+lineColumns+" FROM `orderlines` l JOIN `orders` o ON o.ID = l.OrderID WHERE o."+column+"=? ORDER BY l.ID", username)
+	if err != nil {
+		log.Println("Not able to get order lines")
+		log.Println(err)
+		return orders, false
+	}
+	defer results.Close()
+
+	index := make(map[int64]int)
+	for n, o := range orders {
+		index[o.ID] = n
+	}
+	for results.Next() {
+		var id int64
+		var l Line
+		if err := results.Scan(append([]interface{}{&id}, lineFields(&l)...)...); err != nil {
+			log.Println("Unable to get order lines")
+			log.Println(err)
+			return orders, false
+		}
+		n, ok := index[id]
+		if !ok {
+			// The order was placed after the orders were read
+			continue
+		}
+		orders[n].Lines = append(orders[n].Lines, l.in(orders[n].Gross.Currency))
+	}
+	return orders, true
+}
+
+// Columns read for each order line, in the order of lineFields
+const lineColumns = "l.LotID, l.Item, l.Quantity, l.Unit, l.UnitCost, l.Total, l.Tax"
+
+// This function returns where to scan the columns of lineColumns into a line
+func lineFields(l *Line) []interface{} {
+	return []interface{}{&l.LotID, &l.Item, &l.Quantity, &l.Unit, &l.UnitCost.Amount, &l.Total.Amount, &l.Tax.Amount}
+}
+
+// This method sets the currency of the amounts of a line, which is stored with its order
+func (l Line) in(currency string) Line {
+	l.UnitCost.Currency = currency
+	l.Total.Currency = currency
+	l.Tax.Currency = currency
+	return l
+}
+
+// Function to get the lines of an order from the MYSQL database.
+// It returns false when there is any error encountered.
+func getLines(db *sql.DB, id int64, currency string) ([]Line, bool) {
+	var lines []Line
+	results, err := db.Query("SELECT "+lineColumns+" FROM `orderlines` l WHERE l.OrderID=? ORDER BY l.ID", id)
+	if err != nil {
+		log.Println("Not able to get order lines")
+		log.Println(err)
+		return lines, false
+	}
+	defer results.Close()
+
+	for results.Next() {
+		var l Line
+		if err := results.Scan(lineFields(&l)...); err != nil {
+			log.Println("Unable to get order lines")
+			log.Println(err)
+			return lines, false
+		}
+		lines = append(lines, l.in(currency))
+	}
+	return lines, true
+}
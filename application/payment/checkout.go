@@ -0,0 +1,115 @@
+package payment
+
+import (
+	"fmt"
+	"net/http"
+	"projectGoLive/application/config"
+	"projectGoLive/application/money"
+	"time"
+)
+
+// This function reserves the amount payable by a buyer with the current provider, and stores the payment
+// It returns an error, e.g. ErrDeclined, if the payment is not authorised
+func Authorise(buyername string, amount money.Money) (Record, error) {
+	now := time.Now()
+	r := Record{Provider: Current.Name(), Buyer: buyername, Amount: amount, CreatedAt: now, UpdatedAt: now}
+	p, err := Current.Authorise(fmt.Sprintf("%s-%d", buyername, now.UnixNano()), amount)
+	r.Reference = p.ID
+	r.Status = p.Status
+	if p.ID != "" {
+		if stored, ok := InsertRecord(config.DB, r); ok {
+			r = stored
+		} else if err == nil {
+			config.Error.Printf("Unable to store payment %s of buyer %s\n", p.ID, buyername)
+		}
+	}
+	if err != nil {
+		config.Warning.Printf("Payment of %s by buyer %s not authorised : %v\n", amount, buyername, err)
+		return r, err
+	}
+	return r, nil
+}
+
+// This function takes an authorised payment in full
+func Capture(r Record) error {
+	p, err := Current.Capture(r.Reference, r.Amount)
+	if err != nil {
+		config.Error.Printf("Unable to capture payment %d : %v\n", r.ID, err)
+		return err
+	}
+	if err := UpdateStatus(config.DB, r.ID, p.Status, time.Now()); err != nil {
+		config.Error.Printf("Unable to record capture of payment %d : %v\n", r.ID, err)
+	}
+	return nil
+}
+
+// This function gives back what is left of a payment, or releases it if it was only authorised
+func Refund(r Record) error {
+	left, err := r.Amount.Sub(r.Refunded)
+	if err != nil {
+		return err
+	}
+	return RefundAmount(r, left)
+}
+
+// This function gives back part of a captured payment
+func RefundAmount(r Record, amount money.Money) error {
+	p, err := Current.Refund(r.Reference, amount)
+	if err != nil {
+		config.Error.Printf("Unable to refund %s of payment %d : %v\n", amount, r.ID, err)
+		return err
+	}
+	if err := AddRefund(config.DB, r.ID, amount, p.Status, time.Now()); err != nil {
+		config.Error.Printf("Unable to record refund of payment %d : %v\n", r.ID, err)
+	}
+	return nil
+}
+
+// This function gives back part of the payment stored with the given ID, e.g. for an order paid online that is cancelled
+// The refund is made through the provider the payment was made with, which must still be the current provider
+func RefundPayment(id int64, amount money.Money) error {
+	r, ok := GetRecord(config.DB, id)
+	if !ok {
+		return ErrNotFound
+	}
+	if Current == nil || Current.Name() != r.Provider {
+		config.Error.Printf("Unable to refund payment %d, provider %s is not in use\n", id, r.Provider)
+		return ErrNotFound
+	}
+	return RefundAmount(r, amount)
+}
+
+//---------------------------------------------------------------------------
+// Functions for webhooks of the payment provider
+//---------------------------------------------------------------------------
+// This method is used to receive the events of the payment provider, such as refunds made by the provider
+// Any response other than 200 makes the provider send the event again later
+func WebhookHandler(w http.ResponseWriter, req *http.Request) {
+	if Current == nil {
+		http.NotFound(w, req)
+		return
+	}
+	event, err := Current.ParseWebhook(req)
+	if err != nil {
+		config.Warning.Printf("Rejected payment webhook : %v\n", err)
+		http.Error(w, "Invalid webhook", http.StatusBadRequest)
+		return
+	}
+
+	r, ok := GetRecordByReference(config.DB, Current.Name(), event.PaymentID)
+	if !ok {
+		config.Warning.Printf("Payment webhook %s for unknown payment %s\n", event.ID, event.PaymentID)
+		http.Error(w, "Unknown payment", http.StatusNotFound)
+		return
+	}
+	err = UpdateStatus(config.DB, r.ID, event.Status, time.Now())
+	if err == ErrInvalidState {
+		// An older event arriving after a newer one, there is nothing to do
+		config.Warning.Printf("Payment webhook %s ignored, payment %d is already %s\n", event.ID, r.ID, r.Status)
+	} else if err != nil {
+		http.Error(w, "Unable to update payment", http.StatusInternalServerError)
+		return
+	}
+	config.Info.Printf("Payment webhook %s : payment %d is %s\n", event.ID, r.ID, event.Status)
+	w.WriteHeader(http.StatusOK)
+}
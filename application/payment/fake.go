@@ -0,0 +1,181 @@
+package payment
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"projectGoLive/application/money"
+	"sync"
+	"time"
+)
+
+// Header carrying the signature of the fake provider's webhook requests
+const fakeSignatureHeader = "X-Fake-Signature"
+
+// Fake is a payment provider kept in memory, for development and tests.
+// Amounts ending in 13 minor units, e.g. SGD 4.13, are declined so failed payments can be tried out.
+// After each capture or refund it posts a signed event to WebhookURL, like a real gateway would.
+type Fake struct {
+	Secret     string
+	WebhookURL string
+
+	mu       sync.Mutex
+	payments map[string]Payment
+	next     int
+	client   *http.Client
+}
+
+// This function returns a fake provider signing its webhook events with secret
+// An empty webhookURL turns off sending webhook events
+func NewFake(secret, webhookURL string) *Fake {
+	return &Fake{
+		Secret:     secret,
+		WebhookURL: webhookURL,
+		payments:   make(map[string]Payment),
+		client: &http.Client{
+			Timeout: 10 * time.Second,
+			// The application runs with a self generated certificate in development
+			Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}},
+		},
+	}
+}
+
+// This method returns the name of the provider, stored with each payment
+func (f *Fake) Name() string {
+	return "fake"
+}
+
+// This method reserves an amount, it is declined if the amount ends in 13 minor units
+func (f *Fake) Authorise(reference string, amount money.Money) (Payment, error) {
+	if amount.Amount <= 0 {
+		return Payment{}, money.ErrInvalidAmount
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.next++
+	p := Payment{ID: fmt.Sprintf("fake_%d", f.next), Status: StatusAuthorised, Amount: amount, Refunded: money.Zero(amount.Currency)}
+	if amount.Amount%100 == 13 {
+		p.Status = StatusFailed
+		f.payments[p.ID] = p
+		return p, ErrDeclined
+	}
+	f.payments[p.ID] = p
+	return p, nil
+}
+
+// This method takes an authorised payment, up to the amount authorised
+func (f *Fake) Capture(paymentID string, amount money.Money) (Payment, error) {
+	f.mu.Lock()
+	p, ok := f.payments[paymentID]
+	if !ok {
+		f.mu.Unlock()
+		return p, ErrNotFound
+	}
+	if p.Status != StatusAuthorised || amount.Currency != p.Amount.Currency || amount.Amount > p.Amount.Amount {
+		f.mu.Unlock()
+		return p, ErrInvalidState
+	}
+	p.Status = StatusCaptured
+	p.Amount = amount
+	f.payments[paymentID] = p
+	f.mu.Unlock()
+
+	f.notify("payment.captured", p)
+	return p, nil
+}
+
+// This method gives back part or all of a captured payment, or releases an authorisation in full
+func (f *Fake) Refund(paymentID string, amount money.Money) (Payment, error) {
+	f.mu.Lock()
+	p, ok := f.payments[paymentID]
+	if !ok {
+		f.mu.Unlock()
+		return p, ErrNotFound
+	}
+	left, _ := p.Amount.Sub(p.Refunded)
+	valid := amount.Amount > 0 && amount.Currency == p.Amount.Currency && amount.Amount <= left.Amount
+	if (p.Status != StatusAuthorised && p.Status != StatusCaptured) || !valid || (p.Status == StatusAuthorised && amount != p.Amount) {
+		f.mu.Unlock()
+		return p, ErrInvalidState
+	}
+	if p.Status == StatusCaptured {
+		p.Refunded.Amount = p.Refunded.Amount + amount.Amount
+	}
+	if p.Status == StatusAuthorised || p.Refunded == p.Amount {
+		p.Status = StatusRefunded
+	}
+	f.payments[paymentID] = p
+	f.mu.Unlock()
+
+	f.notify("payment.refunded", p)
+	return p, nil
+}
+
+// This method checks the signature of a webhook request sent by SendWebhook and returns its event
+func (f *Fake) ParseWebhook(req *http.Request) (Event, error) {
+	var event Event
+	body, err := ioutil.ReadAll(io.LimitReader(req.Body, 1<<20))
+	if err != nil {
+		return event, err
+	}
+	signature, err := hex.DecodeString(req.Header.Get(fakeSignatureHeader))
+	if err != nil || !hmac.Equal(signature, f.sign(body)) {
+		return event, ErrSignature
+	}
+	err = json.Unmarshal(body, &event)
+	return event, err
+}
+
+// This method posts a signed event to the webhook URL, it can also be called to simulate an event
+func (f *Fake) SendWebhook(event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, f.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(fakeSignatureHeader, hex.EncodeToString(f.sign(body)))
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// This method sends the event for a change of a payment in the background, as a gateway does after responding
+func (f *Fake) notify(kind string, p Payment) {
+	if f.WebhookURL == "" {
+		return
+	}
+	f.mu.Lock()
+	f.next++
+	event := Event{ID: fmt.Sprintf("evt_%d", f.next), Type: kind, PaymentID: p.ID, Status: p.Status, Amount: p.Amount, CreatedAt: time.Now()}
+	f.mu.Unlock()
+	go func() {
+		if err := f.SendWebhook(event); err != nil {
+			log.Printf("Unable to send fake webhook %s : %v\n", event.ID, err)
+		}
+	}()
+}
+
+// This method returns the HMAC-SHA256 of a webhook body with the secret
+func (f *Fake) sign(body []byte) []byte {
+	mac := hmac.New(sha256.New, []byte(f.Secret))
+	mac.Write(body)
+	return mac.Sum(nil)
+}
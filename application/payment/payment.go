@@ -0,0 +1,102 @@
+// Package payment takes payment for orders through a payment provider.
+// Checkout only uses the PaymentProvider interface, so a real gateway can be added next to the fake provider
+// used for development, and chosen with PAYMENT_PROVIDER in the env file.
+// When no provider is set, buyers pay the seller during collection as before.
+package payment
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"projectGoLive/application/config"
+	"projectGoLive/application/money"
+	"time"
+)
+
+// Secret the fake provider signs its webhooks with when PAYMENT_WEBHOOK_SECRET is not set, only for development
+const fakeDevelopmentSecret = "fake-webhook-secret"
+
+// Status of a payment
+const (
+	StatusAuthorised = "authorised"
+	StatusCaptured   = "captured"
+	StatusRefunded   = "refunded"
+	StatusFailed     = "failed"
+)
+
+// Errors returned by providers
+var (
+	ErrDeclined     = errors.New("payment was declined")
+	ErrNotFound     = errors.New("payment does not exist")
+	ErrInvalidState = errors.New("payment cannot be changed in its current status")
+	ErrSignature    = errors.New("webhook signature does not match")
+)
+
+// Data structure for a payment as the provider sees it, ID is the provider's reference
+// Refunded is the part of a captured amount given back so far.
+type Payment struct {
+	ID       string
+	Status   string
+	Amount   money.Money
+	Refunded money.Money
+}
+
+// Data structure for an event sent by a provider to the webhook, e.g. a payment was captured or refunded
+type Event struct {
+	ID        string      `json:"id"`
+	Type      string      `json:"type"`
+	PaymentID string      `json:"payment_id"`
+	Status    string      `json:"status"`
+	Amount    money.Money `json:"amount"`
+	CreatedAt time.Time   `json:"created_at"`
+}
+
+// Interface for a payment provider
+// Authorise reserves an amount on the buyer's card, Capture takes up to the authorised amount,
+// Refund gives back part or all of a captured amount, or releases in full an authorisation that was not captured.
+// A payment stays captured until all of it has been refunded.
+// ParseWebhook checks that a webhook request comes from the provider and returns its event.
+type PaymentProvider interface {
+	Name() string
+	Authorise(reference string, amount money.Money) (Payment, error)
+	Capture(paymentID string, amount money.Money) (Payment, error)
+	Refund(paymentID string, amount money.Money) (Payment, error)
+	ParseWebhook(req *http.Request) (Event, error)
+}
+
+// Provider used by checkout, nil when buyers pay during collection
+var Current PaymentProvider
+
+// This function chooses the payment provider set in the env file, it is called when the application starts
+// The application does not start with a provider it does not know, or without the secret its webhooks are
+// signed with, so payments are never taken with webhooks anyone could forge.
+func Setup() {
+	secret, _ := os.LookupEnv("PAYMENT_WEBHOOK_SECRET")
+	provider, err := newProvider(os.Getenv("PAYMENT_PROVIDER"), secret, "https://"+config.PortNum+"/payment/webhook")
+	if err != nil {
+		panic(err)
+	}
+	Current = provider
+}
+
+// This function returns the provider called name, signing its webhooks with secret, nil when name is empty
+// Only the fake provider, which must be chosen by name, can run without a secret, it then uses a development secret.
+func newProvider(name, secret, webhookURL string) (PaymentProvider, error) {
+	if name == "" {
+		return nil, nil
+	}
+	if secret == "" && name != "fake" {
+		return nil, fmt.Errorf("PAYMENT_WEBHOOK_SECRET must be set to take payments with %q", name)
+	}
+	switch name {
+	case "fake":
+		if secret == "" {
+			log.Println("PAYMENT_WEBHOOK_SECRET is not set, the fake payment provider signs its webhooks with a development secret")
+			secret = fakeDevelopmentSecret
+		}
+		return NewFake(secret, webhookURL), nil
+	}
+	return nil, fmt.Errorf("unknown PAYMENT_PROVIDER %q", name)
+}
@@ -0,0 +1,212 @@
+package payment
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"projectGoLive/application/money"
+	"testing"
+	"time"
+)
+
+func sgd(amount int64) money.Money {
+	return money.Money{Amount: amount, Currency: "SGD"}
+}
+
+func TestAuthorise(t *testing.T) {
+	tests := []struct {
+		name       string
+		amount     money.Money
+		wantStatus string
+		wantErr    error
+	}{
+		{"authorised", sgd(1000), StatusAuthorised, nil},
+		{"declined", sgd(413), StatusFailed, ErrDeclined},
+		{"zero amount", sgd(0), "", money.ErrInvalidAmount},
+		{"negative amount", sgd(-100), "", money.ErrInvalidAmount},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := NewFake("secret", "")
+			p, err := f.Authorise("ref", tt.amount)
+			if err != tt.wantErr {
+				t.Fatalf("Authorise(%v) error = %v, want %v", tt.amount, err, tt.wantErr)
+			}
+			if p.Status != tt.wantStatus {
+				t.Errorf("Authorise(%v) status = %q, want %q", tt.amount, p.Status, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestCapture(t *testing.T) {
+	tests := []struct {
+		name    string
+		capture money.Money
+		twice   bool
+		unknown bool
+		wantErr error
+	}{
+		{"full amount", sgd(1000), false, false, nil},
+		{"part of the amount", sgd(600), false, false, nil},
+		{"more than authorised", sgd(1001), false, false, ErrInvalidState},
+		{"other currency", money.Money{Amount: 1000, Currency: "MYR"}, false, false, ErrInvalidState},
+		{"already captured", sgd(1000), true, false, ErrInvalidState},
+		{"unknown payment", sgd(1000), false, true, ErrNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := NewFake("secret", "")
+			p, _ := f.Authorise("ref", sgd(1000))
+			id := p.ID
+			if tt.unknown {
+				id = "fake_unknown"
+			}
+			if tt.twice {
+				f.Capture(id, tt.capture)
+			}
+			got, err := f.Capture(id, tt.capture)
+			if err != tt.wantErr {
+				t.Fatalf("Capture(%v) error = %v, want %v", tt.capture, err, tt.wantErr)
+			}
+			if err == nil && (got.Status != StatusCaptured || got.Amount != tt.capture) {
+				t.Errorf("Capture(%v) = %+v, want captured %v", tt.capture, got, tt.capture)
+			}
+		})
+	}
+}
+
+func TestRefund(t *testing.T) {
+	tests := []struct {
+		name         string
+		captured     bool
+		refunds      []money.Money
+		wantErr      error
+		wantStatus   string
+		wantRefunded money.Money
+	}{
+		{"release authorisation", false, []money.Money{sgd(1000)}, nil, StatusRefunded, sgd(0)},
+		{"part of authorisation", false, []money.Money{sgd(500)}, ErrInvalidState, StatusAuthorised, sgd(0)},
+		{"full refund", true, []money.Money{sgd(1000)}, nil, StatusRefunded, sgd(1000)},
+		{"partial refund", true, []money.Money{sgd(300)}, nil, StatusCaptured, sgd(300)},
+		{"partial refunds adding up", true, []money.Money{sgd(300), sgd(700)}, nil, StatusRefunded, sgd(1000)},
+		{"more than is left", true, []money.Money{sgd(600), sgd(600)}, ErrInvalidState, StatusCaptured, sgd(600)},
+		{"zero amount", true, []money.Money{sgd(0)}, ErrInvalidState, StatusCaptured, sgd(0)},
+		{"after full refund", true, []money.Money{sgd(1000), sgd(1)}, ErrInvalidState, StatusRefunded, sgd(1000)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := NewFake("secret", "")
+			p, _ := f.Authorise("ref", sgd(1000))
+			if tt.captured {
+				f.Capture(p.ID, sgd(1000))
+			}
+			var err error
+			for _, amount := range tt.refunds {
+				p, err = f.Refund(p.ID, amount)
+			}
+			if err != tt.wantErr {
+				t.Fatalf("Refund() error = %v, want %v", err, tt.wantErr)
+			}
+			if p.Status != tt.wantStatus || p.Refunded != tt.wantRefunded {
+				t.Errorf("Refund() = %s with %v refunded, want %s with %v refunded", p.Status, p.Refunded, tt.wantStatus, tt.wantRefunded)
+			}
+		})
+	}
+}
+
+func TestParseWebhook(t *testing.T) {
+	event := Event{ID: "evt_1", Type: "payment.captured", PaymentID: "fake_1", Status: StatusCaptured, Amount: sgd(1000), CreatedAt: time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)}
+	body, _ := json.Marshal(event)
+	signer := NewFake("secret", "")
+	tests := []struct {
+		name      string
+		body      []byte
+		signature string
+		wantErr   error
+	}{
+		{"valid signature", body, hex.EncodeToString(signer.sign(body)), nil},
+		{"signed with another secret", body, hex.EncodeToString(NewFake("other", "").sign(body)), ErrSignature},
+		{"body changed after signing", append([]byte(" "), body...), hex.EncodeToString(signer.sign(body)), ErrSignature},
+		{"no signature", body, "", ErrSignature},
+		{"signature not hex", body, "not-hex", ErrSignature},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/payment/webhook", bytes.NewReader(tt.body))
+			req.Header.Set(fakeSignatureHeader, tt.signature)
+			got, err := signer.ParseWebhook(req)
+			if err != tt.wantErr {
+				t.Fatalf("ParseWebhook() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && got != event {
+				t.Errorf("ParseWebhook() = %+v, want %+v", got, event)
+			}
+		})
+	}
+}
+
+func TestSendWebhook(t *testing.T) {
+	received := make(chan Event, 1)
+	receiver := NewFake("secret", "")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		event, err := receiver.ParseWebhook(req)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		received <- event
+	}))
+	defer server.Close()
+
+	f := NewFake("secret", server.URL)
+	p, _ := f.Authorise("ref", sgd(1000))
+	if _, err := f.Capture(p.ID, sgd(1000)); err != nil {
+		t.Fatalf("Capture() error = %v", err)
+	}
+	select {
+	case event := <-received:
+		if event.Type != "payment.captured" || event.PaymentID != p.ID || event.Status != StatusCaptured {
+			t.Errorf("webhook event = %+v, want payment.captured of %s", event, p.ID)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no webhook event received after capture")
+	}
+
+	if err := NewFake("wrong", server.URL).SendWebhook(Event{ID: "evt_2"}); err == nil {
+		t.Error("SendWebhook() signed with another secret was accepted")
+	}
+}
+
+func TestNewProvider(t *testing.T) {
+	tests := []struct {
+		name       string
+		provider   string
+		secret     string
+		wantNil    bool
+		wantSecret string
+		wantErr    bool
+	}{
+		{"no provider", "", "", true, "", false},
+		{"fake with secret", "fake", "s3cret", false, "s3cret", false},
+		{"fake without secret", "fake", "", false, fakeDevelopmentSecret, false},
+		{"other provider without secret", "stripe", "", true, "", true},
+		{"unknown provider", "stripe", "s3cret", true, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := newProvider(tt.provider, tt.secret, "")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newProvider(%q) error = %v, wantErr %v", tt.provider, err, tt.wantErr)
+			}
+			if (got == nil) != tt.wantNil {
+				t.Fatalf("newProvider(%q) = %v, want nil %v", tt.provider, got, tt.wantNil)
+			}
+			if f, ok := got.(*Fake); ok && f.Secret != tt.wantSecret {
+				t.Errorf("newProvider(%q) secret = %q, want %q", tt.provider, f.Secret, tt.wantSecret)
+			}
+		})
+	}
+}
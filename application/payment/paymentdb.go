@@ -0,0 +1,207 @@
+package payment
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"projectGoLive/application/ledger"
+	"projectGoLive/application/money"
+	"time"
+)
+
+// Data structure for each payment stored by the application
+// Reference is the provider's ID of the payment, used to match webhook events.
+// Refunded is the part of a captured amount given back so far.
+type Record struct {
+	ID        int64
+	Provider  string
+	Reference string
+	Buyer     string
+	Amount    money.Money
+	Refunded  money.Money
+	Status    string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// Changes of status allowed for a payment, a webhook event repeating the current status is ignored
+var transitions = map[string][]string{
+	StatusAuthorised: {StatusCaptured, StatusRefunded, StatusFailed},
+	StatusCaptured:   {StatusRefunded},
+}
+
+// Columns read for each payment, in the order used by scanRecord
+const recordColumns = "ID, Provider, Reference, Buyer, Amount, Refunded, Currency, Status, CreatedAt, UpdatedAt"
+
+// Interface satisfied by *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// Function to read one payment from a row selected with recordColumns.
+func scanRecord(row scanner) (Record, error) {
+	var r Record
+	err := row.Scan(&r.ID, &r.Provider, &r.Reference, &r.Buyer, &r.Amount.Amount, &r.Refunded.Amount, &r.Amount.Currency, &r.Status, &r.CreatedAt, &r.UpdatedAt)
+	r.Refunded.Currency = r.Amount.Currency
+	return r, err
+}
+
+//-----------------------------------------------------------------------
+// Functions for payments
+//-----------------------------------------------------------------------
+// Function to store a payment in the MYSQL database.
+// It returns the payment with its ID, and false when there is any error encountered.
+func InsertRecord(db *sql.DB, r Record) (Record, bool) {
+	result, err := db.Exec("INSERT INTO `payments` (Provider, Reference, Buyer, Amount, Currency, Status, CreatedAt, UpdatedAt) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		r.Provider, r.Reference, r.Buyer, r.Amount.Amount, r.Amount.Currency, r.Status, r.CreatedAt, r.UpdatedAt)
+	if err != nil {
+		log.Println("Unable to insert the payment")
+		log.Println(err)
+		return r, false
+	}
+	r.ID, _ = result.LastInsertId()
+	r.Refunded = money.Zero(r.Amount.Currency)
+	return r, true
+}
+
+// Function to get a payment from the MYSQL database by the provider's reference.
+// It returns false when the payment does not exist or there is any error encountered.
+func GetRecordByReference(db *sql.DB, provider, reference string) (Record, bool) {
+	r, err := scanRecord(db.QueryRow("SELECT "+recordColumns+" FROM `payments` WHERE Provider=? AND Reference=?", provider, reference))
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Println("Unable to get the payment")
+			log.Println(err)
+		}
+		return r, false
+	}
+	return r, true
+}
+
+// Function to get a payment from the MYSQL database.
+// It returns false when the payment does not exist or there is any error encountered.
+func GetRecord(db *sql.DB, id int64) (Record, bool) {
+	r, err := scanRecord(db.QueryRow("SELECT "+recordColumns+" FROM `payments` WHERE ID=?", id))
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Println("Unable to get the payment")
+			log.Println(err)
+		}
+		return r, false
+	}
+	return r, true
+}
+
+// Function to change the status of a payment in the MYSQL database, and post the money taken or given back to the ledger.
+// The same change can be reported twice, by the provider's response and by its webhook, it is only applied once.
+// It returns ErrInvalidState when the change is not allowed, e.g. an event arrived after a later one.
+func UpdateStatus(db *sql.DB, id int64, status string, now time.Time) error {
+	tx, err := db.Begin()
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	defer tx.Rollback()
+
+	r, err := scanRecord(tx.QueryRow("SELECT "+recordColumns+" FROM `payments` WHERE ID=? FOR UPDATE", id))
+	if err != nil {
+		log.Println("Unable to get the payment")
+		log.Println(err)
+		return err
+	}
+	if r.Status == status {
+		return nil
+	}
+	allowed := false
+	for _, next := range transitions[r.Status] {
+		allowed = allowed || next == status
+	}
+	if !allowed {
+		log.Printf("Payment %d cannot change from %s to %s\n", id, r.Status, status)
+		return ErrInvalidState
+	}
+
+	// Money only moves when a payment is captured, or refunded after being captured
+	// A refund after some partial refunds gives back what is left of the payment.
+	var entries []ledger.Entry
+	refunded := r.Refunded
+	if status == StatusCaptured {
+		entries = []ledger.Entry{
+			ledger.Debit(ledger.Payments, ledger.KindPayment, r.Amount),
+			ledger.Credit(ledger.BuyerAccount(r.Buyer), ledger.KindPayment, r.Amount),
+		}
+	} else if status == StatusRefunded && r.Status == StatusCaptured {
+		left, _ := r.Amount.Sub(r.Refunded)
+		entries = refundEntries(r.Buyer, left)
+		refunded = r.Amount
+	}
+
+	if _, err := tx.Exec("UPDATE `payments` SET Status=?, Refunded=?, UpdatedAt=? WHERE ID=?", status, refunded.Amount, now, id); err != nil {
+		log.Println("Unable to update the payment")
+		log.Println(err)
+		return err
+	}
+	if entries != nil {
+		if err := ledger.Post(tx, 0, fmt.Sprintf("Payment %d %s", id, status), now, entries); err != nil {
+			log.Println(err)
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Function to record a refund of part or all of a payment in the MYSQL database, and post the money given back to the ledger.
+// status is the status of the payment reported by the provider after the refund.
+// A payment already refunded in full, e.g. by its webhook arriving first, is left unchanged.
+// It returns ErrInvalidState when the payment was not captured or the amount is more than what is left of it.
+func AddRefund(db *sql.DB, id int64, amount money.Money, status string, now time.Time) error {
+	tx, err := db.Begin()
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	defer tx.Rollback()
+
+	r, err := scanRecord(tx.QueryRow("SELECT "+recordColumns+" FROM `payments` WHERE ID=? FOR UPDATE", id))
+	if err != nil {
+		log.Println("Unable to get the payment")
+		log.Println(err)
+		return err
+	}
+	if r.Status == StatusRefunded {
+		return nil
+	}
+	if r.Status == StatusAuthorised && status == StatusRefunded {
+		// An authorisation released before capture, no money was taken
+		if _, err := tx.Exec("UPDATE `payments` SET Status=?, UpdatedAt=? WHERE ID=?", status, now, id); err != nil {
+			log.Println("Unable to update the payment")
+			log.Println(err)
+			return err
+		}
+		return tx.Commit()
+	}
+
+	refunded, err := r.Refunded.Add(amount)
+	if err != nil || r.Status != StatusCaptured || amount.Amount <= 0 || refunded.Amount > r.Amount.Amount {
+		log.Printf("Payment %d cannot be refunded %s\n", id, amount)
+		return ErrInvalidState
+	}
+	if _, err := tx.Exec("UPDATE `payments` SET Status=?, Refunded=?, UpdatedAt=? WHERE ID=?", status, refunded.Amount, now, id); err != nil {
+		log.Println("Unable to update the payment")
+		log.Println(err)
+		return err
+	}
+	if err := ledger.Post(tx, 0, fmt.Sprintf("Payment %d refunded %s", id, amount), now, refundEntries(r.Buyer, amount)); err != nil {
+		log.Println(err)
+		return err
+	}
+	return tx.Commit()
+}
+
+// This function returns the ledger entries for giving back money taken from a buyer
+func refundEntries(buyername string, amount money.Money) []ledger.Entry {
+	return []ledger.Entry{
+		ledger.Debit(ledger.BuyerAccount(buyername), ledger.KindRefund, amount),
+		ledger.Credit(ledger.Payments, ledger.KindRefund, amount),
+	}
+}
@@ -0,0 +1,250 @@
+// Package photo stores the photos sellers upload for their listings.
+// Uploads are validated for type and size, a thumbnail is generated for each photo,
+// and photos and thumbnails are served through the router.
+package photo
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"projectGoLive/application/config"
+
+	"github.com/gorilla/mux"
+	uuid "github.com/satori/go.uuid"
+)
+
+// Errors returned when an uploaded photo is rejected
+var (
+	ErrTooLarge = errors.New("Photo must not be larger than 5 MB")
+	ErrNotImage = errors.New("Photo must be a JPEG, PNG or GIF image")
+)
+
+// Largest width or height of an uploaded photo in pixels, larger images are rejected before decoding
+const maxDimension = 8000
+
+// Largest number of pixels of an uploaded photo, so that decoding one never takes more than about 64 MB
+const maxPixels = 16 << 20
+
+// File extension for each accepted content type
+var extensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+}
+
+// Regular expression for names of stored photos, names are generated from a UUID and the file extension
+var nameMatch = regexp.MustCompile(`^[a-zA-Z0-9-]{1,64}\.(jpg|png|gif)$`)
+
+// Store used for all listing photos, opened when the application starts
+var Storage Store
+
+// This method opens the store of listing photos on local disk, it panics if the directory cannot be created
+func OpenStore() {
+	store, err := NewLocalStore(config.PhotoPath)
+	if err != nil {
+		panic(err)
+	}
+	Storage = store
+}
+
+// This method validates an uploaded photo, and stores it along with its thumbnail
+// It returns the name of the stored photo, which is kept with the listing
+func SaveUpload(file io.Reader) (string, error) {
+	data, err := ioutil.ReadAll(io.LimitReader(file, config.MaxPhotoSize+1))
+	if err != nil {
+		return "", err
+	}
+	if int64(len(data)) > config.MaxPhotoSize {
+		return "", ErrTooLarge
+	}
+
+	ext, ok := extensions[http.DetectContentType(data)]
+	if !ok {
+		return "", ErrNotImage
+	}
+
+	// Check the size of the image before decoding it
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || cfg.Width > maxDimension || cfg.Height > maxDimension || cfg.Width*cfg.Height > maxPixels {
+		return "", ErrNotImage
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return "", ErrNotImage
+	}
+
+	id, err := uuid.NewV4()
+	if err != nil {
+		return "", err
+	}
+	name := id.String() + ext
+
+	var thumb bytes.Buffer
+	if err := jpeg.Encode(&thumb, thumbnail(img, config.ThumbnailSize), &jpeg.Options{Quality: 80}); err != nil {
+		return "", err
+	}
+
+	if err := Storage.Save(name, bytes.NewReader(data)); err != nil {
+		return "", err
+	}
+	if err := Storage.Save(ThumbnailName(name), &thumb); err != nil {
+		Storage.Delete(name)
+		return "", err
+	}
+	return name, nil
+}
+
+// This method deletes a photo along with its thumbnail
+func Delete(name string) {
+	if !nameMatch.MatchString(name) {
+		return
+	}
+	if err := Storage.Delete(name); err != nil {
+		config.Error.Println(err)
+	}
+	if err := Storage.Delete(ThumbnailName(name)); err != nil {
+		config.Error.Println(err)
+	}
+}
+
+// This method returns the name of the thumbnail of a photo
+func ThumbnailName(name string) string {
+	return strings.TrimSuffix(name, filepath.Ext(name)) + "-thumb.jpg"
+}
+
+//---------------------------------------------------------------------------
+// Handlers for serving photos
+//---------------------------------------------------------------------------
+// This method is used to serve a listing photo for /photos/{name}
+func PhotoHandler(w http.ResponseWriter, req *http.Request) {
+	name := mux.Vars(req)["name"]
+	servePhoto(w, name, name)
+}
+
+// This method is used to serve the thumbnail of a listing photo for /photos/thumb/{name}
+func ThumbnailHandler(w http.ResponseWriter, req *http.Request) {
+	name := mux.Vars(req)["name"]
+	servePhoto(w, name, ThumbnailName(name))
+}
+
+// This method writes the stored file to the response, if name is a valid photo name
+func servePhoto(w http.ResponseWriter, name, file string) {
+	if !nameMatch.MatchString(name) {
+		http.NotFound(w, nil)
+		return
+	}
+	f, err := Storage.Open(file)
+	if err != nil {
+		http.NotFound(w, nil)
+		return
+	}
+	defer f.Close()
+
+	contentType := "image/jpeg"
+	for ct, ext := range extensions {
+		if filepath.Ext(file) == ext {
+			contentType = ct
+		}
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	if _, err := io.Copy(w, f); err != nil {
+		config.Error.Println(err)
+	}
+}
+
+// This method scales an image down to fit in a square of size pixels, keeping its aspect ratio
+// Each pixel of the thumbnail is the average of the pixels of the image it covers
+func thumbnail(img image.Image, size int) image.Image {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	tw, th := w, h
+	if w > size || h > size {
+		if w >= h {
+			tw, th = size, h*size/w
+		} else {
+			tw, th = w*size/h, size
+		}
+	}
+	if tw < 1 {
+		tw = 1
+	}
+	if th < 1 {
+		th = 1
+	}
+
+	at := pixelReader(img)
+	thumb := image.NewRGBA(image.Rect(0, 0, tw, th))
+	for ty := 0; ty < th; ty++ {
+		y0 := bounds.Min.Y + ty*h/th
+		y1 := bounds.Min.Y + (ty+1)*h/th
+		for tx := 0; tx < tw; tx++ {
+			x0 := bounds.Min.X + tx*w/tw
+			x1 := bounds.Min.X + (tx+1)*w/tw
+			var r, g, b, a, n uint32
+			for y := y0; y < y1; y++ {
+				for x := x0; x < x1; x++ {
+					pr, pg, pb, pa := at(x, y)
+					r, g, b, a = r+pr, g+pg, b+pb, a+pa
+					n++
+				}
+			}
+			if n == 0 {
+				continue
+			}
+			i := thumb.PixOffset(tx, ty)
+			thumb.Pix[i], thumb.Pix[i+1], thumb.Pix[i+2], thumb.Pix[i+3] = uint8(r/n>>8), uint8(g/n>>8), uint8(b/n>>8), uint8(a/n>>8)
+		}
+	}
+	return thumb
+}
+
+// This method returns a function reading the alpha-premultiplied colour of a pixel of an image, in the range of color.RGBA
+// The pixels of the image types returned by the JPEG, PNG and GIF decoders are read from the image type itself,
+// as reading each pixel through the image.Image interface allocates a colour.
+func pixelReader(img image.Image) func(x, y int) (r, g, b, a uint32) {
+	switch m := img.(type) {
+	case *image.YCbCr:
+		return func(x, y int) (uint32, uint32, uint32, uint32) {
+			yi, ci := m.YOffset(x, y), m.COffset(x, y)
+			return color.YCbCr{Y: m.Y[yi], Cb: m.Cb[ci], Cr: m.Cr[ci]}.RGBA()
+		}
+	case *image.Gray:
+		return func(x, y int) (uint32, uint32, uint32, uint32) {
+			v := uint32(m.Pix[m.PixOffset(x, y)]) * 0x101
+			return v, v, v, 0xffff
+		}
+	case *image.RGBA:
+		return func(x, y int) (uint32, uint32, uint32, uint32) {
+			i := m.PixOffset(x, y)
+			return uint32(m.Pix[i]) * 0x101, uint32(m.Pix[i+1]) * 0x101, uint32(m.Pix[i+2]) * 0x101, uint32(m.Pix[i+3]) * 0x101
+		}
+	case *image.NRGBA:
+		return func(x, y int) (uint32, uint32, uint32, uint32) {
+			i := m.PixOffset(x, y)
+			return color.NRGBA{R: m.Pix[i], G: m.Pix[i+1], B: m.Pix[i+2], A: m.Pix[i+3]}.RGBA()
+		}
+	case *image.Paletted:
+		return func(x, y int) (uint32, uint32, uint32, uint32) {
+			i := int(m.Pix[m.PixOffset(x, y)])
+			if i >= len(m.Palette) {
+				return 0, 0, 0, 0
+			}
+			return m.Palette[i].RGBA()
+		}
+	}
+	return func(x, y int) (uint32, uint32, uint32, uint32) {
+		return img.At(x, y).RGBA()
+	}
+}
@@ -0,0 +1,67 @@
+package photo
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// Store is used to keep listing photos.
+// Photos are kept on local disk for now, object storage can be added by implementing this interface.
+type Store interface {
+	// Save stores the photo data under name, replacing any photo with the same name
+	Save(name string, data io.Reader) error
+	// Open returns the photo stored under name, the caller must close it
+	Open(name string) (io.ReadCloser, error)
+	// Delete removes the photo stored under name
+	Delete(name string) error
+}
+
+// LocalStore keeps photos as files in a directory on local disk
+type LocalStore struct {
+	Dir string
+}
+
+// This method creates a store keeping photos in dir, the directory is created if it does not exist
+func NewLocalStore(dir string) (*LocalStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &LocalStore{Dir: dir}, nil
+}
+
+func (s *LocalStore) Save(name string, data io.Reader) error {
+	// Write to a temporary file first, so a failed upload never leaves half a photo behind
+	tmp, err := ioutil.TempFile(s.Dir, "upload-*")
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(tmp, data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path(name))
+}
+
+func (s *LocalStore) Open(name string) (io.ReadCloser, error) {
+	return os.Open(s.path(name))
+}
+
+func (s *LocalStore) Delete(name string) error {
+	err := os.Remove(s.path(name))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// This method returns the file path of a photo, only the base name is used so names cannot leave the directory
+func (s *LocalStore) path(name string) string {
+	return filepath.Join(s.Dir, filepath.Base(name))
+}
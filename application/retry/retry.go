@@ -0,0 +1,42 @@
+// Package retry runs the jobs queued in the database for the background workers, such as the emails of the outbox
+// and the webhook deliveries, reading the jobs due a batch at a time and trying the failed ones again later,
+// waiting twice as long after each failure.
+package retry
+
+import "time"
+
+// This function returns how long to wait before the next attempt after a number of failed attempts
+// The wait doubles after each failure, from first up to longest.
+func Backoff(attempts int, first, longest time.Duration) time.Duration {
+	wait := first
+	for n := 1; n < attempts && wait < longest; n++ {
+		wait = wait * 2
+	}
+	if wait > longest {
+		wait = longest
+	}
+	return wait
+}
+
+// This function runs the jobs due, a batch at a time, until no more are due
+// due reads up to batch jobs and returns how many it read, or false when they cannot be read. Each job read is
+// claimed with claim, which returns false when another worker has taken it, and only run with run once claimed.
+// Jobs are given to claim and run by their index in the batch read last.
+func RunDue(batch int, due func(limit int) (int, bool), claim func(i int) bool, run func(i int)) {
+	for {
+		n, ok := due(batch)
+		if !ok || n == 0 {
+			return
+		}
+		for i := 0; i < n; i++ {
+			// Another worker may have taken the job, it is held while it is being run
+			if !claim(i) {
+				continue
+			}
+			run(i)
+		}
+		if n < batch {
+			return
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package retry
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{0, time.Minute},
+		{1, time.Minute},
+		{2, 2 * time.Minute},
+		{3, 4 * time.Minute},
+		{5, 16 * time.Minute},
+		{6, 30 * time.Minute},
+		{100, 30 * time.Minute},
+	}
+	for _, test := range tests {
+		if got := Backoff(test.attempts, time.Minute, 30*time.Minute); got != test.want {
+			t.Errorf("Backoff(%d) = %v, want %v", test.attempts, got, test.want)
+		}
+	}
+}
+
+func TestRunDue(t *testing.T) {
+	tests := []struct {
+		name    string
+		jobs    int
+		taken   map[int]bool
+		wantRun []int
+		wantDue int
+	}{
+		{"no jobs", 0, nil, nil, 1},
+		{"less than a batch", 2, nil, []int{0, 1}, 1},
+		{"full batch reads again", 3, nil, []int{0, 1, 2}, 2},
+		{"several batches", 7, nil, []int{0, 1, 2, 3, 4, 5, 6}, 3},
+		{"taken by another worker", 5, map[int]bool{1: true, 4: true}, []int{0, 2, 3}, 2},
+	}
+	for _, test := range tests {
+		// Jobs are numbered, and each is read until it is run or taken
+		var queue []int
+		for j := 0; j < test.jobs; j++ {
+			queue = append(queue, j)
+		}
+		var batch []int
+		var run []int
+		calls := 0
+		RunDue(3, func(limit int) (int, bool) {
+			calls++
+			batch = nil
+			for len(batch) < limit && len(queue) > 0 {
+				batch, queue = append(batch, queue[0]), queue[1:]
+			}
+			return len(batch), true
+		}, func(i int) bool {
+			return !test.taken[batch[i]]
+		}, func(i int) {
+			run = append(run, batch[i])
+		})
+		if !reflect.DeepEqual(run, test.wantRun) {
+			t.Errorf("%s: ran %v, want %v", test.name, run, test.wantRun)
+		}
+		if calls != test.wantDue {
+			t.Errorf("%s: read %d batches, want %d", test.name, calls, test.wantDue)
+		}
+	}
+}
+
+func TestRunDueError(t *testing.T) {
+	RunDue(3, func(limit int) (int, bool) {
+		return 3, false
+	}, func(i int) bool {
+		t.Errorf("claimed job %d of a batch that could not be read", i)
+		return false
+	}, func(i int) {})
+}
@@ -0,0 +1,156 @@
+// Package savedsearch keeps the searches buyers save, and emails them when a listing matching one appears.
+// Searches are checked against the events about listings recorded by the seller API: new listings, listings back
+// in stock and price drops. A buyer is emailed once per listing, and again only if its price drops further.
+package savedsearch
+
+import (
+	"net/http"
+	"projectGoLive/application/apiclient"
+	"projectGoLive/application/config"
+	"projectGoLive/application/email"
+	"projectGoLive/application/listingevents"
+	"projectGoLive/application/location"
+	"projectGoLive/application/money"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Number of searches a buyer can save
+const MaxSearches = 10
+
+// Data structure for a search of a buyer
+// MaxPrice is the highest price per unit, MinQuantity the lowest quantity available and MaxDistance the furthest
+// distance in km from the buyer to the seller. Each is not checked when it is zero.
+type SavedSearch struct {
+	ID          int64
+	Buyer       string
+	Item        string
+	MaxPrice    money.Money
+	MinQuantity float64
+	MaxDistance float64
+	CreatedAt   time.Time
+}
+
+// This method returns a label describing the search, shown to buyers and in the alert emails
+func (s SavedSearch) Label() string {
+	label := s.Item
+	if s.MaxPrice.Amount > 0 {
+		label += " at most " + s.MaxPrice.String() + " per unit"
+	}
+	if s.MinQuantity > 0 {
+		label += " with at least " + strconv.FormatFloat(s.MinQuantity, 'f', -1, 64) + " available"
+	}
+	if s.MaxDistance > 0 {
+		label += " within " + strconv.FormatFloat(s.MaxDistance, 'f', -1, 64) + " km"
+	}
+	return label
+}
+
+// This method returns true if a listing matches the search
+// distance is the distance from the buyer to the seller in km, known is false when it cannot be worked out,
+// in which case listings only match searches without a distance.
+func (s SavedSearch) Matches(item apiclient.ItemsDetails, distance float64, known bool) bool {
+	switch {
+	case item.Item != s.Item || item.SoldOut():
+		return false
+	case s.MaxPrice.Amount > 0 && (item.Cost.Currency != s.MaxPrice.Currency || item.Cost.Amount > s.MaxPrice.Amount):
+		return false
+	case s.MinQuantity > 0 && item.Quantity < s.MinQuantity:
+		return false
+	case s.MaxDistance > 0 && (!known || distance > s.MaxDistance):
+		return false
+	}
+	return true
+}
+
+// This function reads a search from the form of the buyer's search page
+// It returns a message for the user if the form is not valid, or an empty string.
+func ReadForm(req *http.Request, buyer string) (SavedSearch, string) {
+	s := SavedSearch{Buyer: buyer, Item: req.FormValue("fruit"), MaxPrice: money.Zero(money.DefaultCurrency)}
+	if s.Item == "" {
+		return s, "Please choose an item to search"
+	}
+	if maxprice := strings.TrimSpace(req.FormValue("maxprice")); maxprice != "" {
+		amount, err := money.Parse(maxprice, money.DefaultCurrency)
+		if err != nil || amount.Amount <= 0 {
+			return s, "Please enter a highest price such as 1.50"
+		}
+		s.MaxPrice = amount
+	}
+	if minquantity := strings.TrimSpace(req.FormValue("minquantity")); minquantity != "" {
+		q, err := strconv.ParseFloat(minquantity, 64)
+		if err != nil || q < 0 {
+			return s, "Please enter a lowest quantity such as 5"
+		}
+		s.MinQuantity = q
+	}
+	if maxdistance := strings.TrimSpace(req.FormValue("maxdistance")); maxdistance != "" {
+		km, err := strconv.ParseFloat(maxdistance, 64)
+		if err != nil || km < 0 {
+			return s, "Please enter a distance in km such as 5"
+		}
+		s.MaxDistance = km
+	}
+	return s, ""
+}
+
+//---------------------------------------------------------------------------
+// Functions to match listings with saved searches
+//---------------------------------------------------------------------------
+func init() {
+	listingevents.Subscribe(apiclient.EventListed, match)
+	listingevents.Subscribe(apiclient.EventRestocked, match)
+	listingevents.Subscribe(apiclient.EventPriceDrop, match)
+}
+
+// This method emails the buyers whose saved searches match a listing in an event
+// A buyer is only emailed about a listing again if its price is lower than when they were last emailed.
+// It returns false if any buyer could not be emailed, the buyers already emailed are not emailed again.
+func match(event apiclient.ListingEvent) bool {
+	item := event.Item
+	searches, ok := GetSearchesForItem(config.DB, item.Item)
+	if !ok {
+		config.Error.Printf("Unable to get saved searches for listing %d %s\n", item.ID, item.Item)
+		return false
+	}
+	if len(searches) == 0 {
+		return true
+	}
+
+	handled := true
+	seller, sellerKnown := location.OfUser(item.Username)
+	for _, s := range searches {
+		if s.Buyer == item.Username {
+			continue
+		}
+		buyer, buyerKnown := location.OfUser(s.Buyer)
+		distance, known := 0.0, buyerKnown && sellerKnown
+		if known {
+			distance = location.DistanceKm(buyer, seller)
+		}
+		if !s.Matches(item, distance, known) {
+			continue
+		}
+
+		was, alerted, ok := getAlert(config.DB, s.ID, item.ID)
+		if !ok {
+			handled = false
+			continue
+		}
+		if alerted && (was.Currency != item.Cost.Currency || item.Cost.Amount >= was.Amount) {
+			continue
+		}
+		if !alerted {
+			was = money.Money{}
+		}
+		distanceLabel := ""
+		if known {
+			distanceLabel = location.Approximate(distance)
+		}
+		if !email.SendSearchAlert(s.Buyer, s.Label(), item, distanceLabel, was) || !setAlert(config.DB, s.ID, item.ID, item.Cost, time.Now()) {
+			handled = false
+		}
+	}
+	return handled
+}
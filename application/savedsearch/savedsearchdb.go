@@ -0,0 +1,124 @@
+package savedsearch
+
+import (
+	"database/sql"
+	"log"
+	"projectGoLive/application/money"
+	"time"
+)
+
+// Columns read for each saved search, in the order used by querySearches
+const searchColumns = "ID, Buyer, Item, MaxPrice, Currency, MinQuantity, MaxDistance, CreatedAt"
+
+//-----------------------------------------------------------------------
+// Functions for saved searches
+//-----------------------------------------------------------------------
+// Function to store a search of a buyer in the MYSQL database.
+// It returns false when the buyer already has MaxSearches searches or there is any error encountered.
+func InsertSearch(db *sql.DB, s SavedSearch) bool {
+	searches, ok := GetSearches(db, s.Buyer)
+	if !ok || len(searches) >= MaxSearches {
+		return false
+	}
+	_, err := db.Exec("INSERT INTO `savedsearches` (Buyer, Item, MaxPrice, Currency, MinQuantity, MaxDistance, CreatedAt) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		s.Buyer, s.Item, s.MaxPrice.Amount, s.MaxPrice.Currency, s.MinQuantity, s.MaxDistance, time.Now())
+	if err != nil {
+		log.Println("Unable to insert the saved search")
+		log.Println(err)
+		return false
+	}
+	return true
+}
+
+// Function to get the saved searches of a buyer from the MYSQL database, oldest first.
+// It returns false when there is any error encountered.
+func GetSearches(db *sql.DB, buyer string) ([]SavedSearch, bool) {
+	return querySearches(db, "SELECT "+searchColumns+" FROM `savedsearches` WHERE Buyer=? ORDER BY ID", buyer)
+}
+
+// Function to get the saved searches of all buyers for an item from the MYSQL database.
+// It returns false when there is any error encountered.
+func GetSearchesForItem(db *sql.DB, item string) ([]SavedSearch, bool) {
+	return querySearches(db, "SELECT "+searchColumns+" FROM `savedsearches` WHERE Item=? ORDER BY ID", item)
+}
+
+// Function to delete a saved search of a buyer, and the record of its alerts, from the MYSQL database.
+// It returns false when the search does not exist or there is any error encountered.
+func DeleteSearch(db *sql.DB, id int64, buyer string) bool {
+	result, err := db.Exec("DELETE FROM `savedsearches` WHERE ID=? AND Buyer=?", id, buyer)
+	if err != nil {
+		log.Println("Unable to delete the saved search")
+		log.Println(err)
+		return false
+	}
+	n, _ := result.RowsAffected()
+	return n == 1
+}
+
+// Function to delete all saved searches of a buyer from the MYSQL database, used when the account is purged.
+// It returns false when there is any error encountered.
+func DeleteSearches(db *sql.DB, buyer string) bool {
+	_, err := db.Exec("DELETE FROM `savedsearches` WHERE Buyer=?", buyer)
+	if err != nil {
+		log.Println("Unable to delete saved searches")
+		log.Println(err)
+		return false
+	}
+	return true
+}
+
+// Function to run a query selecting saved searches with searchColumns.
+func querySearches(db *sql.DB, query string, args ...interface{}) ([]SavedSearch, bool) {
+	var searches []SavedSearch
+	results, err := db.Query(query, args...)
+	if err != nil {
+		log.Println("Not able to get saved searches")
+		log.Println(err)
+		return searches, false
+	}
+	defer results.Close()
+
+	for results.Next() {
+		var s SavedSearch
+		if err := results.Scan(&s.ID, &s.Buyer, &s.Item, &s.MaxPrice.Amount, &s.MaxPrice.Currency, &s.MinQuantity, &s.MaxDistance, &s.CreatedAt); err != nil {
+			log.Println("Unable to get saved searches")
+			log.Println(err)
+			return searches, false
+		}
+		searches = append(searches, s)
+	}
+	return searches, true
+}
+
+//-----------------------------------------------------------------------
+// Functions for the alerts sent for saved searches
+//-----------------------------------------------------------------------
+// Function to get the price of a listing when the buyer of a search was last emailed about it from the MYSQL database.
+// It returns the price, false as second value if the buyer was never emailed about it, and false as third value
+// when there is any error encountered.
+func getAlert(db *sql.DB, searchID, lotID int64) (money.Money, bool, bool) {
+	var cost money.Money
+	err := db.QueryRow("SELECT Cost, Currency FROM `savedsearchalerts` WHERE SearchID=? AND LotID=?", searchID, lotID).Scan(&cost.Amount, &cost.Currency)
+	if err == sql.ErrNoRows {
+		return cost, false, true
+	}
+	if err != nil {
+		log.Println("Unable to get the saved search alert")
+		log.Println(err)
+		return cost, false, false
+	}
+	return cost, true, true
+}
+
+// Function to record in the MYSQL database that the buyer of a search has been emailed about a listing at a price.
+// It returns false when there is any error encountered.
+func setAlert(db *sql.DB, searchID, lotID int64, cost money.Money, now time.Time) bool {
+	_, err := db.Exec("INSERT INTO `savedsearchalerts` (SearchID, LotID, Cost, Currency, AlertedAt) VALUES (?, ?, ?, ?, ?) ON DUPLICATE KEY UPDATE Cost=VALUES(Cost), Currency=VALUES(Currency), AlertedAt=VALUES(AlertedAt)",
+		searchID, lotID, cost.Amount, cost.Currency, now)
+	if err != nil {
+		log.Println("Unable to record the saved search alert")
+		log.Println(err)
+		return false
+	}
+	return true
+}
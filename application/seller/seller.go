@@ -2,70 +2,115 @@
 package seller
 
 import (
+	"fmt"
 	"net/http"
 	"projectGoLive/application/apiclient"
 	"projectGoLive/application/config"
+	"projectGoLive/application/coupon"
+	"projectGoLive/application/email"
+	"projectGoLive/application/ledger"
+	"projectGoLive/application/money"
+	"projectGoLive/application/notify"
+	"projectGoLive/application/orders"
+	"projectGoLive/application/payment"
+	"projectGoLive/application/photo"
 	"projectGoLive/application/server"
+	"projectGoLive/application/tax"
 	"projectGoLive/application/user_db"
+	"projectGoLive/application/webhook"
+	"strconv"
+	"strings"
+	"time"
 )
 
+// Layout of the dates sent by the date inputs of the item forms
+const dateFormat = "2006-01-02"
+
+// Number of bulk price rows shown in the item forms
+const maxTiers = 3
+
+// Number of latest webhook deliveries shown on the webhooks page
+const deliveryLogSize = 50
+
 type sellerStruct struct {
 	Sellername  string
+	IsBuyer     bool
 	Operation   string
 	Mainmessage []string
 	Selleritems []apiclient.ItemsDetails
+	Catalogue   []apiclient.PeelType
+	Images      map[string]string
+	Units       []string
+	TierRows    []int
+	Coupons     []coupon.Coupon
+	// GST registration of the seller, its number and the rate it charges, shown on the profile
+	GSTRegistered bool
+	GSTNumber     string
+	GSTRate       string
+	// Orders of the seller and what the platform owes the seller, shown on the earnings page
+	Orders     []orders.Order
+	Payouts    []ledger.Payout
+	Commission string
+	// Webhooks of the seller and the log of their deliveries, shown on the webhooks page
+	Webhooks   []webhook.Endpoint
+	Deliveries []webhook.Delivery
+	Events     []string
+}
+
+// This method adds the catalogue of peel types to the data sent to template, used for selects and images
+func (s *sellerStruct) addCatalogue() {
+	catalogue, ok := apiclient.GetCatalogue()
+	if !ok {
+		config.Error.Println("Unable to get catalogue from seller API")
+	}
+	s.Catalogue = catalogue
+	s.Images = apiclient.CatalogueImages(catalogue)
+	s.Units = apiclient.Units
+	s.TierRows = tierRows()
 }
 
 //---------------------------------------------------------------------------
 // Functions to display all items added by seller
 //---------------------------------------------------------------------------
 // This method is used to view the items added by seller
-func SellerHandler(w http.ResponseWriter, req *http.Request) {
-	if !server.ActiveSession(w, req) {
-		http.Redirect(w, req, "/login", http.StatusSeeOther)
-		return
-	}
-
-	user := server.GetUser(w, req)
-
+func SellerHandler(w http.ResponseWriter, req *http.Request, user server.UserInfo) {
 	sellerMessage := sellerStruct{
 		Sellername:  user.Username,
+		IsBuyer:     user.IsBuyer(),
 		Operation:   "view",
 		Mainmessage: nil,
 		Selleritems: nil,
 	}
 	sellerMessage.Mainmessage = append(sellerMessage.Mainmessage, "List of items added: ")
 
-	if user.IsBuyer { // Not possible
-		config.Trace.Printf("Incorrect login information! username: %s  is not a seller.", user.Username)
-		config.Error.Printf("Incorrect login information! username: %s  is not a seller.", user.Username)
-		return
-	}
-
-	si, ok := apiclient.GetItem("", user.Username, user.IsBuyer) // get all items for this seller only
+	si, ok := apiclient.GetItem("", user.Username, false) // get all items for this seller only
 	if !ok {
 		config.Trace.Printf("Unable to get item data for seller %s \n", user.Username)
 		config.Error.Printf("Unable to get item data for seller %s \n", user.Username)
 		return
 	}
 
+	// Tell the seller about lots that sold out or are running low, they are kept until restocked or deleted
+	for _, lot := range si {
+		if lot.SoldOut() {
+			sellerMessage.Mainmessage = append(sellerMessage.Mainmessage, fmt.Sprintf("Sold out : %s lot %d, update its quantity to sell it again", lot.Item, lot.ID))
+		} else if lot.LowOnStock() {
+			sellerMessage.Mainmessage = append(sellerMessage.Mainmessage, fmt.Sprintf("Low stock : %s lot %d, only %s left", lot.Item, lot.ID, lot.QuantityLabel()))
+		}
+	}
+
 	sellerMessage.Selleritems = si
+	sellerMessage.addCatalogue()
 	config.TPL.ExecuteTemplate(w, "sellertemplate.gohtml", sellerMessage)
 }
 
 //---------------------------------------------------------------------------
 // Functions to add an item for seller
 //---------------------------------------------------------------------------
-func AddItemHandler(w http.ResponseWriter, req *http.Request) {
-	if !server.ActiveSession(w, req) {
-		http.Redirect(w, req, "/login", http.StatusSeeOther)
-		return
-	}
-
-	user := server.GetUser(w, req)
-
+func AddItemHandler(w http.ResponseWriter, req *http.Request, user server.UserInfo) {
 	sellerMessage := sellerStruct{
 		Sellername:  user.Username,
+		IsBuyer:     user.IsBuyer(),
 		Operation:   "add",
 		Mainmessage: nil,
 		Selleritems: nil,
@@ -73,6 +118,13 @@ func AddItemHandler(w http.ResponseWriter, req *http.Request) {
 
 	// process form submission , when seller clicks submit
 	if req.Method == http.MethodPost {
+		if err := parseItemForm(w, req); err != nil {
+			config.Warning.Printf("Unable to read item form : %v\n", err)
+			sellerMessage.Mainmessage = append(sellerMessage.Mainmessage, formError("add", err))
+			sellerMessage.addCatalogue()
+			config.TPL.ExecuteTemplate(w, "sellertemplate.gohtml", sellerMessage)
+			return
+		}
 
 		var item apiclient.ItemsDetails
 		fruitname := req.FormValue("fruit")
@@ -81,78 +133,156 @@ func AddItemHandler(w http.ResponseWriter, req *http.Request) {
 
 		if fruitname != "" {
 			item.Item = fruitname
-			item.Quantity = config.ConvertToInt(quantity)
-			item.Cost = config.ConvertToFloat(cost)
+			item.Quantity = config.ConvertToFloat(quantity)
+			price, err := money.Parse(cost, money.DefaultCurrency)
+			if err != nil || price.IsNegative() {
+				sellerMessage.Mainmessage = append(sellerMessage.Mainmessage, "Unable to add item, please enter a cost such as 1.20")
+				sellerMessage.addCatalogue()
+				config.TPL.ExecuteTemplate(w, "sellertemplate.gohtml", sellerMessage)
+				return
+			}
+			item.Cost = price
 			item.Username = user.Username
 
-			ok := apiclient.AddItem(item.Item, item.Username, user.IsBuyer, item)
-			if !ok {
-				sellerMessage.Mainmessage = append(sellerMessage.Mainmessage, "Unable to add item\nIf item already exists, please update item, else try again!")
-			} else {
-				http.Redirect(w, req, "/seller", http.StatusSeeOther)
+			if !readOrderSizes(req, &item) {
+				sellerMessage.Mainmessage = append(sellerMessage.Mainmessage, "Unable to add item, please choose a unit, and a minimum order and step above 0")
+				sellerMessage.addCatalogue()
+				config.TPL.ExecuteTemplate(w, "sellertemplate.gohtml", sellerMessage)
+				return
+			}
+
+			if !readTiers(req, &item) {
+				sellerMessage.Mainmessage = append(sellerMessage.Mainmessage, "Unable to add item, each bulk price needs a quantity and a cost such as 1.20")
+				sellerMessage.addCatalogue()
+				config.TPL.ExecuteTemplate(w, "sellertemplate.gohtml", sellerMessage)
 				return
 			}
+
+			if !readDates(req, &item) {
+				sellerMessage.Mainmessage = append(sellerMessage.Mainmessage, "Unable to add item, collected on date must not be in the future, and best before date must be after it")
+				sellerMessage.addCatalogue()
+				config.TPL.ExecuteTemplate(w, "sellertemplate.gohtml", sellerMessage)
+				return
+			}
+
+			photoname, err := uploadedPhoto(req)
+			if err != nil {
+				sellerMessage.Mainmessage = append(sellerMessage.Mainmessage, formError("add", err))
+			} else {
+				item.Photo = photoname
+				_, ok := apiclient.AddLot(item.Username, item)
+				if !ok {
+					photo.Delete(photoname)
+					sellerMessage.Mainmessage = append(sellerMessage.Mainmessage, "Unable to add item, please try again!")
+				} else {
+					http.Redirect(w, req, "/seller", http.StatusSeeOther)
+					return
+				}
+			}
 		}
 	}
+	sellerMessage.addCatalogue()
 	config.TPL.ExecuteTemplate(w, "sellertemplate.gohtml", sellerMessage)
 }
 
 //---------------------------------------------------------------------------
 // Functions to update an item added by seller
 //---------------------------------------------------------------------------
-func UpdateItemHandler(w http.ResponseWriter, req *http.Request) {
-	if !server.ActiveSession(w, req) {
-		http.Redirect(w, req, "/login", http.StatusSeeOther)
-		return
-	}
-
-	user := server.GetUser(w, req)
-
+func UpdateItemHandler(w http.ResponseWriter, req *http.Request, user server.UserInfo) {
 	sellerMessage := sellerStruct{
 		Sellername:  user.Username,
+		IsBuyer:     user.IsBuyer(),
 		Operation:   "update",
 		Mainmessage: nil,
 		Selleritems: nil,
 	}
 
 	if req.Method == http.MethodPost {
+		if err := parseItemForm(w, req); err != nil {
+			config.Warning.Printf("Unable to read item form : %v\n", err)
+			sellerMessage.Mainmessage = append(sellerMessage.Mainmessage, formError("update", err))
+			sellerMessage.addLots()
+			config.TPL.ExecuteTemplate(w, "sellertemplate.gohtml", sellerMessage)
+			return
+		}
 
-		var item apiclient.ItemsDetails
-		fruitname := req.FormValue("fruit")
+		lotid := req.FormValue("lot")
 		quantity := req.FormValue("quantity")
 		cost := req.FormValue("cost")
 
-		if fruitname != "" {
-			item.Item = fruitname
-			item.Quantity = config.ConvertToInt(quantity)
-			item.Cost = config.ConvertToFloat(cost)
-			item.Username = user.Username
-
-			ok := apiclient.UpdateItem(item.Item, item.Username, user.IsBuyer, item)
+		if lotid != "" {
+			// Keep the peel type, current photo and dates of the lot unless new ones are given
+			item, ok := apiclient.GetLot(convertToID(lotid), user.Username, false)
 			if !ok {
 				sellerMessage.Mainmessage = append(sellerMessage.Mainmessage, "Unable to update item, Item does not exist!")
-			} else {
-				http.Redirect(w, req, "/seller", http.StatusSeeOther)
+				sellerMessage.addLots()
+				config.TPL.ExecuteTemplate(w, "sellertemplate.gohtml", sellerMessage)
+				return
+			}
+			item.Quantity = config.ConvertToFloat(quantity)
+			price, err := money.Parse(cost, money.DefaultCurrency)
+			if err != nil || price.IsNegative() {
+				sellerMessage.Mainmessage = append(sellerMessage.Mainmessage, "Unable to update item, please enter a cost such as 1.20")
+				sellerMessage.addLots()
+				config.TPL.ExecuteTemplate(w, "sellertemplate.gohtml", sellerMessage)
+				return
+			}
+			item.Cost = price
+			oldphoto := item.Photo
+
+			if !readOrderSizes(req, &item) {
+				sellerMessage.Mainmessage = append(sellerMessage.Mainmessage, "Unable to update item, please choose a unit, and a minimum order and step above 0")
+				sellerMessage.addLots()
+				config.TPL.ExecuteTemplate(w, "sellertemplate.gohtml", sellerMessage)
+				return
+			}
+
+			if !readTiers(req, &item) {
+				sellerMessage.Mainmessage = append(sellerMessage.Mainmessage, "Unable to update item, each bulk price needs a quantity and a cost such as 1.20")
+				sellerMessage.addLots()
+				config.TPL.ExecuteTemplate(w, "sellertemplate.gohtml", sellerMessage)
+				return
+			}
+
+			if !readDates(req, &item) {
+				sellerMessage.Mainmessage = append(sellerMessage.Mainmessage, "Unable to update item, collected on date must not be in the future, and best before date must be after it")
+				sellerMessage.addLots()
+				config.TPL.ExecuteTemplate(w, "sellertemplate.gohtml", sellerMessage)
 				return
 			}
+
+			photoname, err := uploadedPhoto(req)
+			if err != nil {
+				sellerMessage.Mainmessage = append(sellerMessage.Mainmessage, formError("update", err))
+			} else {
+				if photoname != "" {
+					item.Photo = photoname
+				}
+				ok := apiclient.UpdateLot(item.ID, item.Username, false, item)
+				if !ok {
+					photo.Delete(photoname)
+					sellerMessage.Mainmessage = append(sellerMessage.Mainmessage, "Unable to update item, please try again!")
+				} else {
+					if photoname != "" && oldphoto != "" {
+						photo.Delete(oldphoto)
+					}
+					http.Redirect(w, req, "/seller", http.StatusSeeOther)
+					return
+				}
+			}
 		}
 	}
+	sellerMessage.addLots()
 	config.TPL.ExecuteTemplate(w, "sellertemplate.gohtml", sellerMessage)
 }
 
 //---------------------------------------------------------------------------
 // Functions to delete an item added by seller
 //---------------------------------------------------------------------------
-func DeleteItemHandler(w http.ResponseWriter, req *http.Request) {
-	if !server.ActiveSession(w, req) {
-		http.Redirect(w, req, "/login", http.StatusSeeOther)
-		return
-	}
-
-	user := server.GetUser(w, req)
-
+func DeleteItemHandler(w http.ResponseWriter, req *http.Request, user server.UserInfo) {
 	sellerMessage := sellerStruct{
 		Sellername:  user.Username,
+		IsBuyer:     user.IsBuyer(),
 		Operation:   "delete",
 		Mainmessage: nil,
 		Selleritems: nil,
@@ -160,35 +290,378 @@ func DeleteItemHandler(w http.ResponseWriter, req *http.Request) {
 
 	if req.Method == http.MethodPost {
 
-		var item apiclient.ItemsDetails
-		fruitname := req.FormValue("fruit")
-
-		if fruitname != "" {
-			item.Item = fruitname
-			item.Username = user.Username
+		lotid := req.FormValue("lot")
 
-			ok := apiclient.DeleteItem(item.Item, item.Username, user.IsBuyer)
+		if lotid != "" {
+			item, ok := apiclient.GetLot(convertToID(lotid), user.Username, false)
+			if ok {
+				ok = apiclient.DeleteLot(item.ID, item.Username, false)
+			}
 			if !ok {
 				sellerMessage.Mainmessage = append(sellerMessage.Mainmessage, "Unable to delete item, Item does not exist!")
 			} else {
+				photo.Delete(item.Photo)
 				http.Redirect(w, req, "/seller", http.StatusSeeOther)
 				return
 			}
 		}
 	}
+	sellerMessage.addLots()
+	config.TPL.ExecuteTemplate(w, "sellertemplate.gohtml", sellerMessage)
+}
+
+// This method adds the lots of the seller to the data sent to template, used to choose the lot to update or delete
+func (s *sellerStruct) addLots() {
+	lots, ok := apiclient.GetItem("", s.Sellername, false)
+	if !ok {
+		config.Error.Printf("Unable to get item data for seller %s \n", s.Sellername)
+	}
+	s.Selleritems = lots
+	s.Units = apiclient.Units
+	s.TierRows = tierRows()
+}
+
+// This method converts the lot id sent by the template, 0 is returned if it is not a number
+func convertToID(lotid string) int64 {
+	id, _ := strconv.ParseInt(lotid, 10, 64)
+	return id
+}
+
+// This method reads the item form, which may have a photo, limiting its size to the largest photo and some more
+// for the other fields. The whole form is read here, so a form that is too large gives one error for the photo
+// instead of the other fields being silently left out.
+// It returns photo.ErrTooLarge when the form is too large, or any other error from reading it.
+func parseItemForm(w http.ResponseWriter, req *http.Request) error {
+	req.Body = http.MaxBytesReader(w, req.Body, config.MaxPhotoSize+1<<20)
+	err := req.ParseMultipartForm(config.MaxPhotoSize)
+	if err == http.ErrNotMultipart {
+		// Forms without a file input are sent url encoded, and have been read already
+		return nil
+	}
+	if err != nil && strings.Contains(err.Error(), "request body too large") {
+		return photo.ErrTooLarge
+	}
+	return err
+}
+
+// This method returns the message shown when the item form or its photo cannot be read, for the operation add or update
+// Only the reasons a photo is rejected are shown to the seller.
+func formError(operation string, err error) string {
+	if err == photo.ErrTooLarge || err == photo.ErrNotImage {
+		return "Unable to " + operation + " item, " + err.Error()
+	}
+	return "Unable to " + operation + " item, please try again!"
+}
+
+// This method saves the photo uploaded with the item form, if any
+// It returns the name of the stored photo, empty if no photo was uploaded
+func uploadedPhoto(req *http.Request) (string, error) {
+	file, _, err := req.FormFile("photo")
+	if err == http.ErrMissingFile {
+		return "", nil
+	}
+	if err != nil {
+		config.Warning.Printf("Unable to read uploaded photo : %v\n", err)
+		return "", err
+	}
+	defer file.Close()
+	return photo.SaveUpload(file)
+}
+
+// This method reads the unit, minimum order, order step and low stock warning from the item form
+// Values left empty keep the value already in item, the seller API fills in defaults for a new item
+// It returns false if the unit is unknown or the quantities are not valid
+func readOrderSizes(req *http.Request, item *apiclient.ItemsDetails) bool {
+	if unit := req.FormValue("unit"); unit != "" {
+		item.Unit = unit
+	}
+	if minorder := req.FormValue("minorder"); minorder != "" {
+		item.MinOrder = apiclient.RoundQuantity(config.ConvertToFloat(minorder))
+		if item.MinOrder <= 0 {
+			return false
+		}
+	}
+	if step := req.FormValue("step"); step != "" {
+		item.Step = apiclient.RoundQuantity(config.ConvertToFloat(step))
+		if item.Step <= 0 {
+			return false
+		}
+	}
+	if lowstock := req.FormValue("lowstock"); lowstock != "" {
+		item.LowStock = apiclient.RoundQuantity(config.ConvertToFloat(lowstock))
+		if item.LowStock < 0 {
+			return false
+		}
+	}
+	item.Quantity = apiclient.RoundQuantity(item.Quantity)
+	if item.Quantity < 0 {
+		return false
+	}
+	for _, unit := range apiclient.Units {
+		if item.UnitLabel() == unit {
+			return true
+		}
+	}
+	return false
+}
+
+// This method returns the numbers of the bulk price rows shown in the item forms
+func tierRows() []int {
+	var rows []int
+	for n := 1; n <= maxTiers; n++ {
+		rows = append(rows, n)
+	}
+	return rows
+}
+
+// This method reads the bulk prices from the item form, rows left empty are skipped
+// If no rows are filled in, the bulk prices already in item are kept unless cleartiers is ticked
+// It returns false if a row has only a quantity or only a cost, or either is not valid
+func readTiers(req *http.Request, item *apiclient.ItemsDetails) bool {
+	var tiers []apiclient.PriceTier
+	for n := 1; n <= maxTiers; n++ {
+		minquantity := req.FormValue("tiermin" + strconv.Itoa(n))
+		cost := req.FormValue("tiercost" + strconv.Itoa(n))
+		if minquantity == "" && cost == "" {
+			continue
+		}
+		price, err := money.Parse(cost, item.Cost.Currency)
+		tier := apiclient.PriceTier{
+			MinQuantity: apiclient.RoundQuantity(config.ConvertToFloat(minquantity)),
+			Cost:        price,
+		}
+		if err != nil || price.IsNegative() || tier.MinQuantity <= 0 {
+			return false
+		}
+		tiers = append(tiers, tier)
+	}
+	if len(tiers) > 0 || req.FormValue("cleartiers") != "" {
+		item.Tiers = tiers
+	}
+	return true
+}
+
+// This method reads the collected on and best before dates from the item form
+// Dates left empty keep the value already in item, the seller API fills in defaults for a new item
+// The item can be bought until the end of its best before date
+// It returns false if a date is invalid, the collected on date is in the future, or the best before date is not after it
+func readDates(req *http.Request, item *apiclient.ItemsDetails) bool {
+	if collectedon := req.FormValue("collectedon"); collectedon != "" {
+		date, err := time.ParseInLocation(dateFormat, collectedon, time.Local)
+		if err != nil {
+			return false
+		}
+		// Peel cannot be collected later than today
+		if date.After(time.Now()) {
+			return false
+		}
+		item.CollectedOn = date
+	}
+	if expiresat := req.FormValue("expiresat"); expiresat != "" {
+		date, err := time.ParseInLocation(dateFormat, expiresat, time.Local)
+		if err != nil {
+			return false
+		}
+		item.ExpiresAt = date.AddDate(0, 0, 1).Add(-time.Second)
+	}
+	if !item.CollectedOn.IsZero() && !item.ExpiresAt.IsZero() {
+		return item.ExpiresAt.After(item.CollectedOn)
+	}
+	return true
+}
+
+//---------------------------------------------------------------------------
+// Functions to manage promo codes of seller
+//---------------------------------------------------------------------------
+// This method is used to list, add and delete the promo codes of the seller
+// A seller's code only takes money off the seller's own listings
+func CouponsHandler(w http.ResponseWriter, req *http.Request, user server.UserInfo) {
+	sellerMessage := sellerStruct{
+		Sellername: user.Username,
+		IsBuyer:    user.IsBuyer(),
+		Operation:  "coupons",
+	}
+
+	if req.Method == http.MethodPost {
+		action := req.FormValue("action")
+		if action == "add" {
+			c, msg := coupon.ReadForm(req, user.Username)
+			if msg != "" {
+				sellerMessage.Mainmessage = append(sellerMessage.Mainmessage, msg)
+			} else if !coupon.InsertCoupon(config.DB, c) {
+				sellerMessage.Mainmessage = append(sellerMessage.Mainmessage, "Unable to add promo code, it may already exist!")
+			} else {
+				http.Redirect(w, req, "/seller/coupons", http.StatusSeeOther)
+				return
+			}
+		} else if action == "delete" {
+			if !coupon.DeleteCoupon(config.DB, coupon.Normalize(req.FormValue("code")), user.Username, false) {
+				sellerMessage.Mainmessage = append(sellerMessage.Mainmessage, "Unable to delete promo code, it does not exist!")
+			} else {
+				http.Redirect(w, req, "/seller/coupons", http.StatusSeeOther)
+				return
+			}
+		}
+	}
+
+	coupons, ok := coupon.GetCoupons(config.DB, user.Username, false)
+	if !ok {
+		config.Error.Printf("Unable to get promo codes for seller %s \n", user.Username)
+		sellerMessage.Mainmessage = append(sellerMessage.Mainmessage, "Unable to get promo codes")
+	}
+	sellerMessage.Coupons = coupons
 	config.TPL.ExecuteTemplate(w, "sellertemplate.gohtml", sellerMessage)
 }
 
 //---------------------------------------------------------------------------
-// Functions to display profile of seller
+// Functions to manage webhooks of seller
 //---------------------------------------------------------------------------
-func ShowProfile(w http.ResponseWriter, req *http.Request) {
-	if !server.ActiveSession(w, req) {
-		http.Redirect(w, req, "/login", http.StatusSeeOther)
-		return
+// This method is used to add, test and delete the webhooks of the seller, and to view the log of their deliveries
+// Deliveries given up or already delivered can be sent again.
+func WebhooksHandler(w http.ResponseWriter, req *http.Request, user server.UserInfo) {
+	sellerMessage := sellerStruct{
+		Sellername: user.Username,
+		IsBuyer:    user.IsBuyer(),
+		Operation:  "webhooks",
+		Events:     webhook.Events,
 	}
 
-	user := server.GetUser(w, req)
+	endpoints, ok := webhook.GetEndpoints(config.DB, user.Username)
+	if !ok {
+		config.Error.Printf("Unable to get webhooks for seller %s \n", user.Username)
+		sellerMessage.Mainmessage = append(sellerMessage.Mainmessage, "Unable to get webhooks")
+	}
+
+	if req.Method == http.MethodPost {
+		id := convertToID(req.FormValue("id"))
+		switch req.FormValue("action") {
+		case "add":
+			e, msg := webhook.ReadForm(req, user.Username)
+			if msg != "" {
+				sellerMessage.Mainmessage = append(sellerMessage.Mainmessage, msg)
+			} else if !webhook.InsertEndpoint(config.DB, e) {
+				sellerMessage.Mainmessage = append(sellerMessage.Mainmessage, fmt.Sprintf("Unable to add webhook, you can have at most %d!", webhook.MaxEndpoints))
+			} else {
+				http.Redirect(w, req, "/seller/webhooks", http.StatusSeeOther)
+				return
+			}
+		case "delete":
+			if !webhook.DeleteEndpoint(config.DB, id, user.Username) {
+				sellerMessage.Mainmessage = append(sellerMessage.Mainmessage, "Unable to delete webhook, it does not exist!")
+			} else {
+				http.Redirect(w, req, "/seller/webhooks", http.StatusSeeOther)
+				return
+			}
+		case "test":
+			sent := false
+			for _, e := range endpoints {
+				if e.ID == id {
+					sent = webhook.Ping(config.DB, e)
+				}
+			}
+			if !sent {
+				sellerMessage.Mainmessage = append(sellerMessage.Mainmessage, "Unable to send a test event to the webhook")
+			} else {
+				http.Redirect(w, req, "/seller/webhooks", http.StatusSeeOther)
+				return
+			}
+		case "redeliver":
+			if !webhook.Redeliver(config.DB, id, user.Username, time.Now()) {
+				sellerMessage.Mainmessage = append(sellerMessage.Mainmessage, "Unable to send the event again, it may still be waiting to be sent!")
+			} else {
+				http.Redirect(w, req, "/seller/webhooks", http.StatusSeeOther)
+				return
+			}
+		}
+	}
+
+	deliveries, ok := webhook.GetDeliveries(config.DB, user.Username, deliveryLogSize)
+	if !ok {
+		config.Error.Printf("Unable to get webhook deliveries for seller %s \n", user.Username)
+		sellerMessage.Mainmessage = append(sellerMessage.Mainmessage, "Unable to get the log of webhook deliveries")
+	}
+	sellerMessage.Webhooks = endpoints
+	sellerMessage.Deliveries = deliveries
+	config.TPL.ExecuteTemplate(w, "sellertemplate.gohtml", sellerMessage)
+}
+
+//---------------------------------------------------------------------------
+// Functions to display earnings of seller
+//---------------------------------------------------------------------------
+// This method is used to view the orders of the seller and the balance of the seller's account with the platform
+// The seller marks an order as collected once the buyer has picked it up and paid
+func EarningsHandler(w http.ResponseWriter, req *http.Request, user server.UserInfo) {
+	sellerMessage := sellerStruct{
+		Sellername: user.Username,
+		IsBuyer:    user.IsBuyer(),
+		Operation:  "earnings",
+		Commission: orders.CommissionLabel(),
+	}
+
+	if req.Method == http.MethodPost && req.FormValue("action") == "collected" {
+		id := convertToID(req.FormValue("order"))
+		if !orders.MarkCollected(config.DB, id, user.Username, time.Now()) {
+			sellerMessage.Mainmessage = append(sellerMessage.Mainmessage, "Unable to mark order as collected, it may already be collected!")
+		} else {
+			if o, ok := orders.GetOrder(config.DB, id); ok {
+				notify.OrderCollected(o)
+			}
+			http.Redirect(w, req, "/seller/earnings", http.StatusSeeOther)
+			return
+		}
+	} else if req.Method == http.MethodPost && req.FormValue("action") == "cancel" {
+		// The buyer is told the reason, the items are put back on sale and any payment is refunded
+		reason := strings.TrimSpace(req.FormValue("reason"))
+		if reason == "" {
+			sellerMessage.Mainmessage = append(sellerMessage.Mainmessage, "Please give the buyer a reason for cancelling the order")
+		} else if o, ok := orders.Cancel(config.DB, convertToID(req.FormValue("order")), user.Username, true, reason, time.Now()); !ok {
+			sellerMessage.Mainmessage = append(sellerMessage.Mainmessage, "Unable to cancel order, it may already be collected!")
+		} else {
+			missing := orders.Restock(o)
+			refunded := o.PaymentID != 0 && payment.RefundPayment(o.PaymentID, o.Due()) == nil
+			email.SendCancellation(o, missing, refunded)
+			notify.OrderCancelled(o)
+			webhook.OrderCancelled(config.DB, o)
+			http.Redirect(w, req, "/seller/earnings", http.StatusSeeOther)
+			return
+		}
+	}
+
+	sellerorders, ok := orders.GetOrders(config.DB, user.Username, true)
+	if !ok {
+		config.Error.Printf("Unable to get orders for seller %s \n", user.Username)
+		sellerMessage.Mainmessage = append(sellerMessage.Mainmessage, "Unable to get orders")
+	}
+	payouts, ok := ledger.SellerPayouts(config.DB, user.Username)
+	if !ok {
+		config.Error.Printf("Unable to get balance for seller %s \n", user.Username)
+		sellerMessage.Mainmessage = append(sellerMessage.Mainmessage, "Unable to get balance")
+	}
+	sellerMessage.Orders = sellerorders
+	sellerMessage.Payouts = payouts
+	config.TPL.ExecuteTemplate(w, "sellertemplate.gohtml", sellerMessage)
+}
+
+//---------------------------------------------------------------------------
+// Functions to display profile of seller
+//---------------------------------------------------------------------------
+func ShowProfile(w http.ResponseWriter, req *http.Request, user server.UserInfo) {
+	// A seller registered for GST must give their GST registration number, it is printed on their tax invoices
+	gstmessage := ""
+	if req.Method == http.MethodPost && req.FormValue("action") == "gst" {
+		registered := req.FormValue("gstregistered") != ""
+		number, valid := tax.ParseGSTNumber(req.FormValue("gstnumber"))
+		switch {
+		case registered && !valid:
+			gstmessage = "Please enter a valid GST registration number, such as M90312345X or 200312345A"
+		case !user_db.UpdateGSTRegistered(config.DB, user.Username, registered, number):
+			config.Error.Printf("Unable to update GST registration of seller %s \n", user.Username)
+			gstmessage = "Unable to save your GST registration, please try again!"
+		default:
+			http.Redirect(w, req, "/seller/profile", http.StatusSeeOther)
+			return
+		}
+	}
 
 	// Seller details :
 	sellerdetails, _ := user_db.GetARecord(config.DB, user.Username)
@@ -199,6 +672,7 @@ func ShowProfile(w http.ResponseWriter, req *http.Request) {
 
 	sellerMessage := sellerStruct{
 		Sellername:  user.Username,
+		IsBuyer:     user.IsBuyer(),
 		Operation:   "profile",
 		Mainmessage: nil,
 		Selleritems: nil,
@@ -209,6 +683,14 @@ func ShowProfile(w http.ResponseWriter, req *http.Request) {
 	sellerMessage.Mainmessage = append(sellerMessage.Mainmessage, "Address :"+selleraddress)
 	sellerMessage.Mainmessage = append(sellerMessage.Mainmessage, "Email :"+selleremail)
 	sellerMessage.Mainmessage = append(sellerMessage.Mainmessage, "Phone :"+sellerphone)
+	sellerMessage.GSTRegistered = sellerdetails.GSTRegistered
+	sellerMessage.GSTNumber = sellerdetails.GSTNumber
+	sellerMessage.GSTRate = tax.Current.RateLabel(sellerdetails.GSTRegistered)
+	if gstmessage != "" {
+		sellerMessage.Mainmessage = append(sellerMessage.Mainmessage, gstmessage)
+		sellerMessage.GSTRegistered = req.FormValue("gstregistered") != ""
+		sellerMessage.GSTNumber = req.FormValue("gstnumber")
+	}
 
 	config.TPL.ExecuteTemplate(w, "sellertemplate.gohtml", sellerMessage)
 }
@@ -6,11 +6,13 @@ import (
 	"fmt"
 	"net/http"
 	"regexp"
-	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	apiclient "projectGoLive/application/apiclient"
 	config "projectGoLive/application/config"
+	location "projectGoLive/application/location"
 	user_db "projectGoLive/application/user_db"
 
 	"github.com/go-playground/validator"
@@ -18,10 +20,30 @@ import (
 	bcrypt "golang.org/x/crypto/bcrypt"
 )
 
-// This struct stores username(all) and type of user(buyer/seller). It is used to maintain a list of all users currently registered.
+// This struct stores username(all) and roles of user(buyer and/or seller). It is used to maintain a list of all users currently registered.
 type UserInfo struct {
 	Username string
-	IsBuyer  bool
+	Roles    []string
+}
+
+// This method checks if the user has the given role
+func (u UserInfo) HasRole(role string) bool {
+	for _, r := range u.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// This method checks if the user can access the buyer pages
+func (u UserInfo) IsBuyer() bool {
+	return u.HasRole(user_db.RoleBuyer)
+}
+
+// This method checks if the user can access the seller pages
+func (u UserInfo) IsSeller() bool {
+	return u.HasRole(user_db.RoleSeller)
 }
 
 // This struct stores username and lastActivity time for current users that have logged in, and is used to maintain sessions
@@ -36,6 +58,9 @@ var mapSessions map[string]Session
 // Create a map to store user a list of all users that have already registered
 var mapUsers map[string]UserInfo
 
+// Lock for mapUsers, which is read by handlers and changed by the purge of deleted accounts running in the background
+var usersMu sync.RWMutex
+
 // Variable to store the time when sessions are cleaned
 var mapSessionCleaned time.Time
 
@@ -69,12 +94,11 @@ type signupErrorMessage struct {
 func init() {
 	mapUsers = make(map[string]UserInfo)
 	mapSessions = make(map[string]Session)
-
-	// Read user_db and populate mapUsers (otherwise buyers/seller in database cannot login)
-	initializeMapUsers()
 }
 
-func initializeMapUsers() {
+// This method reads user_db and populates mapUsers (otherwise buyers/seller in database cannot login)
+// It is called when the application starts, and panics if user_db cannot be read
+func LoadUsers() {
 	userDetails, ok := user_db.GetRecords(config.DB)
 	if !ok {
 		panic("Cannot read user_db")
@@ -82,11 +106,10 @@ func initializeMapUsers() {
 		var myUserInfo UserInfo
 		for _, ui := range userDetails {
 			myUserInfo.Username = ui.Username
-			myUserInfo.IsBuyer = ui.Isbuyer
-			mapUsers[ui.Username] = myUserInfo
+			myUserInfo.Roles = ui.Roles
+			storeUser(myUserInfo)
 		}
 	}
-
 }
 
 // ---------------------------------------------------------------------------
@@ -99,23 +122,26 @@ func initializeMapUsers() {
 func IndexHandler(w http.ResponseWriter, req *http.Request) {
 	myUser := GetUser(w, req)
 	if ok := alreadyLoggedIn(req); ok {
-		if myUser.Username == "admin" {
-			//redirecting to admin page
-			http.Redirect(w, req, "/admin", http.StatusSeeOther)
-			return
-		} else if myUser.IsBuyer {
-			//redirecting to buyer page
-			http.Redirect(w, req, "/buyer", http.StatusSeeOther)
-			return
-		} else {
-			//redirecting to seller page
-			http.Redirect(w, req, "/seller", http.StatusSeeOther)
-			return
-		}
+		http.Redirect(w, req, landingPage(req, myUser), http.StatusSeeOther)
+		return
 	}
 	config.TPL.ExecuteTemplate(w, "index.gohtml", myUser)
 }
 
+// This method is used to let a user who is both buyer and seller choose which pages to go to
+func ChooseRoleHandler(w http.ResponseWriter, req *http.Request) {
+	if !ActiveSession(w, req) {
+		http.Redirect(w, req, "/login", http.StatusSeeOther)
+		return
+	}
+	myUser := GetUser(w, req)
+	if !myUser.IsBuyer() || !myUser.IsSeller() {
+		http.Redirect(w, req, landingPage(req, myUser), http.StatusSeeOther)
+		return
+	}
+	config.TPL.ExecuteTemplate(w, "chooser.gohtml", myUser)
+}
+
 // This method is used to signup a new user
 // It checks if user has already logged in, and if so redirects to index page.
 // If not logged in, the signup form is displayed.
@@ -126,19 +152,8 @@ func SignupHandler(w http.ResponseWriter, req *http.Request) {
 	// Go to landing page of every user, if user has already logged in
 	myUser := GetUser(w, req)
 	if ok := alreadyLoggedIn(req); ok {
-		if myUser.Username == "admin" {
-			//redirecting to admin page
-			http.Redirect(w, req, "/admin", http.StatusSeeOther)
-			return
-		} else if myUser.IsBuyer {
-			//redirecting to buyer page
-			http.Redirect(w, req, "/buyer", http.StatusSeeOther)
-			return
-		} else {
-			//redirecting to seller page
-			http.Redirect(w, req, "/seller", http.StatusSeeOther)
-			return
-		}
+		http.Redirect(w, req, landingPage(req, myUser), http.StatusSeeOther)
+		return
 	}
 
 	var user UserInfo
@@ -156,11 +171,11 @@ func SignupHandler(w http.ResponseWriter, req *http.Request) {
 
 		username := req.FormValue("username")
 		password := req.FormValue("password")
-		isbuyer, _ := strconv.ParseBool(req.FormValue("isbuyer"))
 		address := req.FormValue("address")
 		fullname := req.FormValue("fullname")
 		phone := req.FormValue("phone")
 		email := req.FormValue("email")
+		roles, rolesok := signupRoles(req.Form["roles"])
 
 		if reset != "" {
 			//reload page
@@ -182,6 +197,8 @@ func SignupHandler(w http.ResponseWriter, req *http.Request) {
 				verr := signupValidation(si)
 				if verr != nil { // error
 					sErrMsg.Mainmessage = "Invalid Username/Password"
+				} else if !rolesok {
+					sErrMsg.Mainmessage = "Please sign up as a buyer, a seller or both"
 				} else {
 					// Sanitize input data
 					snInput, serr := signupSanitization(si)
@@ -190,8 +207,12 @@ func SignupHandler(w http.ResponseWriter, req *http.Request) {
 						config.Error.Println("Sanitization error")
 						sErrMsg.Mainmessage = "Invalid Username/Password"
 					} else {
-						// Check if username is already taken
-						if _, ok := mapUsers[snInput.Username]; ok {
+						// Check if username is already taken, or belonged to a purged account whose orders are still kept under it
+						purged, purgedok := user_db.IsPurged(config.DB, snInput.Username)
+						if !purgedok {
+							sErrMsg.Mainmessage = "Unable to reach database, try again!"
+							return
+						} else if _, ok := lookupUser(snInput.Username); ok || purged {
 							// Invalid username or password message to template
 							sErrMsg.Mainmessage = "Invalid Username/Password"
 							return
@@ -209,7 +230,7 @@ func SignupHandler(w http.ResponseWriter, req *http.Request) {
 							// use sanitized versions of entries
 							newUserdb.Username = snInput.Username
 							newUserdb.Password = string(pwBytes)
-							newUserdb.Isbuyer = isbuyer
+							newUserdb.Roles = roles
 							newUserdb.Fullname = snInput.Fullname
 							newUserdb.Address = snInput.Address
 							newUserdb.Phone = snInput.Phone
@@ -224,13 +245,18 @@ func SignupHandler(w http.ResponseWriter, req *http.Request) {
 								return
 							}
 
+							// The location is used to show buyers how far away sellers are, addresses without a postal code have none
+							if _, ok := location.SaveUser(config.DB, newUserdb.Username, newUserdb.Address); !ok {
+								config.Warning.Printf("No location for the address of user %s\n", newUserdb.Username)
+							}
+
 							// This is a local record of all users that have registered- buyers as well as sellers
 							// Password is not stored here for security reasons
 							user.Username = snInput.Username
-							user.IsBuyer = isbuyer
+							user.Roles = roles
 
 							// Store user info in map for users
-							mapUsers[snInput.Username] = user
+							storeUser(user)
 							// Successfully registered, now redirect to login page
 							http.Redirect(w, req, "/login", http.StatusSeeOther)
 							return
@@ -258,7 +284,6 @@ func LoginHandler(w http.ResponseWriter, req *http.Request) {
 	}
 
 	lInput := loginInput{}
-	var isbuyer bool
 	password_db := ""
 
 	// Login message to template
@@ -319,7 +344,7 @@ func LoginHandler(w http.ResponseWriter, req *http.Request) {
 						}
 					} else {
 						// check if user exists with given username
-						if _, ok := mapUsers[lInput.Username]; !ok { // username is not registered
+						if registered, ok := lookupUser(lInput.Username); !ok { // username is not registered
 							loginmessage = append(loginmessage, "Username and/or password do not match")
 						} else {
 							// Valid username, now check password from database
@@ -341,6 +366,24 @@ func LoginHandler(w http.ResponseWriter, req *http.Request) {
 										config.Warning.Printf("Unable to generate UUID : %v\n", err)
 										loginmessage = append(loginmessage, "Server Error, please try again!")
 									} else {
+										// Logging in during the grace period cancels a pending account deletion
+										if user_db.CancelDeletionRequest(config.DB, lInput.Username) {
+											config.Info.Printf("Account deletion cancelled for user %s\n", lInput.Username)
+											if registered.IsSeller() && !apiclient.HideLots(lInput.Username, false) {
+												config.Error.Printf("Unable to show the listings of user %s again\n", lInput.Username)
+											}
+										}
+
+										// The profile searched by buyers is kept up to date with the account of the seller,
+										// in the background so logging in never waits on the REST API
+										if registered.IsSeller() {
+											go func(username, fullname, address string) {
+												if !apiclient.SaveSellerProfile(username, fullname, address) {
+													config.Warning.Printf("Unable to save the profile of seller %s\n", username)
+												}
+											}(details.Username, details.Fullname, details.Address)
+										}
+
 										myCookie := &http.Cookie{
 											Name:     "PeelRescue",
 											Value:    id.String(),
@@ -351,15 +394,9 @@ func LoginHandler(w http.ResponseWriter, req *http.Request) {
 										http.SetCookie(w, myCookie)
 
 										mapSessions[myCookie.Value] = Session{lInput.Username, time.Now()}
-										// Check if user is buyer or seller
-										isbuyer = mapUsers[lInput.Username].IsBuyer
-										if isbuyer {
-											http.Redirect(w, req, "/buyer", http.StatusSeeOther)
-											return
-										} else {
-											http.Redirect(w, req, "/seller", http.StatusSeeOther)
-											return
-										}
+										// Go to buyer or seller pages, or let the user choose if both
+										http.Redirect(w, req, landingPage(req, registered), http.StatusSeeOther)
+										return
 									}
 								}
 							}
@@ -407,9 +444,11 @@ func LogoutHandler(w http.ResponseWriter, req *http.Request) {
 //------------------------------------------------------------------------------
 // Clearn up session before redirecting to index page
 func deleteSession(w http.ResponseWriter, req *http.Request) {
-	myCookie, _ := req.Cookie("PeelRescue")
-	// delete the session
-	delete(mapSessions, myCookie.Value)
+	myCookie, err := req.Cookie("PeelRescue")
+	// delete the session, visitors who never logged in have no cookie
+	if err == nil {
+		delete(mapSessions, myCookie.Value)
+	}
 	// remove the cookie
 	myCookie = &http.Cookie{
 		Name:     "PeelRescue",
@@ -455,14 +494,125 @@ func GetUser(w http.ResponseWriter, req *http.Request) UserInfo {
 
 	// get username with mapsession and UUID of user's cookie
 	username := mapSessions[myCookie.Value].Username
-	user = mapUsers[username]
+	user, _ = lookupUser(username)
 	return user
 }
 
+// This method is used to end every session of a user, and remove the cookie of the current request
+// It is used when the user asks for their account to be deleted
+func EndUserSessions(w http.ResponseWriter, username string) {
+	for key, value := range mapSessions {
+		if value.Username == username {
+			delete(mapSessions, key)
+		}
+	}
+	myCookie := &http.Cookie{
+		Name:     "PeelRescue",
+		Value:    "",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   true,
+	}
+	http.SetCookie(w, myCookie)
+}
+
+// This method is used to check if the user of the current session is the admin
+func IsAdmin(req *http.Request) bool {
+	myCookie, err := req.Cookie("PeelRescue")
+	if err != nil {
+		return false
+	}
+	return config.AdminName != "" && mapSessions[myCookie.Value].Username == config.AdminName
+}
+
+// This method is used to remove a user from the list of registered users once the account is deleted
+func RemoveUser(username string) {
+	usersMu.Lock()
+	defer usersMu.Unlock()
+	delete(mapUsers, username)
+}
+
+// This method is used to update the roles of a registered user, after they are updated in the database
+func SetUserRoles(username string, roles []string) {
+	usersMu.Lock()
+	defer usersMu.Unlock()
+	user := mapUsers[username]
+	user.Roles = roles
+	mapUsers[username] = user
+}
+
+// This method is used to get a registered user by username
+// It returns false if no user is registered with the username
+func lookupUser(username string) (UserInfo, bool) {
+	usersMu.RLock()
+	defer usersMu.RUnlock()
+	user, ok := mapUsers[username]
+	return user, ok
+}
+
+// This method is used to add a newly registered user, or replace the stored details of a user
+func storeUser(user UserInfo) {
+	usersMu.Lock()
+	defer usersMu.Unlock()
+	mapUsers[user.Username] = user
+}
+
 //------------------------------------------------------------------------------
 // Helper functions for sessions and user maintenance
 //------------------------------------------------------------------------------
 
+// This method returns the page a logged in user lands on
+// Admin goes to admin page, users with one role go to the buyer or seller page,
+// users with both roles go to a page where they can choose, and users with no role to their account page.
+func landingPage(req *http.Request, user UserInfo) string {
+	if IsAdmin(req) {
+		return "/admin"
+	}
+	if user.IsBuyer() && user.IsSeller() {
+		return "/choose"
+	} else if user.IsBuyer() {
+		return "/buyer"
+	} else if user.IsSeller() {
+		return "/seller"
+	}
+	// Users with no role, such as users whose roles were removed, choose a role on their account page
+	return "/account"
+}
+
+// This method wraps the handler of a page that only users with the given role can see
+// Users who are not logged in are sent to the login page, and users without the role to the index page,
+// which sends them on to the page they land on. The handler is given the user of the session.
+func RequireRole(role string, handler func(http.ResponseWriter, *http.Request, UserInfo)) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if !ActiveSession(w, req) {
+			http.Redirect(w, req, "/login", http.StatusSeeOther)
+			return
+		}
+		user := GetUser(w, req)
+		if !user.HasRole(role) {
+			http.Redirect(w, req, "/", http.StatusSeeOther)
+			return
+		}
+		handler(w, req, user)
+	}
+}
+
+// This method wraps the handler of a page that only the admin can see
+// Users who are not logged in are sent to the login page, and other users to the index page.
+func RequireAdmin(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if !ActiveSession(w, req) {
+			http.Redirect(w, req, "/login", http.StatusSeeOther)
+			return
+		}
+		if !IsAdmin(req) {
+			http.Redirect(w, req, "/", http.StatusSeeOther)
+			return
+		}
+		handler(w, req)
+	}
+}
+
 // This method checks if the user has already logged in
 // The user can be a buyer or a seller
 // If user has logged in, it returns true, else false
@@ -575,6 +725,22 @@ func signupValidation(si signupInput) error {
 	return err
 }
 
+// This method is used to validate the roles chosen in the signup form
+// Each role must be buyer or seller, and at least one role must be chosen
+// It returns the roles without duplicates, and false if there is any issue with the input
+func signupRoles(input []string) ([]string, bool) {
+	var roles []string
+	for _, role := range input {
+		if role != user_db.RoleBuyer && role != user_db.RoleSeller {
+			return nil, false
+		}
+		if !(UserInfo{Roles: roles}).HasRole(role) {
+			roles = append(roles, role)
+		}
+	}
+	return roles, len(roles) > 0
+}
+
 // This method is used to sanitize the signup input data
 // It checks that username begins with an alphabet and has no special characters
 // It converts username to lowercase.
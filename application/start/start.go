@@ -4,7 +4,15 @@ import (
 	"log"
 	"net/http"
 
+	"projectGoLive/application/account"
 	config "projectGoLive/application/config"
+	"projectGoLive/application/email"
+	"projectGoLive/application/invoice"
+	"projectGoLive/application/listingevents"
+	"projectGoLive/application/payment"
+	"projectGoLive/application/photo"
+	"projectGoLive/application/server"
+	"projectGoLive/application/webhook"
 
 	"github.com/gorilla/mux"
 )
@@ -14,8 +22,29 @@ var (
 )
 
 func StartApplication() {
+	// Connect to the database, open the error log and read the templates, photos and users the handlers need
+	config.Setup()
+	email.ParseTemplates()
+	mailer := email.OpenMailer()
+	photo.OpenStore()
+	server.LoadUsers()
+	payment.Setup()
+	invoice.Setup()
 
 	mapUrls()
+
+	// Purge accounts whose deletion grace period is over
+	go account.PurgeDeletedAccounts()
+
+	// Act on events about listings, such as listings that expired
+	go listingevents.WatchListingEvents()
+
+	// Send the emails waiting in the outbox, and try again the ones that failed
+	go email.DeliverOutbox(mailer)
+
+	// Post the events sellers registered webhooks for, and try again the ones that failed
+	go webhook.Dispatch()
+
 	log.Println(" Listening on port ", config.PortNum)
 	log.Fatal(http.ListenAndServeTLS(config.PortNum, config.CertPath+"cert.pem", config.CertPath+"key.pem", router))
 }
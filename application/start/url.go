@@ -2,10 +2,16 @@ package start
 
 import (
 	"net/http"
+	"projectGoLive/application/account"
 	"projectGoLive/application/admin"
 	"projectGoLive/application/buyer"
+	"projectGoLive/application/invoice"
+	"projectGoLive/application/notify"
+	"projectGoLive/application/payment"
+	"projectGoLive/application/photo"
 	"projectGoLive/application/seller"
 	"projectGoLive/application/server"
+	"projectGoLive/application/user_db"
 )
 
 func mapUrls() {
@@ -15,21 +21,47 @@ func mapUrls() {
 		Handler(http.StripPrefix(resourceDir, http.FileServer(http.Dir("."+resourceDir))))
 
 	router.Handle("/favicon.ico", http.NotFoundHandler())
+	router.HandleFunc("/photos/thumb/{name}", photo.ThumbnailHandler).Methods("GET")
+	router.HandleFunc("/photos/{name}", photo.PhotoHandler).Methods("GET")
 	router.HandleFunc("/", server.IndexHandler)
 	router.HandleFunc("/signup", server.SignupHandler)
 	router.HandleFunc("/login", server.LoginHandler)
 	router.HandleFunc("/logout", server.LogoutHandler)
-	router.HandleFunc("/buyer", buyer.BuyerHandler)
-	router.HandleFunc("/seller", seller.SellerHandler)
-	router.HandleFunc("/admin", admin.AdminHandler)
+	router.HandleFunc("/choose", server.ChooseRoleHandler)
+	router.HandleFunc("/buyer", server.RequireRole(user_db.RoleBuyer, buyer.BuyerHandler))
+	router.HandleFunc("/seller", server.RequireRole(user_db.RoleSeller, seller.SellerHandler))
+	router.HandleFunc("/admin", server.RequireAdmin(admin.AdminHandler))
+	router.HandleFunc("/admin/catalogue", server.RequireAdmin(admin.CatalogueHandler))
+	router.HandleFunc("/admin/coupons", server.RequireAdmin(admin.CouponsHandler))
+	router.HandleFunc("/admin/payouts", server.RequireAdmin(admin.PayoutsHandler))
+	router.HandleFunc("/admin/disputes", server.RequireAdmin(admin.DisputesHandler))
+	router.HandleFunc("/admin/outbox", server.RequireAdmin(admin.OutboxHandler))
+	router.HandleFunc("/admin/emails", server.RequireAdmin(admin.EmailTemplatesHandler))
 
-	router.HandleFunc("/buyer/findoneitem", buyer.LookForItemHandler)
-	router.HandleFunc("/buyer/buyercart", buyer.CartHandler)
-	router.HandleFunc("/buyer/checkoutsuccess", buyer.CheckoutSuccessHandler)
-	router.HandleFunc("/buyer/profile", buyer.ShowProfile)
+	router.HandleFunc("/buyer/findoneitem", server.RequireRole(user_db.RoleBuyer, buyer.LookForItemHandler))
+	router.HandleFunc("/buyer/searches", server.RequireRole(user_db.RoleBuyer, buyer.SearchesHandler))
+	router.HandleFunc("/buyer/search", server.RequireRole(user_db.RoleBuyer, buyer.SearchHandler))
+	router.HandleFunc("/buyer/buyercart", server.RequireRole(user_db.RoleBuyer, buyer.CartHandler))
+	router.HandleFunc("/buyer/checkoutsuccess", server.RequireRole(user_db.RoleBuyer, buyer.CheckoutSuccessHandler))
+	router.HandleFunc("/buyer/profile", server.RequireRole(user_db.RoleBuyer, buyer.ShowProfile))
+	router.HandleFunc("/buyer/orders", server.RequireRole(user_db.RoleBuyer, buyer.OrdersHandler))
+	router.HandleFunc("/invoice", invoice.DownloadHandler)
+	router.HandleFunc("/notifications", notify.NotificationsHandler)
+	router.HandleFunc("/notifications/stream", notify.StreamHandler).Methods("GET")
 
-	router.HandleFunc("/seller/additem", seller.AddItemHandler)
-	router.HandleFunc("/seller/updateitem", seller.UpdateItemHandler)
-	router.HandleFunc("/seller/deleteitem", seller.DeleteItemHandler)
-	router.HandleFunc("/seller/profile", seller.ShowProfile)
+	router.HandleFunc("/payment/webhook", payment.WebhookHandler).Methods("POST")
+
+	router.HandleFunc("/seller/additem", server.RequireRole(user_db.RoleSeller, seller.AddItemHandler))
+	router.HandleFunc("/seller/updateitem", server.RequireRole(user_db.RoleSeller, seller.UpdateItemHandler))
+	router.HandleFunc("/seller/deleteitem", server.RequireRole(user_db.RoleSeller, seller.DeleteItemHandler))
+	router.HandleFunc("/seller/coupons", server.RequireRole(user_db.RoleSeller, seller.CouponsHandler))
+	router.HandleFunc("/seller/earnings", server.RequireRole(user_db.RoleSeller, seller.EarningsHandler))
+	router.HandleFunc("/seller/webhooks", server.RequireRole(user_db.RoleSeller, seller.WebhooksHandler))
+	router.HandleFunc("/seller/profile", server.RequireRole(user_db.RoleSeller, seller.ShowProfile))
+
+	router.HandleFunc("/account", account.AccountHandler)
+	router.HandleFunc("/account/export", account.ExportHandler).Methods("POST")
+	router.HandleFunc("/account/delete", account.DeleteAccountHandler)
+	router.HandleFunc("/account/roles", account.AddRoleHandler).Methods("POST")
+	router.HandleFunc("/account/address", account.AddressHandler).Methods("POST")
 }
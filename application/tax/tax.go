@@ -0,0 +1,201 @@
+// Package tax works out the GST of an order, per cart line, per seller and for the whole order.
+// Only sellers registered for GST charge it, at the rate set for their registration status.
+// Listing prices either include GST or have it added on top, as set in the environment.
+package tax
+
+import (
+	"database/sql"
+	"log"
+	"os"
+	"projectGoLive/application/apiclient"
+	"projectGoLive/application/config"
+	"projectGoLive/application/coupon"
+	"projectGoLive/application/money"
+	"projectGoLive/application/user_db"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Rates used when they are not set in the environment, in basis points (1/100 of a percent)
+const (
+	defaultRegisteredRate   = 900
+	defaultUnregisteredRate = 0
+)
+
+// Rules for working out GST
+// Rates are in basis points, e.g. 900 is 9%. Inclusive is true when listing prices already include GST.
+type Rules struct {
+	RegisteredRate   int64
+	UnregisteredRate int64
+	Inclusive        bool
+}
+
+// GST registration numbers are the seller's UEN, e.g. 200312345A or 53123456B, or a number given by IRAS, e.g. M90312345X
+var gstNumberMatch = regexp.MustCompile(`^([0-9]{8,9}[A-Z]|[A-Z][0-9]{8}[A-Z]|[A-Z][0-9]{2}[A-Z]{2}[0-9]{4}[A-Z])$`)
+
+// GST registration of a seller, Number is empty when the seller is not registered
+type Registration struct {
+	Registered bool
+	Number     string
+}
+
+// Amounts of a line, a seller or an order before GST (Net), the GST (Tax) and with GST (Gross)
+type Amounts struct {
+	Net   money.Money
+	Tax   money.Money
+	Gross money.Money
+}
+
+// GST of an order
+// Lines are in the order of the cart items. A seller's promo code is taken off that seller's amounts before GST,
+// a platform-wide promo code is paid by the platform and is only taken off the amount payable.
+// Registrations are the GST registrations of the sellers the GST was worked out with.
+type Order struct {
+	Lines         []Amounts
+	Sellers       map[string]Amounts
+	Registrations map[string]Registration
+	Total         Amounts
+	Payable       money.Money
+}
+
+// Rules read from the environment when the application starts
+var Current Rules
+
+func init() {
+	Current = Rules{
+		RegisteredRate:   config.RateFromEnv("GST_RATE", defaultRegisteredRate),
+		UnregisteredRate: config.RateFromEnv("GST_RATE_UNREGISTERED", defaultUnregisteredRate),
+		Inclusive:        true,
+	}
+	if inclusive, ok := os.LookupEnv("GST_INCLUSIVE"); ok {
+		b, err := strconv.ParseBool(inclusive)
+		if err != nil {
+			log.Printf("GST_INCLUSIVE %q is not true or false, prices are taken to include GST\n", inclusive)
+		} else {
+			Current.Inclusive = b
+		}
+	}
+}
+
+// This method returns the rate for a seller in basis points
+func (r Rules) Rate(registered bool) int64 {
+	if registered {
+		return r.RegisteredRate
+	}
+	return r.UnregisteredRate
+}
+
+// This method returns the rate for a seller as text, e.g. "9%"
+func (r Rules) RateLabel(registered bool) string {
+	return config.RateLabel(r.Rate(registered))
+}
+
+// This method works out the GST of an amount charged by a seller
+// The GST is rounded half away from zero to the minor unit
+func (r Rules) Line(amount money.Money, registered bool) Amounts {
+	rate := r.Rate(registered)
+	if r.Inclusive {
+		tax := money.Money{Amount: divRound(amount.Amount*rate, 10000+rate), Currency: amount.Currency}
+		return Amounts{Net: money.Money{Amount: amount.Amount - tax.Amount, Currency: amount.Currency}, Tax: tax, Gross: amount}
+	}
+	tax := money.Money{Amount: divRound(amount.Amount*rate, 10000), Currency: amount.Currency}
+	return Amounts{Net: amount, Tax: tax, Gross: money.Money{Amount: amount.Amount + tax.Amount, Currency: amount.Currency}}
+}
+
+// This method returns the rate charged by a seller of the order as text, e.g. "9%"
+func (r Rules) SellerRateLabel(order Order, seller string) string {
+	return r.RateLabel(order.Registrations[seller].Registered)
+}
+
+// This method works out the GST of the items in a cart, with the promo code applied to the cart
+// registered are the GST registrations of the sellers, see RegisteredSellers
+// It returns an error if the amounts are in different currencies, or too large to be stored
+func (r Rules) Compute(items []apiclient.ItemsDetails, registered map[string]Registration, discount coupon.Applied) (Order, error) {
+	order := Order{Sellers: make(map[string]Amounts), Registrations: registered}
+	for _, item := range items {
+		total, err := item.LineTotal()
+		if err != nil {
+			return order, err
+		}
+		line := r.Line(total, registered[item.Username].Registered)
+		order.Lines = append(order.Lines, line)
+		sum, err := order.Sellers[item.Username].Add(line)
+		if err != nil {
+			return order, err
+		}
+		order.Sellers[item.Username] = sum
+	}
+	if discount.Code != "" && discount.Seller != "" {
+		line := r.Line(money.Money{Amount: -discount.Discount.Amount, Currency: discount.Discount.Currency}, registered[discount.Seller].Registered)
+		sum, err := order.Sellers[discount.Seller].Add(line)
+		if err != nil {
+			return order, err
+		}
+		order.Sellers[discount.Seller] = sum
+	}
+	for _, amounts := range order.Sellers {
+		sum, err := order.Total.Add(amounts)
+		if err != nil {
+			return order, err
+		}
+		order.Total = sum
+	}
+
+	order.Payable = order.Total.Gross
+	if discount.Code != "" && discount.Seller == "" {
+		payable, err := order.Payable.Sub(discount.Discount)
+		if err != nil {
+			return order, err
+		}
+		order.Payable = payable
+	}
+	return order, nil
+}
+
+// This method adds two sets of amounts, they must be in the same currency
+func (a Amounts) Add(b Amounts) (Amounts, error) {
+	var err error
+	if a.Net, err = a.Net.Add(b.Net); err != nil {
+		return a, err
+	}
+	if a.Tax, err = a.Tax.Add(b.Tax); err != nil {
+		return a, err
+	}
+	if a.Gross, err = a.Gross.Add(b.Gross); err != nil {
+		return a, err
+	}
+	return a, nil
+}
+
+// Function to find the GST registrations of the sellers of the items in a cart from the MYSQL database.
+// Sellers that cannot be found are taken as not registered.
+func RegisteredSellers(db *sql.DB, items []apiclient.ItemsDetails) map[string]Registration {
+	registered := make(map[string]Registration)
+	for _, item := range items {
+		if _, ok := registered[item.Username]; ok {
+			continue
+		}
+		seller, _ := user_db.GetARecord(db, item.Username)
+		registered[item.Username] = Registration{Registered: seller.GSTRegistered, Number: seller.GSTNumber}
+	}
+	return registered
+}
+
+// This function cleans up a GST registration number typed by a seller, e.g. "m9-0312345-x" becomes "M90312345X"
+// It returns false if the number is not a valid GST registration number
+func ParseGSTNumber(number string) (string, bool) {
+	number = strings.ToUpper(strings.NewReplacer(" ", "", "-", "").Replace(number))
+	return number, gstNumberMatch.MatchString(number)
+}
+
+// This function divides a by b, rounding half away from zero, b must be above 0
+func divRound(a, b int64) int64 {
+	q := a / b
+	if r := a % b; r*2 >= b {
+		q++
+	} else if r*2 <= -b {
+		q--
+	}
+	return q
+}
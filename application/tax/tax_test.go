@@ -0,0 +1,152 @@
+package tax
+
+import (
+	"projectGoLive/application/apiclient"
+	"projectGoLive/application/coupon"
+	"projectGoLive/application/money"
+	"testing"
+)
+
+func sgd(amount int64) money.Money {
+	return money.Money{Amount: amount, Currency: "SGD"}
+}
+
+func TestLine(t *testing.T) {
+	inclusive := Rules{RegisteredRate: 900, UnregisteredRate: 0, Inclusive: true}
+	exclusive := Rules{RegisteredRate: 900, UnregisteredRate: 0, Inclusive: false}
+	tests := []struct {
+		name       string
+		rules      Rules
+		amount     money.Money
+		registered bool
+		want       Amounts
+	}{
+		{"inclusive registered", inclusive, sgd(1090), true, Amounts{Net: sgd(1000), Tax: sgd(90), Gross: sgd(1090)}},
+		{"inclusive rounds half up", inclusive, sgd(6), true, Amounts{Net: sgd(6), Tax: sgd(0), Gross: sgd(6)}},
+		{"inclusive not registered", inclusive, sgd(1090), false, Amounts{Net: sgd(1090), Tax: sgd(0), Gross: sgd(1090)}},
+		{"exclusive registered", exclusive, sgd(1000), true, Amounts{Net: sgd(1000), Tax: sgd(90), Gross: sgd(1090)}},
+		{"exclusive rounds half away from zero", exclusive, sgd(50), true, Amounts{Net: sgd(50), Tax: sgd(5), Gross: sgd(55)}},
+		{"exclusive negative amount", exclusive, sgd(-50), true, Amounts{Net: sgd(-50), Tax: sgd(-5), Gross: sgd(-55)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.rules.Line(tt.amount, tt.registered); got != tt.want {
+				t.Errorf("Line(%v, %v) = %+v, want %+v", tt.amount, tt.registered, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompute(t *testing.T) {
+	rules := Rules{RegisteredRate: 900, UnregisteredRate: 0, Inclusive: false}
+	items := []apiclient.ItemsDetails{
+		{Item: "Orange Peel", Quantity: 2, Cost: sgd(500), Username: "seller1"},
+		{Item: "Lemon Peel", Quantity: 1, Cost: sgd(300), Username: "seller2"},
+		{Item: "Lime Peel", Quantity: 1, Cost: sgd(200), Username: "seller1"},
+	}
+	registered := map[string]Registration{
+		"seller1": {Registered: true, Number: "M90312345X"},
+		"seller2": {Registered: false},
+	}
+	tests := []struct {
+		name        string
+		items       []apiclient.ItemsDetails
+		discount    coupon.Applied
+		wantLines   []money.Money
+		wantSellers map[string]Amounts
+		wantPayable money.Money
+		wantErr     bool
+	}{
+		{"no promo code", items, coupon.Applied{},
+			[]money.Money{sgd(90), sgd(0), sgd(18)},
+			map[string]Amounts{
+				"seller1": {Net: sgd(1200), Tax: sgd(108), Gross: sgd(1308)},
+				"seller2": {Net: sgd(300), Tax: sgd(0), Gross: sgd(300)},
+			}, sgd(1608), false},
+		{"seller promo code taken off before GST", items, coupon.Applied{Code: "S1", Seller: "seller1", Discount: sgd(200)},
+			[]money.Money{sgd(90), sgd(0), sgd(18)},
+			map[string]Amounts{
+				"seller1": {Net: sgd(1000), Tax: sgd(90), Gross: sgd(1090)},
+				"seller2": {Net: sgd(300), Tax: sgd(0), Gross: sgd(300)},
+			}, sgd(1390), false},
+		{"platform promo code taken off amount payable", items, coupon.Applied{Code: "ALL", Discount: sgd(100)},
+			[]money.Money{sgd(90), sgd(0), sgd(18)},
+			map[string]Amounts{
+				"seller1": {Net: sgd(1200), Tax: sgd(108), Gross: sgd(1308)},
+				"seller2": {Net: sgd(300), Tax: sgd(0), Gross: sgd(300)},
+			}, sgd(1508), false},
+		{"mixed currencies", append(items, apiclient.ItemsDetails{Quantity: 1, Cost: money.Money{Amount: 100, Currency: "MYR"}, Username: "seller1"}),
+			coupon.Applied{}, nil, nil, money.Money{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := rules.Compute(tt.items, registered, tt.discount)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Compute() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			for i, want := range tt.wantLines {
+				if got.Lines[i].Tax != want {
+					t.Errorf("line %d GST = %v, want %v", i, got.Lines[i].Tax, want)
+				}
+			}
+			for seller, want := range tt.wantSellers {
+				if got.Sellers[seller] != want {
+					t.Errorf("seller %s = %+v, want %+v", seller, got.Sellers[seller], want)
+				}
+			}
+			if got.Payable != tt.wantPayable {
+				t.Errorf("Payable = %v, want %v", got.Payable, tt.wantPayable)
+			}
+			if got.Registrations["seller1"].Number != "M90312345X" {
+				t.Errorf("Registrations = %v, want the registrations used", got.Registrations)
+			}
+		})
+	}
+}
+
+func TestParseGSTNumber(t *testing.T) {
+	tests := []struct {
+		number string
+		want   string
+		valid  bool
+	}{
+		{"M90312345X", "M90312345X", true},
+		{"m9-0312345-x", "M90312345X", true},
+		{"200312345A", "200312345A", true},
+		{"53123456B", "53123456B", true},
+		{"T08LL1234A", "T08LL1234A", true},
+		{" 200312345a ", "200312345A", true},
+		{"", "", false},
+		{"12345", "12345", false},
+		{"200312345", "200312345", false},
+		{"M903123456X", "M903123456X", false},
+		{"GST-NUMBER", "GSTNUMBER", false},
+	}
+	for _, tt := range tests {
+		got, valid := ParseGSTNumber(tt.number)
+		if got != tt.want || valid != tt.valid {
+			t.Errorf("ParseGSTNumber(%q) = %q, %v, want %q, %v", tt.number, got, valid, tt.want, tt.valid)
+		}
+	}
+}
+
+func TestDivRound(t *testing.T) {
+	tests := []struct {
+		a, b int64
+		want int64
+	}{
+		{10, 4, 3},
+		{9, 4, 2},
+		{-10, 4, -3},
+		{-9, 4, -2},
+		{0, 7, 0},
+	}
+	for _, tt := range tests {
+		if got := divRound(tt.a, tt.b); got != tt.want {
+			t.Errorf("divRound(%d, %d) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package user_db
+
+import (
+	"database/sql"
+	"log"
+	"time"
+)
+
+// This struct stores a request made by a user to delete their account
+type DeletionRequest struct {
+	Username    string
+	RequestedAt time.Time
+}
+
+// Function to record a request to delete a user account in the MYSQL database.
+// The function takes in the handle to the database, the name of the user and the time of the request.
+// It returns true when the request is recorded successfully.
+// It returns false when there is any error encountered, and the request is not recorded.
+func InsertDeletionRequest(db *sql.DB, uname string, requestedAt time.Time) bool {
+	_, err := db.Exec("INSERT INTO `accountdeletions` (Username, RequestedAt) VALUES (?, ?)", uname, requestedAt)
+	if err != nil {
+		log.Println("Unable to insert the deletion request")
+		log.Println(err)
+		return false
+	}
+	return true
+}
+
+// Function to get all pending deletion requests from the MYSQL database.
+// The function takes in the handle to the database.
+// It returns all the deletion requests as an array of type DeletionRequest.
+// It returns false when there is any error encountered and retrieval of requests is not successful.
+func GetDeletionRequests(db *sql.DB) ([]DeletionRequest, bool) {
+	var dr []DeletionRequest
+
+	results, err := db.Query("SELECT Username, RequestedAt FROM `accountdeletions`")
+	if err != nil {
+		log.Println("Not able to get deletion requests")
+		log.Println(err)
+		return dr, false
+	}
+	defer results.Close()
+
+	for results.Next() {
+		var request DeletionRequest
+		err = results.Scan(&request.Username, &request.RequestedAt)
+		if err != nil {
+			log.Println("Unable to get deletion requests")
+			log.Println(err)
+			return dr, false
+		}
+		dr = append(dr, request)
+	}
+	return dr, true
+}
+
+// Function to remove a pending deletion request from the MYSQL database.
+// It is used when a user cancels the deletion, and after the account has been deleted.
+// It returns true only when a pending request existed and has been removed.
+func CancelDeletionRequest(db *sql.DB, uname string) bool {
+	result, err := db.Exec("DELETE FROM `accountdeletions` WHERE Username=?", uname)
+	if err != nil {
+		log.Println("Unable to cancel the deletion request")
+		log.Println(err)
+		return false
+	}
+	rows, err := result.RowsAffected()
+	if err != nil || rows == 0 {
+		return false
+	}
+	return true
+}
+
+// Function to delete the record of a user whose account is purged from the MYSQL database, with their deletion request.
+// The username is kept in the purgedusernames table in the same transaction, so it can never be registered again
+// and a new user cannot take over the orders, invoices and payments still kept under it.
+// It returns false when there is any error encountered, and the user is not deleted.
+func PurgeRecord(db *sql.DB, uname string, purgedAt time.Time) bool {
+	tx, err := db.Begin()
+	if err != nil {
+		log.Println("Unable to purge the record")
+		log.Println(err)
+		return false
+	}
+	defer tx.Rollback()
+
+	statements := []struct {
+		query string
+		args  []interface{}
+	}{
+		{"INSERT IGNORE INTO `purgedusernames` (Username, PurgedAt) VALUES (?, ?)", []interface{}{uname, purgedAt}},
+		{"DELETE FROM `userdetails` WHERE Username=?", []interface{}{uname}},
+		{"DELETE FROM `accountdeletions` WHERE Username=?", []interface{}{uname}},
+	}
+	for _, s := range statements {
+		if _, err := tx.Exec(s.query, s.args...); err != nil {
+			log.Println("Unable to purge the record")
+			log.Println(err)
+			return false
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		log.Println("Unable to purge the record")
+		log.Println(err)
+		return false
+	}
+	return true
+}
+
+// Function to check in the MYSQL database if a username belonged to an account that has been purged.
+// It returns true as first value when the username cannot be registered, and false as second value
+// when there is any error encountered.
+func IsPurged(db *sql.DB, uname string) (bool, bool) {
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM `purgedusernames` WHERE Username=?", uname).Scan(&count); err != nil {
+		log.Println("Unable to check the purged usernames")
+		log.Println(err)
+		return false, false
+	}
+	return count > 0, true
+}
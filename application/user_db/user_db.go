@@ -4,18 +4,46 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
+	"strings"
 
 	_ "github.com/go-sql-driver/mysql"
 )
 
+// Roles a user can have, a user has at least one of them
+const (
+	RoleBuyer  = "buyer"
+	RoleSeller = "seller"
+)
+
 type UserDetails struct {
-	Username string
-	Password string
-	Fullname string
-	Isbuyer  bool
-	Phone    string
-	Address  string
-	Email    string
+	Username      string
+	Password      string
+	Fullname      string
+	Roles         []string
+	Phone         string
+	Address       string
+	Email         string
+	GSTRegistered bool
+	GSTNumber     string
+}
+
+// Columns of userdetails table in the order they are scanned into UserDetails
+const userColumns = "Username, Password, Fullname, Roles, Phone, Address, Email, GSTRegistered, GSTNumber"
+
+// This method joins the roles of a user into the comma separated value stored in the Roles column
+func JoinRoles(roles []string) string {
+	return strings.Join(roles, ",")
+}
+
+// This method splits the comma separated value stored in the Roles column into a list of roles
+func SplitRoles(roles string) []string {
+	var rs []string
+	for _, role := range strings.Split(roles, ",") {
+		if role = strings.TrimSpace(role); role != "" {
+			rs = append(rs, role)
+		}
+	}
+	return rs
 }
 
 // Function to get all records from the MYSQL database.
@@ -26,7 +54,7 @@ type UserDetails struct {
 func GetRecords(db *sql.DB) ([]UserDetails, bool) {
 	var ud []UserDetails
 
-	results, err := db.Query("SELECT * FROM `userdetails`")
+	results, err := db.Query("SELECT " + userColumns + " FROM `userdetails`")
 	if err != nil {
 		log.Println("Not able to get user details")
 		log.Println(err)
@@ -36,12 +64,14 @@ func GetRecords(db *sql.DB) ([]UserDetails, bool) {
 	for results.Next() {
 		// map this type to the record in the table
 		var uinfo UserDetails
-		err = results.Scan(&uinfo.Username, &uinfo.Password, &uinfo.Fullname, &uinfo.Isbuyer, &uinfo.Phone, &uinfo.Address, &uinfo.Email)
+		var roles string
+		err = results.Scan(&uinfo.Username, &uinfo.Password, &uinfo.Fullname, &roles, &uinfo.Phone, &uinfo.Address, &uinfo.Email, &uinfo.GSTRegistered, &uinfo.GSTNumber)
 		if err != nil {
 			log.Println("Unable to get records")
 			log.Println(err)
 			return ud, false
 		}
+		uinfo.Roles = SplitRoles(roles)
 		ud = append(ud, uinfo)
 	}
 	return ud, true
@@ -55,7 +85,7 @@ func GetRecords(db *sql.DB) ([]UserDetails, bool) {
 // It returns false when there is any error encountered, and retrieval of record is not successful.
 func GetARecord(db *sql.DB, uname string) (UserDetails, bool) {
 	var ud UserDetails
-	query := fmt.Sprintf("SELECT * FROM `userdetails` WHERE Username='%s'", uname)
+	query := fmt.Sprintf("SELECT "+userColumns+" FROM `userdetails` WHERE Username='%s'", uname)
 	results, err := db.Query(query)
 	if err != nil {
 		log.Println("Unable to find a record")
@@ -66,12 +96,14 @@ func GetARecord(db *sql.DB, uname string) (UserDetails, bool) {
 
 	for results.Next() {
 		// map this type to the record in the table
-		err = results.Scan(&ud.Username, &ud.Password, &ud.Fullname, &ud.Isbuyer, &ud.Phone, &ud.Address, &ud.Email)
+		var roles string
+		err = results.Scan(&ud.Username, &ud.Password, &ud.Fullname, &roles, &ud.Phone, &ud.Address, &ud.Email, &ud.GSTRegistered, &ud.GSTNumber)
 		if err != nil {
 			log.Println("Unable to get the record")
 			log.Println(err)
 			return ud, false
 		}
+		ud.Roles = SplitRoles(roles)
 	}
 	if ud.Username == "" {
 		return ud, false
@@ -85,7 +117,7 @@ func GetARecord(db *sql.DB, uname string) (UserDetails, bool) {
 // It returns true when the user is inserted into the database successfully.
 // It returns false when there is any error encountered, and user is not inserted successfully.
 func InsertRecord(db *sql.DB, ud UserDetails) bool {
-	query := fmt.Sprintf("INSERT INTO `userdetails`(Username, Password, Fullname, Isbuyer, Phone, Address, Email) VALUES ('%s','%s','%s',%t,'%s','%s','%s')", ud.Username, ud.Password, ud.Fullname, ud.Isbuyer, ud.Phone, ud.Address, ud.Email)
+	query := fmt.Sprintf("INSERT INTO `userdetails`(Username, Password, Fullname, Roles, Phone, Address, Email) VALUES ('%s','%s','%s','%s','%s','%s','%s')", ud.Username, ud.Password, ud.Fullname, JoinRoles(ud.Roles), ud.Phone, ud.Address, ud.Email)
 	_, err := db.Exec(query)
 	if err != nil {
 		log.Println("Unable to insert the record")
@@ -102,7 +134,7 @@ func InsertRecord(db *sql.DB, ud UserDetails) bool {
 // It returns true when the user details is updated in the database successfully.
 // It returns false when there is any error encountered, and user details is not updated successfully.
 func EditRecord(db *sql.DB, uname string, ud UserDetails) bool {
-	query := fmt.Sprintf("UPDATE `userdetails` SET Username='%s', Password='%s', Firstname='%s', Isbuyer=%t, Phone='%s', Address='%s', Email='%s') WHERE Username='%s'", ud.Username, ud.Password, ud.Fullname, ud.Isbuyer, ud.Phone, ud.Address, ud.Email, uname)
+	query := fmt.Sprintf("UPDATE `userdetails` SET Username='%s', Password='%s', Firstname='%s', Roles='%s', Phone='%s', Address='%s', Email='%s') WHERE Username='%s'", ud.Username, ud.Password, ud.Fullname, JoinRoles(ud.Roles), ud.Phone, ud.Address, ud.Email, uname)
 	_, err := db.Query(query)
 	if err != nil {
 		log.Println("Unable to edit the record")
@@ -120,7 +152,7 @@ func EditRecord(db *sql.DB, uname string, ud UserDetails) bool {
 func DeleteRecord(db *sql.DB, uname string) bool {
 	query := fmt.Sprintf(
 		"DELETE FROM `userdetails` WHERE Username='%s'", uname)
-	_, err := db.Query(query)
+	_, err := db.Exec(query)
 	if err != nil {
 		log.Println("Unable to delete the record")
 		log.Println(err)
@@ -128,3 +160,47 @@ func DeleteRecord(db *sql.DB, uname string) bool {
 	}
 	return true
 }
+
+// Function to update the roles of an existing user in the MYSQL database.
+// The function takes in the handle to the database, the name of the user and the new list of roles.
+// It returns true when the roles are updated in the database successfully.
+// It returns false when there is any error encountered, and roles are not updated successfully.
+func UpdateRoles(db *sql.DB, uname string, roles []string) bool {
+	_, err := db.Exec("UPDATE `userdetails` SET Roles=? WHERE Username=?", JoinRoles(roles), uname)
+	if err != nil {
+		log.Println("Unable to update the roles")
+		log.Println(err)
+		return false
+	}
+	return true
+}
+
+// Function to update the address of a user in the MYSQL database.
+// The function takes in the handle to the database, the name of the user and the new address.
+// It returns false when there is any error encountered, and the address is not updated.
+func UpdateAddress(db *sql.DB, uname string, address string) bool {
+	_, err := db.Exec("UPDATE `userdetails` SET Address=? WHERE Username=?", address, uname)
+	if err != nil {
+		log.Println("Unable to update the address")
+		log.Println(err)
+		return false
+	}
+	return true
+}
+
+// Function to update whether a seller is registered for GST, and their GST registration number, in the MYSQL database.
+// The function takes in the handle to the database, the name of the user, the registration status and number.
+// The number is only kept while the seller is registered.
+// It returns false when there is any error encountered, and the status is not updated.
+func UpdateGSTRegistered(db *sql.DB, uname string, registered bool, number string) bool {
+	if !registered {
+		number = ""
+	}
+	_, err := db.Exec("UPDATE `userdetails` SET GSTRegistered=?, GSTNumber=? WHERE Username=?", registered, number, uname)
+	if err != nil {
+		log.Println("Unable to update the GST registration")
+		log.Println(err)
+		return false
+	}
+	return true
+}
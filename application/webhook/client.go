@@ -0,0 +1,91 @@
+package webhook
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// Networks the webhooks are never posted to, so a seller cannot register a URL that reaches the server itself,
+// the private network it runs in or the metadata service of its cloud provider.
+var blockedNetworks = parseNetworks(
+	"0.0.0.0/8",      // this network
+	"10.0.0.0/8",     // private
+	"100.64.0.0/10",  // carrier-grade NAT
+	"127.0.0.0/8",    // loopback
+	"169.254.0.0/16", // link-local, with the cloud metadata service at 169.254.169.254
+	"172.16.0.0/12",  // private
+	"192.168.0.0/16", // private
+	"::/128",         // unspecified
+	"::1/128",        // loopback
+	"fc00::/7",       // unique local
+	"fe80::/10",      // link-local
+	"64:ff9b::/96",   // NAT64, with any IPv4 address, private ones included, in its last 32 bits
+)
+
+// Error of a delivery to an address in blockedNetworks
+var errBlockedAddress = errors.New("address not allowed")
+
+// This function parses a list of networks in CIDR notation, it panics on a network that is not valid
+func parseNetworks(cidrs ...string) []*net.IPNet {
+	var networks []*net.IPNet
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
+
+// This function returns true if deliveries must not be posted to an IP address
+// IPv4 addresses mapped into IPv6 are checked as IPv4 addresses.
+func blocked(ip net.IP) bool {
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+	for _, network := range blockedNetworks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return ip.IsMulticast()
+}
+
+// This function is called before each connection is made, with the IP address the name of the endpoint resolved to
+// Checking the address when connecting, and not when the URL is registered, stops a name that resolves to a
+// public address at first and to a private one later.
+func refuseBlocked(network, address string, c syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || blocked(ip) {
+		return errBlockedAddress
+	}
+	return nil
+}
+
+// This function returns the client used to post deliveries
+// It only connects to public addresses, without a proxy, and returns redirects as they are,
+// so a delivery answered with a redirect fails instead of being posted somewhere else.
+func newClient() *http.Client {
+	dialer := &net.Dialer{Timeout: 5 * time.Second, KeepAlive: 30 * time.Second, Control: refuseBlocked}
+	return &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: &http.Transport{
+			DialContext:           dialer.DialContext,
+			TLSHandshakeTimeout:   5 * time.Second,
+			ResponseHeaderTimeout: 10 * time.Second,
+			MaxIdleConns:          10,
+			IdleConnTimeout:       90 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
@@ -0,0 +1,138 @@
+package webhook
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"projectGoLive/application/config"
+	"projectGoLive/application/retry"
+	"time"
+)
+
+// Status of a delivery
+const (
+	// Waiting to be posted, or to be tried again after failing
+	DeliveryPending = "pending"
+	// Posted and answered with a 2xx status
+	DeliveryDelivered = "delivered"
+	// Given up after WebhookMaxAttempts failures, until the seller sends it again
+	DeliveryFailed = "failed"
+)
+
+// Number of deliveries read at a time
+const deliveryBatch = 20
+
+// Data structure for an event queued for an endpoint, with the result of the last attempt to post it
+type Delivery struct {
+	ID            int64
+	EndpointID    int64
+	Seller        string
+	URL           string
+	EventID       string
+	Event         string
+	Body          string
+	Status        string
+	Attempts      int
+	NextAttemptAt time.Time
+	ResponseCode  int
+	LastError     string
+	CreatedAt     time.Time
+	DeliveredAt   time.Time
+	// Secret of the endpoint, used to sign the delivery and never shown in the log
+	secret string
+}
+
+// This method posts the deliveries waiting to be sent
+// It runs forever, checking for deliveries to send every WebhookPollTime seconds
+func Dispatch() {
+	for {
+		dispatchDue(time.Now())
+		time.Sleep(time.Second * time.Duration(config.WebhookPollTime))
+	}
+}
+
+// This function posts the deliveries due by now, and schedules the failed ones to be tried again
+func dispatchDue(now time.Time) {
+	var due []Delivery
+	retry.RunDue(deliveryBatch, func(limit int) (int, bool) {
+		var ok bool
+		due, ok = dueDeliveries(config.DB, now, limit)
+		return len(due), ok
+	}, func(i int) bool {
+		return claimDelivery(config.DB, due[i].ID, now, now.Add(retryAfter(due[i].Attempts+1)))
+	}, func(i int) {
+		saveAttempt(config.DB, attempt(due[i], now))
+	})
+}
+
+// This function posts a delivery once, and returns it as it is then stored in the delivery log
+// A failed delivery is tried again after retryAfter, and given up after WebhookMaxAttempts attempts.
+// The log only has the status code of the response, or why there was none, as sellers can read it.
+func attempt(d Delivery, now time.Time) Delivery {
+	code, err := post(d, time.Now())
+	d.Attempts++
+	d.ResponseCode = code
+	if err == nil {
+		d.Status = DeliveryDelivered
+		d.LastError = ""
+		d.DeliveredAt = time.Now()
+		return d
+	}
+
+	d.Status = DeliveryPending
+	d.NextAttemptAt = now.Add(retryAfter(d.Attempts))
+	d.LastError = failure(code, err)
+	if d.Attempts >= config.WebhookMaxAttempts {
+		d.Status = DeliveryFailed
+		config.Warning.Printf("Webhook delivery %d of %s to %s given up after %d attempts : %v\n", d.ID, d.Event, d.URL, d.Attempts, err)
+	}
+	return d
+}
+
+// This function returns why a delivery failed as shown in the delivery log, without what the receiver answered
+// or the details of the network error, so the log cannot be used to find out about the systems the server reaches.
+func failure(code int, err error) string {
+	var timeout net.Error
+	switch {
+	case code != 0:
+		return fmt.Sprintf("%d %s", code, http.StatusText(code))
+	case errors.Is(err, errBlockedAddress):
+		return "Address not allowed"
+	case errors.As(err, &timeout) && timeout.Timeout():
+		return "Timed out"
+	}
+	return "No response"
+}
+
+// This function posts a delivery to its endpoint, signed with the secret of the endpoint
+// It returns the status code of the response, and an error unless it is a 2xx status.
+func post(d Delivery, now time.Time) (int, error) {
+	body := []byte(d.Body)
+	req, err := http.NewRequest(http.MethodPost, d.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "PeelRescue-Webhook/1.0")
+	req.Header.Set(EventHeader, d.Event)
+	req.Header.Set(DeliveryHeader, fmt.Sprint(d.ID))
+	req.Header.Set(SignatureHeader, Sign(d.secret, now, body))
+
+	resp, err := Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, errors.New(resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// This function returns how long to wait before the next attempt after a number of failed attempts
+// The wait doubles after each failure, from WebhookRetryTime up to WebhookMaxRetryTime.
+func retryAfter(attempts int) time.Duration {
+	return retry.Backoff(attempts, time.Duration(config.WebhookRetryTime)*time.Second, time.Duration(config.WebhookMaxRetryTime)*time.Second)
+}
@@ -0,0 +1,264 @@
+// Package webhook lets sellers connect their own systems, such as a POS or ERP, to Peel Rescue.
+// A seller registers the URLs to call and the events wanted, and each event is posted to them as JSON,
+// signed with HMAC-SHA256 using the secret of the endpoint. Deliveries are stored in the user database and
+// sent in the background with retries, so the seller can check the log of what was delivered.
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"projectGoLive/application/apiclient"
+	"projectGoLive/application/config"
+	"projectGoLive/application/listingevents"
+	"projectGoLive/application/orders"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Events sent to webhooks
+const (
+	// An order was placed with the seller
+	EventOrderPlaced = "order.placed"
+	// An order of the seller was cancelled by the buyer or the seller
+	EventOrderCancelled = "order.cancelled"
+	// A listing of the seller passed its best before time and was removed from sale
+	EventListingExpired = "listing.expired"
+	// Sent when the seller asks to test an endpoint, whatever events it is registered for
+	EventPing = "ping"
+)
+
+// Events a seller can choose for an endpoint, in the order shown on the page
+var Events = []string{EventOrderPlaced, EventOrderCancelled, EventListingExpired}
+
+// Headers of each delivery
+const (
+	// Name of the event, e.g. order.placed
+	EventHeader = "X-Peel-Event"
+	// ID of the delivery, the same across retries so the receiver can ignore duplicates
+	DeliveryHeader = "X-Peel-Delivery"
+	// Signature of the delivery, "t=<unix time>,v1=<hex HMAC-SHA256 of the time, a dot and the body>"
+	SignatureHeader = "X-Peel-Signature"
+)
+
+// Number of endpoints a seller can register
+const MaxEndpoints = 5
+
+// Client used to post deliveries, it can be replaced, e.g. by a test receiver's client
+// It does not connect to private addresses and does not follow redirects, see newClient.
+var Client = newClient()
+
+// Allow endpoints over plain http, e.g. a receiver on a test network, otherwise https is required
+var AllowHTTP = false
+
+// Data structure for a URL registered by a seller, with the events posted to it
+type Endpoint struct {
+	ID        int64
+	Seller    string
+	URL       string
+	Secret    string
+	Events    []string
+	CreatedAt time.Time
+}
+
+// This method returns true if the endpoint is registered for an event
+func (e Endpoint) Wants(event string) bool {
+	if event == EventPing {
+		return true
+	}
+	for _, ev := range e.Events {
+		if ev == event {
+			return true
+		}
+	}
+	return false
+}
+
+// Data structure for the body posted to an endpoint
+// ID is the same for every endpoint receiving the event, Data is the order or listing it is about.
+type Payload struct {
+	ID        string
+	Event     string
+	CreatedAt time.Time
+	Data      interface{}
+}
+
+// Interface satisfied by both *sql.DB and *sql.Tx, so deliveries can be queued inside the transaction that stores an order
+type querier interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+func init() {
+	listingevents.Subscribe(apiclient.EventExpired, listingExpired)
+}
+
+// This function returns n random bytes in hex
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// This function generates a random secret for a new endpoint
+func NewSecret() (string, error) {
+	secret, err := randomHex(24)
+	return "whsec_" + secret, err
+}
+
+// This function reads a new endpoint from the form of the seller's webhooks page
+// It returns a message for the user if the form is not valid, or an empty string.
+func ReadForm(req *http.Request, seller string) (Endpoint, string) {
+	e := Endpoint{Seller: seller, URL: strings.TrimSpace(req.FormValue("url"))}
+	u, err := url.Parse(e.URL)
+	if err != nil || u.Host == "" || !(u.Scheme == "https" || (AllowHTTP && u.Scheme == "http")) {
+		return e, "Please enter the full https:// address of your system"
+	}
+	if len(e.URL) > 255 {
+		return e, "Please enter an address of at most 255 characters"
+	}
+	for _, ev := range Events {
+		if req.FormValue(ev) != "" {
+			e.Events = append(e.Events, ev)
+		}
+	}
+	if len(e.Events) == 0 {
+		return e, "Please choose at least one event to send"
+	}
+	secret, err := NewSecret()
+	if err != nil {
+		return e, "Unable to generate a secret, try again!"
+	}
+	e.Secret = secret
+	return e, ""
+}
+
+// This function returns the signature header of a body sent at a time with the secret of an endpoint
+// A receiver computes the same HMAC over the time, a dot and the raw body, and rejects old times to stop replays.
+func Sign(secret string, at time.Time, body []byte) string {
+	timestamp := fmt.Sprint(at.Unix())
+	return "t=" + timestamp + ",v1=" + hex.EncodeToString(signature(secret, timestamp, body))
+}
+
+// This function returns the HMAC-SHA256 of the time, a dot and the body with the secret
+func signature(secret, timestamp string, body []byte) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return mac.Sum(nil)
+}
+
+// This function returns true if a signature header matches a body for the secret, signed no longer than tolerance before now
+// It is what a receiver of the webhooks does to check a delivery.
+func Verify(secret, header string, body []byte, now time.Time, tolerance time.Duration) bool {
+	var timestamp string
+	var signed []byte
+	for _, part := range strings.Split(header, ",") {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch kv[0] {
+		case "t":
+			timestamp = kv[1]
+		case "v1":
+			signed, _ = hex.DecodeString(kv[1])
+		}
+	}
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	if age := now.Sub(time.Unix(unix, 0)); age > tolerance || age < -tolerance {
+		return false
+	}
+	return hmac.Equal(signed, signature(secret, timestamp, body))
+}
+
+//---------------------------------------------------------------------------
+// Functions to queue events for the endpoints of a seller
+//---------------------------------------------------------------------------
+// This function queues an event for every endpoint of the seller registered for it
+// It takes in a handle to the database or a transaction, so deliveries about an order are only queued if the order is stored.
+func Queue(db querier, seller, event string, data interface{}) bool {
+	endpoints, ok := GetEndpoints(db, seller)
+	if !ok {
+		config.Error.Printf("Unable to get webhooks of seller %s for %s\n", seller, event)
+		return false
+	}
+
+	var wanted []Endpoint
+	for _, e := range endpoints {
+		if e.Wants(event) {
+			wanted = append(wanted, e)
+		}
+	}
+	if len(wanted) == 0 {
+		return true
+	}
+
+	id, err := randomHex(12)
+	if err != nil {
+		config.Error.Printf("Unable to generate the ID of %s for seller %s : %v\n", event, seller, err)
+		return false
+	}
+	payload := Payload{ID: "evt_" + id, Event: event, CreatedAt: time.Now(), Data: data}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		config.Error.Printf("Unable to encode %s for seller %s : %v\n", event, seller, err)
+		return false
+	}
+
+	allok := true
+	for _, e := range wanted {
+		if !insertDelivery(db, e, payload, body) {
+			config.Error.Printf("Unable to queue %s for webhook %d of seller %s\n", event, e.ID, seller)
+			allok = false
+		}
+	}
+	return allok
+}
+
+// This function queues the order placed event for the seller of each order
+func OrdersPlaced(db querier, placed []orders.Order) bool {
+	allok := true
+	for _, o := range placed {
+		if !Queue(db, o.Seller, EventOrderPlaced, o) {
+			allok = false
+		}
+	}
+	return allok
+}
+
+// This function queues the order cancelled event for the seller of an order
+func OrderCancelled(db querier, o orders.Order) bool {
+	return Queue(db, o.Seller, EventOrderCancelled, o)
+}
+
+// This method queues the listing expired event for the seller of a listing
+func listingExpired(event apiclient.ListingEvent) bool {
+	return Queue(config.DB, event.Item.Username, EventListingExpired, event.Item)
+}
+
+// This function queues a ping to one endpoint of a seller, so the seller can check their system receives the webhooks
+func Ping(db querier, e Endpoint) bool {
+	id, err := randomHex(12)
+	if err != nil {
+		return false
+	}
+	payload := Payload{ID: "evt_" + id, Event: EventPing, CreatedAt: time.Now(), Data: map[string]string{"Seller": e.Seller, "URL": e.URL}}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return false
+	}
+	return insertDelivery(db, e, payload, body)
+}
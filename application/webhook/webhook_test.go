@@ -0,0 +1,227 @@
+package webhook
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"projectGoLive/application/config"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignAndVerify(t *testing.T) {
+	body := []byte(`{"ID":"evt_1","Event":"order.placed"}`)
+	signedAt := time.Date(2021, 6, 1, 10, 0, 0, 0, time.UTC)
+	header := Sign("whsec_test", signedAt, body)
+
+	tests := []struct {
+		name   string
+		secret string
+		header string
+		body   []byte
+		now    time.Time
+		want   bool
+	}{
+		{"valid", "whsec_test", header, body, signedAt.Add(time.Minute), true},
+		{"wrong secret", "whsec_other", header, body, signedAt, false},
+		{"changed body", "whsec_test", header, []byte(`{"ID":"evt_2"}`), signedAt, false},
+		{"too old", "whsec_test", header, body, signedAt.Add(10 * time.Minute), false},
+		{"from the future", "whsec_test", header, body, signedAt.Add(-10 * time.Minute), false},
+		{"no time", "whsec_test", strings.Replace(header, "t=", "x=", 1), body, signedAt, false},
+		{"empty", "whsec_test", "", body, signedAt, false},
+	}
+	for _, test := range tests {
+		if got := Verify(test.secret, test.header, test.body, test.now, 5*time.Minute); got != test.want {
+			t.Errorf("%s: Verify = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestBlocked(t *testing.T) {
+	tests := []struct {
+		address string
+		want    bool
+	}{
+		{"127.0.0.1", true},
+		{"127.10.0.1", true},
+		{"10.1.2.3", true},
+		{"172.16.0.1", true},
+		{"172.31.255.255", true},
+		{"172.32.0.1", false},
+		{"192.168.1.1", true},
+		{"169.254.169.254", true},
+		{"169.254.1.1", true},
+		{"0.0.0.0", true},
+		{"224.0.0.1", true},
+		{"::1", true},
+		{"::", true},
+		{"fe80::1", true},
+		{"fd00::1", true},
+		{"::ffff:127.0.0.1", true},
+		{"::ffff:10.0.0.1", true},
+		{"64:ff9b::7f00:1", true},
+		{"64:ff9b::a9fe:a9fe", true},
+		{"64:ff9b::808:808", true},
+		{"8.8.8.8", false},
+		{"203.0.113.10", false},
+		{"2606:4700:4700::1111", false},
+	}
+	for _, test := range tests {
+		if got := blocked(net.ParseIP(test.address)); got != test.want {
+			t.Errorf("blocked(%s) = %v, want %v", test.address, got, test.want)
+		}
+	}
+}
+
+func TestClientRefusesPrivateAddresses(t *testing.T) {
+	var received int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		received++
+	}))
+	defer server.Close()
+
+	// The test server listens on the loopback address, which the client of the deliveries must not reach
+	d := Delivery{ID: 1, URL: server.URL, Event: EventPing, Body: "{}", secret: "whsec_test"}
+	code, err := post(d, time.Now())
+	if err == nil || code != 0 || received != 0 {
+		t.Errorf("post to %s = %d, %v with %d requests received, want it refused", server.URL, code, err, received)
+	}
+	if got := failure(code, err); got != "Address not allowed" {
+		t.Errorf("failure = %q, want Address not allowed", got)
+	}
+}
+
+func TestAttempt(t *testing.T) {
+	defer func(client *http.Client) { Client = client }(Client)
+	now := time.Date(2021, 6, 1, 10, 0, 0, 0, time.UTC)
+	retry := time.Duration(config.WebhookRetryTime) * time.Second
+
+	tests := []struct {
+		name          string
+		status        int
+		location      string
+		attempts      int
+		wantStatus    string
+		wantCode      int
+		wantError     string
+		wantNext      time.Time
+		wantDelivered bool
+	}{
+		{name: "delivered", status: http.StatusOK, wantStatus: DeliveryDelivered, wantCode: 200, wantDelivered: true},
+		{name: "accepted", status: http.StatusAccepted, attempts: 3, wantStatus: DeliveryDelivered, wantCode: 202, wantDelivered: true},
+		{name: "first failure", status: http.StatusInternalServerError, wantStatus: DeliveryPending, wantCode: 500,
+			wantError: "500 Internal Server Error", wantNext: now.Add(retry)},
+		{name: "third failure waits longer", status: http.StatusServiceUnavailable, attempts: 2, wantStatus: DeliveryPending, wantCode: 503,
+			wantError: "503 Service Unavailable", wantNext: now.Add(4 * retry)},
+		{name: "redirect is not followed", status: http.StatusFound, location: "http://169.254.169.254/latest/meta-data/", wantStatus: DeliveryPending,
+			wantCode: 302, wantError: "302 Found", wantNext: now.Add(retry)},
+		{name: "given up", status: http.StatusBadRequest, attempts: config.WebhookMaxAttempts - 1, wantStatus: DeliveryFailed, wantCode: 400,
+			wantError: "400 Bad Request", wantNext: now.Add(retryAfter(config.WebhookMaxAttempts))},
+	}
+	for _, test := range tests {
+		var requests []*http.Request
+		var bodies []string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			body, _ := ioutil.ReadAll(req.Body)
+			requests = append(requests, req)
+			bodies = append(bodies, string(body))
+			if test.location != "" {
+				w.Header().Set("Location", test.location)
+			}
+			w.WriteHeader(test.status)
+			// What the receiver answers is never kept in the log
+			w.Write([]byte("internal details of the receiver"))
+		}))
+		// The test server is on the loopback address, so its own client is used to reach it, without following redirects
+		Client = server.Client()
+		Client.CheckRedirect = newClient().CheckRedirect
+
+		d := Delivery{ID: 42, URL: server.URL + "/hooks", Event: EventOrderPlaced, Body: `{"ID":"evt_1"}`, Attempts: test.attempts, secret: "whsec_test"}
+		got := attempt(d, now)
+		server.Close()
+
+		if len(requests) != 1 {
+			t.Errorf("%s: %d requests received, want 1", test.name, len(requests))
+			continue
+		}
+		req := requests[0]
+		if req.Method != http.MethodPost || req.URL.Path != "/hooks" || req.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("%s: request = %s %s %s", test.name, req.Method, req.URL.Path, req.Header.Get("Content-Type"))
+		}
+		if req.Header.Get(EventHeader) != EventOrderPlaced || req.Header.Get(DeliveryHeader) != "42" {
+			t.Errorf("%s: event headers = %q, %q", test.name, req.Header.Get(EventHeader), req.Header.Get(DeliveryHeader))
+		}
+		if !Verify("whsec_test", req.Header.Get(SignatureHeader), []byte(bodies[0]), time.Now(), time.Minute) {
+			t.Errorf("%s: signature %q does not verify", test.name, req.Header.Get(SignatureHeader))
+		}
+
+		if got.Status != test.wantStatus || got.ResponseCode != test.wantCode || got.LastError != test.wantError || got.Attempts != test.attempts+1 {
+			t.Errorf("%s: log row = %s, %d, %q after %d attempts, want %s, %d, %q after %d",
+				test.name, got.Status, got.ResponseCode, got.LastError, got.Attempts, test.wantStatus, test.wantCode, test.wantError, test.attempts+1)
+		}
+		if !test.wantDelivered && !got.NextAttemptAt.Equal(test.wantNext) {
+			t.Errorf("%s: next attempt at %v, want %v", test.name, got.NextAttemptAt, test.wantNext)
+		}
+		if got.DeliveredAt.IsZero() == test.wantDelivered {
+			t.Errorf("%s: delivered at %v, want delivered %v", test.name, got.DeliveredAt, test.wantDelivered)
+		}
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	retry := time.Duration(config.WebhookRetryTime) * time.Second
+	longest := time.Duration(config.WebhookMaxRetryTime) * time.Second
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, retry},
+		{2, 2 * retry},
+		{3, 4 * retry},
+		{20, longest},
+	}
+	for _, test := range tests {
+		if got := retryAfter(test.attempts); got != test.want {
+			t.Errorf("retryAfter(%d) = %v, want %v", test.attempts, got, test.want)
+		}
+	}
+}
+
+func TestFailure(t *testing.T) {
+	tests := []struct {
+		name string
+		code int
+		err  error
+		want string
+	}{
+		{"status", 404, nil, "404 Not Found"},
+		{"blocked", 0, &net.OpError{Op: "dial", Err: errBlockedAddress}, "Address not allowed"},
+		{"timeout", 0, &net.DNSError{Err: "i/o timeout", IsTimeout: true}, "Timed out"},
+		{"refused", 0, &net.OpError{Op: "dial", Err: &net.AddrError{Err: "connection refused", Addr: "10.0.0.1:22"}}, "No response"},
+	}
+	for _, test := range tests {
+		if got := failure(test.code, test.err); got != test.want {
+			t.Errorf("%s: failure = %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestWants(t *testing.T) {
+	e := Endpoint{Events: []string{EventOrderPlaced, EventListingExpired}}
+	tests := []struct {
+		event string
+		want  bool
+	}{
+		{EventOrderPlaced, true},
+		{EventOrderCancelled, false},
+		{EventListingExpired, true},
+		{EventPing, true},
+	}
+	for _, test := range tests {
+		if got := e.Wants(test.event); got != test.want {
+			t.Errorf("Wants(%s) = %v, want %v", test.event, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package webhook
+
+import (
+	"database/sql"
+	"log"
+	"strings"
+	"time"
+)
+
+// Columns read for each delivery, in the order used by scanDelivery, the endpoint is joined as e
+const deliveryColumns = "d.ID, d.EndpointID, e.Seller, e.URL, e.Secret, d.EventID, d.Event, d.Body, d.Status, d.Attempts, d.NextAttemptAt, d.ResponseCode, d.LastError, d.CreatedAt, d.DeliveredAt"
+
+// Tables joined to read deliveries with the URL and secret of their endpoint
+const deliveryTables = "`webhookdeliveries` d JOIN `webhookendpoints` e ON e.ID=d.EndpointID"
+
+// Function to read one delivery from a row selected with deliveryColumns.
+func scanDelivery(rows *sql.Rows) (Delivery, error) {
+	var d Delivery
+	var deliveredAt sql.NullTime
+	err := rows.Scan(&d.ID, &d.EndpointID, &d.Seller, &d.URL, &d.secret, &d.EventID, &d.Event, &d.Body, &d.Status, &d.Attempts, &d.NextAttemptAt, &d.ResponseCode, &d.LastError, &d.CreatedAt, &deliveredAt)
+	if deliveredAt.Valid {
+		d.DeliveredAt = deliveredAt.Time
+	}
+	return d, err
+}
+
+//-----------------------------------------------------------------------
+// Functions for the endpoints of sellers
+//-----------------------------------------------------------------------
+// Function to store a new endpoint of a seller in the MYSQL database.
+// It returns false when the seller already has MaxEndpoints endpoints or there is any error encountered.
+func InsertEndpoint(db *sql.DB, e Endpoint) bool {
+	endpoints, ok := GetEndpoints(db, e.Seller)
+	if !ok || len(endpoints) >= MaxEndpoints {
+		return false
+	}
+	_, err := db.Exec("INSERT INTO `webhookendpoints` (Seller, URL, Secret, Events, CreatedAt) VALUES (?, ?, ?, ?, ?)",
+		e.Seller, e.URL, e.Secret, strings.Join(e.Events, ","), time.Now())
+	if err != nil {
+		log.Println("Unable to insert the webhook")
+		log.Println(err)
+		return false
+	}
+	return true
+}
+
+// Function to get the endpoints of a seller from the MYSQL database, oldest first.
+// The function takes in a handle to the database or a transaction. It returns false when there is any error encountered.
+func GetEndpoints(db querier, seller string) ([]Endpoint, bool) {
+	var endpoints []Endpoint
+	results, err := db.Query("SELECT ID, Seller, URL, Secret, Events, CreatedAt FROM `webhookendpoints` WHERE Seller=? ORDER BY ID", seller)
+	if err != nil {
+		log.Println("Not able to get webhooks")
+		log.Println(err)
+		return endpoints, false
+	}
+	defer results.Close()
+
+	for results.Next() {
+		var e Endpoint
+		var events string
+		if err := results.Scan(&e.ID, &e.Seller, &e.URL, &e.Secret, &events, &e.CreatedAt); err != nil {
+			log.Println("Unable to get webhooks")
+			log.Println(err)
+			return endpoints, false
+		}
+		e.Events = strings.Split(events, ",")
+		endpoints = append(endpoints, e)
+	}
+	return endpoints, true
+}
+
+// Function to delete an endpoint of a seller, and its deliveries, from the MYSQL database.
+// It returns false when the endpoint does not exist or there is any error encountered.
+func DeleteEndpoint(db *sql.DB, id int64, seller string) bool {
+	result, err := db.Exec("DELETE FROM `webhookendpoints` WHERE ID=? AND Seller=?", id, seller)
+	if err != nil {
+		log.Println("Unable to delete the webhook")
+		log.Println(err)
+		return false
+	}
+	n, _ := result.RowsAffected()
+	return n == 1
+}
+
+// Function to delete all endpoints of a seller, and their deliveries, from the MYSQL database, used when the account is purged.
+// It returns false when there is any error encountered.
+func DeleteEndpoints(db *sql.DB, seller string) bool {
+	_, err := db.Exec("DELETE FROM `webhookendpoints` WHERE Seller=?", seller)
+	if err != nil {
+		log.Println("Unable to delete webhooks")
+		log.Println(err)
+		return false
+	}
+	return true
+}
+
+//-----------------------------------------------------------------------
+// Functions for the deliveries of events
+//-----------------------------------------------------------------------
+// Function to store a delivery of an event to an endpoint in the MYSQL database, to be posted by Dispatch.
+func insertDelivery(db querier, e Endpoint, payload Payload, body []byte) bool {
+	now := time.Now()
+	_, err := db.Exec("INSERT INTO `webhookdeliveries` (EndpointID, EventID, Event, Body, Status, Attempts, NextAttemptAt, ResponseCode, LastError, CreatedAt) VALUES (?, ?, ?, ?, ?, 0, ?, 0, '', ?)",
+		e.ID, payload.ID, payload.Event, string(body), DeliveryPending, now, now)
+	if err != nil {
+		log.Println("Unable to insert the webhook delivery")
+		log.Println(err)
+		return false
+	}
+	return true
+}
+
+// Function to get the deliveries due to be posted by now from the MYSQL database, oldest first.
+// It returns false when there is any error encountered.
+func dueDeliveries(db *sql.DB, now time.Time, limit int) ([]Delivery, bool) {
+	return queryDeliveries(db, "SELECT "+deliveryColumns+" FROM "+deliveryTables+" WHERE d.Status=? AND d.NextAttemptAt<=? ORDER BY d.NextAttemptAt, d.ID LIMIT ?", DeliveryPending, now, limit)
+}
+
+// Function to get the latest deliveries to the endpoints of a seller from the MYSQL database, newest first.
+// It returns at most limit deliveries, and false when there is any error encountered.
+func GetDeliveries(db *sql.DB, seller string, limit int) ([]Delivery, bool) {
+	return queryDeliveries(db, "SELECT "+deliveryColumns+" FROM "+deliveryTables+" WHERE e.Seller=? ORDER BY d.ID DESC LIMIT ?", seller, limit)
+}
+
+// Function to take a delivery to post it, by moving its next attempt to until.
+// It returns false if the delivery is no longer due, e.g. another worker has taken it.
+func claimDelivery(db *sql.DB, id int64, now, until time.Time) bool {
+	result, err := db.Exec("UPDATE `webhookdeliveries` SET NextAttemptAt=? WHERE ID=? AND Status=? AND NextAttemptAt<=?", until, id, DeliveryPending, now)
+	if err != nil {
+		log.Println("Unable to claim the webhook delivery")
+		log.Println(err)
+		return false
+	}
+	n, _ := result.RowsAffected()
+	return n == 1
+}
+
+// Function to record in the MYSQL database an attempt to post a delivery, as returned by attempt.
+func saveAttempt(db *sql.DB, d Delivery) {
+	var deliveredAt interface{}
+	if d.Status == DeliveryDelivered {
+		deliveredAt = d.DeliveredAt
+	}
+	_, err := db.Exec("UPDATE `webhookdeliveries` SET Status=?, Attempts=?, NextAttemptAt=?, ResponseCode=?, LastError=?, DeliveredAt=? WHERE ID=?",
+		d.Status, d.Attempts, d.NextAttemptAt, d.ResponseCode, d.LastError, deliveredAt, d.ID)
+	if err != nil {
+		log.Println("Unable to update the webhook delivery")
+		log.Println(err)
+	}
+}
+
+// Function to post a delivery to an endpoint of a seller again, with its attempts counted from zero.
+// Only deliveries given up or already delivered can be sent again.
+// It returns false when the delivery does not exist, is still pending, or there is any error encountered.
+func Redeliver(db *sql.DB, id int64, seller string, now time.Time) bool {
+	result, err := db.Exec("UPDATE `webhookdeliveries` d JOIN `webhookendpoints` e ON e.ID=d.EndpointID SET d.Status=?, d.Attempts=0, d.NextAttemptAt=?, d.LastError='', d.DeliveredAt=NULL WHERE d.ID=? AND e.Seller=? AND d.Status<>?",
+		DeliveryPending, now, id, seller, DeliveryPending)
+	if err != nil {
+		log.Println("Unable to redeliver the webhook")
+		log.Println(err)
+		return false
+	}
+	n, _ := result.RowsAffected()
+	return n == 1
+}
+
+// Function to run a query selecting deliveries with deliveryColumns.
+func queryDeliveries(db *sql.DB, query string, args ...interface{}) ([]Delivery, bool) {
+	var deliveries []Delivery
+	results, err := db.Query(query, args...)
+	if err != nil {
+		log.Println("Not able to get webhook deliveries")
+		log.Println(err)
+		return deliveries, false
+	}
+	defer results.Close()
+
+	for results.Next() {
+		d, err := scanDelivery(results)
+		if err != nil {
+			log.Println("Unable to get webhook deliveries")
+			log.Println(err)
+			return deliveries, false
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, true
+}
@@ -20,6 +20,8 @@ import (
 	"net/http"
 	"os"
 	"regexp"
+	"strconv"
+	"strings"
 
 	"github.com/joho/godotenv"
 )
@@ -27,11 +29,37 @@ import (
 //Data structure for each item
 type ItemsDetails struct {
 	Item     string  `json:"Item"`
-	Quantity int     `json:"Quantity"`
-	Cost     float64 `json:"Cost"`
+	Quantity float64 `json:"Quantity"`
+	Unit     string  `json:"Unit,omitempty"`
+	Cost     Money   `json:"Cost"`
 	Username string  `json:"Username"`
 }
 
+// Data structure for an amount of money, in minor units (e.g. cents) of its currency
+// It has the same shape as the amounts sent by the REST API, e.g. {"Amount":120,"Currency":"SGD"}
+type Money struct {
+	Amount   int64  `json:"Amount"`
+	Currency string `json:"Currency"`
+}
+
+// Currency of the costs entered in the console, which are in dollars and cents
+const costCurrency = "SGD"
+
+// This method returns the amount with its currency, e.g. "SGD 1.20"
+// Amounts in currencies other than the console currency are shown in minor units
+func (m Money) String() string {
+	if m.Currency != costCurrency {
+		return fmt.Sprintf("%s %d (minor units)", m.Currency, m.Amount)
+	}
+	sign := ""
+	amount := m.Amount
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+	return fmt.Sprintf("%s %s%d.%02d", m.Currency, sign, amount/100, amount%100)
+}
+
 // Variable used only within this package
 var sellerapikey string
 var buyerapikey string
@@ -92,8 +120,8 @@ func getItem(IN, SN string, isSeller bool) ([]ItemsDetails, bool) {
 					Items = append(Items, oneItem)
 					fmt.Println("Details of Item are : ")
 					fmt.Printf("Item: \"%s\"\n", oneItem.Item)
-					fmt.Printf("Quantity: %d\n", oneItem.Quantity)
-					fmt.Printf("Cost: %f\n", oneItem.Cost)
+					fmt.Printf("Quantity: %g %s\n", oneItem.Quantity, oneItem.Unit)
+					fmt.Printf("Cost: %s\n", oneItem.Cost)
 					fmt.Printf("Username: \"%s\"\n", oneItem.Username)
 					fmt.Println()
 					// return one item in Items array, and true for successful get
@@ -108,8 +136,8 @@ func getItem(IN, SN string, isSeller bool) ([]ItemsDetails, bool) {
 					for i, item := range Items {
 						fmt.Printf("------- %d -------\n", i+1)
 						fmt.Printf("Item: \"%s\"\n", item.Item)
-						fmt.Printf("Quantity: %d\n", item.Quantity)
-						fmt.Printf("Cost: %f\n", item.Cost)
+						fmt.Printf("Quantity: %g %s\n", item.Quantity, item.Unit)
+						fmt.Printf("Cost: %s\n", item.Cost)
 						fmt.Printf("Username: \"%s\"\n", item.Username)
 					}
 					fmt.Println()
@@ -303,8 +331,8 @@ func isValidString(t string) bool {
 }
 
 // Function to validate quantity of Item
-// If returns true if quantity is above 0
-func isValidInt(d int) bool {
+// If returns true if quantity is not negative
+func isValidQuantity(d float64) bool {
 	if d < 0 {
 		return false
 	} else {
@@ -338,13 +366,13 @@ func getStringInput() string {
 	}
 }
 
-// Function to get an input from user that is a valid int
-// If returns the integer input
-func getIntInput() int {
-	var ii int
+// Function to get an input from user that is a valid quantity, which may have decimals such as 2.5
+// If returns the quantity input
+func getQuantityInput() float64 {
+	var ii float64
 	for {
 		_, err := fmt.Scanln(&ii)
-		if err != nil || !isValidInt(ii) {
+		if err != nil || !isValidQuantity(ii) {
 			fmt.Println("Invalid input. Quantity must be non-negative. Try again")
 		} else {
 			return ii
@@ -352,20 +380,41 @@ func getIntInput() int {
 	}
 }
 
-// Function to get an input from user that is a valid float
-// If returns the float input
-func getFloatInput() float64 {
-	var fi float64
+// Function to get an input from user that is a valid cost in dollars and cents, such as 1.20
+// If returns the cost input
+func getCostInput() Money {
+	var ci string
 	for {
-		_, err := fmt.Scanln(&fi)
-		if err != nil || fi < 0.0 {
-			fmt.Println("Invalid input. Cost must not be negative. Try again")
+		_, err := fmt.Scanln(&ci)
+		cost, ok := parseCost(ci)
+		if err != nil || !ok {
+			fmt.Println("Invalid input. Cost must not be negative and have at most 2 decimal places. Try again")
 		} else {
-			return fi
+			return cost
 		}
 	}
 }
 
+// Function to convert a cost typed in dollars and cents, such as 1.20, to Money without going through float64
+// It returns false if the cost is not a non-negative number with at most 2 decimal places
+func parseCost(s string) (Money, bool) {
+	whole, frac := s, ""
+	if i := strings.Index(s, "."); i >= 0 {
+		whole, frac = s[:i], s[i+1:]
+	}
+	if (whole == "" && frac == "") || len(frac) > 2 || strings.ContainsAny(whole+frac, "+-") {
+		return Money{}, false
+	}
+	if whole == "" {
+		whole = "0"
+	}
+	amount, err := strconv.ParseInt(whole+frac+strings.Repeat("0", 2-len(frac)), 10, 64)
+	if err != nil {
+		return Money{}, false
+	}
+	return Money{Amount: amount, Currency: costCurrency}, true
+}
+
 // Function containing the console interface for the client application
 // All user options are displayed using fmt package
 // Case of title of Item is ignored when comparing Item name with mySQL database
@@ -373,8 +422,8 @@ func getFloatInput() float64 {
 func client_console() {
 	var choice int
 	var SN, IN string
-	var CT float64
-	var QT int
+	var CT Money
+	var QT float64
 	fmt.Println("Welcome to Console Application for performing CRUD operations.")
 	for {
 		fmt.Println("Please choose an option: ")
@@ -412,9 +461,9 @@ func client_console() {
 			fmt.Println("Enter the Item name:")
 			IN = getStringInput()
 			fmt.Println("Enter the Quantity:")
-			QT = getIntInput()
+			QT = getQuantityInput()
 			fmt.Println("Enter the Cost:")
-			CT = getFloatInput()
+			CT = getCostInput()
 
 			var si ItemsDetails
 			si.Item = IN
@@ -437,9 +486,9 @@ func client_console() {
 			fmt.Println("Enter the Item name:")
 			si.Item = getStringInput()
 			fmt.Println("Enter the Quantity:")
-			si.Quantity = getIntInput()
+			si.Quantity = getQuantityInput()
 			fmt.Println("Enter the Cost:")
-			si.Cost = getFloatInput()
+			si.Cost = getCostInput()
 			updateItem(IN, SN, true, si)
 
 		case 5:
@@ -466,9 +515,9 @@ func client_console() {
 			fmt.Println("Enter the Item name:")
 			IN = getStringInput()
 			fmt.Println("Enter the Quantity:")
-			QT = getIntInput()
+			QT = getQuantityInput()
 			fmt.Println("Enter the Cost:")
-			CT = getFloatInput()
+			CT = getCostInput()
 
 			var si ItemsDetails
 			si.Item = IN
@@ -491,9 +540,9 @@ func client_console() {
 			fmt.Println("Enter the Item name:")
 			si.Item = getStringInput()
 			fmt.Println("Enter the Quantity:")
-			si.Quantity = getIntInput()
+			si.Quantity = getQuantityInput()
 			fmt.Println("Enter the Cost:")
-			si.Cost = getFloatInput()
+			si.Cost = getCostInput()
 			updateItem(IN, SN, false, si)
 
 		case 10:
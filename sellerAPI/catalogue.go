@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// Function to generate catalogue page /api/v1/catalogue, containing all peel types embedded in JSON format.
+// It can be read with the buyer, seller or admin api key.
+func catalogue_all(w http.ResponseWriter, r *http.Request) {
+	if !validKey(w, r, buyerapikey, sellerapikey, adminapikey) {
+		log.Println("API key not valid for catalogue")
+		return
+	}
+
+	pt, ok := GetPeelTypes(sdb)
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("500 - Unable to get catalogue"))
+		return
+	}
+	// returns all the peel types in JSON
+	json.NewEncoder(w).Encode(pt)
+}
+
+// Function to generate catalogue page for /api/v1/catalogue/{peelname}
+// It handles GET with any api key, and POST/PUT/DELETE with the admin api key only.
+// It generates the peel type information in response to request made, embedded in JSON format.
+// It also generates headers for each request, depending on the status of each operation.
+func catalogue_editpeeltype(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	PN := params["peelname"]
+
+	if r.Method == "GET" {
+		if !validKey(w, r, buyerapikey, sellerapikey, adminapikey) {
+			log.Println("API key not valid for catalogue")
+			return
+		}
+		p, ok := GetAPeelType(sdb, PN)
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte("404 - No peel type found"))
+		} else {
+			json.NewEncoder(w).Encode(p)
+		}
+		return
+	}
+
+	if !validKey(w, r, adminapikey) {
+		log.Println("Admin API key not valid")
+		return
+	}
+
+	if r.Method == "DELETE" {
+		_, ok := GetAPeelType(sdb, PN)
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte("404 - No peel type found"))
+		} else if PeelTypeInUse(sdb, PN) {
+			w.WriteHeader(http.StatusConflict)
+			w.Write([]byte("409 - Peel type has listings and cannot be deleted"))
+		} else if !DeletePeelType(sdb, PN) {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("500 - Unable to delete peel type"))
+		} else {
+			w.WriteHeader(http.StatusAccepted)
+			w.Write([]byte("202 - Peel type deleted: " + PN))
+		}
+		return
+	}
+
+	if r.Header.Get("Content-type") != "application/json" {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write([]byte("422 - Please supply peel type information in JSON format"))
+		return
+	}
+
+	var p PeelType
+	reqBody, err := ioutil.ReadAll(r.Body)
+	if err != nil || json.Unmarshal(reqBody, &p) != nil || p.Name != PN || p.DisplayName == "" || p.Image == "" || p.Unit == "" {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write([]byte("422 - Please supply correct peel type information in JSON format"))
+		return
+	}
+
+	_, exists := GetAPeelType(sdb, PN)
+
+	// POST is for creating a new peel type
+	if r.Method == "POST" {
+		if exists {
+			w.WriteHeader(http.StatusConflict)
+			w.Write([]byte("409 - Duplicate peel type"))
+		} else if !InsertPeelType(sdb, p) {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("500 - Unable to add peel type"))
+		} else {
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte("201 - Peel type added: " + PN))
+		}
+	}
+
+	// PUT is for updating an existing peel type
+	if r.Method == "PUT" {
+		if !exists {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte("404 - No peel type found"))
+		} else if !EditPeelType(sdb, PN, p) {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("500 - Unable to update peel type"))
+		} else {
+			w.WriteHeader(http.StatusAccepted)
+			w.Write([]byte("202 - Peel type updated: " + PN))
+		}
+	}
+}
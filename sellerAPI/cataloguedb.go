@@ -0,0 +1,116 @@
+package main
+
+import (
+	"database/sql"
+	"log"
+
+	_ "github.com/go-sql-driver/mysql"
+)
+
+// Data structure for each type of fruit peel in the catalogue
+type PeelType struct {
+	Name        string `json:"Name"`
+	DisplayName string `json:"DisplayName"`
+	Image       string `json:"Image"`
+	Unit        string `json:"Unit"`
+	Description string `json:"Description"`
+	Nutrients   string `json:"Nutrients"`
+}
+
+//-----------------------------------------------------------------------
+// Functions for catalogue
+//-----------------------------------------------------------------------
+// Function to get all peel types in the catalogue from the MYSQL database.
+// The function takes in the handle to the database.
+// It returns all the peel types as an array of type PeelType, sorted by name.
+// It returns false when there is any error encountered and retrieval of records is not successful.
+func GetPeelTypes(db *sql.DB) ([]PeelType, bool) {
+	var pt []PeelType
+	results, err := db.Query("SELECT Name, DisplayName, Image, Unit, Description, Nutrients FROM peeltypes ORDER BY Name")
+	if err != nil {
+		log.Println("Not able to get catalogue")
+		log.Println(err)
+		return pt, false
+	}
+	defer results.Close()
+
+	for results.Next() {
+		var p PeelType
+		err = results.Scan(&p.Name, &p.DisplayName, &p.Image, &p.Unit, &p.Description, &p.Nutrients)
+		if err != nil {
+			log.Println("Unable to get catalogue records")
+			log.Println(err)
+			return pt, false
+		}
+		pt = append(pt, p)
+	}
+	return pt, true
+}
+
+// Function to get one peel type from the MYSQL database.
+// It takes in the handle to the database and the name of the peel type.
+// It returns false when the peel type is not in the catalogue, or there is any error encountered.
+func GetAPeelType(db *sql.DB, name string) (PeelType, bool) {
+	var p PeelType
+	err := db.QueryRow("SELECT Name, DisplayName, Image, Unit, Description, Nutrients FROM peeltypes WHERE Name=?", name).
+		Scan(&p.Name, &p.DisplayName, &p.Image, &p.Unit, &p.Description, &p.Nutrients)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Println("Unable to get the peel type")
+			log.Println(err)
+		}
+		return p, false
+	}
+	return p, true
+}
+
+// Function to insert one peel type into the MYSQL database.
+// It returns false when there is any error encountered, and peel type is not inserted successfully.
+func InsertPeelType(db *sql.DB, p PeelType) bool {
+	_, err := db.Exec("INSERT INTO peeltypes (Name, DisplayName, Image, Unit, Description, Nutrients) VALUES (?, ?, ?, ?, ?, ?)",
+		p.Name, p.DisplayName, p.Image, p.Unit, p.Description, p.Nutrients)
+	if err != nil {
+		log.Println("Unable to insert the peel type")
+		log.Println(err)
+		return false
+	}
+	return true
+}
+
+// Function to update an existing peel type in the MYSQL database.
+// The name of a peel type cannot be changed, as listings refer to it.
+// It returns false when there is any error encountered, and peel type is not updated successfully.
+func EditPeelType(db *sql.DB, name string, p PeelType) bool {
+	_, err := db.Exec("UPDATE peeltypes SET DisplayName=?, Image=?, Unit=?, Description=?, Nutrients=? WHERE Name=?",
+		p.DisplayName, p.Image, p.Unit, p.Description, p.Nutrients, name)
+	if err != nil {
+		log.Println("Unable to edit the peel type")
+		log.Println(err)
+		return false
+	}
+	return true
+}
+
+// Function to delete a peel type from the MYSQL database.
+// It returns false when there is any error encountered, and peel type is not deleted successfully.
+func DeletePeelType(db *sql.DB, name string) bool {
+	_, err := db.Exec("DELETE FROM peeltypes WHERE Name=?", name)
+	if err != nil {
+		log.Println("Unable to delete the peel type")
+		log.Println(err)
+		return false
+	}
+	return true
+}
+
+// Function to check if a peel type has any listings, a peel type in use cannot be deleted.
+func PeelTypeInUse(db *sql.DB, name string) bool {
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM itemsdetails WHERE Item=?", name).Scan(&count)
+	if err != nil {
+		log.Println("Unable to count listings of peel type")
+		log.Println(err)
+		return true
+	}
+	return count > 0
+}
@@ -0,0 +1,100 @@
+-- Schema for sellerAPIdb, the database behind the Seller REST API.
+-- Statements are listed in the order the tables were introduced, run them on a fresh database
+-- or run only the newer statements on an existing database.
+
+CREATE DATABASE IF NOT EXISTS sellerAPIdb;
+USE sellerAPIdb;
+
+-- Items listed for sale by each seller
+CREATE TABLE IF NOT EXISTS itemsdetails (
+    Item VARCHAR(30) NOT NULL,
+    Quantity INT NOT NULL,
+    Cost FLOAT NOT NULL,
+    Username VARCHAR(30) NOT NULL
+);
+
+-- Catalogue of fruit peel types that can be listed, managed by admin
+CREATE TABLE IF NOT EXISTS peeltypes (
+    Name VARCHAR(30) NOT NULL PRIMARY KEY,
+    DisplayName VARCHAR(50) NOT NULL,
+    Image VARCHAR(100) NOT NULL,
+    Unit VARCHAR(10) NOT NULL,
+    Description VARCHAR(500) NOT NULL DEFAULT '',
+    Nutrients VARCHAR(500) NOT NULL DEFAULT ''
+);
+
+INSERT IGNORE INTO peeltypes (Name, DisplayName, Image, Unit, Description, Nutrients) VALUES
+    ('apple', 'Apple', '/assets/apple.jpg', 'kg', 'Apple peel from juicing and baking', 'Fibre, Vitamin C, Quercetin'),
+    ('avacado', 'Avacado', '/assets/avacado.JPG', 'kg', 'Avocado skin', 'Fibre, Antioxidants'),
+    ('banana', 'Banana', '/assets/banana.jpg', 'kg', 'Banana peel', 'Potassium, Fibre, Vitamin B6'),
+    ('grapefruit', 'Grapefruit', '/assets/grapefruit.jpg', 'kg', 'Grapefruit peel', 'Vitamin C, Pectin'),
+    ('kiwi', 'Kiwi', '/assets/kiwi.JPG', 'kg', 'Kiwi skin', 'Fibre, Folate, Vitamin E'),
+    ('lemon', 'Lemon', '/assets/lemon.jpg', 'kg', 'Lemon peel from juice shops', 'Vitamin C, Limonene, Pectin'),
+    ('orange', 'Orange', '/assets/orange.jpg', 'kg', 'Orange peel from juice shops', 'Vitamin C, Limonene, Pectin'),
+    ('watermelon', 'Watermelon', '/assets/watermelon.JPG', 'kg', 'Watermelon rind', 'Citrulline, Fibre');
+
+-- Photo of the actual peel batch uploaded by the seller, empty for the catalogue image
+ALTER TABLE itemsdetails ADD COLUMN Photo VARCHAR(100) NOT NULL DEFAULT '';
+
+-- Freshness of each listing, existing listings are given a week from now
+-- All times are stored in UTC, as written by the seller API, whatever the time zone of the database server
+ALTER TABLE itemsdetails ADD COLUMN CollectedOn DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP;
+ALTER TABLE itemsdetails ADD COLUMN ExpiresAt DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP;
+UPDATE itemsdetails SET CollectedOn = UTC_TIMESTAMP(), ExpiresAt = DATE_ADD(UTC_TIMESTAMP(), INTERVAL 7 DAY);
+
+-- Events about listings, read by the main application to notify sellers and buyers
+CREATE TABLE IF NOT EXISTS listingevents (
+    ID BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    Kind VARCHAR(20) NOT NULL,
+    Username VARCHAR(30) NOT NULL,
+    Item VARCHAR(30) NOT NULL,
+    Payload TEXT NOT NULL,
+    CreatedAt DATETIME NOT NULL
+);
+
+-- Each lot of peel gets its own ID, so a seller can list several lots of the same peel type
+ALTER TABLE itemsdetails ADD COLUMN ID BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY FIRST;
+CREATE INDEX itemsdetails_seller_item ON itemsdetails (Username, Item);
+
+-- Decimal quantities, with the unit of measure and order sizes of each lot
+ALTER TABLE itemsdetails MODIFY COLUMN Quantity DECIMAL(10,3) NOT NULL;
+ALTER TABLE itemsdetails ADD COLUMN Unit VARCHAR(10) NOT NULL DEFAULT 'kg';
+ALTER TABLE itemsdetails ADD COLUMN MinOrder DECIMAL(10,3) NOT NULL DEFAULT 1;
+ALTER TABLE itemsdetails ADD COLUMN Step DECIMAL(10,3) NOT NULL DEFAULT 1;
+
+-- Prices are kept exactly in minor units of their currency, e.g. 120 SGD cents, instead of a FLOAT
+ALTER TABLE itemsdetails ADD COLUMN CostAmount BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE itemsdetails ADD COLUMN CostCurrency CHAR(3) NOT NULL DEFAULT 'SGD';
+UPDATE itemsdetails SET CostAmount = ROUND(Cost * 100), CostCurrency = 'SGD';
+ALTER TABLE itemsdetails DROP COLUMN Cost;
+
+-- Quantity price breaks of each lot, removed with their lot
+CREATE TABLE IF NOT EXISTS pricetiers (
+    ItemID BIGINT NOT NULL,
+    MinQuantity DECIMAL(10,3) NOT NULL,
+    CostAmount BIGINT NOT NULL,
+    CostCurrency CHAR(3) NOT NULL,
+    PRIMARY KEY (ItemID, MinQuantity),
+    FOREIGN KEY (ItemID) REFERENCES itemsdetails (ID) ON DELETE CASCADE
+);
+
+-- Quantity at or below which the seller is told a lot is running low, 0 for no warning
+ALTER TABLE itemsdetails ADD COLUMN LowStock DECIMAL(10,3) NOT NULL DEFAULT 0;
+
+-- Public profile of each seller, searched along with their listings
+CREATE TABLE IF NOT EXISTS sellerprofiles (
+    Username VARCHAR(30) NOT NULL PRIMARY KEY,
+    Name VARCHAR(50) NOT NULL,
+    Location VARCHAR(100) NOT NULL DEFAULT '',
+    UpdatedAt DATETIME NOT NULL
+);
+
+-- Centre of the area of each seller, used to work out how far listings are from buyers, NULL when not known
+ALTER TABLE sellerprofiles ADD COLUMN Latitude DOUBLE NULL;
+ALTER TABLE sellerprofiles ADD COLUMN Longitude DOUBLE NULL;
+
+-- Sellers whose lots are hidden from buyers while their account waits to be deleted
+CREATE TABLE IF NOT EXISTS hiddensellers (
+    Username VARCHAR(30) NOT NULL PRIMARY KEY,
+    HiddenAt DATETIME NOT NULL
+);
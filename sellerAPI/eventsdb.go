@@ -0,0 +1,180 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+)
+
+// Kinds of listing events
+const (
+	// Listing removed from sale because it passed its expiry time
+	EventExpired = "expired"
+	// Quantity of a listing fell to its low stock warning or below
+	EventLowStock = "lowstock"
+	// Quantity of a listing fell to zero, the listing is kept for the seller to restock
+	EventSoldOut = "soldout"
+	// Price of a listing was lowered by its seller
+	EventPriceDrop = "pricedrop"
+	// New listing put on sale by its seller
+	EventListed = "listed"
+	// Listing that had sold out was given more quantity by its seller
+	EventRestocked = "restocked"
+)
+
+// Data structure for each event about a listing, the item is a snapshot of the listing when the event happened
+type ListingEvent struct {
+	ID        int64        `json:"ID"`
+	Kind      string       `json:"Kind"`
+	Item      ItemsDetails `json:"Item"`
+	CreatedAt time.Time    `json:"CreatedAt"`
+}
+
+// Interface satisfied by both *sql.DB and *sql.Tx, so events can be recorded inside a transaction
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+//-----------------------------------------------------------------------
+// Functions for listing events
+//-----------------------------------------------------------------------
+// Function to record an event about a listing in the MYSQL database.
+// The function takes in a handle to the database or a transaction, the kind of event and the listing.
+// It returns false when there is any error encountered, and the event is not recorded.
+func InsertEvent(db execer, kind string, item ItemsDetails) bool {
+	payload, err := json.Marshal(item)
+	if err != nil {
+		log.Println(err)
+		return false
+	}
+	_, err = db.Exec("INSERT INTO listingevents (Kind, Username, Item, Payload, CreatedAt) VALUES (?, ?, ?, ?, ?)",
+		kind, item.Username, item.Item, string(payload), time.Now().UTC())
+	if err != nil {
+		log.Println("Unable to insert the event")
+		log.Println(err)
+		return false
+	}
+	return true
+}
+
+// Function to record the events of a seller editing a listing, in the transaction storing the edit.
+// Buyers with the lot in their cart or a saved search for it are told when its price drops or it is back in stock.
+// The seller is not told about the stock they changed themselves, so no sold out or low stock event is recorded.
+// It returns false when there is any error encountered, and the events are not recorded.
+func InsertEditEvents(db execer, before, after ItemsDetails) bool {
+	if after.Cost.Currency == before.Cost.Currency && after.Cost.Amount < before.Cost.Amount && !InsertEvent(db, EventPriceDrop, after) {
+		return false
+	}
+	if stockEvent(before, after) == EventRestocked && !InsertEvent(db, EventRestocked, after) {
+		return false
+	}
+	return true
+}
+
+// Function to add a new lot in the MYSQL database, with the listed event about it.
+// The function takes in a handle to a transaction, so the lot is not added when its event cannot be recorded.
+// It returns the ID of the new lot, and false when there is any error encountered.
+func InsertListedRecord(db execer, sid ItemsDetails) (int64, bool) {
+	id, ok := InsertRecordSeller(db, sid)
+	if !ok {
+		return 0, false
+	}
+	sid.ID = id
+	return id, InsertEvent(db, EventListed, sid)
+}
+
+// Function to get the events recorded after the event with ID after, oldest first.
+// At most limit events are returned, the caller asks again with the last ID to get more.
+// It returns false when there is any error encountered and retrieval of events is not successful.
+func GetEvents(db *sql.DB, after int64, limit int) ([]ListingEvent, bool) {
+	events := []ListingEvent{}
+	results, err := db.Query("SELECT ID, Kind, Payload, CreatedAt FROM listingevents WHERE ID > ? ORDER BY ID LIMIT ?", after, limit)
+	if err != nil {
+		log.Println("Not able to get events")
+		log.Println(err)
+		return events, false
+	}
+	defer results.Close()
+
+	for results.Next() {
+		var e ListingEvent
+		var payload string
+		if err := results.Scan(&e.ID, &e.Kind, &payload, &e.CreatedAt); err != nil {
+			log.Println("Unable to get events")
+			log.Println(err)
+			return events, false
+		}
+		if err := json.Unmarshal([]byte(payload), &e.Item); err != nil {
+			log.Println(err)
+			return events, false
+		}
+		events = append(events, e)
+	}
+	return events, true
+}
+
+// Function to get the kind of event recorded when the quantity of a listing changes from before to after.
+// An event is only recorded when the quantity crosses the low stock warning, reaches zero or a sold out listing
+// gets quantity again, not on every sale.
+// It returns an empty string when no event is needed.
+func stockEvent(before, after ItemsDetails) string {
+	switch {
+	case before.Quantity <= 0 && after.Quantity > 0:
+		return EventRestocked
+	case after.Quantity <= 0 && before.Quantity > 0:
+		return EventSoldOut
+	case after.LowStock > 0 && after.Quantity > 0 && after.Quantity <= after.LowStock && before.Quantity > after.LowStock:
+		return EventLowStock
+	}
+	return ""
+}
+
+// Function to remove the listings that expired before now from sale.
+// Each expired listing is deleted and an expired event is recorded, in one transaction.
+// It returns the expired listings, and false when there is any error encountered.
+func ExpireListings(db *sql.DB, now time.Time) ([]ItemsDetails, bool) {
+	var expired []ItemsDetails
+
+	tx, err := db.Begin()
+	if err != nil {
+		log.Println(err)
+		return expired, false
+	}
+	defer tx.Rollback()
+
+	results, err := tx.Query("SELECT "+itemColumns+" FROM itemsdetails WHERE ExpiresAt <= ? FOR UPDATE", now.UTC())
+	if err != nil {
+		log.Println("Unable to get expired listings")
+		log.Println(err)
+		return expired, false
+	}
+	for results.Next() {
+		si, err := scanItem(results)
+		if err != nil {
+			log.Println(err)
+			results.Close()
+			return nil, false
+		}
+		expired = append(expired, si)
+	}
+	results.Close()
+
+	for _, si := range expired {
+		if _, err := tx.Exec("DELETE FROM itemsdetails WHERE ID=?", si.ID); err != nil {
+			log.Println("Unable to delete expired listing")
+			log.Println(err)
+			return nil, false
+		}
+		if !InsertEvent(tx, EventExpired, si) {
+			return nil, false
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		log.Println(err)
+		return nil, false
+	}
+	return expired, true
+}
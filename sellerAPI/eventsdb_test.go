@@ -0,0 +1,131 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// fakeEvents records the kinds of the events inserted through it, failing when fail is set
+// Other statements, such as adding a lot, are recorded as "lot" and give the new lot ID 42, failing when failLot is set.
+type fakeEvents struct {
+	fail    bool
+	failLot bool
+	kinds   []string
+}
+
+// fakeResult is the result of a statement run through fakeEvents
+type fakeResult struct{}
+
+func (fakeResult) LastInsertId() (int64, error) { return 42, nil }
+func (fakeResult) RowsAffected() (int64, error) { return 1, nil }
+
+// This method records the kind of an event inserted in the listingevents table
+func (f *fakeEvents) Exec(query string, args ...interface{}) (sql.Result, error) {
+	if !strings.HasPrefix(query, "INSERT INTO listingevents") {
+		if f.failLot {
+			return nil, errors.New("database is not available")
+		}
+		f.kinds = append(f.kinds, "lot")
+		return fakeResult{}, nil
+	}
+	if f.fail {
+		return nil, errors.New("database is not available")
+	}
+	var item ItemsDetails
+	json.Unmarshal([]byte(args[3].(string)), &item)
+	f.kinds = append(f.kinds, fmt.Sprintf("%s %d", args[0], item.ID))
+	return nil, nil
+}
+
+func TestStockEvent(t *testing.T) {
+	tests := []struct {
+		name     string
+		before   float64
+		after    float64
+		lowStock float64
+		want     string
+	}{
+		{"restocked", 0, 5, 0, EventRestocked},
+		{"restocked from below zero", -1, 2, 3, EventRestocked},
+		{"sold out", 2, 0, 0, EventSoldOut},
+		{"running low", 10, 2, 3, EventLowStock},
+		{"already low", 3, 2, 3, ""},
+		{"no low stock level", 10, 2, 0, ""},
+		{"more stock", 5, 10, 3, ""},
+		{"still sold out", 0, 0, 3, ""},
+	}
+	for _, test := range tests {
+		before := ItemsDetails{Quantity: test.before, LowStock: test.lowStock}
+		after := ItemsDetails{Quantity: test.after, LowStock: test.lowStock}
+		if got := stockEvent(before, after); got != test.want {
+			t.Errorf("%s: stockEvent(%v, %v) = %q, want %q", test.name, test.before, test.after, got, test.want)
+		}
+	}
+}
+
+func TestInsertEditEvents(t *testing.T) {
+	lot := ItemsDetails{ID: 7, Username: "seller1", Item: "Orange", Quantity: 5, Cost: Money{Amount: 200, Currency: "SGD"}}
+	edited := func(quantity float64, amount int64, currency string) ItemsDetails {
+		after := lot
+		after.Quantity = quantity
+		after.Cost = Money{Amount: amount, Currency: currency}
+		return after
+	}
+	soldOut := lot
+	soldOut.Quantity = 0
+
+	tests := []struct {
+		name   string
+		before ItemsDetails
+		after  ItemsDetails
+		fail   bool
+		want   []string
+		wantOK bool
+	}{
+		{"no change", lot, lot, false, nil, true},
+		{"price drop", lot, edited(5, 150, "SGD"), false, []string{"pricedrop 7"}, true},
+		{"price rise", lot, edited(5, 250, "SGD"), false, nil, true},
+		{"other currency", lot, edited(5, 100, "MYR"), false, nil, true},
+		{"restocked", soldOut, edited(8, 200, "SGD"), false, []string{"restocked 7"}, true},
+		{"restocked cheaper", soldOut, edited(8, 150, "SGD"), false, []string{"pricedrop 7", "restocked 7"}, true},
+		{"sold out by the seller", lot, edited(0, 200, "SGD"), false, nil, true},
+		{"database error", lot, edited(5, 150, "SGD"), true, nil, false},
+	}
+	for _, test := range tests {
+		events := &fakeEvents{fail: test.fail}
+		if ok := InsertEditEvents(events, test.before, test.after); ok != test.wantOK {
+			t.Errorf("%s: InsertEditEvents = %v, want %v", test.name, ok, test.wantOK)
+		}
+		if fmt.Sprint(events.kinds) != fmt.Sprint(test.want) {
+			t.Errorf("%s: events = %v, want %v", test.name, events.kinds, test.want)
+		}
+	}
+}
+
+func TestInsertListedRecord(t *testing.T) {
+	lot := ItemsDetails{Username: "seller1", Item: "Orange", Quantity: 5, Cost: Money{Amount: 200, Currency: "SGD"}}
+	tests := []struct {
+		name   string
+		events *fakeEvents
+		want   []string
+		wantID int64
+		wantOK bool
+	}{
+		{"listed", &fakeEvents{}, []string{"lot", "listed 42"}, 42, true},
+		{"event not recorded", &fakeEvents{fail: true}, []string{"lot"}, 42, false},
+		{"lot not added", &fakeEvents{failLot: true}, nil, 0, false},
+	}
+	for _, test := range tests {
+		id, ok := InsertListedRecord(test.events, lot)
+		if id != test.wantID || ok != test.wantOK {
+			t.Errorf("%s: InsertListedRecord = %d, %v, want %d, %v", test.name, id, ok, test.wantID, test.wantOK)
+		}
+		if fmt.Sprint(test.events.kinds) != fmt.Sprint(test.want) {
+			t.Errorf("%s: statements = %v, want %v", test.name, test.events.kinds, test.want)
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Largest number of listings returned in one page
+const maxPageSize = 100
+
+// Header of the response to /api/v1/buyer holding the cursor of the next page, absent on the last page
+const nextCursorHeader = "X-Next-Cursor"
+
+// Distance in km sorting listings whose seller has no known location after all others
+const unknownDistance = 1000000.0
+
+// Radius of the earth in km, the same as the main application uses so both work out the same distances
+const earthRadius = 6371.0
+
+// Column a sort order of listings is on, and whether it is descending
+// Listings with the same value are returned in order of ID, so each page follows on from the last.
+// DistanceKey is not a column, listings are sorted by distance once they are read as distances are worked out then.
+// A sort ByCurrency groups the listings by currency first, so prices in different currencies are never compared.
+type listingSort struct {
+	Column     string
+	Desc       bool
+	ByCurrency bool
+}
+
+// Sort orders of listings for buyers, given with ?sort=
+// Without a sort, listings are returned in the order they were listed.
+var listingSorts = map[string]listingSort{
+	"":           {"ID", false, false},
+	"newest":     {"ID", true, false},
+	"price":      {"CostAmount", false, true},
+	"price_desc": {"CostAmount", true, true},
+	"freshest":   {"CollectedOn", true, false},
+	"expiring":   {"ExpiresAt", false, false},
+	"quantity":   {"Quantity", true, false},
+	"distance":   {"DistanceKey", false, false},
+}
+
+// Data structure for the filters, sort order and page of listings asked for by a buyer
+// Prices are in minor units of Currency, and each filter is not applied when it is zero or empty.
+// Limit is the number of listings in a page, 0 for all listings.
+type ListingQuery struct {
+	Item        string
+	Seller      string
+	Currency    string
+	MinPrice    int64
+	MaxPrice    int64
+	MinQuantity float64
+	MaxAge      int
+	Sort        string
+	Limit       int
+	After       *listingCursor
+
+	// Location of the buyer in degrees to work out distances from, nil when not given, and the furthest distance in km
+	Latitude    *float64
+	Longitude   *float64
+	MaxDistance float64
+}
+
+// Data structure for the position after the last listing of a page, sent to buyers as an opaque string
+// Key is the value of the sort column of that listing, and Currency its currency for the sorts ByCurrency.
+type listingCursor struct {
+	Sort     string `json:"S"`
+	Key      string `json:"K"`
+	Currency string `json:"C,omitempty"`
+	ID       int64  `json:"I"`
+}
+
+// Function to read the filters, sort order and page from the query parameters of /api/v1/buyer:
+// item, seller, currency (default SGD), minprice and maxprice in major units e.g. 1.50, minquantity,
+// maxage in days since the peel was collected, lat and lng of the buyer, maxdistance in km from them,
+// sort, limit and cursor. Sorting by distance and maxdistance need lat and lng.
+// It returns an error describing the first parameter that is not valid.
+func parseListingQuery(r *http.Request) (ListingQuery, error) {
+	v := r.URL.Query()
+	q := ListingQuery{
+		Item:     v.Get("item"),
+		Seller:   v.Get("seller"),
+		Currency: strings.ToUpper(v.Get("currency")),
+		Sort:     v.Get("sort"),
+	}
+	if q.Currency == "" {
+		q.Currency = defaultCurrency
+	}
+	if _, ok := minorUnits[q.Currency]; !ok {
+		return q, errors.New("unknown currency")
+	}
+	if _, ok := listingSorts[q.Sort]; !ok {
+		return q, errors.New("unknown sort, use newest, price, price_desc, freshest, expiring, quantity or distance")
+	}
+
+	for name, price := range map[string]*int64{"minprice": &q.MinPrice, "maxprice": &q.MaxPrice} {
+		if s := v.Get(name); s != "" {
+			f, err := strconv.ParseFloat(s, 64)
+			if err != nil || f < 0 {
+				return q, errors.New(name + " must be a price such as 1.50")
+			}
+			*price = moneyFromFloat(f, q.Currency).Amount
+		}
+	}
+	if s := v.Get("minquantity"); s != "" {
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil || f < 0 {
+			return q, errors.New("minquantity must be a quantity such as 5")
+		}
+		q.MinQuantity = f
+	}
+	for name, n := range map[string]*int{"maxage": &q.MaxAge, "limit": &q.Limit} {
+		if s := v.Get(name); s != "" {
+			i, err := strconv.Atoi(s)
+			if err != nil || i < 0 {
+				return q, errors.New(name + " must be a whole number")
+			}
+			*n = i
+		}
+	}
+	if q.Limit > maxPageSize {
+		q.Limit = maxPageSize
+	}
+
+	if v.Get("lat") != "" || v.Get("lng") != "" {
+		lat, errLat := strconv.ParseFloat(v.Get("lat"), 64)
+		lng, errLng := strconv.ParseFloat(v.Get("lng"), 64)
+		if errLat != nil || errLng != nil || !validCoordinates(&lat, &lng) {
+			return q, errors.New("lat and lng must be a location such as 1.3521 and 103.8198")
+		}
+		q.Latitude, q.Longitude = &lat, &lng
+	}
+	if s := v.Get("maxdistance"); s != "" {
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil || f < 0 {
+			return q, errors.New("maxdistance must be a distance in km such as 5")
+		}
+		q.MaxDistance = f
+	}
+	if q.Latitude == nil && (q.MaxDistance > 0 || q.Sort == "distance") {
+		return q, errors.New("lat and lng are needed to filter or sort by distance")
+	}
+
+	if s := v.Get("cursor"); s != "" {
+		var c listingCursor
+		data, err := base64.RawURLEncoding.DecodeString(s)
+		if err != nil || json.Unmarshal(data, &c) != nil || c.Sort != q.Sort || (listingSorts[q.Sort].ByCurrency && c.Currency == "") {
+			return q, errors.New("cursor is not valid for this sort")
+		}
+		q.After = &c
+	}
+	return q, nil
+}
+
+// Function to encode the cursor of the page after a listing
+func encodeCursor(c listingCursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// Function to get the value of the sort column of a listing, as kept in the cursor
+func sortKey(column string, si ItemsDetails) string {
+	switch column {
+	case "CostAmount":
+		return strconv.FormatInt(si.Cost.Amount, 10)
+	case "CollectedOn":
+		return si.CollectedOn.UTC().Format(timeFormat)
+	case "ExpiresAt":
+		return si.ExpiresAt.UTC().Format(timeFormat)
+	case "Quantity":
+		return strconv.FormatFloat(si.Quantity, 'f', -1, 64)
+	case "DistanceKey":
+		return strconv.FormatFloat(distanceKey(si), 'g', -1, 64)
+	}
+	return strconv.FormatInt(si.ID, 10)
+}
+
+// Function to get the distance a listing is sorted by, unknownDistance when the location of its seller is not known
+func distanceKey(si ItemsDetails) float64 {
+	if si.Distance == nil {
+		return unknownDistance
+	}
+	return *si.Distance
+}
+
+// Function to sort listings by distance, nearest first and in order of ID for the same distance,
+// keeping only those after the cursor when there is one.
+func sortByDistance(sd []ItemsDetails, after *listingCursor) []ItemsDetails {
+	sort.Slice(sd, func(i, j int) bool {
+		if a, b := distanceKey(sd[i]), distanceKey(sd[j]); a != b {
+			return a < b
+		}
+		return sd[i].ID < sd[j].ID
+	})
+	if after == nil {
+		return sd
+	}
+	key, err := strconv.ParseFloat(after.Key, 64)
+	if err != nil {
+		return nil
+	}
+	i := sort.Search(len(sd), func(i int) bool {
+		d := distanceKey(sd[i])
+		return d > key || (d == key && sd[i].ID > after.ID)
+	})
+	return sd[i:]
+}
+
+// Function to get the distance between two places in km, as the crow flies, given their latitude and longitude in degrees
+// It works out distances the same way as the location package of the main application.
+func distanceKm(lat1, lng1, lat2, lng2 float64) float64 {
+	rad := math.Pi / 180
+	dLat := (lat2 - lat1) * rad
+	dLng := (lng2 - lng1) * rad
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * earthRadius * math.Asin(math.Sqrt(h))
+}
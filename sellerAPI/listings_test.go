@@ -0,0 +1,144 @@
+package main
+
+import (
+	"math"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestSortClauses(t *testing.T) {
+	cursor := &listingCursor{Key: "150", Currency: "SGD", ID: 7}
+	tests := []struct {
+		name      string
+		sort      string
+		cursor    *listingCursor
+		wantOrder string
+		wantAfter string
+		wantArgs  []interface{}
+	}{
+		{"listed order", "", nil, "ID ASC", "", nil},
+		{"newest after cursor", "newest", cursor, "ID DESC", "ID < ?", []interface{}{int64(7)}},
+		{"quantity", "quantity", nil, "Quantity DESC, ID ASC", "", nil},
+		{"expiring after cursor", "expiring", cursor, "ExpiresAt ASC, ID ASC",
+			"(ExpiresAt > ? OR (ExpiresAt = ? AND ID > ?))", []interface{}{"150", "150", int64(7)}},
+		{"price by currency", "price", nil, "CostCurrency ASC, CostAmount ASC, ID ASC", "", nil},
+		{"price after cursor", "price", cursor, "CostCurrency ASC, CostAmount ASC, ID ASC",
+			"(CostCurrency > ? OR (CostCurrency = ? AND (CostAmount > ? OR (CostAmount = ? AND ID > ?))))",
+			[]interface{}{"SGD", "SGD", "150", "150", int64(7)}},
+		{"price descending after cursor", "price_desc", cursor, "CostCurrency ASC, CostAmount DESC, ID ASC",
+			"(CostCurrency > ? OR (CostCurrency = ? AND (CostAmount < ? OR (CostAmount = ? AND ID > ?))))",
+			[]interface{}{"SGD", "SGD", "150", "150", int64(7)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order, after, args := sortClauses(listingSorts[tt.sort], tt.cursor)
+			if order != tt.wantOrder {
+				t.Errorf("order = %q, want %q", order, tt.wantOrder)
+			}
+			if after != tt.wantAfter {
+				t.Errorf("after = %q, want %q", after, tt.wantAfter)
+			}
+			if !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("args = %v, want %v", args, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestParseListingCursor(t *testing.T) {
+	tests := []struct {
+		name    string
+		sort    string
+		cursor  listingCursor
+		wantErr bool
+	}{
+		{"same sort", "newest", listingCursor{Sort: "newest", Key: "9", ID: 9}, false},
+		{"price with currency", "price", listingCursor{Sort: "price", Key: "150", Currency: "SGD", ID: 7}, false},
+		{"price without currency", "price", listingCursor{Sort: "price", Key: "150", ID: 7}, true},
+		{"other sort", "price", listingCursor{Sort: "newest", Key: "9", ID: 9}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/api/v1/buyer?sort="+tt.sort+"&cursor="+encodeCursor(tt.cursor), nil)
+			q, err := parseListingQuery(r)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil && !reflect.DeepEqual(*q.After, tt.cursor) {
+				t.Errorf("cursor = %+v, want %+v", *q.After, tt.cursor)
+			}
+		})
+	}
+	if _, err := parseListingQuery(httptest.NewRequest("GET", "/api/v1/buyer?sort=price&cursor=not-base64!", nil)); err == nil {
+		t.Error("a cursor that is not base64 was accepted")
+	}
+}
+
+// The distances are the same as those the location package of the main application is tested with
+func TestDistanceKm(t *testing.T) {
+	tests := []struct {
+		name                   string
+		lat1, lng1, lat2, lng2 float64
+		want                   float64
+	}{
+		{"same point", 1.3521, 103.8198, 1.3521, 103.8198, 0},
+		{"one degree of latitude", 0, 0, 1, 0, 111.195},
+		{"one degree of longitude at the equator", 0, 0, 0, 1, 111.195},
+		{"half way round", 0, 0, 0, 180, 20015.087},
+		{"Raffles Place to Tampines", 1.2840, 103.8510, 1.3550, 103.9450, 13.097},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := distanceKm(tt.lat1, tt.lng1, tt.lat2, tt.lng2); math.Abs(got-tt.want) > 0.001 {
+				t.Errorf("distanceKm = %.3f, want %.3f", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSortByDistance(t *testing.T) {
+	km := func(d float64) *float64 { return &d }
+	listings := func() []ItemsDetails {
+		return []ItemsDetails{
+			{ID: 1, Distance: nil},
+			{ID: 2, Distance: km(5)},
+			{ID: 3, Distance: km(1.5)},
+			{ID: 4, Distance: km(5)},
+			{ID: 5, Distance: nil},
+			{ID: 6, Distance: km(0)},
+		}
+	}
+	tests := []struct {
+		name  string
+		after *listingCursor
+		want  []int64
+	}{
+		{"nearest first, unknown last", nil, []int64{6, 3, 2, 4, 1, 5}},
+		{"after a distance", &listingCursor{Key: "1.5", ID: 3}, []int64{2, 4, 1, 5}},
+		{"same distance, higher ID", &listingCursor{Key: "5", ID: 2}, []int64{4, 1, 5}},
+		{"among unknown", &listingCursor{Key: "1e+06", ID: 1}, []int64{5}},
+		{"after the last", &listingCursor{Key: "1e+06", ID: 5}, []int64{}},
+		{"key not a number", &listingCursor{Key: "near", ID: 1}, []int64{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ids := []int64{}
+			for _, si := range sortByDistance(listings(), tt.after) {
+				ids = append(ids, si.ID)
+			}
+			if !reflect.DeepEqual(ids, tt.want) {
+				t.Errorf("sortByDistance = %v, want %v", ids, tt.want)
+			}
+		})
+	}
+
+	// The cursor of a listing finds the listings after it
+	sorted := sortByDistance(listings(), nil)
+	for i, si := range sorted[:len(sorted)-1] {
+		rest := sortByDistance(listings(), &listingCursor{Key: sortKey("DistanceKey", si), ID: si.ID})
+		if len(rest) == 0 || rest[0].ID != sorted[i+1].ID {
+			t.Errorf("the listings after listing %d do not start with listing %d", si.ID, sorted[i+1].ID)
+		}
+	}
+}
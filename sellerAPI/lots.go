@@ -0,0 +1,272 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// Function to read and check a lot sent by the main application for seller {sellername}.
+// It writes a 422 response and returns false if the lot is not valid.
+func readLot(w http.ResponseWriter, r *http.Request, SN string) (ItemsDetails, bool) {
+	var sid ItemsDetails
+	if r.Header.Get("Content-type") != "application/json" {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write([]byte("422 - Please supply item information in JSON format"))
+		return sid, false
+	}
+	reqBody, err := ioutil.ReadAll(r.Body)
+	if err != nil || json.Unmarshal(reqBody, &sid) != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write([]byte("422 - Please supply item information in JSON format"))
+		return sid, false
+	}
+	if sid.Username == "" || sid.Username != SN || sid.Item == "" || !validMoney(sid.Cost) || !validQuantity(&sid) || !validPhoto(sid.Photo) || !validFreshness(&sid) || !validTiers(&sid) {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write([]byte("422 - Please supply correct item information in JSON format"))
+		return sid, false
+	}
+	if !validPeelType(w, sid.Item) {
+		return sid, false
+	}
+	return sid, true
+}
+
+// Function to get the lot ID from the url, the router only matches digits.
+func lotID(r *http.Request) int64 {
+	id, _ := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	return id
+}
+
+// Function to generate lots page /api/v1/seller/{sellername}/items
+// GET returns all lots of the seller embedded in JSON format.
+// POST adds a new lot, a seller can have several lots of the same item, and returns the new lot with its ID.
+// An event is recorded for each new lot, so buyers with a matching saved search can be told.
+func seller_lots(w http.ResponseWriter, r *http.Request) {
+	if !validKey(w, r, sellerapikey) {
+		log.Println("Seller API key not valid")
+		return
+	}
+	SN := mux.Vars(r)["sellername"]
+
+	if r.Method == "GET" {
+		sid, ok := GetRecordsSeller(sdb, SN)
+		if ok {
+			// returns all the lots in JSON
+			json.NewEncoder(w).Encode(sid)
+		}
+		return
+	}
+
+	sid, ok := readLot(w, r, SN)
+	if !ok {
+		return
+	}
+	id, ok := SaveTiers(sdb, sid.Tiers, func(tx execer) (int64, bool) {
+		return InsertListedRecord(tx, sid)
+	})
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("500 - Unable to add item"))
+		return
+	}
+	sid.ID = id
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(sid)
+}
+
+// Function to generate lot page for /api/v1/seller/{sellername}/items/{id}
+// It handles GET/PUT/DELETE methods sent from main application for one lot of the seller.
+// PUT only updates an existing lot, new lots are added with POST to /api/v1/seller/{sellername}/items
+// An event is recorded when PUT lowers the price of the lot, or gives more quantity to a lot that had sold out.
+func seller_editlot(w http.ResponseWriter, r *http.Request) {
+	if !validKey(w, r, sellerapikey) {
+		log.Println("Seller API key not valid")
+		return
+	}
+	SN := mux.Vars(r)["sellername"]
+	id := lotID(r)
+
+	lot, ok := GetLotSeller(sdb, SN, id)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("404 - No item found"))
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		json.NewEncoder(w).Encode(lot)
+	case "DELETE":
+		if !DeleteLotSeller(sdb, SN, id) {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("500 - Unable to delete item"))
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte("202 - Item deleted: " + lot.Item + " For seller: " + SN))
+	case "PUT":
+		sid, ok := readLot(w, r, SN)
+		if !ok {
+			return
+		}
+		// The price drop and stock events are recorded with the edit
+		sid.ID = id
+		_, ok = SaveTiers(sdb, sid.Tiers, func(tx execer) (int64, bool) {
+			return id, EditLotSeller(tx, SN, id, sid) && InsertEditEvents(tx, lot, sid)
+		})
+		if !ok {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("500 - Unable to update item"))
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte("202 - Item updated: From " + lot.Item + " To " + sid.Item + " For seller: " + SN))
+	}
+}
+
+// Function to generate lot page for /api/v1/buyer/{sellername}/items/{id}
+// It handles GET/DELETE methods sent from main application for one lot of the seller.
+// Buyers change the quantity of a lot only by taking what they bought, with POST to /items/{id}/take
+func buyer_editlot(w http.ResponseWriter, r *http.Request) {
+	if !validKey(w, r, buyerapikey) {
+		log.Println("Buyer API key not valid")
+		return
+	}
+	SN := mux.Vars(r)["sellername"]
+	id := lotID(r)
+
+	lot, ok := GetLotSeller(sdb, SN, id)
+	if !ok || sellerHidden(sdb, SN) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("404 - No item found"))
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		json.NewEncoder(w).Encode(lot)
+	case "DELETE":
+		if !DeleteLotSeller(sdb, SN, id) {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("500 - Unable to delete item"))
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte("202 - Item deleted: " + lot.Item + " For seller: " + SN))
+	}
+}
+
+// Data structure for a quantity sent by the main application to take from, or put back into, a lot
+// UnitCost is the price per unit the buyer was shown, only sent to take a quantity, nil to take it at any price.
+type stockChange struct {
+	Quantity float64 `json:"Quantity"`
+	UnitCost *Money  `json:"UnitCost,omitempty"`
+}
+
+// Function to read and check a quantity sent by the main application.
+// It writes a 422 response and returns false if the quantity is not above 0.
+func readStockChange(w http.ResponseWriter, r *http.Request) (stockChange, bool) {
+	var sc stockChange
+	reqBody, err := ioutil.ReadAll(r.Body)
+	if r.Header.Get("Content-type") != "application/json" || err != nil || json.Unmarshal(reqBody, &sc) != nil || sc.Quantity <= 0 {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write([]byte("422 - Please supply a quantity above 0 in JSON format"))
+		return sc, false
+	}
+	return sc, true
+}
+
+// Function to generate restock page for /api/v1/seller/{sellername}/items/{id}/restock
+// POST puts a quantity back into the lot, e.g. from a cancelled order, and returns the lot with its new quantity.
+// The quantity is added to what is left, so sales made at the same time are kept, and an event is recorded
+// when a lot that had sold out is back in stock.
+func seller_restocklot(w http.ResponseWriter, r *http.Request) {
+	if !validKey(w, r, sellerapikey) {
+		log.Println("Seller API key not valid")
+		return
+	}
+	SN := mux.Vars(r)["sellername"]
+	id := lotID(r)
+
+	sc, ok := readStockChange(w, r)
+	if !ok {
+		return
+	}
+	lot, found, ok := RestockLotSeller(sdb, SN, id, sc.Quantity)
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("500 - Unable to update item"))
+		return
+	}
+	if !found {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("404 - No item found"))
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(lot)
+}
+
+// Function to generate take page for /api/v1/buyer/{sellername}/items/{id}/take
+// POST takes the quantity bought by a buyer from the lot, and returns the lot with the quantity left.
+// The quantity must be a valid order of the lot, and is taken only if enough is left and the lot has not expired,
+// otherwise 409 is returned and the lot is not changed. When the price per unit the buyer was shown is sent as UnitCost and the lot now has
+// another price for the quantity, nothing is taken and 412 is returned with the lot as it is now.
+// A lot that sells out is kept with no quantity, and an event is recorded when it sells out or runs low.
+func buyer_takelot(w http.ResponseWriter, r *http.Request) {
+	if !validKey(w, r, buyerapikey) {
+		log.Println("Buyer API key not valid")
+		return
+	}
+	SN := mux.Vars(r)["sellername"]
+	id := lotID(r)
+
+	if sellerHidden(sdb, SN) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("404 - No item found"))
+		return
+	}
+	sc, ok := readStockChange(w, r)
+	if !ok {
+		return
+	}
+	quantity := sc.Quantity
+
+	// The minimum order and order step are checked here as well as in the main application
+	lot, ok := GetLotSeller(sdb, SN, id)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("404 - No item found"))
+		return
+	}
+	if !validOrder(lot, quantity) {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write([]byte("422 - Quantity must be at least the minimum order, in order steps"))
+		return
+	}
+
+	lot, taken, ok := TakeLotBuyer(sdb, SN, id, quantity, sc.UnitCost)
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("500 - Unable to update item"))
+		return
+	}
+	if !taken && sc.UnitCost != nil && lot.ID != 0 && unitCost(lot, quantity) != *sc.UnitCost {
+		// The lot is returned with its new price, so the buyer can be shown it
+		w.WriteHeader(http.StatusPreconditionFailed)
+		json.NewEncoder(w).Encode(lot)
+		return
+	}
+	if !taken {
+		w.WriteHeader(http.StatusConflict)
+		w.Write([]byte("409 - Not enough quantity left in the item, or the item has expired"))
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(lot)
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+)
+
+// Currency used when none is given, listings made before currencies were stored are in this currency
+const defaultCurrency = "SGD"
+
+// Number of decimal places of the minor unit of each currency that can be used for a price
+var minorUnits = map[string]int{
+	"SGD": 2,
+	"MYR": 2,
+	"USD": 2,
+	"JPY": 0,
+}
+
+// Money is an amount in minor units of its currency, e.g. {120, "SGD"} is SGD 1.20
+// It is the same type as money.Money in the main application.
+type Money struct {
+	Amount   int64  `json:"Amount"`
+	Currency string `json:"Currency"`
+}
+
+// Function to convert an amount in major units stored as float64, e.g. 1.2 dollars, to Money.
+func moneyFromFloat(f float64, currency string) Money {
+	scale := math.Pow(10, float64(minorUnits[currency]))
+	return Money{Amount: int64(math.Round(f * scale)), Currency: currency}
+}
+
+// Function to check the price of an item, it must be in a known currency and not negative.
+func validMoney(m Money) bool {
+	_, ok := minorUnits[m.Currency]
+	return ok && m.Amount >= 0
+}
+
+// Function to read an amount from JSON.
+// Besides {"Amount":120,"Currency":"SGD"} it accepts a plain number in major units, e.g. 1.2,
+// which is how prices were sent before amounts were kept in minor units.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	var f float64
+	if err := json.Unmarshal(data, &f); err == nil {
+		*m = moneyFromFloat(f, defaultCurrency)
+		return nil
+	}
+	type plain Money
+	var p plain
+	if err := json.Unmarshal(data, &p); err != nil {
+		return err
+	}
+	if p.Currency == "" {
+		p.Currency = defaultCurrency
+	}
+	*m = Money(p)
+	return nil
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// Function to generate profile page for /api/v1/seller/{sellername}/profile
+// It handles GET/PUT/DELETE methods sent from main application with the seller api key.
+// PUT adds or replaces the profile of the seller, which is searched along with their listings.
+func seller_profile(w http.ResponseWriter, r *http.Request) {
+	if !validKey(w, r, sellerapikey) {
+		log.Println("Seller API key not valid")
+		return
+	}
+	params := mux.Vars(r)
+	SN := params["sellername"]
+
+	if r.Method == "GET" {
+		p, ok := GetAProfile(sdb, SN)
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte("404 - No profile found"))
+		} else {
+			json.NewEncoder(w).Encode(p)
+		}
+		return
+	}
+
+	if r.Method == "DELETE" {
+		if !DeleteProfile(sdb, SN) {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("500 - Unable to delete profile"))
+		} else {
+			w.WriteHeader(http.StatusAccepted)
+			w.Write([]byte("202 - Profile deleted: " + SN))
+		}
+		return
+	}
+
+	if r.Header.Get("Content-type") != "application/json" {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write([]byte("422 - Please supply profile information in JSON format"))
+		return
+	}
+
+	var p SellerProfile
+	reqBody, err := ioutil.ReadAll(r.Body)
+	if err == nil {
+		err = json.Unmarshal(reqBody, &p)
+	}
+	p.Username = SN
+	p.Name = strings.TrimSpace(p.Name)
+	p.Location = strings.TrimSpace(p.Location)
+	if err != nil || p.Name == "" || len(p.Name) > maxProfileName || len(p.Location) > maxProfileLocation || !validCoordinates(p.Latitude, p.Longitude) {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write([]byte("422 - Please supply correct profile information in JSON format"))
+		return
+	}
+
+	if !SaveProfile(sdb, p) {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("500 - Unable to save profile"))
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+	w.Write([]byte("202 - Profile updated: " + SN))
+}
+
+// Function to generate hidden page for /api/v1/seller/{sellername}/hidden
+// It handles PUT/DELETE methods sent from main application with the seller api key.
+// PUT hides every lot of the seller from buyers while their account waits to be deleted, DELETE shows them again
+// when the deletion is cancelled. The lots themselves are kept until the account is purged.
+func seller_hidden(w http.ResponseWriter, r *http.Request) {
+	if !validKey(w, r, sellerapikey) {
+		log.Println("Seller API key not valid")
+		return
+	}
+	SN := mux.Vars(r)["sellername"]
+
+	if !HideSeller(sdb, SN, r.Method == "PUT") {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("500 - Unable to change the listings shown for seller"))
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+	if r.Method == "PUT" {
+		w.Write([]byte("202 - Listings hidden for seller: " + SN))
+	} else {
+		w.Write([]byte("202 - Listings shown for seller: " + SN))
+	}
+}
+
+// Function to check that a latitude and longitude are both given and on the map, or both left out.
+func validCoordinates(lat, lng *float64) bool {
+	if lat == nil || lng == nil {
+		return lat == nil && lng == nil
+	}
+	return *lat >= -90 && *lat <= 90 && *lng >= -180 && *lng <= 180
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"database/sql"
+	"log"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+)
+
+// Data structure for the public profile of a seller, sent by the main application and used by search
+// Location is the area the seller is in, such as the name of their postal district, never their full address.
+// Latitude and Longitude are the centre of that area, used to work out distances, and are nil when not known.
+type SellerProfile struct {
+	Username  string   `json:"Username"`
+	Name      string   `json:"Name"`
+	Location  string   `json:"Location"`
+	Latitude  *float64 `json:"Latitude,omitempty"`
+	Longitude *float64 `json:"Longitude,omitempty"`
+}
+
+// Columns of sellerprofiles table in the order they are scanned into SellerProfile
+const profileColumns = "Username, Name, Location, Latitude, Longitude"
+
+// Longest name and location of a seller profile, the sizes of their columns
+const (
+	maxProfileName     = 50
+	maxProfileLocation = 100
+)
+
+//-----------------------------------------------------------------------
+// Functions for seller profiles
+//-----------------------------------------------------------------------
+// Function to get all seller profiles from the MYSQL database.
+// It returns false when there is any error encountered.
+func GetProfiles(db *sql.DB) ([]SellerProfile, bool) {
+	var sp []SellerProfile
+	results, err := db.Query("SELECT " + profileColumns + " FROM sellerprofiles ORDER BY Username")
+	if err != nil {
+		log.Println("Not able to get seller profiles")
+		log.Println(err)
+		return sp, false
+	}
+	defer results.Close()
+
+	for results.Next() {
+		var p SellerProfile
+		if err := results.Scan(&p.Username, &p.Name, &p.Location, &p.Latitude, &p.Longitude); err != nil {
+			log.Println("Unable to get seller profiles")
+			log.Println(err)
+			return sp, false
+		}
+		sp = append(sp, p)
+	}
+	return sp, true
+}
+
+// Function to get the profile of one seller from the MYSQL database.
+// It returns false when the seller has no profile, or there is any error encountered.
+func GetAProfile(db *sql.DB, username string) (SellerProfile, bool) {
+	var p SellerProfile
+	err := db.QueryRow("SELECT "+profileColumns+" FROM sellerprofiles WHERE Username=?", username).Scan(&p.Username, &p.Name, &p.Location, &p.Latitude, &p.Longitude)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Println("Unable to get the seller profile")
+			log.Println(err)
+		}
+		return p, false
+	}
+	return p, true
+}
+
+// Function to add or replace the profile of a seller in the MYSQL database.
+// It returns false when there is any error encountered.
+func SaveProfile(db *sql.DB, p SellerProfile) bool {
+	_, err := db.Exec("INSERT INTO sellerprofiles (Username, Name, Location, Latitude, Longitude, UpdatedAt) VALUES (?, ?, ?, ?, ?, ?) ON DUPLICATE KEY UPDATE Name=VALUES(Name), Location=VALUES(Location), Latitude=VALUES(Latitude), Longitude=VALUES(Longitude), UpdatedAt=VALUES(UpdatedAt)",
+		p.Username, p.Name, p.Location, p.Latitude, p.Longitude, time.Now().UTC().Format(timeFormat))
+	if err != nil {
+		log.Println("Unable to save the seller profile")
+		log.Println(err)
+		return false
+	}
+	return true
+}
+
+// Function to hide the lots of a seller from buyers, or show them again, in the MYSQL database.
+// It returns false when there is any error encountered.
+func HideSeller(db *sql.DB, username string, hidden bool) bool {
+	var err error
+	if hidden {
+		_, err = db.Exec("INSERT IGNORE INTO hiddensellers (Username, HiddenAt) VALUES (?, ?)", username, time.Now().UTC().Format(timeFormat))
+	} else {
+		_, err = db.Exec("DELETE FROM hiddensellers WHERE Username=?", username)
+	}
+	if err != nil {
+		log.Println("Unable to change the listings shown for the seller")
+		log.Println(err)
+		return false
+	}
+	return true
+}
+
+// Function to check in the MYSQL database if the lots of a seller are hidden from buyers.
+// Lots are taken as hidden when there is any error encountered, so they are not sold by mistake.
+func sellerHidden(db *sql.DB, username string) bool {
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM hiddensellers WHERE Username=?", username).Scan(&count); err != nil {
+		log.Println("Unable to check if the seller is hidden")
+		log.Println(err)
+		return true
+	}
+	return count > 0
+}
+
+// Function to delete the profile of a seller from the MYSQL database.
+// It returns false when there is any error encountered.
+func DeleteProfile(db *sql.DB, username string) bool {
+	_, err := db.Exec("DELETE FROM sellerprofiles WHERE Username=?", username)
+	if err != nil {
+		log.Println("Unable to delete the seller profile")
+		log.Println(err)
+		return false
+	}
+	return true
+}
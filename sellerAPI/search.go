@@ -0,0 +1,179 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Kinds of documents found by search, given with ?kind= to find only one kind
+const (
+	KindListing = "listing"
+	KindSeller  = "seller"
+)
+
+const (
+	// Number of results returned by search when no limit is given
+	defaultSearchLimit = 20
+
+	// Longest query searched for, in bytes
+	maxSearchQuery = 200
+
+	// Time between builds of the search index from the database, so changes show up in search within it
+	searchIndexTime = 30 * time.Second
+)
+
+// Data structure for one result of a search, a listing for sale or a seller
+// Listing hits also have the profile of their seller, when the seller has one.
+type SearchHit struct {
+	Kind    string         `json:"Kind"`
+	Score   float64        `json:"Score"`
+	Listing *ItemsDetails  `json:"Listing,omitempty"`
+	Seller  *SellerProfile `json:"Seller,omitempty"`
+}
+
+// This method returns a key ordering hits with the same score, listings in the order they were listed then sellers by name
+func (h SearchHit) key() string {
+	if h.Kind == KindListing {
+		return fmt.Sprintf("0%020d", h.Listing.ID)
+	}
+	return "1" + h.Seller.Username
+}
+
+// Search index shared by all requests, it is only read once built
+var (
+	searchMu      sync.RWMutex
+	searchCurrent *searchIndex
+)
+
+// Function to get the search index last built by refreshSearchIndex
+// It returns false when there is no index to search yet.
+func currentSearchIndex() (*searchIndex, bool) {
+	searchMu.RLock()
+	defer searchMu.RUnlock()
+	return searchCurrent, searchCurrent != nil
+}
+
+// Function to build the search index again, it runs forever building it every searchIndexTime.
+// When it cannot be built, the last index is kept so search goes on working from it.
+func refreshSearchIndex() {
+	for {
+		if idx, ok := buildSearchIndex(); ok {
+			searchMu.Lock()
+			searchCurrent = idx
+			searchMu.Unlock()
+		} else {
+			log.Println("Unable to build the search index")
+		}
+		time.Sleep(searchIndexTime)
+	}
+}
+
+// Function to build the search index from the listings for sale, the catalogue and the seller profiles.
+// Listings are found by the name and description of their peel, and the name and location of their seller.
+// Sellers are found by their name, location and the names of the peel they list.
+// It returns false when there is any error encountered.
+func buildSearchIndex() (*searchIndex, bool) {
+	listings, _, ok := GetRecordsBuyer(sdb, ListingQuery{})
+	if !ok {
+		return nil, false
+	}
+	catalogue, ok := GetPeelTypes(sdb)
+	if !ok {
+		return nil, false
+	}
+	profiles, ok := GetProfiles(sdb)
+	if !ok {
+		return nil, false
+	}
+
+	peelTypes := make(map[string]PeelType)
+	for _, p := range catalogue {
+		peelTypes[p.Name] = p
+	}
+	sellers := make(map[string]*SellerProfile)
+	for i := range profiles {
+		sellers[profiles[i].Username] = &profiles[i]
+	}
+
+	idx := newSearchIndex()
+	listed := make(map[string][]string)
+	for i := range listings {
+		si := &listings[i]
+		p := peelTypes[si.Item]
+		var name, location string
+		seller := sellers[si.Username]
+		if seller != nil {
+			name, location = seller.Name, seller.Location
+		}
+		idx.add(SearchHit{Kind: KindListing, Listing: si, Seller: seller},
+			searchField{si.Item + " " + p.DisplayName, weightItem},
+			searchField{si.Username + " " + name, weightSeller},
+			searchField{location, weightLocation},
+			searchField{p.Description + " " + p.Nutrients, weightDescription},
+		)
+		listed[si.Username] = append(listed[si.Username], si.Item+" "+p.DisplayName)
+	}
+	for _, seller := range sellers {
+		idx.add(SearchHit{Kind: KindSeller, Seller: seller},
+			searchField{seller.Username + " " + seller.Name, weightItem},
+			searchField{seller.Location, weightLocation},
+			searchField{strings.Join(listed[seller.Username], " "), weightDescription},
+		)
+	}
+	return idx, true
+}
+
+// Function to generate search page /api/v1/search, containing the listings and sellers matching ?q= embedded in JSON format.
+// Results are ranked best match first, at most ?limit= of them, of ?kind= listing or seller only when given.
+// Words may be misspelt or only started, e.g. "banan" or "bananna" find banana peel.
+func search_all(w http.ResponseWriter, r *http.Request) {
+	if !validKey(w, r, buyerapikey, sellerapikey) {
+		log.Println("API key not valid for search")
+		return
+	}
+
+	v := r.URL.Query()
+	query := strings.TrimSpace(v.Get("q"))
+	if len(tokenize(query)) == 0 || len(query) > maxSearchQuery {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("400 - Please supply words to search for with q"))
+		return
+	}
+	kind := v.Get("kind")
+	if kind != "" && kind != KindListing && kind != KindSeller {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("400 - kind must be listing or seller"))
+		return
+	}
+	limit := defaultSearchLimit
+	if s := v.Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("400 - limit must be a whole number"))
+			return
+		}
+		limit = n
+	}
+	if limit > maxPageSize {
+		limit = maxPageSize
+	}
+
+	idx, ok := currentSearchIndex()
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("500 - Unable to search"))
+		return
+	}
+	hits := idx.search(query, kind)
+	if len(hits) > limit {
+		hits = hits[:limit]
+	}
+	json.NewEncoder(w).Encode(hits)
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Weights of the fields of a document, a word in the name of the peel counts for more than one in its description
+const (
+	weightItem        = 3.0
+	weightSeller      = 2.0
+	weightLocation    = 2.0
+	weightDescription = 1.0
+)
+
+// How much a word counts when it only starts with, or is misspelt from, a word searched for
+const (
+	matchPrefix = 0.8
+	matchTypo   = 0.6
+)
+
+// Words searched for that are shorter than this are not looked up by prefix
+const minPrefixLength = 3
+
+// Data structure for a field of a document to index, and how much its words count
+type searchField struct {
+	Text   string
+	Weight float64
+}
+
+// Data structure for an inverted index of documents, each one a listing or a seller
+// Postings holds for each word the documents it is in, with the sum of the weights of the fields it is in.
+type searchIndex struct {
+	Docs     []SearchHit
+	Postings map[string]map[int]float64
+}
+
+// Data structure for a word of the index matching a word searched for, and how well it matches
+type termMatch struct {
+	Term    string
+	Quality float64
+}
+
+// Function to create an empty search index
+func newSearchIndex() *searchIndex {
+	return &searchIndex{Postings: make(map[string]map[int]float64)}
+}
+
+// This method adds a document to the index, with the fields its words are taken from
+func (idx *searchIndex) add(doc SearchHit, fields ...searchField) {
+	id := len(idx.Docs)
+	idx.Docs = append(idx.Docs, doc)
+	for _, f := range fields {
+		for _, term := range tokenize(f.Text) {
+			if idx.Postings[term] == nil {
+				idx.Postings[term] = make(map[int]float64)
+			}
+			idx.Postings[term][id] += f.Weight
+		}
+	}
+}
+
+// This method returns the documents matching every word of a query, best match first, keeping only those of kind
+// when it is not empty. Each word may match a word of the index exactly, by prefix, or with a small typo.
+// Words of the query matching no word of the index are left out, so one misspelt word does not hide every result.
+func (idx *searchIndex) search(query, kind string) []SearchHit {
+	scores := make(map[int]float64)
+	first := true
+	for _, word := range unique(tokenize(query)) {
+		matches := idx.match(word)
+		if len(matches) == 0 {
+			continue
+		}
+
+		// The best matching word of the index counts for each document
+		wordScores := make(map[int]float64)
+		for _, m := range matches {
+			postings := idx.Postings[m.Term]
+			idf := math.Log(1 + float64(len(idx.Docs))/float64(len(postings)))
+			for id, weight := range postings {
+				score := m.Quality * idf * weight / (weight + 1)
+				if score > wordScores[id] {
+					wordScores[id] = score
+				}
+			}
+		}
+
+		// Documents must match every word searched for
+		if first {
+			scores = wordScores
+			first = false
+			continue
+		}
+		for id := range scores {
+			if s, ok := wordScores[id]; ok {
+				scores[id] += s
+			} else {
+				delete(scores, id)
+			}
+		}
+	}
+
+	hits := []SearchHit{}
+	for id, score := range scores {
+		doc := idx.Docs[id]
+		if kind != "" && doc.Kind != kind {
+			continue
+		}
+		doc.Score = math.Round(score*1000) / 1000
+		hits = append(hits, doc)
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].key() < hits[j].key()
+	})
+	return hits
+}
+
+// This method returns the words of the index matching a word searched for
+// A word matches itself, words it starts, and words at most maxEdits(word) typos away.
+func (idx *searchIndex) match(word string) []termMatch {
+	if _, ok := idx.Postings[word]; ok {
+		return []termMatch{{word, 1}}
+	}
+	var matches []termMatch
+	edits := maxEdits(word)
+	for term := range idx.Postings {
+		if len(word) >= minPrefixLength && strings.HasPrefix(term, word) {
+			matches = append(matches, termMatch{term, matchPrefix})
+		} else if edits > 0 && editDistance(word, term, edits) <= edits {
+			matches = append(matches, termMatch{term, matchTypo})
+		}
+	}
+	return matches
+}
+
+// Function to split text into lower case words of letters and digits
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// Function to remove repeated words, keeping the first of each
+func unique(words []string) []string {
+	seen := make(map[string]bool)
+	var u []string
+	for _, w := range words {
+		if !seen[w] {
+			seen[w] = true
+			u = append(u, w)
+		}
+	}
+	return u
+}
+
+// Function to get the number of typos allowed in a word searched for, none in short words as they match too much
+func maxEdits(word string) int {
+	switch n := len([]rune(word)); {
+	case n <= 3:
+		return 0
+	case n <= 7:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// Function to count the letters to insert, delete, change or swap with the next one to turn a into b
+// Counting stops once it is over limit, limit+1 is returned for words further apart.
+func editDistance(a, b string, limit int) int {
+	s, t := []rune(a), []rune(b)
+	if d := len(s) - len(t); d > limit || -d > limit {
+		return limit + 1
+	}
+
+	// Only the last three rows of the table are kept
+	prev2 := make([]int, len(t)+1)
+	prev := make([]int, len(t)+1)
+	cur := make([]int, len(t)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(s); i++ {
+		cur[0] = i
+		best := cur[0]
+		for j := 1; j <= len(t); j++ {
+			cost := 1
+			if s[i-1] == t[j-1] {
+				cost = 0
+			}
+			cur[j] = minInt(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && s[i-1] == t[j-2] && s[i-2] == t[j-1] {
+				cur[j] = minInt(cur[j], prev2[j-2]+1)
+			}
+			best = minInt(best, cur[j])
+		}
+		if best > limit {
+			return limit + 1
+		}
+		prev2, prev, cur = prev, cur, prev2
+	}
+	return prev[len(t)]
+}
+
+// Function to get the smallest of some numbers
+func minInt(n int, ns ...int) int {
+	for _, m := range ns {
+		if m < n {
+			n = m
+		}
+	}
+	return n
+}
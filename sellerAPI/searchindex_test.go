@@ -0,0 +1,154 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{"empty", "", []string{}},
+		{"punctuation only", " - , ", []string{}},
+		{"lower case words", "Banana Peel", []string{"banana", "peel"}},
+		{"punctuation splits words", "orange-peel, 2kg!", []string{"orange", "peel", "2kg"}},
+		{"letters of other scripts", "Café 香蕉", []string{"café", "香蕉"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tokenize(tt.text); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("tokenize(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b  string
+		limit int
+		want  int
+	}{
+		{"banana", "banana", 2, 0},
+		{"banana", "bananna", 2, 1},
+		{"banana", "banan", 2, 1},
+		{"banana", "bonana", 2, 1},
+		{"banana", "bnaana", 2, 1},
+		{"orange", "roange", 2, 1},
+		{"orange", "ornage", 2, 1},
+		{"orange", "orgnae", 2, 2},
+		{"banana", "orange", 2, 3},
+		{"peel", "peelings", 2, 3},
+		{"", "abc", 3, 3},
+		{"香蕉", "香焦", 1, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.a+"/"+tt.b, func(t *testing.T) {
+			if got := editDistance(tt.a, tt.b, tt.limit); got != tt.want {
+				t.Errorf("editDistance(%q, %q, %d) = %d, want %d", tt.a, tt.b, tt.limit, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMaxEdits(t *testing.T) {
+	tests := []struct {
+		word string
+		want int
+	}{
+		{"fig", 0},
+		{"lime", 1},
+		{"bananas", 1},
+		{"mandarin", 2},
+		{"香蕉香蕉", 1},
+	}
+	for _, tt := range tests {
+		if got := maxEdits(tt.word); got != tt.want {
+			t.Errorf("maxEdits(%q) = %d, want %d", tt.word, got, tt.want)
+		}
+	}
+}
+
+// testSearchIndex returns an index of two listings and a seller, with banana in a different field of each
+func testSearchIndex() *searchIndex {
+	alice := &SellerProfile{Username: "alice", Name: "Alice Tan", Location: "Tampines"}
+	bob := &SellerProfile{Username: "bob", Name: "Banana Grove", Location: "Jurong"}
+	idx := newSearchIndex()
+	idx.add(SearchHit{Kind: KindListing, Listing: &ItemsDetails{ID: 1, Item: "banana"}, Seller: alice},
+		searchField{"banana Banana Peel", weightItem},
+		searchField{"alice Alice Tan", weightSeller},
+		searchField{"Tampines", weightLocation},
+		searchField{"Rich in potassium", weightDescription},
+	)
+	idx.add(SearchHit{Kind: KindListing, Listing: &ItemsDetails{ID: 2, Item: "orange"}, Seller: bob},
+		searchField{"orange Orange Peel", weightItem},
+		searchField{"bob Banana Grove", weightSeller},
+		searchField{"Jurong", weightLocation},
+		searchField{"Rich in vitamin C", weightDescription},
+	)
+	idx.add(SearchHit{Kind: KindSeller, Seller: alice},
+		searchField{"alice Alice Tan", weightItem},
+		searchField{"Tampines", weightLocation},
+		searchField{"banana Banana Peel", weightDescription},
+	)
+	return idx
+}
+
+// hitNames returns the ID of each listing hit and the username of each seller hit, in order
+func hitNames(hits []SearchHit) []string {
+	names := []string{}
+	for _, h := range hits {
+		if h.Kind == KindListing {
+			names = append(names, h.Listing.Item)
+		} else {
+			names = append(names, h.Seller.Username)
+		}
+	}
+	return names
+}
+
+func TestSearch(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		kind  string
+		want  []string
+	}{
+		{"ranked by field", "banana", "", []string{"banana", "orange", "alice"}},
+		{"typo", "bananna", "", []string{"banana", "orange", "alice"}},
+		{"prefix", "ban", "", []string{"banana", "orange", "alice"}},
+		{"every word must match", "banana tampines", "", []string{"banana", "alice"}},
+		{"unknown word is left out", "banana xyzzy", "", []string{"banana", "orange", "alice"}},
+		{"kind seller", "banana", KindSeller, []string{"alice"}},
+		{"kind listing", "tampines", KindListing, []string{"banana"}},
+		{"short words have no typos", "pel", "", []string{}},
+		{"no match", "xyzzy", "", []string{}},
+	}
+	idx := testSearchIndex()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := hitNames(idx.search(tt.query, tt.kind)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("search(%q, %q) = %q, want %q", tt.query, tt.kind, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchScores(t *testing.T) {
+	idx := testSearchIndex()
+	exact := idx.search("banana", "")[0].Score
+	prefix := idx.search("banan", "")[0].Score
+	typo := idx.search("bananna", "")[0].Score
+	if !(exact > prefix && prefix > typo && typo > 0) {
+		t.Errorf("scores exact %v, prefix %v, typo %v, want exact > prefix > typo > 0", exact, prefix, typo)
+	}
+
+	// Hits with the same score keep listings first, in the order they were listed
+	tied := idx.search("rich", "")
+	if got := hitNames(tied); !reflect.DeepEqual(got, []string{"banana", "orange"}) || tied[0].Score != tied[1].Score {
+		t.Errorf("search(rich) = %q with scores %v and %v, want a tie of banana then orange", got, tied[0].Score, tied[1].Score)
+	}
+}
@@ -17,8 +17,13 @@ import (
 	"fmt"
 	"io/ioutil"
 	"log"
+	"math"
 	"net/http"
 	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
 
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/gorilla/mux"
@@ -31,13 +36,23 @@ var sdb *sql.DB
 const (
 	// Directory that stores self generated cerificate.
 	certPath = "./cert/"
+
+	// Shelf life of a listing when the seller does not give an expiry time
+	defaultShelfLife = 7 * 24 * time.Hour
+
+	// Time between checks for expired listings
+	expiryCheckTime = 10 * time.Minute
+
+	// Largest number of events returned for one request
+	maxEvents = 100
 )
 
 // Variable used only within this package
 var sellerapikey string
 var buyerapikey string
+var adminapikey string
 
-//Initialization function
+// Initialization function
 func init() {
 	// loads values from .env into the system
 	if err := godotenv.Load(); err != nil {
@@ -49,24 +64,28 @@ func init() {
 
 	// Get the SELLER_API_KEY environment variable
 	buyerapikey, _ = os.LookupEnv("BUYER_API_KEY")
+
+	// Get the ADMIN_API_KEY environment variable, used to manage the catalogue
+	adminapikey, _ = os.LookupEnv("ADMIN_API_KEY")
 }
 
 // Function to validate the API KEY.
-// It takes in the http response writer and http request as input and also the api key for either buyer or seller.
-// It extracts the query in the request and checks if the api key in the url matches with the expected api key.
+// It takes in the http response writer and http request as input and also the api keys that are allowed, for buyer, seller or admin.
+// It extracts the query in the request and checks if the api key in the url matches with one of the expected api keys.
 // It returns true is api keys match, and false if they dont.
 // TO be updated for api
-func validKey(w http.ResponseWriter, r *http.Request, apikey string) bool {
+func validKey(w http.ResponseWriter, r *http.Request, apikeys ...string) bool {
 	v := r.URL.Query()
 
 	if key, ok := v["key"]; ok {
-		if key[0] == apikey {
-			return true
-		} else {
-			w.WriteHeader(http.StatusNotFound)
-			w.Write([]byte("401 - Invalid key"))
-			return false
+		for _, apikey := range apikeys {
+			if apikey != "" && key[0] == apikey {
+				return true
+			}
 		}
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("401 - Invalid key"))
+		return false
 	} else {
 		w.WriteHeader(http.StatusNotFound)
 		w.Write([]byte("401 - Please supply access key"))
@@ -74,6 +93,112 @@ func validKey(w http.ResponseWriter, r *http.Request, apikey string) bool {
 	}
 }
 
+// Function to check that an item is a peel type in the catalogue.
+// It writes a 422 response and returns false if the item is unknown.
+func validPeelType(w http.ResponseWriter, item string) bool {
+	if _, ok := GetAPeelType(sdb, item); !ok {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write([]byte("422 - Unknown item, please choose an item from the catalogue"))
+		return false
+	}
+	return true
+}
+
+// Function to fill in and check the freshness of an item sent by the main application.
+// Collected on defaults to now, and expires at defaults to the default shelf life after collection.
+// It returns false if the item is collected in the future, or expires before it is collected.
+func validFreshness(sid *ItemsDetails) bool {
+	if sid.CollectedOn.IsZero() {
+		sid.CollectedOn = time.Now()
+	}
+	if sid.ExpiresAt.IsZero() {
+		sid.ExpiresAt = sid.CollectedOn.Add(defaultShelfLife)
+	}
+	return !sid.CollectedOn.After(time.Now()) && sid.ExpiresAt.After(sid.CollectedOn)
+}
+
+// Function to check that the seller has only one lot of an item, so it can be changed by item name.
+// It writes a 409 response and returns false if there are several lots, they must be changed by ID.
+func singleLot(w http.ResponseWriter, IN string, SN string) bool {
+	if count, ok := CountLotsSeller(sdb, IN, SN); ok && count > 1 {
+		w.WriteHeader(http.StatusConflict)
+		w.Write([]byte("409 - Several lots of this item, please use /items/{id}"))
+		return false
+	}
+	return true
+}
+
+// Units of measure a listing can be sold in
+var validUnits = map[string]bool{"kg": true, "g": true, "bag": true, "crate": true}
+
+// Function to fill in and check the quantity, unit and order sizes of an item sent by the main application.
+// Unit defaults to kg, step defaults to 1 unit, and minimum order defaults to one step.
+// It returns false if the unit is unknown, or any of the quantities, including the low stock warning, is negative.
+func validQuantity(sid *ItemsDetails) bool {
+	if sid.Unit == "" {
+		sid.Unit = "kg"
+	}
+	if sid.Step == 0 {
+		sid.Step = 1
+	}
+	if sid.MinOrder == 0 {
+		sid.MinOrder = sid.Step
+	}
+	return validUnits[sid.Unit] && sid.Quantity >= 0 && sid.Step > 0 && sid.MinOrder > 0 && sid.LowStock >= 0
+}
+
+// Function to check that a buyer can order quantity q of a lot.
+// q must be at least the minimum order, and the minimum order plus a whole number of order steps.
+func validOrder(lot ItemsDetails, q float64) bool {
+	step := lot.Step
+	if step <= 0 {
+		step = 1
+	}
+	minOrder := math.Max(lot.MinOrder, 0)
+	if q <= 0 || math.Round(q*1000) < math.Round(minOrder*1000) {
+		return false
+	}
+	steps := (q - minOrder) / step
+	return math.Abs(steps-math.Round(steps)) < 1e-6
+}
+
+// Function to get the price per unit of an order of quantity q of a lot, using the highest price break q reaches.
+// It works out the price the same way as the main application, to check the price a buyer saw is still the price.
+func unitCost(lot ItemsDetails, q float64) Money {
+	cost := lot.Cost
+	for _, tier := range lot.Tiers {
+		if math.Round(q*1000) >= math.Round(tier.MinQuantity*1000) {
+			cost = tier.Cost
+		}
+	}
+	return cost
+}
+
+// Function to sort and check the quantity price breaks of an item sent by the main application.
+// Each break needs a quantity above 0 and a price in the currency of the item, and quantities cannot repeat.
+func validTiers(sid *ItemsDetails) bool {
+	sort.Slice(sid.Tiers, func(i, j int) bool {
+		return sid.Tiers[i].MinQuantity < sid.Tiers[j].MinQuantity
+	})
+	for i, tier := range sid.Tiers {
+		if tier.MinQuantity <= 0 || !validMoney(tier.Cost) || tier.Cost.Currency != sid.Cost.Currency {
+			return false
+		}
+		if i > 0 && tier.MinQuantity == sid.Tiers[i-1].MinQuantity {
+			return false
+		}
+	}
+	return true
+}
+
+// Regular expression for the name of a listing photo, stored by the main application
+var photoMatch = regexp.MustCompile(`^[a-zA-Z0-9-]{1,64}\.(jpg|png|gif)$`)
+
+// Function to check the photo of an item, it can be empty or the name of an uploaded photo.
+func validPhoto(photo string) bool {
+	return photo == "" || photoMatch.MatchString(photo)
+}
+
 // Function to display home page of REST API.
 func sellerapihome(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprintf(w, "Welcome to Seller API")
@@ -97,6 +222,7 @@ func seller_allitems(w http.ResponseWriter, r *http.Request) {
 
 // Function to generate items page for /api/v1/seller/{sellername}/{itemname}
 // It handles GET/POST/PUT/DELETE methods sent from main application
+// GET returns the oldest lot of the item, POST adds a new lot, PUT and DELETE are refused when there are several lots.
 // It generates all item information in response to request made, embedded in JSON format.
 // It also generates headers for each request, depending on the status of each operation.
 func seller_edititems(w http.ResponseWriter, r *http.Request) {
@@ -124,8 +250,12 @@ func seller_edititems(w http.ResponseWriter, r *http.Request) {
 		if !ok {
 			w.WriteHeader(http.StatusNotFound)
 			w.Write([]byte("404 - No item found"))
+		} else if !singleLot(w, IN, SN) {
+			return
+		} else if !DeleteRecordSeller(sdb, IN, SN) {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("500 - Unable to delete item"))
 		} else {
-			DeleteRecordSeller(sdb, IN, SN)
 			w.WriteHeader(http.StatusAccepted)
 			w.Write([]byte("202 - Item deleted: " + IN + " For seller: " + SN))
 		}
@@ -143,18 +273,22 @@ func seller_edititems(w http.ResponseWriter, r *http.Request) {
 			} else {
 				// convert JSON to object
 				json.Unmarshal(reqBody, &sid)
-				if sid.Username == "" || sid.Username != SN || sid.Item == "" || sid.Cost < 0.0 || sid.Quantity < 0 || sid.Item != IN {
+				if sid.Username == "" || sid.Username != SN || sid.Item == "" || !validMoney(sid.Cost) || !validQuantity(&sid) || !validPhoto(sid.Photo) || !validFreshness(&sid) || !validTiers(&sid) || sid.Item != IN {
 					w.WriteHeader(http.StatusUnprocessableEntity)
 					w.Write([]byte("422 - Please supply correct item information in JSON format"))
 					return
 				}
-				// check if item exists; add only if the item does not exist
-				_, ok := GetARecordSeller(sdb, IN, SN)
-				if ok { // ok means its exists
-					w.WriteHeader(http.StatusConflict)
-					w.Write([]byte("409 - Duplicate item ID"))
+				if !validPeelType(w, sid.Item) {
+					return
+				}
+				// always add a new lot, the seller can have several lots of the same item, with the listed event about it
+				_, ok := SaveTiers(sdb, sid.Tiers, func(tx execer) (int64, bool) {
+					return InsertListedRecord(tx, sid)
+				})
+				if !ok {
+					w.WriteHeader(http.StatusInternalServerError)
+					w.Write([]byte("500 - Unable to add item"))
 				} else {
-					InsertRecordSeller(sdb, sid)
 					w.WriteHeader(http.StatusCreated)
 					w.Write([]byte("201 - Item added: " + IN + " For seller: " + SN))
 				}
@@ -167,15 +301,18 @@ func seller_edititems(w http.ResponseWriter, r *http.Request) {
 			reqBody, err := ioutil.ReadAll(r.Body)
 			if err == nil {
 				json.Unmarshal(reqBody, &sid)
-				if sid.Username == "" || sid.Username != SN || sid.Item == "" || sid.Cost < 0.0 || sid.Quantity < 0 {
+				if sid.Username == "" || sid.Username != SN || sid.Item == "" || !validMoney(sid.Cost) || !validQuantity(&sid) || !validPhoto(sid.Photo) || !validFreshness(&sid) || !validTiers(&sid) {
 					w.WriteHeader(
 						http.StatusUnprocessableEntity)
 					w.Write([]byte(
 						"422 - Please supply item information in JSON format"))
 					return
 				}
+				if !validPeelType(w, sid.Item) {
+					return
+				}
 				// check if item exists; add only if item does not exist
-				_, ok := GetARecordSeller(sdb, IN, SN)
+				existing, ok := GetARecordSeller(sdb, IN, SN)
 				if !ok { // item does not exist in db
 					// Now check if new item does not exist either
 					_, ok := GetARecordSeller(sdb, sid.Item, sid.Username)
@@ -184,13 +321,30 @@ func seller_edititems(w http.ResponseWriter, r *http.Request) {
 						w.Write([]byte("422 - Please supply correct item information in JSON format"))
 						return
 					} else { // item does not exist at all, need to add as new item
-						InsertRecordSeller(sdb, sid)
+						_, ok := SaveTiers(sdb, sid.Tiers, func(tx execer) (int64, bool) {
+							return InsertListedRecord(tx, sid)
+						})
+						if !ok {
+							w.WriteHeader(http.StatusInternalServerError)
+							w.Write([]byte("500 - Unable to add item"))
+							return
+						}
 						w.WriteHeader(http.StatusCreated)
 						w.Write([]byte("201 - Item added: " + IN + " For seller: " + SN))
 					}
+				} else if !singleLot(w, IN, SN) {
+					return
 				} else {
-					// update the item if item exists
-					EditRecordSeller(sdb, IN, SN, sid)
+					// update the item if item exists, with the price drop and stock events of the edit
+					sid.ID = existing.ID
+					_, ok := SaveTiers(sdb, sid.Tiers, func(tx execer) (int64, bool) {
+						return existing.ID, EditRecordSeller(tx, IN, SN, sid) && InsertEditEvents(tx, existing, sid)
+					})
+					if !ok {
+						w.WriteHeader(http.StatusInternalServerError)
+						w.Write([]byte("500 - Unable to update item"))
+						return
+					}
 					w.WriteHeader(http.StatusAccepted)
 					w.Write([]byte("202 - Item updated: From " + IN + " To " + sid.Item + " For seller: " + SN))
 				}
@@ -202,22 +356,37 @@ func seller_edititems(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// Function to generate items page /api/v1/buyer, containing all items information from all sellers embedded in JSON format.
+// Function to generate items page /api/v1/buyer, containing the items of all sellers embedded in JSON format.
+// Items can be filtered, sorted and paged with the query parameters read by parseListingQuery.
+// Without a limit all matching items are returned, otherwise the cursor of the next page is sent in the X-Next-Cursor header.
 func buyer_allitems(w http.ResponseWriter, r *http.Request) {
 	if !validKey(w, r, buyerapikey) {
 		log.Println("Buyer API key not valid")
 		return
 	}
 
-	bid, ok := GetRecordsBuyer(sdb)
-	if ok {
-		// returns all the items in JSON
-		json.NewEncoder(w).Encode(bid)
+	q, err := parseListingQuery(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("400 - " + err.Error()))
+		return
+	}
+	bid, next, ok := GetRecordsBuyer(sdb, q)
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("500 - Unable to get items"))
+		return
+	}
+	if next != "" {
+		w.Header().Set(nextCursorHeader, next)
 	}
+	// returns the items in JSON
+	json.NewEncoder(w).Encode(bid)
 }
 
 // Function to generate items page for /api/v1/buyer/{sellername}/{itemname}
-// It handles GET/PUT/DELETE methods sent from main application, POST cannot be done by buyer
+// It handles GET/DELETE methods sent from main application. Buyers cannot add or change items, they only take
+// the quantity they bought from a lot with POST to /api/v1/buyer/{sellername}/items/{id}/take
 // It generates all item information in response to request made, embedded in JSON format.
 // It also generates headers for each request, depending on the status of each operation.
 func buyer_edititems(w http.ResponseWriter, r *http.Request) {
@@ -230,6 +399,13 @@ func buyer_edititems(w http.ResponseWriter, r *http.Request) {
 	SN := params["sellername"]
 	IN := params["itemname"]
 
+	// Lots of sellers waiting for their account to be deleted are hidden
+	if sellerHidden(sdb, SN) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("404 - No items found"))
+		return
+	}
+
 	if r.Method == "GET" { // need not been checked if JSON or not
 		item, ok := GetARecordSeller(sdb, IN, SN)
 		if !ok {
@@ -245,57 +421,50 @@ func buyer_edititems(w http.ResponseWriter, r *http.Request) {
 		if !ok {
 			w.WriteHeader(http.StatusNotFound)
 			w.Write([]byte("404 - No item found"))
+		} else if !singleLot(w, IN, SN) {
+			return
+		} else if !DeleteRecordSeller(sdb, IN, SN) {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("500 - Unable to delete item"))
 		} else {
-			DeleteRecordSeller(sdb, IN, SN)
 			w.WriteHeader(http.StatusAccepted)
 			w.Write([]byte("202 - Item deleted: " + IN + " For seller: " + SN))
 		}
 	}
+}
 
-	if r.Header.Get("Content-type") == "application/json" {
-		// POST is for creating a new item
-		if r.Method == "POST" {
-			w.WriteHeader(http.StatusMethodNotAllowed)
-			w.Write([]byte("405 - Method Not Allowed"))
-		}
+// Function to generate events page /api/v1/events, containing the events about listings after ?after={id} embedded in JSON format.
+// Events are returned oldest first, at most maxEvents at a time.
+func events_all(w http.ResponseWriter, r *http.Request) {
+	if !validKey(w, r, sellerapikey) {
+		log.Println("Seller API key not valid")
+		return
+	}
 
-		//PUT is for creating or updating an existing item
-		if r.Method == "PUT" {
-			var sid ItemsDetails
-			reqBody, err := ioutil.ReadAll(r.Body)
-			if err == nil {
-				json.Unmarshal(reqBody, &sid)
-				if sid.Username == "" || sid.Username != SN || sid.Item == "" || sid.Cost < 0.0 || sid.Quantity < 0 {
-					w.WriteHeader(
-						http.StatusUnprocessableEntity)
-					w.Write([]byte(
-						"422 - Please supply item information in JSON format"))
-					return
-				}
-				// check if item exists; add only if item does not exist
-				_, ok := GetARecordSeller(sdb, IN, SN)
-				if !ok { // item does not exist in db
-					// Now check if new item does not exist either
-					_, ok := GetARecordSeller(sdb, sid.Item, sid.Username)
-					if ok { // exists, that means item for seller name does exist, but new item exists in db
-						w.WriteHeader(http.StatusUnprocessableEntity)
-						w.Write([]byte("422 - Please supply correct item information in JSON format"))
-						return
-					} else { // item does not exist at all, it cannot be added by buyer
-						w.WriteHeader(http.StatusMethodNotAllowed)
-						w.Write([]byte("405 - Method Not Allowed"))
-					}
-				} else {
-					// update the item if item exists
-					EditRecordSeller(sdb, IN, SN, sid)
-					w.WriteHeader(http.StatusAccepted)
-					w.Write([]byte("202 - Item updated: From " + IN + " To " + sid.Item + " For seller: " + SN))
-				}
-			} else {
-				w.WriteHeader(http.StatusUnprocessableEntity)
-				w.Write([]byte("422 - Please supply item information in JSON format"))
-			}
+	after, err := strconv.ParseInt(r.URL.Query().Get("after"), 10, 64)
+	if err != nil {
+		after = 0
+	}
+	events, ok := GetEvents(sdb, after, maxEvents)
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("500 - Unable to get events"))
+		return
+	}
+	json.NewEncoder(w).Encode(events)
+}
+
+// Function to remove expired listings from sale, it runs forever checking every expiryCheckTime.
+// An expired event is recorded for each listing, so the main application can notify the seller.
+func expireListings() {
+	for {
+		expired, ok := ExpireListings(sdb, time.Now())
+		if !ok {
+			log.Println("Unable to remove expired listings")
+		} else if len(expired) > 0 {
+			log.Printf("Removed %d expired listings\n", len(expired))
 		}
+		time.Sleep(expiryCheckTime)
 	}
 }
 
@@ -303,8 +472,9 @@ func buyer_edititems(w http.ResponseWriter, r *http.Request) {
 func main() {
 
 	// Open the mysql database seller_db created as a container in docker
+	// Times are read and written in UTC, and the session time zone is UTC so CURRENT_TIMESTAMP agrees with them
 	var err error
-	sdb, err = sql.Open("mysql", "root:password@tcp(localhost:33061)/sellerAPIdb")
+	sdb, err = sql.Open("mysql", "root:password@tcp(localhost:33061)/sellerAPIdb?parseTime=true&loc=UTC&time_zone=%27%2B00%3A00%27")
 	// panic if unable to open database
 	if err != nil {
 		log.Println("Unable to open database")
@@ -320,6 +490,10 @@ func main() {
 	// defer the database closing till after the main function has finished executing
 	defer sdb.Close()
 
+	// Remove expired listings from sale, and build the search index, in the background
+	go expireListings()
+	go refreshSearchIndex()
+
 	// Instantiate mux router for handling urls
 	router := mux.NewRouter()
 
@@ -328,11 +502,28 @@ func main() {
 
 	// Handle function for all router functions for seller
 	router.HandleFunc("/api/v1/seller/{sellername}", seller_allitems)                                                     // GET all items for a particular seller {sellername}
+	router.HandleFunc("/api/v1/seller/{sellername}/items", seller_lots).Methods("GET", "POST")                            // GET all lots, or POST a new lot for {sellername}
+	router.HandleFunc("/api/v1/seller/{sellername}/items/{id:[0-9]+}", seller_editlot).Methods("GET", "PUT", "DELETE")    // one lot by ID for {sellername}
+	router.HandleFunc("/api/v1/seller/{sellername}/items/{id:[0-9]+}/restock", seller_restocklot).Methods("POST")         // put a quantity back into one lot of {sellername}
+	router.HandleFunc("/api/v1/seller/{sellername}/profile", seller_profile).Methods("GET", "PUT", "DELETE")              // public profile of {sellername}, searched with listings
+	router.HandleFunc("/api/v1/seller/{sellername}/hidden", seller_hidden).Methods("PUT", "DELETE")                       // hide or show all lots of {sellername} to buyers
 	router.HandleFunc("/api/v1/seller/{sellername}/{itemname}", seller_edititems).Methods("GET", "PUT", "POST", "DELETE") // one specific item for a particular seller {sellername}
 
+	// Handle function for all router functions for catalogue
+	router.HandleFunc("/api/v1/catalogue", catalogue_all).Methods("GET")                                              // GET all peel types, for buyer, seller and admin
+	router.HandleFunc("/api/v1/catalogue/{peelname}", catalogue_editpeeltype).Methods("GET", "PUT", "POST", "DELETE") // one peel type, changes only by admin
+
+	// Handle function for events about listings, read by the main application
+	router.HandleFunc("/api/v1/events", events_all).Methods("GET") // GET events after ?after={id}
+
+	// Handle function for searching listings and sellers
+	router.HandleFunc("/api/v1/search", search_all).Methods("GET") // GET listings and sellers matching ?q=
+
 	// Handle function for all router functions for buyer
-	router.HandleFunc("/api/v1/buyer", buyer_allitems)                                                          // GET all items from all sellers
-	router.HandleFunc("/api/v1/buyer/{sellername}/{itemname}", buyer_edititems).Methods("GET", "PUT", "DELETE") // one specific item from {sellername}, no POST
+	router.HandleFunc("/api/v1/buyer", buyer_allitems)                                                        // GET all items from all sellers
+	router.HandleFunc("/api/v1/buyer/{sellername}/items/{id:[0-9]+}", buyer_editlot).Methods("GET", "DELETE") // one lot by ID from {sellername}
+	router.HandleFunc("/api/v1/buyer/{sellername}/items/{id:[0-9]+}/take", buyer_takelot).Methods("POST")     // take the quantity bought from one lot of {sellername}
+	router.HandleFunc("/api/v1/buyer/{sellername}/{itemname}", buyer_edititems).Methods("GET", "DELETE")      // one specific item from {sellername}, no POST or PUT
 
 	// Listen and Serve TLS, using self generated cert.pem and key.pem
 	fmt.Println("Listening at port 5000")
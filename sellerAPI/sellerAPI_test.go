@@ -0,0 +1,35 @@
+package main
+
+import "testing"
+
+func TestUnitCost(t *testing.T) {
+	lot := ItemsDetails{
+		Cost: Money{Amount: 200, Currency: "SGD"},
+		Tiers: []PriceTier{
+			{MinQuantity: 10, Cost: Money{Amount: 150, Currency: "SGD"}},
+			{MinQuantity: 25.5, Cost: Money{Amount: 120, Currency: "SGD"}},
+		},
+	}
+	tests := []struct {
+		name     string
+		quantity float64
+		want     int64
+	}{
+		{"below the first break", 9.5, 200},
+		{"at the first break", 10, 150},
+		{"between breaks", 25, 150},
+		{"at the last break", 25.5, 120},
+		{"rounding error at a break", 25.4999999, 120},
+		{"above the last break", 100, 120},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := unitCost(lot, tt.quantity); got != (Money{Amount: tt.want, Currency: "SGD"}) {
+				t.Errorf("unitCost(%v) = %v, want %d", tt.quantity, got, tt.want)
+			}
+		})
+	}
+	if got := unitCost(ItemsDetails{Cost: Money{Amount: 99, Currency: "MYR"}}, 1000); got != (Money{Amount: 99, Currency: "MYR"}) {
+		t.Errorf("unitCost without tiers = %v, want the cost of the lot", got)
+	}
+}
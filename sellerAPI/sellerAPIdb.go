@@ -4,15 +4,68 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
+	"strconv"
+	"strings"
+	"time"
 
 	_ "github.com/go-sql-driver/mysql"
 )
 
 type ItemsDetails struct {
+	ID       int64   `json:"ID"`
 	Item     string  `json:"Item"`
-	Quantity int     `json:"Quantity"`
-	Cost     float64 `json:"Cost"`
+	Quantity float64 `json:"Quantity"`
+	Cost     Money   `json:"Cost"`
 	Username string  `json:"Username"`
+	Photo    string  `json:"Photo"`
+
+	// Unit the quantity is measured in, and the smallest order and order step in that unit
+	Unit     string  `json:"Unit"`
+	MinOrder float64 `json:"MinOrder"`
+	Step     float64 `json:"Step"`
+
+	// Quantity price breaks, lowest quantity first
+	Tiers []PriceTier `json:"Tiers"`
+
+	// Date the peel was collected, and time after which it is no longer for sale
+	CollectedOn time.Time `json:"CollectedOn"`
+	ExpiresAt   time.Time `json:"ExpiresAt"`
+
+	// Quantity at or below which the seller is told the lot is running low, 0 for no warning
+	LowStock float64 `json:"LowStock"`
+
+	// Area the peel is picked up from, the location in the profile of the seller, and its distance in km from the buyer
+	// when the buyer gives their location, nil if the location of the seller is not known. Only filled in for buyers.
+	Pickup   string   `json:"Pickup,omitempty"`
+	Distance *float64 `json:"Distance,omitempty"`
+}
+
+// Columns of itemsdetails table in the order they are scanned into ItemsDetails
+const itemColumns = "ID, Item, Quantity, CostAmount, CostCurrency, Username, Photo, CollectedOn, ExpiresAt, Unit, MinOrder, Step, LowStock"
+
+// Format used for writing times into DATETIME columns
+const timeFormat = "2006-01-02 15:04:05"
+
+// Interface satisfied by both *sql.Rows and *sql.Row, used to scan one listing
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// Function to scan one listing selected with itemColumns.
+func scanItem(row scanner) (ItemsDetails, error) {
+	var si ItemsDetails
+	err := row.Scan(&si.ID, &si.Item, &si.Quantity, &si.Cost.Amount, &si.Cost.Currency, &si.Username, &si.Photo, &si.CollectedOn, &si.ExpiresAt, &si.Unit, &si.MinOrder, &si.Step, &si.LowStock)
+	return si, err
+}
+
+// Function to scan one listing selected for buyers with itemColumns, followed by Pickup and the Latitude and Longitude
+// of its seller, which are returned nil when not known.
+func scanListing(row scanner) (ItemsDetails, *float64, *float64, error) {
+	var si ItemsDetails
+	var lat, lng *float64
+	err := row.Scan(&si.ID, &si.Item, &si.Quantity, &si.Cost.Amount, &si.Cost.Currency, &si.Username, &si.Photo, &si.CollectedOn, &si.ExpiresAt, &si.Unit, &si.MinOrder, &si.Step, &si.LowStock,
+		&si.Pickup, &lat, &lng)
+	return si, lat, lng, err
 }
 
 //-----------------------------------------------------------------------
@@ -25,7 +78,7 @@ type ItemsDetails struct {
 // It returns false when there is any error encountered and retrieval of records is not successful.
 func GetRecordsSeller(db *sql.DB, SN string) ([]ItemsDetails, bool) {
 	var sd []ItemsDetails
-	query := fmt.Sprintf("SELECT * FROM sellerAPIdb.itemsdetails WHERE Username='%s';", SN)
+	query := fmt.Sprintf("SELECT "+itemColumns+" FROM sellerAPIdb.itemsdetails WHERE Username='%s' ORDER BY ID;", SN)
 	results, err := db.Query(query)
 	if err != nil {
 		log.Println("Not able to get seller details")
@@ -34,8 +87,7 @@ func GetRecordsSeller(db *sql.DB, SN string) ([]ItemsDetails, bool) {
 
 	for results.Next() {
 		// map this type to the record in the table
-		var si ItemsDetails
-		err = results.Scan(&si.Item, &si.Quantity, &si.Cost, &si.Username)
+		si, err := scanItem(results)
 		if err != nil {
 			log.Println("Unable to get records")
 			log.Println(err)
@@ -43,7 +95,7 @@ func GetRecordsSeller(db *sql.DB, SN string) ([]ItemsDetails, bool) {
 		}
 		sd = append(sd, si)
 	}
-	return sd, true
+	return sd, attachTiers(db, sd)
 }
 
 // Following functions need to be updated for sellerAPIdb
@@ -51,12 +103,13 @@ func GetRecordsSeller(db *sql.DB, SN string) ([]ItemsDetails, bool) {
 // Function to get one record from the MYSQL database.
 // The function takes in the handle to the database.
 // Its also takes in the name of the course to search for, of type string.
+// When the seller has several lots of the item, the oldest lot is returned.
 // It returns all the info of one course of type SellerDetails.
 // It returns true when retrieval of the record from the database is successful.
 // It returns false when there is any error encountered, and retrieval of record is not successful.
 func GetARecordSeller(db *sql.DB, IN string, SN string) (ItemsDetails, bool) {
 	var si ItemsDetails
-	query := fmt.Sprintf("SELECT * FROM sellerAPIdb.itemsdetails WHERE Username='%s' AND Item='%s';", SN, IN)
+	query := fmt.Sprintf("SELECT "+itemColumns+" FROM sellerAPIdb.itemsdetails WHERE Username='%s' AND Item='%s' ORDER BY ID LIMIT 1;", SN, IN)
 	results, err := db.Query(query)
 	if err != nil {
 		log.Println("Unable to find a record")
@@ -65,7 +118,7 @@ func GetARecordSeller(db *sql.DB, IN string, SN string) (ItemsDetails, bool) {
 	}
 	for results.Next() {
 		// map this type to the record in the table
-		err = results.Scan(&si.Item, &si.Quantity, &si.Cost, &si.Username)
+		si, err = scanItem(results)
 		if err != nil {
 			log.Println("Unable to get the record")
 			log.Println(err)
@@ -75,24 +128,31 @@ func GetARecordSeller(db *sql.DB, IN string, SN string) (ItemsDetails, bool) {
 	if si.Item == "" || si.Username == "" {
 		return si, false
 	}
-	return si, true
+	items := []ItemsDetails{si}
+	ok := attachTiers(db, items)
+	return items[0], ok
 }
 
 // Function to insert one record into the MYSQL database.
 // The function takes in the handle to the database.
 // Its also takes in the course to insert of type SellerDetails.
-// It returns true when the course is inserted into the database successfully.
+// It returns the ID given to the new lot, and true when the course is inserted into the database successfully.
 // It returns false when there is any error encountered, and course is not inserted successfully.
-func InsertRecordSeller(db *sql.DB, sid ItemsDetails) bool {
-	query := fmt.Sprintf("INSERT INTO `itemsdetails` (Item, Quantity, Cost, Username) VALUES ('%s',%d, %f,'%s');", sid.Item, sid.Quantity, sid.Cost, sid.Username)
-	_, err := db.Query(query)
+func InsertRecordSeller(db execer, sid ItemsDetails) (int64, bool) {
+	query := fmt.Sprintf("INSERT INTO `itemsdetails` (Item, Quantity, CostAmount, CostCurrency, Username, Photo, CollectedOn, ExpiresAt, Unit, MinOrder, Step, LowStock) VALUES ('%s',%f, %d,'%s','%s','%s','%s','%s','%s',%f,%f,%f);", sid.Item, sid.Quantity, sid.Cost.Amount, sid.Cost.Currency, sid.Username, sid.Photo, sid.CollectedOn.UTC().Format(timeFormat), sid.ExpiresAt.UTC().Format(timeFormat), sid.Unit, sid.MinOrder, sid.Step, sid.LowStock)
+	result, err := db.Exec(query)
 
 	if err != nil {
 		log.Println("Unable to insert the record")
 		log.Println(err)
-		return false
+		return 0, false
 	}
-	return true
+	id, err := result.LastInsertId()
+	if err != nil {
+		log.Println(err)
+		return 0, false
+	}
+	return id, true
 }
 
 // Function to update an existing record in the MYSQL database.
@@ -100,9 +160,9 @@ func InsertRecordSeller(db *sql.DB, sid ItemsDetails) bool {
 // It also takes in the name of the course to update of type string, and the new details of the course of type SellerDetails.
 // It returns true when the course is updated in the database successfully.
 // It returns false when there is any error encountered, and course is not updated successfully.
-func EditRecordSeller(db *sql.DB, IN string, SN string, sid ItemsDetails) bool {
-	query := fmt.Sprintf("UPDATE `itemsdetails` SET Item='%s', Quantity= %d, Cost=%f, Username='%s' WHERE Item='%s' AND Username='%s';", sid.Item, sid.Quantity, sid.Cost, sid.Username, IN, SN)
-	_, err := db.Query(query)
+func EditRecordSeller(db execer, IN string, SN string, sid ItemsDetails) bool {
+	query := fmt.Sprintf("UPDATE `itemsdetails` SET Item='%s', Quantity= %f, CostAmount=%d, CostCurrency='%s', Username='%s', Photo='%s', CollectedOn='%s', ExpiresAt='%s', Unit='%s', MinOrder=%f, Step=%f, LowStock=%f WHERE Item='%s' AND Username='%s';", sid.Item, sid.Quantity, sid.Cost.Amount, sid.Cost.Currency, sid.Username, sid.Photo, sid.CollectedOn.UTC().Format(timeFormat), sid.ExpiresAt.UTC().Format(timeFormat), sid.Unit, sid.MinOrder, sid.Step, sid.LowStock, IN, SN)
+	_, err := db.Exec(query)
 	if err != nil {
 		log.Println("Unable to edit the record")
 		log.Println(err)
@@ -118,7 +178,7 @@ func EditRecordSeller(db *sql.DB, IN string, SN string, sid ItemsDetails) bool {
 // It returns false when there is any error encountered, and course is not deleted successfully.
 func DeleteRecordSeller(db *sql.DB, IN string, SN string) bool {
 	query := fmt.Sprintf("DELETE FROM `itemsdetails` WHERE Item='%s' AND Username='%s'", IN, SN)
-	_, err := db.Query(query)
+	_, err := db.Exec(query)
 	if err != nil {
 		log.Println("Unable to delete the record")
 		log.Println(err)
@@ -127,33 +187,311 @@ func DeleteRecordSeller(db *sql.DB, IN string, SN string) bool {
 	return true
 }
 
+// Function to count the lots a seller has of one item in the MYSQL database.
+// It is used to refuse changes by item name when the name does not identify a single lot.
+// It returns false when there is any error encountered.
+func CountLotsSeller(db *sql.DB, IN string, SN string) (int, bool) {
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM itemsdetails WHERE Username=? AND Item=?", SN, IN).Scan(&count)
+	if err != nil {
+		log.Println("Unable to count lots")
+		log.Println(err)
+		return 0, false
+	}
+	return count, true
+}
+
+//-----------------------------------------------------------------------
+// Functions for lots, identified by ID
+//-----------------------------------------------------------------------
+// Function to get one lot of a seller from the MYSQL database.
+// The function takes in the handle to the database, the name of the seller and the ID of the lot.
+// It returns false when the lot does not exist, or there is any error encountered.
+func GetLotSeller(db *sql.DB, SN string, id int64) (ItemsDetails, bool) {
+	si, err := scanItem(db.QueryRow("SELECT "+itemColumns+" FROM itemsdetails WHERE Username=? AND ID=?", SN, id))
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Println("Unable to get the lot")
+			log.Println(err)
+		}
+		return si, false
+	}
+	items := []ItemsDetails{si}
+	ok := attachTiers(db, items)
+	return items[0], ok
+}
+
+// Function to update one lot of a seller in the MYSQL database.
+// The ID and seller of the lot cannot be changed.
+// It returns false when there is any error encountered, and the lot is not updated.
+func EditLotSeller(db execer, SN string, id int64, sid ItemsDetails) bool {
+	_, err := db.Exec("UPDATE itemsdetails SET Item=?, Quantity=?, CostAmount=?, CostCurrency=?, Photo=?, CollectedOn=?, ExpiresAt=?, Unit=?, MinOrder=?, Step=?, LowStock=? WHERE Username=? AND ID=?",
+		sid.Item, sid.Quantity, sid.Cost.Amount, sid.Cost.Currency, sid.Photo, sid.CollectedOn.UTC(), sid.ExpiresAt.UTC(), sid.Unit, sid.MinOrder, sid.Step, sid.LowStock, SN, id)
+	if err != nil {
+		log.Println("Unable to edit the lot")
+		log.Println(err)
+		return false
+	}
+	return true
+}
+
+// Function to delete one lot of a seller from the MYSQL database.
+// It returns false when there is any error encountered, and the lot is not deleted.
+func DeleteLotSeller(db *sql.DB, SN string, id int64) bool {
+	_, err := db.Exec("DELETE FROM itemsdetails WHERE Username=? AND ID=?", SN, id)
+	if err != nil {
+		log.Println("Unable to delete the lot")
+		log.Println(err)
+		return false
+	}
+	return true
+}
+
+// Function to take a quantity bought by a buyer from one lot in the MYSQL database.
+// The quantity is taken in a single update that checks enough is left, so two buyers cannot both buy the last of a lot,
+// nor buy from a lot that has expired before it is delisted, and a sold out or low stock event is recorded in the same transaction when the lot crosses its warning.
+// When cost is given, the quantity is only taken if it is still the price per unit of the quantity. The lot is locked
+// while its price is checked, so an edit of the seller made at the same time waits until the quantity is taken.
+// It returns the lot after the quantity is taken, false as second value if the lot does not exist, has expired,
+// has not enough quantity left or another price, and false as third value when there is any error encountered.
+// The lot is returned as it is when the quantity is not taken because of its price, to tell the buyer the new price.
+func TakeLotBuyer(db *sql.DB, SN string, id int64, quantity float64, cost *Money) (ItemsDetails, bool, bool) {
+	var after ItemsDetails
+	tx, err := db.Begin()
+	if err != nil {
+		log.Println(err)
+		return after, false, false
+	}
+	defer tx.Rollback()
+
+	lot, err := scanItem(tx.QueryRow("SELECT "+itemColumns+" FROM itemsdetails WHERE Username=? AND ID=? FOR UPDATE", SN, id))
+	if err == sql.ErrNoRows {
+		return after, false, true
+	}
+	lots := []ItemsDetails{lot}
+	if err != nil || !attachTiers(tx, lots) {
+		log.Println("Unable to read the lot to take the quantity from")
+		log.Println(err)
+		return after, false, false
+	}
+	lot = lots[0]
+	if cost != nil && unitCost(lot, quantity) != *cost {
+		return lot, false, true
+	}
+
+	res, err := tx.Exec("UPDATE itemsdetails SET Quantity = Quantity - ? WHERE Username=? AND ID=? AND Quantity >= ? AND ExpiresAt > ?",
+		quantity, SN, id, quantity, time.Now().UTC())
+	if err == nil {
+		var n int64
+		if n, err = res.RowsAffected(); err == nil && n == 0 {
+			return after, false, true
+		}
+	}
+	if err == nil {
+		after, err = scanItem(tx.QueryRow("SELECT "+itemColumns+" FROM itemsdetails WHERE Username=? AND ID=?", SN, id))
+	}
+	if err != nil {
+		log.Println("Unable to take the quantity from the lot")
+		log.Println(err)
+		return after, false, false
+	}
+
+	after.Tiers = lot.Tiers
+	before := after
+	before.Quantity += quantity
+	if kind := stockEvent(before, after); kind != "" && !InsertEvent(tx, kind, after) {
+		return after, false, false
+	}
+	if err := tx.Commit(); err != nil {
+		log.Println(err)
+		return after, false, false
+	}
+	return after, true, true
+}
+
+// Function to put a quantity back into one lot of a seller in the MYSQL database, e.g. from a cancelled order.
+// The quantity is added in a single update, so quantities taken by buyers at the same time are not lost,
+// and a restocked event is recorded in the same transaction when the lot had sold out.
+// It returns the lot after the quantity is added, false as second value if the lot does not exist,
+// and false as third value when there is any error encountered.
+func RestockLotSeller(db *sql.DB, SN string, id int64, quantity float64) (ItemsDetails, bool, bool) {
+	var after ItemsDetails
+	tx, err := db.Begin()
+	if err != nil {
+		log.Println(err)
+		return after, false, false
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec("UPDATE itemsdetails SET Quantity = Quantity + ? WHERE Username=? AND ID=?", quantity, SN, id)
+	if err == nil {
+		var n int64
+		if n, err = res.RowsAffected(); err == nil && n == 0 {
+			return after, false, true
+		}
+	}
+	if err == nil {
+		after, err = scanItem(tx.QueryRow("SELECT "+itemColumns+" FROM itemsdetails WHERE Username=? AND ID=?", SN, id))
+	}
+	if err != nil {
+		log.Println("Unable to put the quantity back into the lot")
+		log.Println(err)
+		return after, false, false
+	}
+
+	before := after
+	before.Quantity -= quantity
+	if kind := stockEvent(before, after); kind != "" && !InsertEvent(tx, kind, after) {
+		return after, false, false
+	}
+	if err := tx.Commit(); err != nil {
+		log.Println(err)
+		return after, false, false
+	}
+	return after, true, true
+}
+
 //-----------------------------------------------------------------------
 // Functions for buyer
 //-----------------------------------------------------------------------
-// Function to get all records from the MYSQL database.
-// The function takes in the handle to the database.
-// It returns all the info of all courses as an array of type SellerDetails.
-// It returns true when retrieval of records from the database is successful.
+// Function to get the ORDER BY clause of a sort order of listings, and the condition keeping the listings after a cursor.
+// Listings after the cursor come after its key in the sort order, or have the same key and a higher ID.
+// Sorts ByCurrency are by currency first, the listings after the cursor then have a later currency or the same one.
+// The condition is empty when there is no cursor.
+func sortClauses(s listingSort, cursor *listingCursor) (string, string, []interface{}) {
+	op, dir := ">", "ASC"
+	if s.Desc {
+		op, dir = "<", "DESC"
+	}
+	order := s.Column + " " + dir
+	if s.Column != "ID" {
+		order += ", ID ASC"
+	}
+	if s.ByCurrency {
+		order = "CostCurrency ASC, " + order
+	}
+	if cursor == nil {
+		return order, "", nil
+	}
+
+	if s.Column == "ID" {
+		return order, "ID " + op + " ?", []interface{}{cursor.ID}
+	}
+	after := "(" + s.Column + " " + op + " ? OR (" + s.Column + " = ? AND ID > ?))"
+	args := []interface{}{cursor.Key, cursor.Key, cursor.ID}
+	if s.ByCurrency {
+		after = "(CostCurrency > ? OR (CostCurrency = ? AND " + after + "))"
+		args = append([]interface{}{cursor.Currency, cursor.Currency}, args...)
+	}
+	return order, after, args
+}
+
+// Function to get the records matching the filters of a buyer from the MYSQL database, in the sort order asked for.
+// The function takes in the handle to the database, and the filters, sort order and page of type ListingQuery.
+// Sold out lots are kept for their seller to restock, but are not shown to buyers, nor are expired lots
+// or lots of hidden sellers.
+// Each listing has the area of its seller, and its distance from the buyer when the query has their location.
+// It returns one page of listings, with the cursor of the next page, empty on the last page.
 // It returns false when there is any error encountered and retrieval of records is not successful.
-func GetRecordsBuyer(db *sql.DB) ([]ItemsDetails, bool) {
+func GetRecordsBuyer(db *sql.DB, q ListingQuery) ([]ItemsDetails, string, bool) {
 	var sd []ItemsDetails
-	query := fmt.Sprintf("SELECT * FROM sellerAPIdb.itemsdetails;")
-	results, err := db.Query(query)
+	var args []interface{}
+
+	// Listings are joined with the profiles of their sellers, the location of a seller is NULL when not known
+	from := "(SELECT i.*, COALESCE(p.Location, '') AS Pickup, p.Latitude, p.Longitude" +
+		" FROM sellerAPIdb.itemsdetails i LEFT JOIN sellerAPIdb.sellerprofiles p ON p.Username = i.Username) AS l"
+
+	// Lots of sellers waiting for their account to be deleted are hidden, and so are expired lots
+	// until the background job delists them
+	where := []string{"Quantity > 0", "Username NOT IN (SELECT Username FROM sellerAPIdb.hiddensellers)", "ExpiresAt > ?"}
+	args = append(args, time.Now().UTC())
+	if q.Item != "" {
+		where = append(where, "Item = ?")
+		args = append(args, q.Item)
+	}
+	if q.Seller != "" {
+		where = append(where, "Username = ?")
+		args = append(args, q.Seller)
+	}
+	if q.MinPrice > 0 || q.MaxPrice > 0 {
+		where = append(where, "CostCurrency = ?")
+		args = append(args, q.Currency)
+	}
+	if q.MinPrice > 0 {
+		where = append(where, "CostAmount >= ?")
+		args = append(args, q.MinPrice)
+	}
+	if q.MaxPrice > 0 {
+		where = append(where, "CostAmount <= ?")
+		args = append(args, q.MaxPrice)
+	}
+	if q.MinQuantity > 0 {
+		where = append(where, "Quantity >= ?")
+		args = append(args, q.MinQuantity)
+	}
+	if q.MaxAge > 0 {
+		where = append(where, "CollectedOn >= ?")
+		args = append(args, time.Now().UTC().AddDate(0, 0, -q.MaxAge).Format(timeFormat))
+	}
+
+	// Distances are worked out once the listings are read, so the distance filter and sort are done then,
+	// and all the listings are read when there is one
+	s := listingSorts[q.Sort]
+	byDistance := s.Column == "DistanceKey"
+	order := "ID ASC"
+	if !byDistance {
+		var after string
+		var afterArgs []interface{}
+		order, after, afterArgs = sortClauses(s, q.After)
+		if after != "" {
+			where = append(where, after)
+			args = append(args, afterArgs...)
+		}
+	}
+
+	query := "SELECT " + itemColumns + ", Pickup, Latitude, Longitude FROM " + from + " WHERE " + strings.Join(where, " AND ") + " ORDER BY " + order
+	if q.Limit > 0 && !byDistance && q.MaxDistance == 0 {
+		// One more listing is read to know if there is a next page
+		query += " LIMIT " + strconv.Itoa(q.Limit+1)
+	}
+	results, err := db.Query(query, args...)
 	if err != nil {
 		log.Println("Not able to get seller details")
 		log.Println(err)
+		return sd, "", false
 	}
+	defer results.Close()
 
 	for results.Next() {
 		// map this type to the record in the table
-		var si ItemsDetails
-		err = results.Scan(&si.Item, &si.Quantity, &si.Cost, &si.Username)
+		si, lat, lng, err := scanListing(results)
 		if err != nil {
 			log.Println("Unable to get records")
 			log.Println(err)
-			return sd, false
+			return sd, "", false
+		}
+		if q.Latitude != nil && lat != nil && lng != nil {
+			d := distanceKm(*q.Latitude, *q.Longitude, *lat, *lng)
+			si.Distance = &d
+		}
+		if q.MaxDistance > 0 && (si.Distance == nil || *si.Distance > q.MaxDistance) {
+			continue
 		}
 		sd = append(sd, si)
 	}
-	return sd, true
+	if byDistance {
+		sd = sortByDistance(sd, q.After)
+	}
+
+	next := ""
+	if q.Limit > 0 && len(sd) > q.Limit {
+		sd = sd[:q.Limit]
+		last := sd[len(sd)-1]
+		c := listingCursor{Sort: q.Sort, Key: sortKey(s.Column, last), ID: last.ID}
+		if s.ByCurrency {
+			c.Currency = last.Cost.Currency
+		}
+		next = encodeCursor(c)
+	}
+	return sd, next, attachTiers(db, sd)
 }
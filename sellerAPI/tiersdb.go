@@ -0,0 +1,103 @@
+package main
+
+import (
+	"database/sql"
+	"log"
+	"strings"
+)
+
+// Data structure for a quantity price break of a lot
+// Orders of at least MinQuantity are charged Cost per unit, below the first break the lot's own cost applies
+type PriceTier struct {
+	MinQuantity float64 `json:"MinQuantity"`
+	Cost        Money   `json:"Cost"`
+}
+
+// Interface satisfied by a handle to the database or a transaction, to read rows with
+type querier interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+//-----------------------------------------------------------------------
+// Functions for price tiers
+//-----------------------------------------------------------------------
+// Function to read the price tiers of the given lots from the MYSQL database, or within a transaction.
+// The tiers of each lot are stored into its Tiers field, lowest quantity first.
+// It returns false when there is any error encountered and retrieval of tiers is not successful.
+func attachTiers(db querier, items []ItemsDetails) bool {
+	if len(items) == 0 {
+		return true
+	}
+	index := make(map[int64]int)
+	args := make([]interface{}, 0, len(items))
+	for i, item := range items {
+		index[item.ID] = i
+		args = append(args, item.ID)
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(items)), ",")
+
+	results, err := db.Query("SELECT ItemID, MinQuantity, CostAmount, CostCurrency FROM pricetiers WHERE ItemID IN ("+placeholders+") ORDER BY ItemID, MinQuantity", args...)
+	if err != nil {
+		log.Println("Not able to get price tiers")
+		log.Println(err)
+		return false
+	}
+	defer results.Close()
+
+	for results.Next() {
+		var id int64
+		var tier PriceTier
+		if err := results.Scan(&id, &tier.MinQuantity, &tier.Cost.Amount, &tier.Cost.Currency); err != nil {
+			log.Println("Unable to get price tiers")
+			log.Println(err)
+			return false
+		}
+		i := index[id]
+		items[i].Tiers = append(items[i].Tiers, tier)
+	}
+	return true
+}
+
+// Function to add or update a lot along with its price tiers in the MYSQL database, in one transaction.
+// The save function inserts or updates the lot with the transaction and returns the ID of the lot,
+// the tiers of that lot are then replaced, so a lot is never saved without its tiers.
+// Tiers are removed along with their lot by the foreign key, so this is only needed when adding or updating a lot.
+// It returns the ID of the lot, and false when there is any error encountered and nothing is saved.
+func SaveTiers(db *sql.DB, tiers []PriceTier, save func(tx execer) (int64, bool)) (int64, bool) {
+	tx, err := db.Begin()
+	if err != nil {
+		log.Println(err)
+		return 0, false
+	}
+	defer tx.Rollback()
+
+	id, ok := save(tx)
+	if !ok || !replaceTiers(tx, id, tiers) {
+		return 0, false
+	}
+	if err := tx.Commit(); err != nil {
+		log.Println(err)
+		return 0, false
+	}
+	return id, true
+}
+
+// Function to replace the price tiers of one lot in the MYSQL database.
+// It returns false when there is any error encountered, and the tiers are not saved.
+func replaceTiers(db execer, id int64, tiers []PriceTier) bool {
+	if _, err := db.Exec("DELETE FROM pricetiers WHERE ItemID=?", id); err != nil {
+		log.Println("Unable to remove price tiers")
+		log.Println(err)
+		return false
+	}
+	for _, tier := range tiers {
+		_, err := db.Exec("INSERT INTO pricetiers (ItemID, MinQuantity, CostAmount, CostCurrency) VALUES (?, ?, ?, ?)",
+			id, tier.MinQuantity, tier.Cost.Amount, tier.Cost.Currency)
+		if err != nil {
+			log.Println("Unable to insert price tier")
+			log.Println(err)
+			return false
+		}
+	}
+	return true
+}